@@ -0,0 +1,91 @@
+// Command gophkeeper-admin управляет сервером от имени администратора: ищет пользователей,
+// блокирует учетные записи, завершает сессии и запускает обслуживание базы. Использует
+// конфигурацию и локальную сессию клиента, поэтому сначала нужно войти через
+// 'gophkeeper auth login' под учетной записью с ролью администратора (см. grant-admin).
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/agent"
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+	"github.com/alisaviation/GophKeeper/internal/client/commands"
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+	"github.com/alisaviation/GophKeeper/internal/client/transport"
+	"github.com/alisaviation/GophKeeper/internal/config"
+)
+
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+	builtBy = "unknown"
+)
+
+func main() {
+
+	rootCmd := &cobra.Command{
+		Use:   "gophkeeper-admin",
+		Short: "GophKeeper server administration",
+		Version: fmt.Sprintf("%s (commit: %s, built: %s, by: %s, go: %s)",
+			version, commit, date, builtBy, runtime.Version()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cfg := config.SetClientConfig()
+	socketPath := agent.SocketPath(cfg.StoragePath)
+	fileCache := storage.NewFileKeyCache(cfg.StoragePath, cfg.AutoLockTimeout)
+
+	clientApp, err := initApp(cfg, socketPath, fileCache)
+	if err != nil {
+		fmt.Printf("Failed to initialize app: %v\n", err)
+		os.Exit(1)
+	}
+
+	rootCmd.AddCommand(
+		commands.NewAdminUsersCommand(clientApp),
+		commands.NewAdminMaintenanceCommand(clientApp),
+		commands.NewLockCommand(clientApp),
+		commands.NewUnlockCommand(clientApp),
+		commands.NewVersionCommand(version, commit, date),
+	)
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func initApp(cfg config.ClientConfig, socketPath string, fileCache storage.KeyCache) (*app.Client, error) {
+	// Запущенный агент держит ключ в памяти вместо файлового кеша
+	keyCache := fileCache
+	if agentClient := agent.NewClient(socketPath); agentClient.Running() {
+		keyCache = agentClient
+	}
+
+	localStorage, err := storage.NewFileStorage(cfg.StoragePath, keyCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init local storage: %w", err)
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled || cfg.TLS.CAFile != "" || cfg.TLS.CertFile != "" {
+		tlsConfig, err = transport.NewClientTLSConfig(cfg.TLS.CAFile, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init TLS: %w", err)
+		}
+	}
+
+	grpcClient, err := transport.NewGRPCClient(cfg.ServerAddress, version, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init gRPC client: %w", err)
+	}
+
+	return app.NewClient(localStorage, grpcClient), nil
+}
@@ -0,0 +1,41 @@
+// Command clear-lockout снимает блокировку входа, наложенную после неудачных попыток.
+//
+// Принимает те же флаги и переменные окружения, что и сервер, а также --login и (или)
+// --address: логин пользователя и IP-адрес клиента, для которых сбрасываются счетчики неудач.
+// Перезапуск сервера не требуется.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/alisaviation/GophKeeper/internal/config"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/storage"
+)
+
+func main() {
+	login := flag.String("login", "", "Login to unlock")
+	address := flag.String("address", "", "Client IP address to unlock")
+	cfg := config.SetServerConfig()
+
+	if *login == "" && *address == "" {
+		log.Fatal("Either --login or --address is required")
+	}
+
+	newStorage, err := storage.NewStorage(storage.Config{
+		Type:     storage.TypePostgreSQL,
+		Database: cfg.Database,
+	})
+	if err != nil {
+		log.Fatal("Failed to create storage:", err)
+	}
+	defer newStorage.Close()
+
+	if err := app.ClearLoginLockout(context.Background(), newStorage.LoginAttemptRepository(), *login, *address); err != nil {
+		log.Fatal("Failed to clear lockout:", err)
+	}
+
+	log.Println("Login lockout cleared")
+}
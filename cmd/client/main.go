@@ -1,12 +1,14 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
 	"runtime"
 
 	"github.com/spf13/cobra"
 
+	"github.com/alisaviation/GophKeeper/internal/client/agent"
 	"github.com/alisaviation/GophKeeper/internal/client/app"
 	"github.com/alisaviation/GophKeeper/internal/client/commands"
 	"github.com/alisaviation/GophKeeper/internal/client/storage"
@@ -32,7 +34,11 @@ func main() {
 			cmd.Help()
 		},
 	}
-	clientApp, err := initApp()
+	cfg := config.SetClientConfig()
+	socketPath := agent.SocketPath(cfg.StoragePath)
+	fileCache := storage.NewFileKeyCache(cfg.StoragePath, cfg.AutoLockTimeout)
+
+	clientApp, err := initApp(cfg, socketPath, fileCache)
 	if err != nil {
 		fmt.Printf("Failed to initialize app: %v\n", err)
 		os.Exit(1)
@@ -42,6 +48,13 @@ func main() {
 		commands.NewAuthCommand(clientApp),
 		commands.NewSyncCommand(clientApp),
 		commands.NewSecretsCommand(clientApp),
+		commands.NewVaultCommand(clientApp),
+		commands.NewAccountCommand(clientApp),
+		commands.NewDevicesCommand(clientApp),
+		commands.NewTokensCommand(clientApp),
+		commands.NewLockCommand(clientApp),
+		commands.NewUnlockCommand(clientApp),
+		commands.NewAgentCommand(socketPath, fileCache, cfg.AutoLockTimeout),
 		commands.NewVersionCommand(version, commit, date),
 	)
 
@@ -51,19 +64,35 @@ func main() {
 	}
 }
 
-func initApp() (*app.Client, error) {
-	cfg := config.SetClientConfig()
-	localStorage, err := storage.NewFileStorage(cfg.StoragePath)
+func initApp(cfg config.ClientConfig, socketPath string, fileCache storage.KeyCache) (*app.Client, error) {
+	// Ключ держит в памяти агент: разблокировка запускает его, если он еще не работает,
+	// а файловый кеш используется, только если агента запустить не удалось
+	keyCache := agent.NewAutoStartCache(socketPath, fileCache,
+		"agent", "--foreground", "--ttl", cfg.AutoLockTimeout.String())
+
+	localStorage, err := storage.NewFileStorage(cfg.StoragePath, keyCache)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init local storage: %w", err)
 	}
 
-	grpcClient, err := transport.NewGRPCClient(cfg.ServerAddress)
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled || cfg.TLS.CAFile != "" || cfg.TLS.CertFile != "" {
+		tlsConfig, err = transport.NewClientTLSConfig(cfg.TLS.CAFile, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init TLS: %w", err)
+		}
+	}
+
+	grpcClient, err := transport.NewGRPCClient(cfg.ServerAddress, version, tlsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init gRPC client: %w", err)
 	}
 
-	clientApp := app.NewClient(localStorage, grpcClient)
+	opts := []app.ClientOption{app.WithPasswordPrompt(commands.ReadPassword)}
+	if cfg.RejectLegacyCiphertexts {
+		opts = append(opts, app.WithoutLegacyCiphertexts())
+	}
+	clientApp := app.NewClient(localStorage, grpcClient, opts...)
 
 	return clientApp, nil
 }
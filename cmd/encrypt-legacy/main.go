@@ -0,0 +1,60 @@
+// Command encrypt-legacy шифрует ключом сервера записи, сохраненные до включения шифрования
+// на сервере: поля секретов и секреты TOTP.
+//
+// Принимает те же флаги и переменные окружения, что и сервер; ключ шифрования обязателен.
+// Записи каждого пользователя шифруются в отдельной транзакции, поэтому останавливать сервер
+// не нужно, а повторный запуск после сбоя безопасен. После успешного запуска сервер можно
+// запускать с --reject-unencrypted-secrets.
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/alisaviation/GophKeeper/internal/config"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/storage"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/encrypted"
+)
+
+func main() {
+	cfg := config.SetServerConfig()
+	ctx := context.Background()
+
+	keyProvider, err := crypto.NewKeyProvider(crypto.KeySource{
+		Raw:     cfg.Encryption.Key,
+		File:    cfg.Encryption.KeyFile,
+		Env:     cfg.Encryption.KeyEnv,
+		Command: cfg.Encryption.KeyCommand,
+	})
+	if err != nil {
+		log.Fatal("Invalid encryption key configuration:", err)
+	}
+	if keyProvider == nil {
+		log.Fatal("Encryption key is required")
+	}
+	masterKey, err := crypto.LoadKey(ctx, keyProvider)
+	if err != nil {
+		log.Fatal("Failed to load encryption key:", err)
+	}
+	master, err := crypto.NewAESGCMEncryptor(masterKey)
+	if err != nil {
+		log.Fatal("Invalid encryption key:", err)
+	}
+
+	newStorage, err := storage.NewStorage(storage.Config{
+		Type:     storage.TypePostgreSQL,
+		Database: cfg.Database,
+	})
+	if err != nil {
+		log.Fatal("Failed to create storage:", err)
+	}
+	defer newStorage.Close()
+
+	count, err := encrypted.EncryptLegacyRecords(ctx, newStorage, master)
+	if err != nil {
+		log.Fatalf("Failed to encrypt records (%d encrypted before the error): %v", count, err)
+	}
+
+	log.Printf("Encrypted %d records", count)
+}
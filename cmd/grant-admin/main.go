@@ -0,0 +1,46 @@
+// Command grant-admin назначает пользователю роль администратора или снимает ее.
+//
+// Принимает те же флаги и переменные окружения, что и сервер, а также --login: логин
+// пользователя, которому выдаются права. С флагом --revoke роль администратора снимается.
+// Администратор управляет сервером через gophkeeper-admin; перезапуск сервера не требуется.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/alisaviation/GophKeeper/internal/config"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage"
+)
+
+func main() {
+	login := flag.String("login", "", "Login of the user")
+	revoke := flag.Bool("revoke", false, "Revoke the admin role instead of granting it")
+	cfg := config.SetServerConfig()
+
+	if *login == "" {
+		log.Fatal("--login is required")
+	}
+
+	newStorage, err := storage.NewStorage(storage.Config{
+		Type:     storage.TypePostgreSQL,
+		Database: cfg.Database,
+	})
+	if err != nil {
+		log.Fatal("Failed to create storage:", err)
+	}
+	defer newStorage.Close()
+
+	role := domain.RoleAdmin
+	if *revoke {
+		role = domain.RoleUser
+	}
+	if err := app.SetUserRole(context.Background(), newStorage.UserRepository(), *login, role); err != nil {
+		log.Fatal("Failed to set user role:", err)
+	}
+
+	log.Printf("User %s now has role %s", *login, role)
+}
@@ -0,0 +1,95 @@
+// Command rewrap-keys перешифровывает ключи данных пользователей новым мастер-ключом сервера.
+//
+// Принимает те же флаги и переменные окружения, что и сервер; новым ключом считается ключ
+// из --encryption-key, --encryption-key-file, --encryption-key-env или --encryption-key-command,
+// прежним — --old-encryption-key (OLD_ENCRYPTION_KEY) или --old-encryption-key-file
+// (OLD_ENCRYPTION_KEY_FILE). Все ключи перешифровываются в одной транзакции.
+// После успешного запуска сервер нужно перезапустить с новым ключом.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/alisaviation/GophKeeper/internal/config"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/storage"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/encrypted"
+)
+
+func main() {
+	oldKey := flag.String("old-encryption-key", "", "Previous encryption key")
+	oldKeyFile := flag.String("old-encryption-key-file", "", "Path to a file with the previous hex or base64 encoded encryption key")
+	cfg := config.SetServerConfig()
+	if envOldKey, exists := os.LookupEnv("OLD_ENCRYPTION_KEY"); exists {
+		*oldKey = envOldKey
+	}
+	if envOldKeyFile, exists := os.LookupEnv("OLD_ENCRYPTION_KEY_FILE"); exists {
+		*oldKeyFile = envOldKeyFile
+	}
+
+	ctx := context.Background()
+
+	oldProvider, err := crypto.NewKeyProvider(crypto.KeySource{Raw: *oldKey, File: *oldKeyFile})
+	if err != nil {
+		log.Fatal("Invalid old encryption key configuration:", err)
+	}
+	newProvider, err := crypto.NewKeyProvider(crypto.KeySource{
+		Raw:     cfg.Encryption.Key,
+		File:    cfg.Encryption.KeyFile,
+		Env:     cfg.Encryption.KeyEnv,
+		Command: cfg.Encryption.KeyCommand,
+	})
+	if err != nil {
+		log.Fatal("Invalid new encryption key configuration:", err)
+	}
+	if oldProvider == nil || newProvider == nil {
+		log.Fatal("Both the old and the new encryption keys are required")
+	}
+
+	// Прежний ключ мог быть задан до появления проверки стойкости, поэтому проверяется только длина
+	oldKeyBytes, err := oldProvider.Key(ctx)
+	if err != nil {
+		log.Fatal("Failed to load old encryption key:", err)
+	}
+	oldMaster, err := crypto.NewAESGCMEncryptor(oldKeyBytes)
+	if err != nil {
+		log.Fatal("Invalid old encryption key:", err)
+	}
+	newKeyBytes, err := crypto.LoadKey(ctx, newProvider)
+	if err != nil {
+		log.Fatal("Failed to load new encryption key:", err)
+	}
+	newMaster, err := crypto.NewAESGCMEncryptor(newKeyBytes)
+	if err != nil {
+		log.Fatal("Invalid new encryption key:", err)
+	}
+
+	newStorage, err := storage.NewStorage(storage.Config{
+		Type:     storage.TypePostgreSQL,
+		Database: cfg.Database,
+	})
+	if err != nil {
+		log.Fatal("Failed to create storage:", err)
+	}
+	defer newStorage.Close()
+
+	tx, err := newStorage.TransactionManager().BeginTx(ctx)
+	if err != nil {
+		log.Fatal("Failed to begin transaction:", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	count, err := encrypted.RewrapDataKeys(ctx, tx.DataKeyRepository(), oldMaster, newMaster)
+	if err != nil {
+		log.Fatal("Failed to rewrap data keys:", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Fatal("Failed to commit:", err)
+	}
+
+	log.Printf("Rewrapped %d data keys", count)
+}
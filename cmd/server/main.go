@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"log"
 	"runtime"
 
@@ -8,7 +11,9 @@ import (
 	"github.com/alisaviation/GophKeeper/internal/crypto"
 	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/storage"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/encrypted"
 	"github.com/alisaviation/GophKeeper/internal/server/transport"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
 )
 
 var (
@@ -34,30 +39,177 @@ func main() {
 	}
 	defer newStorage.Close()
 
-	jwtManager := crypto.NewJWTManager(&crypto.JWTConfig{
+	jwtConfig := &crypto.JWTConfig{
 		Secret:        cfg.JWT.Secret,
 		AccessExpiry:  cfg.JWT.AccessExpiry,
 		RefreshExpiry: cfg.JWT.RefreshExpiry,
-	})
+	}
+	if cfg.JWT.SigningKeyFile != "" {
+		jwtConfig.SigningKey, err = crypto.LoadJWTSigningKey(cfg.JWT.SigningKeyFile)
+		if err != nil {
+			log.Fatal("Failed to load JWT signing key:", err)
+		}
+		log.Printf("Signing tokens with JWT key %s", jwtConfig.SigningKey.ID)
+	}
+	for _, path := range cfg.JWT.VerificationKeyFiles {
+		verificationKey, err := crypto.LoadJWTVerificationKey(path)
+		if err != nil {
+			log.Fatal("Failed to load JWT verification key:", err)
+		}
+		jwtConfig.VerificationKeys = append(jwtConfig.VerificationKeys, verificationKey)
+	}
+	switch {
+	case jwtConfig.SigningKey == nil && jwtConfig.Secret != "":
+		log.Println("Warning: tokens are signed with the legacy HS256 secret, configure --jwt-signing-key")
+	case jwtConfig.SigningKey == nil:
+		log.Println("Warning: JWT signing key is not set, using a temporary key; tokens will be invalid after restart")
+	}
+	jwtManager := crypto.NewJWTManager(jwtConfig)
 
-	var encryptor crypto.Encryptor
-	if cfg.Encryption.Key != "" {
-		encryptor, err = crypto.NewAESGCMEncryptor([]byte(cfg.Encryption.Key))
+	keyProvider, err := crypto.NewKeyProvider(crypto.KeySource{
+		Raw:     cfg.Encryption.Key,
+		File:    cfg.Encryption.KeyFile,
+		Env:     cfg.Encryption.KeyEnv,
+		Command: cfg.Encryption.KeyCommand,
+	})
+	if err != nil {
+		log.Fatal("Invalid encryption key configuration:", err)
+	}
+	var srpFakeSeed []byte
+	if keyProvider != nil {
+		masterKey, err := crypto.LoadKey(context.Background(), keyProvider)
+		if err != nil {
+			log.Fatal("Failed to load encryption key:", err)
+		}
+		// Ключ шифрования переживает перезапуск, поэтому ложные ответы SRP тоже не меняются
+		mac := hmac.New(sha256.New, masterKey)
+		mac.Write([]byte("gophkeeper srp fake credentials"))
+		srpFakeSeed = mac.Sum(nil)
+		masterEncryptor, err := crypto.NewAESGCMEncryptor(masterKey)
 		if err != nil {
 			log.Fatal("Failed to create encryptor:", err)
 		}
+		var encryptionOptions []encrypted.Option
+		if cfg.Encryption.RejectUnencrypted {
+			encryptionOptions = append(encryptionOptions, encrypted.WithRejectUnencrypted())
+		}
+		newStorage = encrypted.NewStorage(newStorage, masterEncryptor, encryptionOptions...)
 	} else {
-		encryptor = &crypto.NoopEncryptor{}
+		if cfg.Encryption.RejectUnencrypted {
+			log.Fatal("Rejecting unencrypted secrets requires an encryption key")
+		}
+		log.Println("Warning: encryption key is not set, secrets are stored without server-side encryption")
 	}
 
-	authService := app.NewAuthService(newStorage.UserRepository(), jwtManager)
-	dataService := app.NewDataService(newStorage.SecretRepository(), encryptor)
+	hashParams := crypto.DefaultArgon2idParams()
+	hashParams.Iterations = cfg.PasswordHash.Iterations
+	hashParams.Memory = cfg.PasswordHash.Memory
+	hashParams.Parallelism = cfg.PasswordHash.Parallelism
+	passwordHasher, err := crypto.NewArgon2idHasher(hashParams)
+	if err != nil {
+		log.Fatal("Invalid password hash configuration:", err)
+	}
+
+	lockoutPolicy := app.LockoutPolicy{
+		MaxFailures:        cfg.LoginLockout.MaxFailures,
+		MaxAddressFailures: cfg.LoginLockout.MaxAddressFailures,
+		BaseDelay:          cfg.LoginLockout.BaseDelay,
+		MaxDelay:           cfg.LoginLockout.MaxDelay,
+		FailureWindow:      cfg.LoginLockout.FailureWindow,
+	}
+	kdfParams := crypto.KDFParams{
+		Algorithm:   crypto.KDFAlgorithmArgon2id,
+		Iterations:  cfg.KDF.Iterations,
+		Memory:      cfg.KDF.Memory,
+		Parallelism: cfg.KDF.Parallelism,
+	}
+	// Клиент откажется формировать ключ с параметрами, которые не проходят эту проверку.
+	// Соль выдается каждому пользователю при регистрации, поэтому проверяется пробная.
+	kdfCheck := kdfParams
+	kdfCheck.Salt = make([]byte, crypto.SaltSize)
+	if err := kdfCheck.Validate(); err != nil {
+		log.Fatal("Invalid KDF configuration:", err)
+	}
+
+	authOptions := []app.AuthServiceOption{
+		app.WithPasswordHasher(passwordHasher),
+		app.WithKDFParams(kdfParams),
+		app.WithVaultKeys(newStorage.VaultKeyRepository()),
+		app.WithTransactionManager(newStorage.TransactionManager()),
+		app.WithRefreshTokens(newStorage.RefreshTokenRepository()),
+		app.WithSessions(newStorage.SessionRepository()),
+		app.WithTOTP(newStorage.TOTPRepository()),
+		app.WithDevices(newStorage.DeviceRepository(), cfg.RequireDeviceApproval),
+		app.WithAccessTokens(newStorage.AccessTokenRepository()),
+		app.WithLoginLockout(newStorage.LoginAttemptRepository(), lockoutPolicy),
+		app.WithLegacyPasswordAuth(cfg.LegacyPasswordAuth),
+	}
+	if keyProvider == nil {
+		log.Println("Warning: two-factor authentication cannot be enabled without the encryption key")
+		log.Println("Warning: SRP responses for unknown logins change after restart without the encryption key")
+		authOptions = append(authOptions, app.WithoutTOTPEnrollment())
+	} else {
+		authOptions = append(authOptions, app.WithSRPFakeSeed(srpFakeSeed))
+	}
+	authService := app.NewAuthService(newStorage.UserRepository(), jwtManager, authOptions...)
+	dataService := app.NewDataService(newStorage.SecretRepository(),
+		app.WithAtomicSync(newStorage.TransactionManager()),
+		app.WithManifests(newStorage.ManifestRepository()),
+	)
+	adminService := app.NewAdminService(newStorage, lockoutPolicy)
 
 	grpcConfig := transport.Config{
 		Port: cfg.GRPCPort,
+		RateLimit: middleware.RateLimiterConfig{
+			Default: middleware.RateLimit(cfg.RateLimit.Default),
+			Methods: make(map[string]middleware.RateLimit, len(cfg.RateLimit.Methods)),
+		},
+		AddressRateLimit: middleware.RateLimit(cfg.RateLimit.Address),
+	}
+	for method, limit := range cfg.RateLimit.Methods {
+		grpcConfig.RateLimit.Methods[method] = middleware.RateLimit(limit)
+	}
+
+	grpcConfig.AuthMode, err = middleware.ParseAuthMode(cfg.AuthMode)
+	if err != nil {
+		log.Fatal("Invalid auth mode:", err)
+	}
+	if cfg.TLS.CertFile != "" {
+		tlsConfig := transport.TLSConfig{
+			CertFile: cfg.TLS.CertFile,
+			KeyFile:  cfg.TLS.KeyFile,
+		}
+		if cfg.TLS.ClientCAFile != "" {
+			tlsConfig.ClientCAs, err = crypto.LoadCACertificates(cfg.TLS.ClientCAFile)
+			if err != nil {
+				log.Fatal("Failed to load client CA certificates:", err)
+			}
+		}
+		if cfg.TLS.CRLFile != "" {
+			tlsConfig.Revocations, err = crypto.LoadRevocationList(cfg.TLS.CRLFile, tlsConfig.ClientCAs)
+			if err != nil {
+				log.Fatal("Failed to load CRL:", err)
+			}
+			if cfg.TLS.CRLReloadInterval > 0 {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go tlsConfig.Revocations.Watch(ctx, cfg.TLS.CRLReloadInterval, func(err error) {
+					log.Printf("Warning: failed to reload CRL, keeping the previous one: %v", err)
+				})
+			}
+			grpcConfig.Revocations = tlsConfig.Revocations
+		}
+		grpcConfig.TLS, err = transport.NewTLSConfig(tlsConfig, grpcConfig.AuthMode)
+		if err != nil {
+			log.Fatal("Invalid TLS configuration:", err)
+		}
+	} else if grpcConfig.AuthMode.AcceptsClientCert() {
+		log.Fatalf("Auth mode %s requires TLS, configure --tls-cert", grpcConfig.AuthMode)
+	} else {
+		log.Println("Warning: TLS is not configured, connections are not encrypted")
 	}
 
-	grpcServer := transport.NewServer(authService, dataService, grpcConfig)
+	grpcServer := transport.NewServer(authService, dataService, adminService, grpcConfig)
 
 	log.Printf("Starting gRPC server on port %d", grpcConfig.Port)
 	if err = grpcServer.Start(); err != nil {
@@ -0,0 +1,219 @@
+// Package agent реализует фоновый процесс, который держит ключ разблокированного
+// локального хранилища в памяти и выдает его клиенту через unix-сокет, подобно ssh-agent.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+)
+
+// Операции протокола агента
+const (
+	opLoad   = "load"
+	opSave   = "save"
+	opClear  = "clear"
+	opStatus = "status"
+	opStop   = "stop"
+)
+
+// request запрос к агенту; одно соединение — один запрос
+type request struct {
+	Op  string `json:"op"`
+	Key []byte `json:"key,omitempty"`
+}
+
+// response ответ агента
+type response struct {
+	Key       []byte `json:"key,omitempty"`
+	Unlocked  bool   `json:"unlocked"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	PID       int    `json:"pid"`
+	Error     string `json:"error,omitempty"`
+}
+
+// connTimeout ограничивает время обработки одного запроса
+const connTimeout = 5 * time.Second
+
+// SocketPath возвращает путь к сокету агента для хранилища basePath
+func SocketPath(basePath string) string {
+	return storage.RuntimePath(basePath, ".sock")
+}
+
+// Agent держит ключ хранилища в памяти, пока простой не превысит ttl: каждое получение
+// ключа продлевает срок, а по истечении простоя таймер стирает ключ без участия клиента
+type Agent struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	key       []byte
+	expiresAt time.Time
+	timer     *time.Timer
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// New создает агента. Нулевой ttl хранит ключ до явной блокировки или остановки.
+func New(ttl time.Duration) *Agent {
+	return &Agent{
+		ttl:  ttl,
+		stop: make(chan struct{}),
+	}
+}
+
+// Listen создает сокет агента, доступный только владельцу. Сокет, оставшийся
+// от аварийно завершенного агента, удаляется; если агент уже работает, возвращается ошибка.
+func Listen(socketPath string) (net.Listener, error) {
+	if err := storage.EnsurePrivateDir(filepath.Dir(socketPath)); err != nil {
+		return nil, err
+	}
+
+	if NewClient(socketPath).Running() {
+		return nil, errors.New("agent is already running")
+	}
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := listenPrivate(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+
+	return listener, nil
+}
+
+// Serve обслуживает запросы до отмены ctx или команды остановки.
+// При выходе ключ стирается, а сокет закрывается.
+func (a *Agent) Serve(ctx context.Context, listener net.Listener) error {
+	defer a.clear()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-a.stop:
+		}
+		_ = listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-a.stop:
+				return nil
+			default:
+				return fmt.Errorf("failed to accept connection: %w", err)
+			}
+		}
+		go a.handle(conn)
+	}
+}
+
+// handle обрабатывает один запрос
+func (a *Agent) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(connTimeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	resp := a.process(req)
+	resp.PID = os.Getpid()
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// process выполняет операцию протокола
+func (a *Agent) process(req request) response {
+	switch req.Op {
+	case opLoad:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.touch()
+		// Копия: ответ кодируется уже после снятия блокировки, когда таймер мог стереть ключ
+		return response{Key: append([]byte(nil), a.key...), Unlocked: a.key != nil, ExpiresAt: a.expiresUnix()}
+	case opSave:
+		if len(req.Key) == 0 {
+			return response{Error: "key is required"}
+		}
+		a.save(req.Key)
+		return a.status()
+	case opClear:
+		a.clear()
+		return a.status()
+	case opStatus:
+		return a.status()
+	case opStop:
+		a.stopOnce.Do(func() { close(a.stop) })
+		return a.status()
+	default:
+		return response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+	}
+}
+
+// save запоминает ключ и запускает таймер его удаления
+func (a *Agent) save(key []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.wipe()
+	a.key = append([]byte(nil), key...)
+	if a.ttl > 0 {
+		a.timer = time.AfterFunc(a.ttl, a.clear)
+	}
+	a.touch()
+}
+
+// touch откладывает автоматическую блокировку на ttl от текущего момента; вызывается под мьютексом
+func (a *Agent) touch() {
+	if a.key == nil || a.timer == nil {
+		return
+	}
+	a.expiresAt = time.Now().Add(a.ttl)
+	a.timer.Reset(a.ttl)
+}
+
+// clear стирает ключ из памяти
+func (a *Agent) clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.wipe()
+}
+
+// wipe затирает ключ; вызывается под мьютексом
+func (a *Agent) wipe() {
+	for i := range a.key {
+		a.key[i] = 0
+	}
+	a.key = nil
+	a.expiresAt = time.Time{}
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+func (a *Agent) status() response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return response{Unlocked: a.key != nil, ExpiresAt: a.expiresUnix()}
+}
+
+func (a *Agent) expiresUnix() int64 {
+	if a.expiresAt.IsZero() {
+		return 0
+	}
+	return a.expiresAt.Unix()
+}
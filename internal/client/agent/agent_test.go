@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+)
+
+func startAgent(t *testing.T, ttl time.Duration) (*Client, string, <-chan error) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "run", "agent.sock")
+	listener, err := Listen(socketPath)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- New(ttl).Serve(ctx, listener) }()
+
+	return NewClient(socketPath), socketPath, done
+}
+
+func TestAgent(t *testing.T) {
+	client, socketPath, done := startAgent(t, time.Hour)
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.True(t, client.Running())
+	assert.Nil(t, client.Load())
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	require.NoError(t, client.Save(key))
+	assert.Equal(t, key, client.Load())
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	assert.True(t, status.Unlocked)
+	assert.Equal(t, os.Getpid(), status.PID)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), status.ExpiresAt, time.Minute)
+
+	_, err = Listen(socketPath)
+	assert.Error(t, err, "second agent must not take over a live socket")
+
+	require.NoError(t, client.Clear())
+	assert.Nil(t, client.Load())
+
+	require.NoError(t, client.Stop())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("agent did not stop")
+	}
+	assert.False(t, client.Running())
+	assert.Nil(t, client.Load())
+}
+
+func TestAgent_TTL(t *testing.T) {
+	client, _, _ := startAgent(t, 200*time.Millisecond)
+
+	require.NoError(t, client.Save([]byte("key")))
+
+	// Использование ключа откладывает блокировку
+	for i := 0; i < 5; i++ {
+		time.Sleep(100 * time.Millisecond)
+		require.NotNil(t, client.Load())
+	}
+
+	// Опрос состояния ключ не использует, поэтому блокировка наступает сама
+	assert.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && !status.Unlocked
+	}, time.Second, 20*time.Millisecond)
+	assert.True(t, client.Running(), "agent keeps running after the key expires")
+}
+
+func TestAutoStartCache(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	client, socketPath, _ := startAgent(t, time.Hour)
+
+	fallback := storage.NewFileKeyCache(t.TempDir(), time.Hour)
+	require.NoError(t, fallback.Save([]byte("stale key")))
+
+	cache := NewAutoStartCache(socketPath, fallback)
+	key := []byte("0123456789abcdef0123456789abcdef")
+	require.NoError(t, cache.Save(key))
+	assert.Equal(t, key, client.Load())
+	assert.Nil(t, fallback.Load(), "key must not stay on disk once the agent holds it")
+	assert.Equal(t, key, cache.Load())
+
+	require.NoError(t, cache.Clear())
+	assert.Nil(t, client.Load())
+	assert.Nil(t, cache.Load())
+}
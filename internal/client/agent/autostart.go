@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+)
+
+// AutoStartCache кеш ключа, который при разблокировке запускает агента, если он еще не
+// работает, и держит ключ только в нем. Запасной кеш fallback используется, лишь когда
+// агента запустить не удалось.
+type AutoStartCache struct {
+	client   *Client
+	fallback storage.KeyCache
+	args     []string
+}
+
+var _ storage.KeyCache = (*AutoStartCache)(nil)
+
+// NewAutoStartCache создает кеш для агента на сокете socketPath. args — аргументы,
+// с которыми исполняемый файл клиента запускает агента на переднем плане.
+func NewAutoStartCache(socketPath string, fallback storage.KeyCache, args ...string) *AutoStartCache {
+	return &AutoStartCache{
+		client:   NewClient(socketPath),
+		fallback: fallback,
+		args:     args,
+	}
+}
+
+// Load возвращает ключ из агента, а если агент не запущен, из запасного кеша
+func (c *AutoStartCache) Load() []byte {
+	if c.client.Running() {
+		return c.client.Load()
+	}
+	return c.fallback.Load()
+}
+
+// Save передает ключ агенту, при необходимости запуская его, и стирает запасной кеш
+func (c *AutoStartCache) Save(key []byte) error {
+	if !c.client.Running() {
+		if _, err := Spawn(c.client.socketPath, c.args...); err != nil {
+			if fallbackErr := c.fallback.Save(key); fallbackErr != nil {
+				return fmt.Errorf("%w (%v)", fallbackErr, err)
+			}
+			return nil
+		}
+	}
+
+	if err := c.client.Save(key); err != nil {
+		return err
+	}
+	return c.fallback.Clear()
+}
+
+// Clear стирает ключ и в агенте, и в запасном кеше
+func (c *AutoStartCache) Clear() error {
+	if c.client.Running() {
+		if err := c.client.Clear(); err != nil {
+			return err
+		}
+	}
+	return c.fallback.Clear()
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+)
+
+// Client обращается к агенту через unix-сокет и может использоваться
+// хранилищем вместо файлового кеша ключа
+type Client struct {
+	socketPath string
+}
+
+var _ storage.KeyCache = (*Client)(nil)
+
+// Status состояние агента
+type Status struct {
+	PID       int
+	Unlocked  bool
+	ExpiresAt time.Time // нулевое значение, если срок не ограничен
+}
+
+// NewClient создает клиента агента
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// Running сообщает, что агент запущен и отвечает
+func (c *Client) Running() bool {
+	_, err := c.call(request{Op: opStatus})
+	return err == nil
+}
+
+// Load возвращает ключ хранилища или nil, если агент не запущен или ключа у него нет
+func (c *Client) Load() []byte {
+	resp, err := c.call(request{Op: opLoad})
+	if err != nil {
+		return nil
+	}
+	return resp.Key
+}
+
+// Save передает агенту ключ разблокированного хранилища
+func (c *Client) Save(key []byte) error {
+	_, err := c.call(request{Op: opSave, Key: key})
+	return err
+}
+
+// Clear стирает ключ в агенте, блокируя хранилище
+func (c *Client) Clear() error {
+	_, err := c.call(request{Op: opClear})
+	return err
+}
+
+// Status возвращает состояние агента
+func (c *Client) Status() (*Status, error) {
+	resp, err := c.call(request{Op: opStatus})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{PID: resp.PID, Unlocked: resp.Unlocked}
+	if resp.ExpiresAt != 0 {
+		status.ExpiresAt = time.Unix(resp.ExpiresAt, 0)
+	}
+	return status, nil
+}
+
+// Stop стирает ключ и завершает агента
+func (c *Client) Stop() error {
+	_, err := c.call(request{Op: opStop})
+	return err
+}
+
+// call отправляет запрос и читает ответ
+func (c *Client) call(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.socketPath, connTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("agent is not running: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(connTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to agent: %w", err)
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read agent response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package agent
+
+import "syscall"
+
+// detachedProcAttr отвязывает агента от терминала, чтобы он пережил завершение сеанса
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package agent
+
+import "syscall"
+
+// detachedProcAttr на Windows дополнительных атрибутов не требует
+func detachedProcAttr() *syscall.SysProcAttr {
+	return nil
+}
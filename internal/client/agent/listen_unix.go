@@ -0,0 +1,16 @@
+//go:build !windows
+
+package agent
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenPrivate создает unix-сокет с правами 0600: umask действует уже при создании файла,
+// поэтому у других пользователей нет окна, в котором сокет был бы им доступен
+func listenPrivate(socketPath string) (net.Listener, error) {
+	previous := syscall.Umask(0o177)
+	defer syscall.Umask(previous)
+	return net.Listen("unix", socketPath)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package agent
+
+import "net"
+
+// listenPrivate на Windows доступ к сокету ограничивают права каталога хранилища
+func listenPrivate(socketPath string) (net.Listener, error) {
+	return net.Listen("unix", socketPath)
+}
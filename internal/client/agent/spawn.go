@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// spawnTimeout время ожидания готовности запущенного агента
+const spawnTimeout = 5 * time.Second
+
+// Spawn запускает агента в фоне повторным вызовом текущего исполняемого файла
+// с аргументами args и ждет, пока он начнет отвечать на socketPath
+func Spawn(socketPath string, args ...string) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start agent: %w", err)
+	}
+	pid := cmd.Process.Pid
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	client := NewClient(socketPath)
+	deadline := time.After(spawnTimeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited unexpectedly")
+			}
+			return 0, fmt.Errorf("agent failed to start: %w", err)
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			return 0, errors.New("agent did not start in time")
+		case <-ticker.C:
+			if client.Running() {
+				return pid, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// accessTokenPrefix начало токенов доступа, выпущенных сервером
+const accessTokenPrefix = "gkp_"
+
+// ErrInvalidAccessToken строка не похожа на токен доступа, выпущенный командой 'tokens create'
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// AccessTokenScope область нового токена доступа
+type AccessTokenScope struct {
+	SecretIDs []string
+	// Tags метки секретов; сервер их не видит, поэтому они заменяются ID секретов
+	// с этими метками в локальном хранилище на момент создания токена
+	Tags  []string
+	Write bool
+}
+
+// AccessTokenInfo отображаемый токен доступа
+type AccessTokenInfo struct {
+	ID         string
+	Name       string
+	SecretIDs  []string
+	Tags       []string
+	Write      bool
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// CreateAccessToken выпускает токен доступа к секретам из scope и возвращает строку токена.
+// Строка состоит из токена, выданного сервером, и ключа, которым зашифрован делегированный
+// ключ хранилища; ключ серверу не передается. Нулевой ttl — бессрочный токен.
+func (c *Client) CreateAccessToken(ctx context.Context, name string, scope AccessTokenScope, ttl time.Duration) (string, error) {
+	session, err := c.ensureAuthenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(session.EncryptionKey) == 0 {
+		return "", fmt.Errorf("vault key is not available, please log in again")
+	}
+
+	secretIDs, err := c.resolveTokenScope(scope)
+	if err != nil {
+		return "", err
+	}
+
+	tokenKey, err := crypto.GenerateKey(crypto.VaultKeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token key: %w", err)
+	}
+	delegatedKey, err := crypto.WrapVaultKey(tokenKey, session.EncryptionKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap vault key: %w", err)
+	}
+
+	permission := pb.AccessTokenPermission_ACCESS_TOKEN_READ
+	if scope.Write {
+		permission = pb.AccessTokenPermission_ACCESS_TOKEN_READ_WRITE
+	}
+	req := &pb.CreateAccessTokenRequest{
+		Name: name,
+		Scope: &pb.AccessTokenScope{
+			SecretIds:  secretIDs,
+			Tags:       scope.Tags,
+			Permission: permission,
+		},
+		DelegatedKey: delegatedKey,
+	}
+	if ttl > 0 {
+		req.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+
+	resp, err := c.transport.CreateAccessToken(ctx, req)
+	if deviceNotApproved(err) {
+		return "", fmt.Errorf("failed to create access token: %w", ErrDeviceNotApproved)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	// Токен не обновляет опись хранилища, поэтому его изменения отмечаются в ней заранее
+	if scope.Write {
+		if err := c.markTokenWritable(ctx, session, secretIDs); err != nil {
+			fmt.Printf("Warning: failed to record access token in vault manifest: %v\n", err)
+		}
+	}
+
+	return resp.GetToken() + "." + base64.RawURLEncoding.EncodeToString(tokenKey), nil
+}
+
+// ListAccessTokens возвращает токены доступа пользователя
+func (c *Client) ListAccessTokens(ctx context.Context) ([]*AccessTokenInfo, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	tokens, err := c.transport.ListAccessTokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+
+	result := make([]*AccessTokenInfo, 0, len(tokens))
+	for _, t := range tokens {
+		info := &AccessTokenInfo{
+			ID:        t.GetId(),
+			Name:      t.GetName(),
+			SecretIDs: t.GetScope().GetSecretIds(),
+			Tags:      t.GetScope().GetTags(),
+			Write:     t.GetScope().GetPermission() == pb.AccessTokenPermission_ACCESS_TOKEN_READ_WRITE,
+			CreatedAt: time.Unix(t.GetCreatedAt(), 0),
+		}
+		if t.GetExpiresAt() != 0 {
+			expiresAt := time.Unix(t.GetExpiresAt(), 0)
+			info.ExpiresAt = &expiresAt
+		}
+		if t.GetLastUsedAt() != 0 {
+			lastUsedAt := time.Unix(t.GetLastUsedAt(), 0)
+			info.LastUsedAt = &lastUsedAt
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// RevokeAccessToken отзывает токен доступа
+func (c *Client) RevokeAccessToken(ctx context.Context, tokenID string) error {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if err := c.transport.RevokeAccessToken(ctx, tokenID); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+// FetchWithAccessToken загружает и расшифровывает секреты, доступные токену доступа.
+// Мастер-пароль и локальное хранилище не нужны: ключ хранилища расшифровывается ключом из токена.
+func (c *Client) FetchWithAccessToken(ctx context.Context, token string) ([]*domain.SecretData, error) {
+	serverToken, tokenKey, err := splitAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	c.transport.SetToken(serverToken)
+
+	delegatedKey, err := c.transport.GetProtectedVaultKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delegated key: %w", err)
+	}
+	vaultKey, err := crypto.UnwrapVaultKey(tokenKey, delegatedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap delegated key: %w", err)
+	}
+
+	pbSecrets, err := c.transport.ListSecrets(ctx, "", pb.SecretType_SECRET_TYPE_UNSPECIFIED)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	secrets := make([]*domain.SecretData, 0, len(pbSecrets))
+	for _, pbSecret := range pbSecrets {
+		if pbSecret.IsDeleted {
+			continue
+		}
+		secret, err := decryptSecretWithKey(pbSecret, vaultKey, c.encryptorOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", pbSecret.Id, err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// resolveTokenScope возвращает ID секретов области: заданные явно и найденные по меткам
+func (c *Client) resolveTokenScope(scope AccessTokenScope) ([]string, error) {
+	secretIDs := append([]string(nil), scope.SecretIDs...)
+	if len(scope.Tags) == 0 {
+		return secretIDs, nil
+	}
+
+	secrets, err := c.storage.GetSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local secrets: %w", err)
+	}
+
+	for _, tag := range scope.Tags {
+		found := false
+		for _, secret := range secrets {
+			if secret.IsDeleted || secret.Metadata == nil || !slices.Contains(secret.Metadata.Tags, tag) {
+				continue
+			}
+			found = true
+			if !slices.Contains(secretIDs, secret.ID) {
+				secretIDs = append(secretIDs, secret.ID)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no local secrets are tagged %q, run 'gophkeeper sync' first", tag)
+		}
+	}
+	return secretIDs, nil
+}
+
+// splitAccessToken разделяет строку токена на часть для сервера и ключ делегированного ключа
+func splitAccessToken(token string) (string, []byte, error) {
+	token = strings.TrimSpace(token)
+	i := strings.LastIndex(token, ".")
+	if !strings.HasPrefix(token, accessTokenPrefix) || i < 0 {
+		return "", nil, ErrInvalidAccessToken
+	}
+
+	tokenKey, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || len(tokenKey) != crypto.VaultKeySize {
+		return "", nil, ErrInvalidAccessToken
+	}
+	return token[:i], tokenKey, nil
+}
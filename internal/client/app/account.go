@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// DeleteAccount безвозвратно удаляет учетную запись со всеми данными на сервере и завершает
+// локальную сессию. Текущий пароль подтверждается обменом SRP, а если пользователь еще не
+// перешел на SRP — самим паролем. Если включена 2FA, а код не передан, возвращает ErrTOTPRequired.
+func (c *Client) DeleteAccount(ctx context.Context, password, totpCode string) error {
+	session, err := c.ensureAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := c.confirmPassword(ctx, session, password)
+	if err != nil {
+		return fmt.Errorf("account deletion failed: %w", err)
+	}
+
+	req := &pb.DeleteAccountRequest{
+		Password:       confirmation.password,
+		SrpHandshakeId: confirmation.handshakeID,
+		SrpProof:       confirmation.proof,
+		TotpCode:       totpCode,
+	}
+	err = c.transport.DeleteAccount(ctx, req)
+	if srpRejected(err) {
+		legacyReq := &pb.DeleteAccountRequest{Password: password, TotpCode: totpCode}
+		if legacyErr := c.transport.DeleteAccount(ctx, legacyReq); !srpUnavailable(legacyErr) {
+			err = legacyErr
+		}
+	}
+	if err != nil {
+		if totpRequired(err) {
+			return ErrTOTPRequired
+		}
+		if status.Code(err) == codes.Unauthenticated {
+			return fmt.Errorf("account deletion failed: invalid password or two-factor code")
+		}
+		return fmt.Errorf("account deletion failed: %w", err)
+	}
+
+	if err := c.storage.DeleteSession(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	c.transport.SetToken("")
+	return nil
+}
+
+// passwordConfirmation подтверждение текущего пароля в запросах, меняющих учетную запись:
+// обмен SRP (handshakeID, proof) или сам пароль для пользователей, еще не перешедших на SRP
+type passwordConfirmation struct {
+	password    string
+	handshakeID string
+	proof       []byte
+}
+
+// confirmPassword начинает обмен SRP и вычисляет доказательство знания пароля. Если сервер не
+// поддерживает SRP или пользователь еще не перешел на него, подтверждением служит сам пароль.
+func (c *Client) confirmPassword(ctx context.Context, session *domain.Session, password string) (*passwordConfirmation, error) {
+	if session.KDF == nil {
+		return nil, fmt.Errorf("kdf params are unknown, please log in again")
+	}
+
+	masterKey, err := deriveMasterKey(password, session.KDF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+
+	srpClient, err := crypto.NewSRPClient(session.Login)
+	if err != nil {
+		return nil, err
+	}
+	start, err := c.transport.SRPStart(ctx, session.Login, srpClient.PublicEphemeral())
+	switch {
+	case err == nil:
+		proof, err := srpProof(srpClient, masterKey, start)
+		if err != nil {
+			return nil, err
+		}
+		return &passwordConfirmation{handshakeID: start.GetHandshakeId(), proof: proof}, nil
+	case srpUnavailable(err):
+		return &passwordConfirmation{password: password}, nil
+	default:
+		return nil, err
+	}
+}
+
+// ExportAccount выгружает все, что сервер хранит об учетной записи, в w: по одной записи
+// в формате JSON на строку. Секреты и ключ хранилища остаются зашифрованными.
+// Возвращает число выгруженных записей.
+func (c *Client) ExportAccount(ctx context.Context, w io.Writer) (int, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := c.transport.ExportAccount(ctx, func(record *pb.AccountRecord) error {
+		line, err := protojson.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("account export failed: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// MaintenanceJobs задачи обслуживания, которые можно запустить командой 'maintenance run'
+var MaintenanceJobs = map[string]pb.MaintenanceJob{
+	"purge-sessions":       pb.MaintenanceJob_MAINTENANCE_PURGE_SESSIONS,
+	"purge-login-attempts": pb.MaintenanceJob_MAINTENANCE_PURGE_LOGIN_ATTEMPTS,
+	"purge-access-tokens":  pb.MaintenanceJob_MAINTENANCE_PURGE_ACCESS_TOKENS,
+}
+
+// AdminUserInfo пользователь сервера, каким его видит администратор
+type AdminUserInfo struct {
+	ID         string
+	Login      string
+	Admin      bool
+	CreatedAt  time.Time
+	DisabledAt *time.Time
+}
+
+// AdminUserStats сводка по учетной записи пользователя. Содержимое секретов администратору недоступно.
+type AdminUserStats struct {
+	User           *AdminUserInfo
+	Secrets        int64
+	DeletedSecrets int64
+	StorageBytes   int64
+	ActiveSessions int
+	Devices        int
+	AccessTokens   int
+	TOTPEnabled    bool
+}
+
+// ListUsers возвращает страницу пользователей, логин которых содержит query
+func (c *Client) ListUsers(ctx context.Context, query string, limit, offset int) ([]*AdminUserInfo, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	users, err := c.transport.ListUsers(ctx, query, int32(limit), int32(offset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	result := make([]*AdminUserInfo, 0, len(users))
+	for _, u := range users {
+		result = append(result, adminUserFromProto(u))
+	}
+	return result, nil
+}
+
+// GetUserStats возвращает сводку по учетной записи пользователя login
+func (c *Client) GetUserStats(ctx context.Context, login string) (*AdminUserStats, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.transport.GetUserStats(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user stats: %w", err)
+	}
+
+	stats := resp.GetStats()
+	return &AdminUserStats{
+		User:           adminUserFromProto(resp.GetUser()),
+		Secrets:        stats.GetSecrets(),
+		DeletedSecrets: stats.GetDeletedSecrets(),
+		StorageBytes:   stats.GetStorageBytes(),
+		ActiveSessions: int(stats.GetActiveSessions()),
+		Devices:        int(stats.GetDevices()),
+		AccessTokens:   int(stats.GetAccessTokens()),
+		TOTPEnabled:    stats.GetTotpEnabled(),
+	}, nil
+}
+
+// SetUserDisabled блокирует или разблокирует учетную запись пользователя login
+func (c *Client) SetUserDisabled(ctx context.Context, login string, disabled bool) (*AdminUserInfo, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := c.transport.SetUserDisabled(ctx, login, disabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return adminUserFromProto(user), nil
+}
+
+// ForceLogout завершает все сессии пользователя login, а если revokeAccessTokens, удаляет и его токены доступа
+func (c *Client) ForceLogout(ctx context.Context, login string, revokeAccessTokens bool) error {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if err := c.transport.ForceLogout(ctx, login, revokeAccessTokens); err != nil {
+		return fmt.Errorf("failed to force logout: %w", err)
+	}
+	return nil
+}
+
+// ClearLoginLockout снимает блокировку входа под логином login и (или) с адреса address
+func (c *Client) ClearLoginLockout(ctx context.Context, login, address string) error {
+	if login == "" && address == "" {
+		return fmt.Errorf("login or address is required")
+	}
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if err := c.transport.ClearLoginLockout(ctx, login, address); err != nil {
+		return fmt.Errorf("failed to clear login lockout: %w", err)
+	}
+	return nil
+}
+
+// RunMaintenance запускает задачу обслуживания job из MaintenanceJobs и возвращает число удаленных записей
+func (c *Client) RunMaintenance(ctx context.Context, job string) (int64, error) {
+	pbJob, ok := MaintenanceJobs[job]
+	if !ok {
+		return 0, fmt.Errorf("unknown maintenance job %q", job)
+	}
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return 0, err
+	}
+
+	removed, err := c.transport.RunMaintenance(ctx, pbJob)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run maintenance: %w", err)
+	}
+	return removed, nil
+}
+
+func adminUserFromProto(u *pb.AdminUser) *AdminUserInfo {
+	info := &AdminUserInfo{
+		ID:        u.GetId(),
+		Login:     u.GetLogin(),
+		Admin:     u.GetAdmin(),
+		CreatedAt: time.Unix(u.GetCreatedAt(), 0),
+	}
+	if u.GetDisabledAt() != 0 {
+		disabledAt := time.Unix(u.GetDisabledAt(), 0)
+		info.DisabledAt = &disabledAt
+	}
+	return info
+}
@@ -1,11 +1,17 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
 	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 )
 
@@ -13,14 +19,46 @@ import (
 type Client struct {
 	storage   Storage
 	transport Transport
+	// encryptorOpts параметры расшифровки секретов, полученных с сервера
+	encryptorOpts []crypto.EncryptorOption
+	// passwordPrompt запрашивает у пользователя прежний пароль локального хранилища
+	passwordPrompt func(prompt string) string
+}
+
+// ErrPreviousPasswordRequired локальное хранилище защищено прежним мастер-паролем, а ввести его
+// не удалось. Хранилище не пересоздается, чтобы не потерять несинхронизированные секреты.
+var ErrPreviousPasswordRequired = errors.New("local vault is protected by a previous master password: " +
+	"log in again and enter it to keep unsynced changes, or move the local storage directory away to start over")
+
+// ClientOption настраивает необязательные параметры Client
+type ClientOption func(*Client)
+
+// WithoutLegacyCiphertexts запрещает расшифровку секретов в старом формате без заголовка
+// конверта: такие записи отклоняются, как подмененные
+func WithoutLegacyCiphertexts() ClientOption {
+	return func(c *Client) {
+		c.encryptorOpts = append(c.encryptorOpts, crypto.WithoutLegacyCiphertexts())
+	}
+}
+
+// WithPasswordPrompt задает запрос прежнего пароля, если локальное хранилище защищено паролем,
+// который сменили на другом устройстве
+func WithPasswordPrompt(prompt func(prompt string) string) ClientOption {
+	return func(c *Client) {
+		c.passwordPrompt = prompt
+	}
 }
 
 // NewClient создает новый клиент
-func NewClient(storage Storage, transport Transport) *Client {
-	return &Client{
+func NewClient(storage Storage, transport Transport, opts ...ClientOption) *Client {
+	c := &Client{
 		storage:   storage,
 		transport: transport,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // SyncResult результат синхронизации
@@ -28,6 +66,10 @@ type SyncResult struct {
 	Uploaded   int
 	Downloaded int
 	Conflicts  []string
+	Rejected   []string
+	// Warnings расхождения с описью хранилища: сервер скрыл секреты, вернул их прежние версии
+	// или изменил содержимое
+	Warnings []string
 }
 
 // SecretDisplay отображаемый секрет
@@ -35,28 +77,52 @@ type SecretDisplay struct {
 	ID        string
 	Name      string
 	Type      string
+	Metadata  *domain.SecretMetadata
 	Data      interface{}
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-// Register регистрирует нового пользователя
+// SessionInfo отображаемая активная сессия
+type SessionInfo struct {
+	ID            string
+	DeviceName    string
+	ClientVersion string
+	IPAddress     string
+	CreatedAt     time.Time
+	LastUsedAt    time.Time
+	Current       bool
+}
+
+// Register регистрирует нового пользователя. Пароль на сервер не передается (SRP),
+// если только сервер не поддерживает SRP.
 func (c *Client) Register(ctx context.Context, login, password string) (string, error) {
-	userID, err := c.transport.Register(ctx, login, password)
+	userID, kdf, err := c.registerSRP(ctx, login, password)
+	if status.Code(err) == codes.Unimplemented {
+		var resp *pb.RegisterResponse
+		resp, err = c.transport.Register(ctx, login, password)
+		userID, kdf = resp.GetUserId(), nil
+	}
 	if err != nil {
 		return "", fmt.Errorf("registration failed: %w", err)
 	}
 
-	encryptionKey, err := generateEncryptionKey()
+	if err := c.openLocalVault(password); err != nil {
+		return "", err
+	}
+
+	// Ключ хранилища случайный; на сервер он попадет в защищенном виде при первом входе
+	vaultKey, err := crypto.GenerateVaultKey()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate encryption key: %w", err)
+		return "", fmt.Errorf("failed to generate vault key: %w", err)
 	}
 
 	session := &domain.Session{
 		UserID:        userID,
 		Login:         login,
 		LastSync:      time.Now().Unix(),
-		EncryptionKey: encryptionKey,
+		EncryptionKey: vaultKey,
+		KDF:           kdf,
 	}
 
 	if err := c.storage.SaveSession(session); err != nil {
@@ -66,27 +132,76 @@ func (c *Client) Register(ctx context.Context, login, password string) (string,
 	return userID, nil
 }
 
-// Login выполняет аутентификацию
-func (c *Client) Login(ctx context.Context, login, password string) error {
-	accessToken, refreshToken, userID, err := c.transport.Login(ctx, login, password)
+// Login выполняет аутентификацию и получает ключ хранилища. Сначала используется SRP;
+// если обмен отклонен (пользователь мог еще не перейти на SRP) или сервер его не поддерживает,
+// вход выполняется по паролю, после чего на сервер отправляется верификатор SRP. Если у пользователя включена 2FA, а totpCode пуст,
+// возвращается ErrTOTPRequired: вход нужно повторить с кодом.
+func (c *Client) Login(ctx context.Context, login, password, totpCode string) error {
+	result, err := c.loginSRP(ctx, login, password, totpCode)
+	switch {
+	case srpUnavailable(err):
+		enroll := status.Code(err) == codes.FailedPrecondition
+		result, err = c.loginPassword(ctx, login, password, totpCode)
+		if result != nil {
+			result.enrollSRP = enroll
+		}
+	case srpRejected(err):
+		// Если вход по паролю на сервере запрещен, пароль неверен и остается ошибка SRP
+		legacy, legacyErr := c.loginPassword(ctx, login, password, totpCode)
+		if !srpUnavailable(legacyErr) {
+			result, err = legacy, legacyErr
+			if result != nil {
+				result.enrollSRP = true
+			}
+		}
+	}
+	if totpRequired(err) {
+		return ErrTOTPRequired
+	}
+	if delay, ok := retryDelay(err); ok && status.Code(err) == codes.ResourceExhausted {
+		return fmt.Errorf("login failed: too many failed attempts, try again in %s", delay)
+	}
 	if err != nil {
 		return fmt.Errorf("login failed: %w", err)
 	}
+	accessToken, refreshToken, userID := result.accessToken, result.refreshToken, result.userID
+	kdf, masterKey := result.kdf, result.masterKey
+
+	c.transport.SetToken(accessToken)
+
+	if result.enrollSRP {
+		c.enrollSRP(ctx, login, password, masterKey)
+	}
+
+	if err := c.openLocalVault(password); err != nil {
+		return err
+	}
+
+	accessToken, refreshToken = c.registerDevice(ctx, accessToken, refreshToken)
 
 	session, err := c.storage.GetSession()
-	if err != nil || session == nil {
-		encryptionKey, err := generateEncryptionKey()
-		if err != nil {
-			return fmt.Errorf("failed to generate encryption key: %w", err)
+	if err != nil || session == nil || session.UserID != userID {
+		session = &domain.Session{
+			UserID: userID,
+			Login:  login,
 		}
+	}
 
-		session = &domain.Session{
-			UserID:        userID,
-			Login:         login,
-			EncryptionKey: encryptionKey,
+	vaultKey, err := c.resolveVaultKey(ctx, masterKey, session.EncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	if len(session.EncryptionKey) > 0 && !bytes.Equal(session.EncryptionKey, vaultKey) {
+		// Локальные секреты были зашифрованы другим ключом:
+		// их нужно перешифровать ключом хранилища при следующей синхронизации
+		if err := c.markSecretsDirty(); err != nil {
+			return fmt.Errorf("failed to schedule re-encryption: %w", err)
 		}
 	}
 
+	session.EncryptionKey = vaultKey
+	session.KDF = kdf
 	session.AccessToken = accessToken
 	session.RefreshToken = refreshToken
 	session.LastSync = time.Now().Unix()
@@ -95,11 +210,231 @@ func (c *Client) Login(ctx context.Context, login, password string) error {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
 
-	c.transport.SetToken(accessToken)
+	return nil
+}
+
+// ChangePassword меняет мастер-пароль. Ключ хранилища не меняется:
+// он перешифровывается ключом из нового пароля, поэтому данные остаются доступны.
+func (c *Client) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	session, err := c.ensureAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	if session.KDF == nil {
+		return fmt.Errorf("kdf params are unknown, please log in again")
+	}
+
+	oldMasterKey, err := deriveMasterKey(oldPassword, session.KDF)
+	if err != nil {
+		return fmt.Errorf("failed to derive master key: %w", err)
+	}
+
+	vaultKey, err := c.resolveVaultKey(ctx, oldMasterKey, session.EncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	newMasterKey, err := deriveMasterKey(newPassword, session.KDF)
+	if err != nil {
+		return fmt.Errorf("failed to derive new master key: %w", err)
+	}
+
+	protectedKey, err := crypto.WrapVaultKey(newMasterKey, vaultKey)
+	if err != nil {
+		return fmt.Errorf("failed to protect vault key: %w", err)
+	}
+
+	newVerifier, err := newSRPVerifier(session.Login, newMasterKey)
+	if err != nil {
+		return err
+	}
+
+	req := &pb.ChangePasswordRequest{
+		ProtectedVaultKey: protectedKey,
+		NewSrp:            newVerifier,
+	}
+
+	// Знание текущего пароля подтверждается обменом SRP, а если пользователь
+	// еще не перешел на SRP — самим паролем
+	srpClient, err := crypto.NewSRPClient(session.Login)
+	if err != nil {
+		return err
+	}
+	start, err := c.transport.SRPStart(ctx, session.Login, srpClient.PublicEphemeral())
+	switch {
+	case err == nil:
+		proof, err := srpProof(srpClient, oldMasterKey, start)
+		if err != nil {
+			return err
+		}
+		req.SrpHandshakeId = start.GetHandshakeId()
+		req.SrpProof = proof
+	case srpUnavailable(err):
+		req.OldPassword = oldPassword
+		req.NewPassword = newPassword
+		if status.Code(err) == codes.Unimplemented {
+			req.NewSrp = nil
+		}
+	default:
+		return fmt.Errorf("password change failed: %w", err)
+	}
+
+	resp, err := c.transport.ChangePassword(ctx, req)
+	if srpRejected(err) {
+		legacyReq := &pb.ChangePasswordRequest{
+			OldPassword:       oldPassword,
+			NewPassword:       newPassword,
+			ProtectedVaultKey: req.ProtectedVaultKey,
+			NewSrp:            req.NewSrp,
+		}
+		if legacyResp, legacyErr := c.transport.ChangePassword(ctx, legacyReq); !srpUnavailable(legacyErr) {
+			req, resp, err = legacyReq, legacyResp, legacyErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("password change failed: %w", err)
+	}
+
+	if req.SrpHandshakeId != "" {
+		if err := srpClient.VerifyServerProof(resp.GetServerProof()); err != nil {
+			return fmt.Errorf("server failed to prove knowledge of the password verifier: %w", err)
+		}
+	}
+
+	session.EncryptionKey = vaultKey
+	session.AccessToken = resp.GetAccessToken()
+	session.RefreshToken = resp.GetRefreshToken()
+
+	if err := c.storage.SaveSession(session); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	if err := c.storage.ChangeVaultPassword(newPassword); err != nil {
+		return fmt.Errorf("failed to re-protect local vault: %w", err)
+	}
+
+	c.transport.SetToken(session.AccessToken)
 
 	return nil
 }
 
+// KeyRotationResult итог ротации ключа хранилища
+type KeyRotationResult struct {
+	// Secrets число перешифрованных секретов
+	Secrets int
+	// RevokedAccessTokens число токенов доступа, которые сервер отозвал вместе со сменой ключа
+	RevokedAccessTokens int
+}
+
+// RotateVaultKey заменяет ключ хранилища новым случайным ключом: все секреты на сервере
+// перешифровываются и отправляются одной атомарной синхронизацией вместе с новым защищенным ключом.
+// Если хотя бы один секрет не удалось обработать, ничего не меняется. Сервер отзывает все токены
+// доступа пользователя: их делегированный ключ после ротации ничего не расшифровывает.
+func (c *Client) RotateVaultKey(ctx context.Context, password string) (*KeyRotationResult, error) {
+	session, err := c.ensureAuthenticated(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.KDF == nil {
+		return nil, fmt.Errorf("kdf params are unknown, please log in again")
+	}
+
+	localSecrets, err := c.storage.GetSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local secrets: %w", err)
+	}
+	for _, secret := range localSecrets {
+		if secret.IsDirty {
+			return nil, fmt.Errorf("there are unsynced local changes, run sync first")
+		}
+	}
+
+	masterKey, err := deriveMasterKey(password, session.KDF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+
+	oldKey, err := c.resolveVaultKey(ctx, masterKey, session.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	serverSecrets, err := c.transport.ListSecrets(ctx, session.UserID, pb.SecretType_SECRET_TYPE_UNSPECIFIED)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	newKey, err := crypto.GenerateVaultKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate vault key: %w", err)
+	}
+
+	// Сервер примет ротацию, только если секреты не менялись после получения списка
+	var listedVersion int64
+	reencrypted := make([]*pb.Secret, 0, len(serverSecrets))
+	for _, serverSecret := range serverSecrets {
+		listedVersion = max(listedVersion, serverSecret.Version)
+
+		secret, err := decryptSecretWithKey(serverSecret, oldKey, c.encryptorOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", serverSecret.Id, err)
+		}
+
+		pbSecret, err := encryptSecretWithKey(secret, newKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt secret %s: %w", serverSecret.Id, err)
+		}
+		reencrypted = append(reencrypted, pbSecret)
+	}
+
+	protectedKey, err := crypto.WrapVaultKey(masterKey, newKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to protect vault key: %w", err)
+	}
+
+	syncResponse, err := c.transport.SyncAtomic(ctx, session.UserID, listedVersion, reencrypted, protectedKey)
+	if err != nil {
+		return nil, fmt.Errorf("key rotation failed: %w", err)
+	}
+	if len(syncResponse.Conflicts) > 0 {
+		return nil, fmt.Errorf("key rotation aborted, secrets changed on server: %v", syncResponse.Conflicts)
+	}
+
+	// Ответ атомарной синхронизации содержит все секреты после ротации: опись строится
+	// по нему заново и подписывается новым ключом
+	if err := c.rotateManifest(ctx, session, syncResponse.Secrets, newKey); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	for _, serverSecret := range syncResponse.Secrets {
+		if serverSecret.IsDeleted {
+			continue
+		}
+		secret, err := decryptSecretWithKey(serverSecret, newKey, c.encryptorOpts...)
+		if err != nil {
+			fmt.Printf("Warning: failed to decrypt secret %s: %v\n", serverSecret.Id, err)
+			continue
+		}
+		if err := c.storage.SaveSecret(secret); err != nil {
+			fmt.Printf("Warning: failed to save secret %s: %v\n", secret.ID, err)
+		}
+	}
+
+	session.EncryptionKey = newKey
+	session.LastSync = time.Now().Unix()
+	session.LastSyncVersion = syncResponse.CurrentVersion
+	if err := c.storage.SaveSession(session); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return &KeyRotationResult{
+		Secrets:             len(reencrypted),
+		RevokedAccessTokens: int(syncResponse.RevokedAccessTokens),
+	}, nil
+}
+
 // Logout выходит из системы
 func (c *Client) Logout() error {
 	session, err := c.storage.GetSession()
@@ -119,11 +454,74 @@ func (c *Client) Logout() error {
 	return nil
 }
 
+// Unlock разблокирует локальное хранилище мастер-паролем; сервер не требуется
+func (c *Client) Unlock(password string) error {
+	err := c.storage.Unlock(password)
+	switch {
+	case errors.Is(err, domain.ErrVaultNotInitialized):
+		return fmt.Errorf("%w, please log in first", err)
+	case err != nil:
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	return nil
+}
+
+// Lock блокирует локальное хранилище
+func (c *Client) Lock() error {
+	if err := c.storage.Lock(); err != nil {
+		return fmt.Errorf("failed to lock: %w", err)
+	}
+	return nil
+}
+
+// IsLocked сообщает, что локальное хранилище заблокировано
+func (c *Client) IsLocked() bool {
+	return c.storage.IsLocked()
+}
+
 // GetSession возвращает текущую сессию
 func (c *Client) GetSession() (*domain.Session, error) {
 	return c.storage.GetSession()
 }
 
+// ListSessions возвращает активные сессии пользователя на всех устройствах
+func (c *Client) ListSessions(ctx context.Context) ([]*SessionInfo, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	sessions, err := c.transport.ListSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	result := make([]*SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, &SessionInfo{
+			ID:            s.GetId(),
+			DeviceName:    s.GetDeviceName(),
+			ClientVersion: s.GetClientVersion(),
+			IPAddress:     s.GetIpAddress(),
+			CreatedAt:     time.Unix(s.GetCreatedAt(), 0),
+			LastUsedAt:    time.Unix(s.GetLastUsedAt(), 0),
+			Current:       s.GetCurrent(),
+		})
+	}
+	return result, nil
+}
+
+// RevokeSession завершает сессию на другом устройстве
+func (c *Client) RevokeSession(ctx context.Context, sessionID string) error {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if err := c.transport.RevokeSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
 // CreateSecret создает новый секрет
 func (c *Client) CreateSecret(ctx context.Context, secretData *domain.SecretData) (string, error) {
 	session, err := c.ensureAuthenticated(ctx)
@@ -155,6 +553,7 @@ func (c *Client) GetSecret(ctx context.Context, id string) (*SecretDisplay, erro
 		ID:        secret.ID,
 		Name:      secret.Name,
 		Type:      string(secret.Type),
+		Metadata:  secret.Metadata,
 		Data:      secret.Data,
 		CreatedAt: secret.CreatedAt,
 		UpdatedAt: secret.UpdatedAt,
@@ -175,6 +574,7 @@ func (c *Client) ListSecrets(ctx context.Context, filterType string) ([]*SecretD
 				ID:        secret.ID,
 				Name:      secret.Name,
 				Type:      string(secret.Type),
+				Metadata:  secret.Metadata,
 				CreatedAt: secret.CreatedAt,
 				UpdatedAt: secret.UpdatedAt,
 			})
@@ -225,20 +625,41 @@ func (c *Client) Sync(ctx context.Context, force bool, resolveStrategy string) (
 		}
 	}
 
+	// Опись запрашивается до синхронизации, чтобы ответ сервера был не старше нее
+	manifest, err := c.loadManifest(ctx, session)
+	if deviceNotApproved(err) {
+		return nil, ErrDeviceNotApproved
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	syncResponse, err := c.transport.Sync(ctx, session.UserID, session.LastSyncVersion, secretsToSync)
+	if deviceNotApproved(err) {
+		return nil, ErrDeviceNotApproved
+	}
 	if err != nil {
 		return nil, fmt.Errorf("sync failed: %w", err)
 	}
 
+	if manifest != nil {
+		manifest.verify(syncResponse.Secrets, localSecrets)
+	}
+
 	downloaded := 0
 	var conflicts []string
+	var rejected []string
+	var accepted []*pb.Secret
 
 	for _, serverSecret := range syncResponse.Secrets {
 		decryptedSecret, err := c.decryptSecret(serverSecret)
 		if err != nil {
-			fmt.Printf("Warning: failed to decrypt secret %s: %v\n", serverSecret.Id, err)
+			// Запись не проходит проверку подлинности (подменены данные, ID или тип) —
+			// локальная копия не перезаписывается
+			rejected = append(rejected, fmt.Sprintf("%s: %v", serverSecret.Id, err))
 			continue
 		}
+		accepted = append(accepted, serverSecret)
 
 		localSecret, err := c.storage.GetSecret(decryptedSecret.ID)
 		if err == nil && localSecret.Version != decryptedSecret.Version {
@@ -261,6 +682,14 @@ func (c *Client) Sync(ctx context.Context, force bool, resolveStrategy string) (
 		}
 	}
 
+	var warnings []string
+	if manifest != nil {
+		warnings = manifest.warnings
+		if err := c.updateManifest(ctx, session, manifest, accepted, secretsToSync, syncResponse.Conflicts); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
 	session.LastSync = time.Now().Unix()
 	session.LastSyncVersion = syncResponse.CurrentVersion
 	if err := c.storage.SaveSession(session); err != nil {
@@ -271,5 +700,7 @@ func (c *Client) Sync(ctx context.Context, force bool, resolveStrategy string) (
 		Uploaded:   len(secretsToSync),
 		Downloaded: downloaded,
 		Conflicts:  conflicts,
+		Rejected:   rejected,
+		Warnings:   warnings,
 	}, nil
 }
@@ -1,19 +1,114 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 )
 
+func testKDFParams() *pb.KDFParams {
+	return &pb.KDFParams{
+		Algorithm:   crypto.KDFAlgorithmArgon2id,
+		Salt:        []byte("0123456789abcdef"),
+		Iterations:  1,
+		Memory:      16 * 1024,
+		Parallelism: 1,
+	}
+}
+
+func testDomainKDFParams(t *testing.T) *domain.KDFParams {
+	t.Helper()
+
+	kdf, err := kdfParamsFromProto(testKDFParams())
+	require.NoError(t, err)
+	return kdf
+}
+
+func testMasterKey(t *testing.T, password string) []byte {
+	t.Helper()
+
+	key, err := deriveMasterKey(password, testDomainKDFParams(t))
+	require.NoError(t, err)
+	return key
+}
+
+func testLoginResponse() *pb.LoginResponse {
+	return &pb.LoginResponse{
+		AccessToken:  "access123",
+		RefreshToken: "refresh123",
+		UserId:       "user123",
+		Kdf:          testKDFParams(),
+	}
+}
+
+// expectPasswordLogin настраивает сервер без поддержки SRP, на котором вход выполняется по паролю
+func expectPasswordLogin(mt *MockTransport, resp *pb.LoginResponse, err error) {
+	mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+		Return(nil, status.Error(codes.Unimplemented, "unknown method SRPStart"))
+	mt.On("Login", mock.Anything, "testuser", "testpass", "").Return(resp, err)
+}
+
+// expectSRPStart настраивает начало обмена SRP с сервером, хранящим верификатор для masterKey,
+// и возвращает проверку доказательства клиента, выдающую доказательство сервера
+func expectSRPStart(t *testing.T, mt *MockTransport, masterKey []byte) func(clientProof []byte) []byte {
+	passwordKey, err := crypto.SRPPasswordKey(masterKey)
+	require.NoError(t, err)
+	salt := []byte("fedcba9876543210")
+	verifier := crypto.ComputeSRPVerifier("testuser", salt, passwordKey)
+
+	var server *crypto.SRPServer
+	resp := &pb.SRPStartResponse{HandshakeId: "handshake1", Salt: salt, Kdf: testKDFParams()}
+	mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+		Run(func(args mock.Arguments) {
+			server, err = crypto.NewSRPServer("testuser", salt, verifier, args.Get(2).([]byte))
+			require.NoError(t, err)
+			resp.ServerEphemeral = server.PublicEphemeral()
+		}).
+		Return(resp, nil)
+
+	return func(clientProof []byte) []byte {
+		serverProof, err := server.VerifyClientProof(clientProof)
+		require.NoError(t, err)
+		return serverProof
+	}
+}
+
+// expectSRPLogin настраивает вход по SRP с кодом 2FA totpCode. При forgeProof сервер возвращает ложное доказательство.
+func expectSRPLogin(t *testing.T, mt *MockTransport, masterKey []byte, totpCode string, forgeProof bool) {
+	verifyProof := expectSRPStart(t, mt, masterKey)
+
+	resp := &pb.SRPLoginResponse{AccessToken: "access123", RefreshToken: "refresh123", UserId: "user123"}
+	mt.On("SRPLogin", mock.Anything, "handshake1", mock.AnythingOfType("[]uint8"), totpCode).
+		Run(func(args mock.Arguments) {
+			resp.ServerProof = verifyProof(args.Get(2).([]byte))
+			if forgeProof {
+				resp.ServerProof = make([]byte, len(resp.ServerProof))
+			}
+		}).
+		Return(resp, nil)
+}
+
 func TestClient_Register(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -27,26 +122,57 @@ func TestClient_Register(t *testing.T) {
 			login:    "testuser",
 			password: "testpass",
 			setupMocks: func(ms *MockStorage, mt *MockTransport) {
-				mt.On("Register", mock.Anything, "testuser", "testpass").
-					Return("user123", nil)
+				var kdf *pb.KDFParams
+				mt.On("SRPRegister", mock.Anything, "testuser", mock.AnythingOfType("*grpc.KDFParams"), mock.AnythingOfType("*grpc.SRPVerifier")).
+					Run(func(args mock.Arguments) {
+						kdf = args.Get(2).(*pb.KDFParams)
+						srp := args.Get(3).(*pb.SRPVerifier)
+						assert.Len(t, kdf.GetSalt(), crypto.SaltSize)
+						assert.Len(t, srp.GetSalt(), crypto.SRPSaltSize)
+						assert.NoError(t, crypto.ValidateSRPVerifier(srp.GetVerifier()))
+					}).
+					Return(&pb.SRPRegisterResponse{UserId: "user123"}, nil)
+				ms.On("Unlock", "testpass").Return(nil)
 				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
 					Run(func(args mock.Arguments) {
 						session := args.Get(0).(*domain.Session)
 						assert.Equal(t, "user123", session.UserID)
 						assert.Equal(t, "testuser", session.Login)
-						assert.NotEmpty(t, session.EncryptionKey)
+						assert.Len(t, session.EncryptionKey, crypto.VaultKeySize)
+						require.NotNil(t, session.KDF)
+						assert.Equal(t, kdf.GetSalt(), session.KDF.Salt)
 					}).
 					Return(nil)
 			},
 			expectError: false,
 		},
 		{
-			name:     "registration failed on transport",
+			name:     "server without srp falls back to password registration",
 			login:    "testuser",
 			password: "testpass",
 			setupMocks: func(ms *MockStorage, mt *MockTransport) {
+				mt.On("SRPRegister", mock.Anything, "testuser", mock.Anything, mock.Anything).
+					Return(nil, status.Error(codes.Unimplemented, "unknown method SRPRegister"))
 				mt.On("Register", mock.Anything, "testuser", "testpass").
-					Return("", errors.New("transport error"))
+					Return(&pb.RegisterResponse{UserId: "user123", Kdf: testKDFParams()}, nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, "user123", session.UserID)
+						assert.Nil(t, session.KDF)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "registration failed on transport",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(ms *MockStorage, mt *MockTransport) {
+				mt.On("SRPRegister", mock.Anything, "testuser", mock.Anything, mock.Anything).
+					Return(nil, errors.New("transport error"))
 			},
 			expectError: true,
 		},
@@ -55,8 +181,9 @@ func TestClient_Register(t *testing.T) {
 			login:    "testuser",
 			password: "testpass",
 			setupMocks: func(ms *MockStorage, mt *MockTransport) {
-				mt.On("Register", mock.Anything, "testuser", "testpass").
-					Return("user123", nil)
+				mt.On("SRPRegister", mock.Anything, "testuser", mock.Anything, mock.Anything).
+					Return(&pb.SRPRegisterResponse{UserId: "user123"}, nil)
+				ms.On("Unlock", "testpass").Return(nil)
 				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
 					Return(errors.New("storage error"))
 			},
@@ -88,21 +215,45 @@ func TestClient_Register(t *testing.T) {
 }
 
 func TestClient_Login(t *testing.T) {
+	masterKey := testMasterKey(t, "testpass")
+
+	escrowedKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+	protectedKey, err := crypto.WrapVaultKey(masterKey, escrowedKey)
+	require.NoError(t, err)
+
+	localKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+
+	expectUploadOf := func(t *testing.T, mt *MockTransport, vaultKey []byte) {
+		mt.On("SetProtectedVaultKey", mock.Anything, mock.AnythingOfType("[]uint8")).
+			Run(func(args mock.Arguments) {
+				unwrapped, err := crypto.UnwrapVaultKey(masterKey, args.Get(1).([]byte))
+				require.NoError(t, err)
+				assert.Equal(t, vaultKey, unwrapped)
+			}).
+			Return(nil)
+	}
+
 	tests := []struct {
 		name        string
 		login       string
 		password    string
-		setupMocks  func(*MockStorage, *MockTransport)
+		totpCode    string
+		setupMocks  func(*testing.T, *MockStorage, *MockTransport)
 		expectError bool
+		expectedErr error
 	}{
 		{
-			name:     "successful login with new session",
+			name:     "new device fetches escrowed vault key",
 			login:    "testuser",
 			password: "testpass",
-			setupMocks: func(ms *MockStorage, mt *MockTransport) {
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
 				ms.On("GetSession").Return(nil, errors.New("no session"))
-				mt.On("Login", mock.Anything, "testuser", "testpass").
-					Return("access123", "refresh123", "user123", nil)
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
 				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
 					Run(func(args mock.Arguments) {
 						session := args.Get(0).(*domain.Session)
@@ -110,48 +261,780 @@ func TestClient_Login(t *testing.T) {
 						assert.Equal(t, "testuser", session.Login)
 						assert.Equal(t, "access123", session.AccessToken)
 						assert.Equal(t, "refresh123", session.RefreshToken)
-						assert.NotEmpty(t, session.EncryptionKey)
+						assert.Equal(t, escrowedKey, session.EncryptionKey)
+						assert.Equal(t, testDomainKDFParams(t), session.KDF)
 					}).
 					Return(nil)
-				mt.On("SetToken", "access123").Once()
 			},
 			expectError: false,
 		},
 		{
-			name:     "successful login with existing session",
+			name:     "local vault key is escrowed on first login",
 			login:    "testuser",
 			password: "testpass",
-			setupMocks: func(ms *MockStorage, mt *MockTransport) {
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
 				existingSession := &domain.Session{
 					UserID:        "user123",
 					Login:         "testuser",
-					EncryptionKey: []byte("existingkey"),
+					EncryptionKey: localKey,
 				}
 				ms.On("GetSession").Return(existingSession, nil)
-				mt.On("Login", mock.Anything, "testuser", "testpass").
-					Return("access123", "refresh123", "user123", nil)
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(nil, nil)
+				expectUploadOf(t, mt, localKey)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, localKey, session.EncryptionKey)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "legacy account without local key escrows master key",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(nil, errors.New("no session"))
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(nil, nil)
+				expectUploadOf(t, mt, masterKey)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, masterKey, session.EncryptionKey)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "key escrowed by another device first is used",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(nil, errors.New("no session"))
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(nil, nil).Once()
+				mt.On("SetProtectedVaultKey", mock.Anything, mock.AnythingOfType("[]uint8")).
+					Return(status.Error(codes.AlreadyExists, "vault key already exists"))
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil).Once()
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, escrowedKey, session.EncryptionKey)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "stale local key schedules re-encryption",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				existingSession := &domain.Session{
+					UserID:          "user123",
+					Login:           "testuser",
+					LastSyncVersion: 5,
+					EncryptionKey:   localKey,
+				}
+				ms.On("GetSession").Return(existingSession, nil)
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				secret := &domain.SecretData{ID: "secret1", UserID: "user123"}
+				ms.On("GetSecrets").Return([]*domain.SecretData{secret}, nil)
+				ms.On("SaveSecret", secret).
+					Run(func(args mock.Arguments) {
+						assert.True(t, args.Get(0).(*domain.SecretData).IsDirty)
+					}).
+					Return(nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, int64(5), session.LastSyncVersion)
+						assert.Equal(t, escrowedKey, session.EncryptionKey)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "escrowed key cannot be unwrapped",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				otherMasterKey, err := crypto.GenerateVaultKey()
+				require.NoError(t, err)
+				foreignKey, err := crypto.WrapVaultKey(otherMasterKey, escrowedKey)
+				require.NoError(t, err)
+
+				ms.On("GetSession").Return(nil, errors.New("no session"))
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(foreignKey, nil)
+			},
+			expectError: true,
+		},
+		{
+			name:     "local vault is created on first login",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(domain.ErrVaultNotInitialized)
+				ms.On("InitVault", "testpass").Return(nil)
+				ms.On("GetSession").Return(nil, nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "local vault protected by a previous password is not recreated",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(domain.ErrWrongPassword)
+				mt.On("SetToken", "access123").Once()
+			},
+			expectError: true,
+			expectedErr: ErrPreviousPasswordRequired,
+		},
+		{
+			name:     "local vault cannot be opened",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectPasswordLogin(mt, testLoginResponse(), nil)
+				ms.On("Unlock", "testpass").Return(errors.New("disk error"))
+				mt.On("SetToken", "access123").Once()
+			},
+			expectError: true,
+		},
+		{
+			name:     "server did not provide kdf params",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				resp := testLoginResponse()
+				resp.Kdf = nil
+				expectPasswordLogin(mt, resp, nil)
+			},
+			expectError: true,
+		},
+		{
+			name:     "login failed on transport",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(nil, errors.New("no session")).Maybe()
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, errors.New("connection refused"))
+			},
+			expectError: true,
+		},
+		{
+			name:     "password login rejected",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectPasswordLogin(mt, nil, errors.New("auth failed"))
+			},
+			expectError: true,
+		},
+		{
+			name:     "srp login",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPLogin(t, mt, masterKey, "", false)
+				ms.On("Unlock", "testpass").Return(nil)
+				ms.On("GetSession").Return(nil, nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
 				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
 					Run(func(args mock.Arguments) {
 						session := args.Get(0).(*domain.Session)
 						assert.Equal(t, "user123", session.UserID)
-						assert.Equal(t, "testuser", session.Login)
 						assert.Equal(t, "access123", session.AccessToken)
-						assert.Equal(t, "refresh123", session.RefreshToken)
-						assert.Equal(t, []byte("existingkey"), session.EncryptionKey)
+						assert.Equal(t, escrowedKey, session.EncryptionKey)
+						assert.Equal(t, testDomainKDFParams(t), session.KDF)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "srp server proof mismatch",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPLogin(t, mt, masterKey, "", true)
+			},
+			expectError: true,
+		},
+		{
+			name:     "two-factor code required",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPStart(t, mt, masterKey)
+				st, err := status.New(codes.Unauthenticated, "two-factor code required").
+					WithDetails(&errdetails.ErrorInfo{Reason: pb.ErrorReason_TOTP_REQUIRED.String(), Domain: "gophkeeper"})
+				require.NoError(t, err)
+				mt.On("SRPLogin", mock.Anything, "handshake1", mock.AnythingOfType("[]uint8"), "").Return(nil, st.Err())
+			},
+			expectError: true,
+			expectedErr: ErrTOTPRequired,
+		},
+		{
+			name:     "srp login with two-factor code",
+			login:    "testuser",
+			password: "testpass",
+			totpCode: "123456",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPLogin(t, mt, masterKey, "123456", false)
+				ms.On("Unlock", "testpass").Return(nil)
+				ms.On("GetSession").Return(nil, nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "login locked after failed attempts",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				st, err := status.New(codes.ResourceExhausted, "too many failed login attempts").WithDetails(
+					&errdetails.ErrorInfo{Reason: pb.ErrorReason_LOGIN_LOCKED.String(), Domain: "gophkeeper"},
+					&errdetails.RetryInfo{RetryDelay: durationpb.New(30 * time.Second)})
+				require.NoError(t, err)
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).Return(nil, st.Err())
+			},
+			expectError: true,
+		},
+		{
+			name:     "invalid credentials are not mistaken for missing code",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPStart(t, mt, masterKey)
+				mt.On("SRPLogin", mock.Anything, "handshake1", mock.AnythingOfType("[]uint8"), "").
+					Return(nil, status.Error(codes.Unauthenticated, "invalid credentials"))
+			},
+			expectError: true,
+		},
+		{
+			name:     "rejected srp exchange falls back to password",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPStart(t, mt, masterKey)
+				mt.On("SRPLogin", mock.Anything, "handshake1", mock.AnythingOfType("[]uint8"), "").
+					Return(nil, invalidCredentialsError(t))
+				mt.On("Login", mock.Anything, "testuser", "testpass", "").Return(testLoginResponse(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("EnrollSRP", mock.Anything, "testpass", mock.AnythingOfType("*grpc.SRPVerifier")).Return(nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				ms.On("GetSession").Return(nil, nil)
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "rejected srp exchange with password login disabled",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				expectSRPStart(t, mt, masterKey)
+				mt.On("SRPLogin", mock.Anything, "handshake1", mock.AnythingOfType("[]uint8"), "").
+					Return(nil, invalidCredentialsError(t))
+				mt.On("Login", mock.Anything, "testuser", "testpass", "").
+					Return(nil, status.Error(codes.FailedPrecondition, "password authentication is disabled, use srp"))
+			},
+			expectError: true,
+		},
+		{
+			name:     "account without verifier is switched to srp",
+			login:    "testuser",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, status.Error(codes.FailedPrecondition, "srp verifier is not set"))
+				mt.On("Login", mock.Anything, "testuser", "testpass", "").Return(testLoginResponse(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("EnrollSRP", mock.Anything, "testpass", mock.AnythingOfType("*grpc.SRPVerifier")).
+					Run(func(args mock.Arguments) {
+						srp := args.Get(2).(*pb.SRPVerifier)
+						passwordKey, err := crypto.SRPPasswordKey(masterKey)
+						require.NoError(t, err)
+						assert.Equal(t, crypto.ComputeSRPVerifier("testuser", srp.GetSalt(), passwordKey), srp.GetVerifier())
+					}).
+					Return(nil)
+				ms.On("Unlock", "testpass").Return(nil)
+				ms.On("GetSession").Return(nil, nil)
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil)
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockStorage{}
+			mockTransport := &MockTransport{}
+			tt.setupMocks(t, mockStorage, mockTransport)
+			// Регистрация устройства проверяется отдельно; здесь сервер ее не поддерживает
+			mockStorage.On("GetDevice").Return(nil, nil).Maybe()
+			mockTransport.On("RegisterDevice", mock.Anything, mock.Anything).
+				Return(nil, status.Error(codes.Unimplemented, "unknown method")).Maybe()
+
+			client := NewClient(mockStorage, mockTransport)
+			err := client.Login(context.Background(), tt.login, tt.password, tt.totpCode)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				} else {
+					assert.NotErrorIs(t, err, ErrTOTPRequired)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockStorage.AssertExpectations(t)
+			mockTransport.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_OpenLocalVaultAfterPasswordChangedElsewhere(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	newVault := func(t *testing.T) *storage.FileStorage {
+		dir := t.TempDir()
+		fs, err := storage.NewFileStorage(dir, nil)
+		require.NoError(t, err)
+		require.NoError(t, fs.InitVault("old-password"))
+		require.NoError(t, fs.SaveSecret(&domain.SecretData{ID: "s1", Name: "unsynced", IsDirty: true}))
+		require.NoError(t, fs.SaveDevice(&domain.Device{ID: "device1"}))
+		require.NoError(t, fs.Lock())
+		return fs
+	}
+
+	assertKept := func(t *testing.T, fs *storage.FileStorage, password string) {
+		require.NoError(t, fs.Lock())
+		require.NoError(t, fs.Unlock(password))
+
+		secrets, err := fs.GetSecrets()
+		require.NoError(t, err)
+		require.Len(t, secrets, 1)
+		assert.Equal(t, "unsynced", secrets[0].Name)
+		assert.True(t, secrets[0].IsDirty)
+
+		device, err := fs.GetDevice()
+		require.NoError(t, err)
+		require.NotNil(t, device)
+		assert.Equal(t, "device1", device.ID)
+	}
+
+	t.Run("previous password re-protects the vault", func(t *testing.T) {
+		fs := newVault(t)
+		client := NewClient(fs, &MockTransport{}, WithPasswordPrompt(func(string) string { return "old-password" }))
+
+		require.NoError(t, client.openLocalVault("new-password"))
+		assertKept(t, fs, "new-password")
+	})
+
+	t.Run("wrong previous password keeps the vault", func(t *testing.T) {
+		fs := newVault(t)
+		client := NewClient(fs, &MockTransport{}, WithPasswordPrompt(func(string) string { return "guess" }))
+
+		assert.ErrorIs(t, client.openLocalVault("new-password"), ErrPreviousPasswordRequired)
+		assertKept(t, fs, "old-password")
+	})
+
+	t.Run("without a prompt the vault is kept", func(t *testing.T) {
+		fs := newVault(t)
+		client := NewClient(fs, &MockTransport{})
+
+		assert.ErrorIs(t, client.openLocalVault("new-password"), ErrPreviousPasswordRequired)
+		assertKept(t, fs, "old-password")
+	})
+}
+
+func TestClient_LoginRegistersDevice(t *testing.T) {
+	masterKey := testMasterKey(t, "testpass")
+	vaultKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+	protectedKey, err := crypto.WrapVaultKey(masterKey, vaultKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		stored     *domain.Device
+		response   *pb.RegisterDeviceResponse
+		expectSave *domain.Device
+		// expectAccess access-токен сессии после регистрации; пустой — выданный при регистрации
+		expectAccess string
+	}{
+		{
+			name: "first login saves issued device credentials",
+			response: &pb.RegisterDeviceResponse{
+				DeviceId: "device1", DeviceSecret: "secret1", Approved: true,
+				AccessToken: "device-access", RefreshToken: "device-refresh",
+			},
+			expectSave: &domain.Device{ID: "device1", Secret: "secret1"},
+		},
+		{
+			name:   "known device presents stored credentials",
+			stored: &domain.Device{ID: "device1", Secret: "secret1"},
+			response: &pb.RegisterDeviceResponse{
+				DeviceId: "device1", Approved: false,
+				AccessToken: "device-access", RefreshToken: "device-refresh",
+			},
+		},
+		{
+			name:         "certificate login keeps session tokens",
+			stored:       &domain.Device{ID: "device1", Secret: "secret1"},
+			response:     &pb.RegisterDeviceResponse{DeviceId: "device1", Approved: true},
+			expectAccess: "access123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockStorage{}
+			mockTransport := &MockTransport{}
+
+			expectPasswordLogin(mockTransport, testLoginResponse(), nil)
+			mockStorage.On("Unlock", "testpass").Return(nil)
+			mockTransport.On("SetToken", "access123").Once()
+			mockStorage.On("GetDevice").Return(tt.stored, nil)
+			mockTransport.On("RegisterDevice", mock.Anything, mock.MatchedBy(func(req *pb.RegisterDeviceRequest) bool {
+				if tt.stored == nil {
+					return req.DeviceId == "" && req.DeviceSecret == ""
+				}
+				return req.DeviceId == tt.stored.ID && req.DeviceSecret == tt.stored.Secret
+			})).Return(tt.response, nil)
+			if tt.expectSave != nil {
+				mockStorage.On("SaveDevice", tt.expectSave).Return(nil)
+			}
+			expectAccess, expectRefresh := "device-access", "device-refresh"
+			if tt.expectAccess != "" {
+				expectAccess, expectRefresh = tt.expectAccess, "refresh123"
+			} else {
+				mockTransport.On("SetToken", "device-access").Once()
+			}
+			mockStorage.On("GetSession").Return(nil, nil)
+			mockTransport.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+			mockStorage.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+				Run(func(args mock.Arguments) {
+					session := args.Get(0).(*domain.Session)
+					assert.Equal(t, expectAccess, session.AccessToken)
+					assert.Equal(t, expectRefresh, session.RefreshToken)
+				}).
+				Return(nil)
+
+			client := NewClient(mockStorage, mockTransport)
+			require.NoError(t, client.Login(context.Background(), "testuser", "testpass", ""))
+
+			mockStorage.AssertExpectations(t)
+			mockTransport.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_ChangePassword(t *testing.T) {
+	oldMasterKey := testMasterKey(t, "oldpass123")
+	newMasterKey := testMasterKey(t, "newpass456")
+
+	vaultKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+	protectedKey, err := crypto.WrapVaultKey(oldMasterKey, vaultKey)
+	require.NoError(t, err)
+
+	newSession := func() *domain.Session {
+		return &domain.Session{
+			UserID:        "user123",
+			Login:         "testuser",
+			AccessToken:   "access123",
+			RefreshToken:  "refresh123",
+			EncryptionKey: vaultKey,
+			KDF:           testDomainKDFParams(t),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		oldPassword string
+		setupMocks  func(*testing.T, *MockStorage, *MockTransport)
+		expectError bool
+	}{
+		{
+			name:        "vault key re-wrapped under new password",
+			oldPassword: "oldpass123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, status.Error(codes.Unimplemented, "unknown method SRPStart"))
+				mt.On("ChangePassword", mock.Anything, mock.AnythingOfType("*grpc.ChangePasswordRequest")).
+					Run(func(args mock.Arguments) {
+						req := args.Get(1).(*pb.ChangePasswordRequest)
+						assert.Equal(t, "oldpass123", req.GetOldPassword())
+						assert.Equal(t, "newpass456", req.GetNewPassword())
+						assert.Nil(t, req.GetNewSrp())
+						unwrapped, err := crypto.UnwrapVaultKey(newMasterKey, req.GetProtectedVaultKey())
+						require.NoError(t, err)
+						assert.Equal(t, vaultKey, unwrapped)
+					}).
+					Return(&pb.ChangePasswordResponse{AccessToken: "access456", RefreshToken: "refresh456"}, nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, "access456", session.AccessToken)
+						assert.Equal(t, "refresh456", session.RefreshToken)
+						assert.Equal(t, vaultKey, session.EncryptionKey)
 					}).
 					Return(nil)
+				ms.On("ChangeVaultPassword", "newpass456").Return(nil)
+				mt.On("SetToken", "access456").Once()
+			},
+			expectError: false,
+		},
+		{
+			name:        "wrong current password",
+			oldPassword: "wrongpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+			},
+			expectError: true,
+		},
+		{
+			name:        "server rejects change",
+			oldPassword: "oldpass123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, status.Error(codes.FailedPrecondition, "srp verifier is not set"))
+				mt.On("ChangePassword", mock.Anything, mock.AnythingOfType("*grpc.ChangePasswordRequest")).
+					Run(func(args mock.Arguments) {
+						req := args.Get(1).(*pb.ChangePasswordRequest)
+						assert.Equal(t, "oldpass123", req.GetOldPassword())
+						assert.NotNil(t, req.GetNewSrp())
+					}).
+					Return(nil, errors.New("invalid credentials"))
+			},
+			expectError: true,
+		},
+		{
+			name:        "password changed over srp",
+			oldPassword: "oldpass123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				verifyProof := expectSRPStart(t, mt, oldMasterKey)
+				resp := &pb.ChangePasswordResponse{AccessToken: "access456", RefreshToken: "refresh456"}
+				mt.On("ChangePassword", mock.Anything, mock.AnythingOfType("*grpc.ChangePasswordRequest")).
+					Run(func(args mock.Arguments) {
+						req := args.Get(1).(*pb.ChangePasswordRequest)
+						assert.Empty(t, req.GetOldPassword())
+						assert.Empty(t, req.GetNewPassword())
+						assert.Equal(t, "handshake1", req.GetSrpHandshakeId())
+						newPasswordKey, err := crypto.SRPPasswordKey(newMasterKey)
+						require.NoError(t, err)
+						assert.Equal(t, crypto.ComputeSRPVerifier("testuser", req.GetNewSrp().GetSalt(), newPasswordKey), req.GetNewSrp().GetVerifier())
+						resp.ServerProof = verifyProof(req.GetSrpProof())
+					}).
+					Return(resp, nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil)
+				ms.On("ChangeVaultPassword", "newpass456").Return(nil)
+				mt.On("SetToken", "access456").Once()
+			},
+			expectError: false,
+		},
+		{
+			name:        "session without kdf params",
+			oldPassword: "oldpass123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				session := newSession()
+				session.KDF = nil
+				ms.On("GetSession").Return(session, nil)
+				mt.On("SetToken", "access123").Once()
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockStorage{}
+			mockTransport := &MockTransport{}
+			tt.setupMocks(t, mockStorage, mockTransport)
+
+			client := NewClient(mockStorage, mockTransport)
+			err := client.ChangePassword(context.Background(), tt.oldPassword, "newpass456")
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockStorage.AssertExpectations(t)
+			mockTransport.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_RotateVaultKey(t *testing.T) {
+	masterKey := testMasterKey(t, "password123")
+
+	oldKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+	protectedKey, err := crypto.WrapVaultKey(masterKey, oldKey)
+	require.NoError(t, err)
+
+	serverSecret, err := encryptSecretWithKey(&domain.SecretData{
+		ID:      "secret1",
+		UserID:  "user123",
+		Type:    domain.SecretTypeText,
+		Name:    "note",
+		Data:    domain.TextData{Content: "hello"},
+		Version: 3,
+	}, oldKey)
+	require.NoError(t, err)
+
+	newSession := func() *domain.Session {
+		return &domain.Session{
+			UserID:        "user123",
+			Login:         "testuser",
+			AccessToken:   "access123",
+			EncryptionKey: oldKey,
+			KDF:           testDomainKDFParams(t),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		password    string
+		setupMocks  func(*testing.T, *MockStorage, *MockTransport)
+		expectError bool
+	}{
+		{
+			name:     "secrets re-encrypted with new key",
+			password: "password123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				var newKey []byte
+				ms.On("GetSession").Return(newSession(), nil)
+				ms.On("GetSecrets").Return([]*domain.SecretData{}, nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				mt.On("ListSecrets", mock.Anything, "user123", pb.SecretType_SECRET_TYPE_UNSPECIFIED).
+					Return([]*pb.Secret{serverSecret}, nil)
+				syncResponse := &pb.SyncResponse{CurrentVersion: 4}
+				mt.On("SyncAtomic", mock.Anything, "user123", int64(3), mock.Anything, mock.AnythingOfType("[]uint8")).
+					Run(func(args mock.Arguments) {
+						var err error
+						newKey, err = crypto.UnwrapVaultKey(masterKey, args.Get(4).([]byte))
+						require.NoError(t, err)
+
+						secrets := args.Get(3).([]*pb.Secret)
+						require.Len(t, secrets, 1)
+						assert.Equal(t, crypto.KeyID(newKey), secrets[0].KeyId)
+						assert.Equal(t, int64(3), secrets[0].Version)
+
+						applied := proto.Clone(secrets[0]).(*pb.Secret)
+						applied.Version = 4
+						syncResponse.Secrets = []*pb.Secret{applied}
+					}).
+					Return(syncResponse, nil)
+				ms.On("SaveSecret", mock.AnythingOfType("*domain.SecretData")).
+					Run(func(args mock.Arguments) {
+						secret := args.Get(0).(*domain.SecretData)
+						assert.Equal(t, int64(4), secret.Version)
+						assert.Equal(t, domain.TextData{Content: "hello"}, secret.Data)
+					}).
+					Return(nil)
+				mt.On("GetVaultManifest", mock.Anything).Return(nil, int64(0), nil)
+				mt.On("PutVaultManifest", mock.Anything, mock.AnythingOfType("[]uint8"), int64(1)).
+					Run(func(args mock.Arguments) {
+						manifest, err := openManifest(args.Get(1).([]byte), "user123", newKey)
+						require.NoError(t, err)
+						assert.Equal(t, int64(4), manifest.Entries["secret1"].Version)
+					}).
+					Return(nil)
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+					Run(func(args mock.Arguments) {
+						session := args.Get(0).(*domain.Session)
+						assert.Equal(t, newKey, session.EncryptionKey)
+						assert.NotEqual(t, oldKey, session.EncryptionKey)
+						assert.Equal(t, int64(4), session.LastSyncVersion)
+						require.NotNil(t, session.Manifest)
+						assert.Equal(t, int64(1), session.Manifest.Sequence)
+					}).
+					Return(nil)
+			},
+			expectError: false,
+		},
+		{
+			name:     "unsynced local changes",
+			password: "password123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				ms.On("GetSecrets").Return([]*domain.SecretData{{ID: "local", IsDirty: true}}, nil)
+				mt.On("SetToken", "access123").Once()
+			},
+			expectError: true,
+		},
+		{
+			name:     "conflict leaves key unchanged",
+			password: "password123",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				ms.On("GetSecrets").Return([]*domain.SecretData{}, nil)
 				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
+				mt.On("ListSecrets", mock.Anything, "user123", pb.SecretType_SECRET_TYPE_UNSPECIFIED).
+					Return([]*pb.Secret{serverSecret}, nil)
+				mt.On("SyncAtomic", mock.Anything, "user123", int64(3), mock.Anything, mock.AnythingOfType("[]uint8")).
+					Return(&pb.SyncResponse{Conflicts: []string{"secret1"}}, nil)
 			},
-			expectError: false,
+			expectError: true,
 		},
 		{
-			name:     "login failed on transport",
-			login:    "testuser",
-			password: "testpass",
-			setupMocks: func(ms *MockStorage, mt *MockTransport) {
-				ms.On("GetSession").Return(nil, errors.New("no session")).Maybe()
-				mt.On("Login", mock.Anything, "testuser", "testpass").
-					Return("", "", "", errors.New("auth failed"))
+			name:     "wrong password",
+			password: "wrongpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				ms.On("GetSecrets").Return([]*domain.SecretData{}, nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("GetProtectedVaultKey", mock.Anything).Return(protectedKey, nil)
 			},
 			expectError: true,
 		},
@@ -161,10 +1044,10 @@ func TestClient_Login(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStorage := &MockStorage{}
 			mockTransport := &MockTransport{}
-			tt.setupMocks(mockStorage, mockTransport)
+			tt.setupMocks(t, mockStorage, mockTransport)
 
 			client := NewClient(mockStorage, mockTransport)
-			err := client.Login(context.Background(), tt.login, tt.password)
+			_, err := client.RotateVaultKey(context.Background(), tt.password)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -240,6 +1123,289 @@ func TestClient_Logout(t *testing.T) {
 	}
 }
 
+func TestClient_DeleteAccount(t *testing.T) {
+	masterKey := testMasterKey(t, "testpass")
+
+	newSession := func() *domain.Session {
+		return &domain.Session{
+			UserID:       "user123",
+			Login:        "testuser",
+			AccessToken:  "access123",
+			RefreshToken: "refresh123",
+			KDF:          testDomainKDFParams(t),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		password    string
+		totpCode    string
+		setupMocks  func(*testing.T, *MockStorage, *MockTransport)
+		expectedErr error
+		expectError bool
+	}{
+		{
+			name:     "deleted over srp",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				verifyProof := expectSRPStart(t, mt, masterKey)
+				mt.On("DeleteAccount", mock.Anything, mock.AnythingOfType("*grpc.DeleteAccountRequest")).
+					Run(func(args mock.Arguments) {
+						req := args.Get(1).(*pb.DeleteAccountRequest)
+						assert.Empty(t, req.GetPassword())
+						assert.Equal(t, "handshake1", req.GetSrpHandshakeId())
+						verifyProof(req.GetSrpProof())
+					}).
+					Return(nil)
+				ms.On("DeleteSession").Return(nil)
+				mt.On("SetToken", "").Once()
+			},
+		},
+		{
+			name:     "deleted with password before srp enrollment",
+			password: "testpass",
+			totpCode: "123456",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, status.Error(codes.FailedPrecondition, "srp verifier is not set"))
+				mt.On("DeleteAccount", mock.Anything, &pb.DeleteAccountRequest{Password: "testpass", TotpCode: "123456"}).
+					Return(nil)
+				ms.On("DeleteSession").Return(nil)
+				mt.On("SetToken", "").Once()
+			},
+		},
+		{
+			name:     "two-factor code required",
+			password: "testpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, status.Error(codes.FailedPrecondition, "srp verifier is not set"))
+				st, err := status.New(codes.Unauthenticated, "two-factor code required").WithDetails(
+					&errdetails.ErrorInfo{Reason: pb.ErrorReason_TOTP_REQUIRED.String(), Domain: "gophkeeper"})
+				require.NoError(t, err)
+				mt.On("DeleteAccount", mock.Anything, mock.AnythingOfType("*grpc.DeleteAccountRequest")).Return(st.Err())
+			},
+			expectedErr: ErrTOTPRequired,
+			expectError: true,
+		},
+		{
+			name:     "wrong password keeps session",
+			password: "wrongpass",
+			setupMocks: func(t *testing.T, ms *MockStorage, mt *MockTransport) {
+				ms.On("GetSession").Return(newSession(), nil)
+				mt.On("SetToken", "access123").Once()
+				mt.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+					Return(nil, status.Error(codes.FailedPrecondition, "srp verifier is not set"))
+				mt.On("DeleteAccount", mock.Anything, mock.AnythingOfType("*grpc.DeleteAccountRequest")).
+					Return(status.Error(codes.Unauthenticated, "invalid credentials"))
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockStorage{}
+			mockTransport := &MockTransport{}
+			tt.setupMocks(t, mockStorage, mockTransport)
+
+			client := NewClient(mockStorage, mockTransport)
+			err := client.DeleteAccount(context.Background(), tt.password, tt.totpCode)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockStorage.AssertExpectations(t)
+			mockTransport.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_DisableTOTP(t *testing.T) {
+	masterKey := testMasterKey(t, "testpass")
+	session := &domain.Session{
+		UserID:      "user123",
+		Login:       "testuser",
+		AccessToken: "access123",
+		KDF:         testDomainKDFParams(t),
+	}
+
+	t.Run("password confirmed over srp", func(t *testing.T) {
+		mockStorage := &MockStorage{}
+		mockTransport := &MockTransport{}
+		mockStorage.On("GetSession").Return(session, nil)
+		mockTransport.On("SetToken", "access123").Once()
+		verifyProof := expectSRPStart(t, mockTransport, masterKey)
+		mockTransport.On("DisableTOTP", mock.Anything, mock.AnythingOfType("*grpc.DisableTOTPRequest")).
+			Run(func(args mock.Arguments) {
+				req := args.Get(1).(*pb.DisableTOTPRequest)
+				assert.Empty(t, req.GetPassword())
+				assert.Equal(t, "123456", req.GetCode())
+				assert.Equal(t, "handshake1", req.GetSrpHandshakeId())
+				verifyProof(req.GetSrpProof())
+			}).
+			Return(nil)
+
+		client := NewClient(mockStorage, mockTransport)
+		require.NoError(t, client.DisableTOTP(context.Background(), "testpass", "123456"))
+
+		mockStorage.AssertExpectations(t)
+		mockTransport.AssertExpectations(t)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		mockStorage := &MockStorage{}
+		mockTransport := &MockTransport{}
+		mockStorage.On("GetSession").Return(session, nil)
+		mockTransport.On("SetToken", "access123").Once()
+		mockTransport.On("SRPStart", mock.Anything, "testuser", mock.AnythingOfType("[]uint8")).
+			Return(nil, status.Error(codes.FailedPrecondition, "srp verifier is not set"))
+		mockTransport.On("DisableTOTP", mock.Anything, &pb.DisableTOTPRequest{Password: "wrongpass", Code: "123456"}).
+			Return(status.Error(codes.Unauthenticated, "invalid credentials"))
+
+		client := NewClient(mockStorage, mockTransport)
+		err := client.DisableTOTP(context.Background(), "wrongpass", "123456")
+		assert.ErrorContains(t, err, "invalid password or two-factor code")
+
+		mockStorage.AssertExpectations(t)
+		mockTransport.AssertExpectations(t)
+	})
+}
+
+func TestClient_ExportAccount(t *testing.T) {
+	mockStorage := &MockStorage{}
+	mockTransport := &MockTransport{}
+	mockStorage.On("GetSession").Return(&domain.Session{UserID: "user123", AccessToken: "access123"}, nil)
+	mockTransport.On("SetToken", "access123")
+	mockTransport.On("ExportAccount", mock.Anything).Return([]*pb.AccountRecord{
+		{Record: &pb.AccountRecord_Account{Account: &pb.AccountInfo{UserId: "user123", Login: "testuser"}}},
+		{Record: &pb.AccountRecord_Secret{Secret: &pb.Secret{Id: "secret1", EncryptedData: []byte("sealed")}}},
+	}, nil)
+
+	client := NewClient(mockStorage, mockTransport)
+	var out bytes.Buffer
+	count, err := client.ExportAccount(context.Background(), &out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var record pb.AccountRecord
+	require.NoError(t, protojson.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "secret1", record.GetSecret().GetId())
+	assert.Equal(t, []byte("sealed"), record.GetSecret().GetEncryptedData())
+
+	mockStorage.AssertExpectations(t)
+	mockTransport.AssertExpectations(t)
+}
+
+func TestClient_AccessToken(t *testing.T) {
+	vaultKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+
+	tagged := &domain.SecretData{ID: "secret2", UserID: "user123", Type: domain.SecretTypeText, Name: "deploy key",
+		Metadata: &domain.SecretMetadata{Tags: []string{"deploy"}}, Data: domain.TextData{Content: "ssh-key"}}
+	untagged := &domain.SecretData{ID: "secret3", UserID: "user123", Type: domain.SecretTypeText}
+
+	mockStorage := &MockStorage{}
+	mockTransport := &MockTransport{}
+	mockStorage.On("GetSession").Return(&domain.Session{UserID: "user123", AccessToken: "access123", EncryptionKey: vaultKey}, nil)
+	mockTransport.On("SetToken", "access123")
+	mockStorage.On("GetSecrets").Return([]*domain.SecretData{tagged, untagged}, nil)
+
+	var delegatedKey []byte
+	mockTransport.On("CreateAccessToken", mock.Anything, mock.AnythingOfType("*grpc.CreateAccessTokenRequest")).
+		Run(func(args mock.Arguments) {
+			req := args.Get(1).(*pb.CreateAccessTokenRequest)
+			assert.Equal(t, "ci", req.Name)
+			assert.Equal(t, []string{"secret1", "secret2"}, req.Scope.SecretIds)
+			assert.Equal(t, pb.AccessTokenPermission_ACCESS_TOKEN_READ, req.Scope.Permission)
+			assert.NotZero(t, req.ExpiresAt)
+			delegatedKey = req.DelegatedKey
+		}).
+		Return(&pb.CreateAccessTokenResponse{Token: "gkp_token1.server-secret"}, nil)
+
+	client := NewClient(mockStorage, mockTransport)
+	token, err := client.CreateAccessToken(context.Background(), "ci",
+		AccessTokenScope{SecretIDs: []string{"secret1"}, Tags: []string{"deploy"}}, time.Hour)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, "gkp_token1.server-secret."))
+
+	_, err = client.CreateAccessToken(context.Background(), "ci", AccessTokenScope{Tags: []string{"missing"}}, 0)
+	assert.Error(t, err)
+
+	// Токен расшифровывает секреты без мастер-пароля и локального хранилища
+	encrypted, err := encryptSecretWithKey(tagged, vaultKey)
+	require.NoError(t, err)
+	fetchTransport := &MockTransport{}
+	fetchTransport.On("SetToken", "gkp_token1.server-secret")
+	fetchTransport.On("GetProtectedVaultKey", mock.Anything).Return(delegatedKey, nil)
+	fetchTransport.On("ListSecrets", mock.Anything, "", pb.SecretType_SECRET_TYPE_UNSPECIFIED).
+		Return([]*pb.Secret{encrypted}, nil)
+
+	secrets, err := NewClient(&MockStorage{}, fetchTransport).FetchWithAccessToken(context.Background(), token)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "deploy key", secrets[0].Name)
+	assert.Equal(t, domain.TextData{Content: "ssh-key"}, secrets[0].Data)
+
+	_, err = NewClient(&MockStorage{}, &MockTransport{}).FetchWithAccessToken(context.Background(), "gkp_token1.server-secret")
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+
+	mockStorage.AssertExpectations(t)
+	mockTransport.AssertExpectations(t)
+	fetchTransport.AssertExpectations(t)
+}
+
+func TestClient_WriteAccessTokenMarksManifest(t *testing.T) {
+	vaultKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+
+	entries := map[string]domain.ManifestEntry{
+		"secret1": {Version: 2, Hash: []byte{1}},
+		"secret2": {Version: 1, Hash: []byte{2}},
+	}
+	remote, err := sealManifest(&domain.VaultManifest{Sequence: 3, Entries: entries}, "user123", vaultKey)
+	require.NoError(t, err)
+
+	mockStorage := &MockStorage{}
+	mockTransport := &MockTransport{}
+	mockStorage.On("GetSession").Return(&domain.Session{UserID: "user123", AccessToken: "access123", EncryptionKey: vaultKey}, nil)
+	mockTransport.On("SetToken", "access123")
+	mockTransport.On("CreateAccessToken", mock.Anything, mock.AnythingOfType("*grpc.CreateAccessTokenRequest")).
+		Return(&pb.CreateAccessTokenResponse{Token: "gkp_token1.server-secret"}, nil)
+	mockTransport.On("GetVaultManifest", mock.Anything).Return(remote, int64(3), nil).Once()
+	mockTransport.On("PutVaultManifest", mock.Anything, mock.AnythingOfType("[]uint8"), int64(4)).
+		Run(func(args mock.Arguments) {
+			manifest, err := openManifest(args.Get(1).([]byte), "user123", vaultKey)
+			require.NoError(t, err)
+			assert.True(t, manifest.Entries["secret1"].TokenWritable)
+			assert.False(t, manifest.Entries["secret2"].TokenWritable)
+		}).
+		Return(nil).Once()
+	mockStorage.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil).Once()
+
+	client := NewClient(mockStorage, mockTransport)
+	_, err = client.CreateAccessToken(context.Background(), "ci",
+		AccessTokenScope{SecretIDs: []string{"secret1"}, Write: true}, 0)
+	require.NoError(t, err)
+
+	mockStorage.AssertExpectations(t)
+	mockTransport.AssertExpectations(t)
+}
+
 func TestClient_CreateSecret(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -528,6 +1694,7 @@ func TestClient_Sync(t *testing.T) {
 		force           bool
 		resolveStrategy string
 		setupMocks      func(*MockStorage, *MockTransport)
+		expectRejected  int
 		expectError     bool
 	}{
 		{
@@ -546,6 +1713,8 @@ func TestClient_Sync(t *testing.T) {
 				}
 				ms.On("GetSession").Return(session, nil).Maybe()
 				mt.On("SetToken", "access123").Maybe()
+				mt.On("GetVaultManifest", mock.Anything).
+					Return(nil, int64(0), status.Error(codes.Unimplemented, "not implemented")).Once()
 
 				localSecrets := []*domain.SecretData{
 					{
@@ -585,6 +1754,50 @@ func TestClient_Sync(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name:            "tampered server records rejected",
+			force:           false,
+			resolveStrategy: "server",
+			setupMocks: func(ms *MockStorage, mt *MockTransport) {
+				encryptionKey := make([]byte, 32)
+				copy(encryptionKey, "testkey12345678901234567890123456")
+
+				session := &domain.Session{
+					UserID:          "user123",
+					AccessToken:     "access123",
+					LastSyncVersion: 1,
+					EncryptionKey:   encryptionKey,
+				}
+				ms.On("GetSession").Return(session, nil).Maybe()
+				mt.On("SetToken", "access123").Maybe()
+				mt.On("GetVaultManifest", mock.Anything).
+					Return(nil, int64(0), status.Error(codes.Unimplemented, "not implemented")).Once()
+				ms.On("GetSecrets").Return([]*domain.SecretData{}, nil).Once()
+
+				encrypt := func(id string, secretType domain.SecretType, data interface{}) *pb.Secret {
+					pbSecret, err := encryptSecretWithKey(&domain.SecretData{
+						ID: id, UserID: "user123", Type: secretType, Name: id, Data: data, Version: 2,
+					}, encryptionKey)
+					require.NoError(t, err)
+					return pbSecret
+				}
+
+				// Данные второго секрета подставлены в первый
+				swapped := encrypt("secret1", domain.SecretTypeText, domain.TextData{Content: "a"})
+				swapped.EncryptedData = encrypt("secret2", domain.SecretTypeText, domain.TextData{Content: "b"}).EncryptedData
+
+				// Изменен тип секрета
+				retyped := encrypt("secret3", domain.SecretTypeText, domain.TextData{Content: "c"})
+				retyped.Type = pb.SecretType_BANK_CARD
+
+				mt.On("Sync", mock.Anything, "user123", int64(1), mock.Anything).
+					Return(&pb.SyncResponse{CurrentVersion: 2, Secrets: []*pb.Secret{swapped, retyped}}, nil).Once()
+
+				ms.On("SaveSession", mock.AnythingOfType("*domain.Session")).Return(nil).Once()
+			},
+			expectRejected: 2,
+			expectError:    false,
+		},
 		{
 			name:            "not authenticated",
 			force:           false,
@@ -604,6 +1817,9 @@ func TestClient_Sync(t *testing.T) {
 
 			client := NewClient(mockStorage, mockTransport)
 			result, err := client.Sync(context.Background(), tt.force, tt.resolveStrategy)
+			if err == nil {
+				assert.Len(t, result.Rejected, tt.expectRejected)
+			}
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -619,6 +1835,268 @@ func TestClient_Sync(t *testing.T) {
 	}
 }
 
+func TestClient_SyncDeviceNotApproved(t *testing.T) {
+	mockStorage := &MockStorage{}
+	mockTransport := &MockTransport{}
+	mockStorage.On("GetSession").Return(&domain.Session{
+		UserID: "user123", AccessToken: "access123", EncryptionKey: make([]byte, 32),
+	}, nil)
+	mockTransport.On("SetToken", "access123")
+	mockStorage.On("GetSecrets").Return([]*domain.SecretData{}, nil)
+	st, err := status.New(codes.FailedPrecondition, "device is not approved").
+		WithDetails(&errdetails.ErrorInfo{Reason: pb.ErrorReason_DEVICE_NOT_APPROVED.String(), Domain: "gophkeeper"})
+	require.NoError(t, err)
+	mockTransport.On("GetVaultManifest", mock.Anything).Return(nil, int64(0), st.Err())
+
+	client := NewClient(mockStorage, mockTransport)
+	result, err := client.Sync(context.Background(), false, "server")
+	assert.ErrorIs(t, err, ErrDeviceNotApproved)
+	assert.Nil(t, result)
+
+	mockStorage.AssertExpectations(t)
+	mockTransport.AssertExpectations(t)
+}
+
+func TestClient_SyncManifest(t *testing.T) {
+	encryptionKey := make([]byte, 32)
+	copy(encryptionKey, "testkey12345678901234567890123456")
+
+	encrypt := func(id, content string, version int64) *pb.Secret {
+		pbSecret, err := encryptSecretWithKey(&domain.SecretData{
+			ID: id, UserID: "user123", Type: domain.SecretTypeText, Name: id,
+			Data: domain.TextData{Content: content}, Version: version,
+		}, encryptionKey)
+		require.NoError(t, err)
+		return pbSecret
+	}
+	seal := func(manifest *domain.VaultManifest, key []byte) []byte {
+		data, err := sealManifest(manifest, "user123", key)
+		require.NoError(t, err)
+		return data
+	}
+	manifestOf := func(sequence int64, secrets ...*pb.Secret) *domain.VaultManifest {
+		entries := make(map[string]domain.ManifestEntry)
+		for _, secret := range secrets {
+			entries[secret.Id] = manifestEntryFor(secret, secret.Version)
+		}
+		return &domain.VaultManifest{Sequence: sequence, Entries: entries}
+	}
+
+	tokenWritable := func(manifest *domain.VaultManifest) *domain.VaultManifest {
+		for id, entry := range manifest.Entries {
+			entry.TokenWritable = true
+			manifest.Entries[id] = entry
+		}
+		return manifest
+	}
+
+	secret1 := encrypt("secret1", "a", 2)
+	replayed := &pb.Secret{Id: secret1.Id, UserId: secret1.UserId, Type: secret1.Type, KeyId: secret1.KeyId,
+		EncryptedData: secret1.EncryptedData, EncryptedMeta: secret1.EncryptedMeta, Version: 3}
+
+	tests := []struct {
+		name string
+		// local последняя опись, известная устройству
+		local *domain.VaultManifest
+		// remote опись на сервере и ее версия
+		remote        []byte
+		remoteVersion int64
+		// received секреты в ответе синхронизации
+		received []*pb.Secret
+		// bootstrap сервер вернет полный список секретов для построения описи
+		bootstrap []*pb.Secret
+		// expectPut версия сохраненной описи, 0 — опись не сохраняется
+		expectPut      int64
+		expectSequence int64
+		expectWarnings []string
+	}{
+		{
+			name:           "manifest created on first sync",
+			received:       []*pb.Secret{secret1},
+			bootstrap:      []*pb.Secret{secret1},
+			expectPut:      1,
+			expectSequence: 1,
+		},
+		{
+			name:           "server state matches manifest",
+			local:          manifestOf(3, secret1),
+			remote:         seal(manifestOf(3, secret1), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectSequence: 3,
+		},
+		{
+			name:           "secret not recorded in manifest",
+			local:          manifestOf(3),
+			remote:         seal(manifestOf(3), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectSequence: 3,
+			expectWarnings: []string{"secret secret1 is not recorded in vault manifest"},
+		},
+		{
+			name:           "secret rolled back to older version",
+			remote:         seal(manifestOf(3, encrypt("secret1", "b", 3)), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectSequence: 3,
+			expectWarnings: []string{"secret secret1 rolled back"},
+		},
+		{
+			name:           "secret missing on server",
+			remote:         seal(manifestOf(3, secret1, encrypt("secret2", "b", 5)), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectSequence: 3,
+			expectWarnings: []string{"secret secret2 (version 5) is recorded in vault manifest but missing on server"},
+		},
+		{
+			name:           "secret modified without version change",
+			remote:         seal(manifestOf(3, encrypt("secret1", "b", 2)), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectSequence: 3,
+			expectWarnings: []string{"secret secret1 was modified on server without a version change"},
+		},
+		{
+			name:           "secret updated by write access token",
+			local:          tokenWritable(manifestOf(3, secret1)),
+			remote:         seal(tokenWritable(manifestOf(3, secret1)), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{encrypt("secret1", "b", 3)},
+			expectPut:      4,
+			expectSequence: 4,
+		},
+		{
+			name:           "write access token does not cover replayed ciphertext",
+			local:          tokenWritable(manifestOf(3, secret1)),
+			remote:         seal(tokenWritable(manifestOf(3, secret1)), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{replayed},
+			expectSequence: 3,
+			expectWarnings: []string{"secret secret1 version 3 is newer than vault manifest"},
+		},
+		{
+			name:           "manifest signed with another key",
+			local:          manifestOf(3, secret1),
+			remote:         seal(manifestOf(3, secret1), make([]byte, 32)),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectPut:      4,
+			expectSequence: 4,
+			expectWarnings: []string{"vault manifest failed integrity check"},
+		},
+		{
+			name:           "manifest rolled back",
+			local:          manifestOf(5, secret1),
+			remote:         seal(manifestOf(3), encryptionKey),
+			remoteVersion:  3,
+			received:       []*pb.Secret{secret1},
+			expectPut:      4,
+			expectSequence: 4,
+			expectWarnings: []string{"vault manifest rolled back from sequence 5 to 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockStorage{}
+			mockTransport := &MockTransport{}
+
+			session := &domain.Session{
+				UserID:          "user123",
+				AccessToken:     "access123",
+				LastSyncVersion: 1,
+				EncryptionKey:   encryptionKey,
+				Manifest:        tt.local,
+			}
+			mockStorage.On("GetSession").Return(session, nil)
+			mockTransport.On("SetToken", "access123")
+			mockStorage.On("GetSecrets").Return([]*domain.SecretData{}, nil).Once()
+			mockStorage.On("GetSecret", mock.Anything).Return(nil, errors.New("not found"))
+			mockStorage.On("SaveSecret", mock.AnythingOfType("*domain.SecretData")).Return(nil)
+
+			mockTransport.On("GetVaultManifest", mock.Anything).Return(tt.remote, tt.remoteVersion, nil).Once()
+			mockTransport.On("Sync", mock.Anything, "user123", int64(1), mock.Anything).
+				Return(&pb.SyncResponse{CurrentVersion: 2, Secrets: tt.received}, nil).Once()
+			if tt.bootstrap != nil {
+				mockTransport.On("ListSecrets", mock.Anything, "user123", pb.SecretType_SECRET_TYPE_UNSPECIFIED).
+					Return(tt.bootstrap, nil).Once()
+			}
+			if tt.expectPut != 0 {
+				mockTransport.On("PutVaultManifest", mock.Anything, mock.AnythingOfType("[]uint8"), tt.expectPut).
+					Run(func(args mock.Arguments) {
+						manifest, err := openManifest(args.Get(1).([]byte), "user123", encryptionKey)
+						require.NoError(t, err)
+						assert.Equal(t, tt.expectPut, manifest.Sequence)
+						assert.Equal(t, tt.received[0].Version, manifest.Entries["secret1"].Version)
+						if tt.local != nil {
+							assert.Equal(t, tt.local.Entries["secret1"].TokenWritable, manifest.Entries["secret1"].TokenWritable)
+						}
+					}).
+					Return(nil).Once()
+			}
+			mockStorage.On("SaveSession", mock.AnythingOfType("*domain.Session")).
+				Run(func(args mock.Arguments) {
+					session := args.Get(0).(*domain.Session)
+					require.NotNil(t, session.Manifest)
+					assert.Equal(t, tt.expectSequence, session.Manifest.Sequence)
+				}).
+				Return(nil).Once()
+
+			client := NewClient(mockStorage, mockTransport)
+			result, err := client.Sync(context.Background(), false, "server")
+			require.NoError(t, err)
+
+			require.Len(t, result.Warnings, len(tt.expectWarnings))
+			for i, warning := range tt.expectWarnings {
+				assert.Contains(t, result.Warnings[i], warning)
+			}
+
+			mockStorage.AssertExpectations(t)
+			mockTransport.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_PublishManifestRetry(t *testing.T) {
+	vaultKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+	entry := func(version int64) domain.ManifestEntry {
+		return domain.ManifestEntry{Version: version, Hash: []byte{byte(version)}}
+	}
+
+	// Другое устройство успело сохранить опись с новой версией secret1 и новым secret2
+	other, err := sealManifest(&domain.VaultManifest{Sequence: 5, Entries: map[string]domain.ManifestEntry{
+		"secret1": entry(4), "secret2": entry(1),
+	}}, "user123", vaultKey)
+	require.NoError(t, err)
+
+	mockTransport := &MockTransport{}
+	mockTransport.On("PutVaultManifest", mock.Anything, mock.AnythingOfType("[]uint8"), int64(5)).
+		Return(status.Error(codes.Aborted, "vault manifest was updated concurrently")).Once()
+	mockTransport.On("GetVaultManifest", mock.Anything).Return(other, int64(5), nil).Once()
+	mockTransport.On("PutVaultManifest", mock.Anything, mock.AnythingOfType("[]uint8"), int64(6)).
+		Run(func(args mock.Arguments) {
+			manifest, err := openManifest(args.Get(1).([]byte), "user123", vaultKey)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]domain.ManifestEntry{
+				"secret1": entry(4), "secret2": entry(1), "secret3": entry(2),
+			}, manifest.Entries)
+		}).
+		Return(nil).Once()
+
+	session := &domain.Session{UserID: "user123"}
+	client := NewClient(&MockStorage{}, mockTransport)
+	err = client.publishManifest(context.Background(), session, &domain.VaultManifest{Sequence: 5, Entries: map[string]domain.ManifestEntry{
+		"secret1": entry(3), "secret3": entry(2),
+	}}, vaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), session.Manifest.Sequence)
+
+	mockTransport.AssertExpectations(t)
+}
+
 func TestClient_GetSession(t *testing.T) {
 	mockStorage := &MockStorage{}
 	mockTransport := &MockTransport{}
@@ -681,19 +2159,32 @@ func TestMapSecretTypeFunctions(t *testing.T) {
 	}
 }
 
-func TestGenerateEncryptionKey(t *testing.T) {
-	key, err := generateEncryptionKey()
+func TestDeriveMasterKey(t *testing.T) {
+	key, err := deriveMasterKey("testpass", testDomainKDFParams(t))
+	assert.NoError(t, err)
+	assert.Len(t, key, crypto.DerivedKeySize)
 
+	other, err := deriveMasterKey("otherpass", testDomainKDFParams(t))
 	assert.NoError(t, err)
-	assert.Len(t, key, 32)
-	assert.NotEmpty(t, key)
+	assert.NotEqual(t, key, other)
+
+	weak := testDomainKDFParams(t)
+	weak.Memory = 1024
+	_, err = deriveMasterKey("testpass", weak)
+	assert.Error(t, err)
+
+	_, err = deriveMasterKey("testpass", nil)
+	assert.Error(t, err)
+
+	_, err = kdfParamsFromProto(nil)
+	assert.Error(t, err)
 }
 
 func TestEncryptDecryptSecret(t *testing.T) {
 	mockStorage := &MockStorage{}
 	mockTransport := &MockTransport{}
 
-	encryptionKey, err := generateEncryptionKey()
+	encryptionKey, err := crypto.GenerateKey(32)
 	require.NoError(t, err)
 
 	session := &domain.Session{
@@ -737,3 +2228,114 @@ func TestEncryptDecryptSecret(t *testing.T) {
 
 	mockStorage.AssertExpectations(t)
 }
+
+func TestDecryptSecret_LegacyCiphertext(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+
+	// Старые клиенты шифровали данные без заголовка конверта и дополнительных данных
+	legacy := &pb.Secret{
+		Id:            "old",
+		UserId:        "user123",
+		Name:          "Old note",
+		Type:          pb.SecretType_TEXT_DATA,
+		EncryptedData: gcm.Seal(nonce, nonce, []byte(`{"content":"x"}`), nil),
+	}
+
+	decrypted, err := decryptSecretWithKey(legacy, key)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TextData{Content: "x"}, decrypted.Data)
+	assert.Equal(t, "Old note", decrypted.Name)
+	assert.Nil(t, decrypted.Metadata)
+	assert.True(t, decrypted.IsDirty, "legacy secret must be re-encrypted on the next sync")
+
+	reencrypted, err := encryptSecretWithKey(decrypted, key)
+	require.NoError(t, err)
+	assert.Empty(t, reencrypted.Name)
+	assert.NotEmpty(t, reencrypted.EncryptedMeta)
+
+	_, err = decryptSecretWithKey(legacy, key, crypto.WithoutLegacyCiphertexts())
+	assert.ErrorIs(t, err, crypto.ErrLegacyCiphertext)
+
+	current, err := encryptSecretWithKey(&domain.SecretData{
+		ID: "new", UserID: "user123", Type: domain.SecretTypeText, Name: "New", Data: domain.TextData{Content: "y"},
+	}, key)
+	require.NoError(t, err)
+	decrypted, err = decryptSecretWithKey(current, key, crypto.WithoutLegacyCiphertexts())
+	require.NoError(t, err)
+	assert.False(t, decrypted.IsDirty)
+}
+
+func TestEncryptDecryptSecret_Metadata(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	secret := &domain.SecretData{
+		ID:     "secret123",
+		UserID: "user123",
+		Name:   "Prod AWS root",
+		Type:   domain.SecretTypeText,
+		Metadata: &domain.SecretMetadata{
+			Labels:      map[string]string{"env": "prod"},
+			Description: "root account",
+			Category:    "cloud",
+			Tags:        []string{"aws", "critical"},
+		},
+		Data: domain.TextData{Content: "key"},
+	}
+
+	encrypted, err := encryptSecretWithKey(secret, key)
+	require.NoError(t, err)
+	assert.Empty(t, encrypted.Name)
+	assert.NotEmpty(t, encrypted.EncryptedMeta)
+	assert.NotContains(t, string(encrypted.EncryptedMeta), "Prod AWS root")
+
+	decrypted, err := decryptSecretWithKey(encrypted, key)
+	require.NoError(t, err)
+	assert.Equal(t, secret.Name, decrypted.Name)
+	assert.Equal(t, secret.Metadata, decrypted.Metadata)
+
+	t.Run("plaintext name instead of encrypted metadata", func(t *testing.T) {
+		stripped := proto.Clone(encrypted).(*pb.Secret)
+		stripped.EncryptedMeta = nil
+		stripped.Name = "Renamed by server"
+
+		_, err := decryptSecretWithKey(stripped, key)
+		assert.Error(t, err)
+
+		stripped.KeyId = ""
+		_, err = decryptSecretWithKey(stripped, key)
+		assert.Error(t, err, "envelope header alone marks a client that encrypts metadata")
+	})
+
+	t.Run("metadata swapped between secrets", func(t *testing.T) {
+		other, err := encryptSecretWithKey(&domain.SecretData{
+			ID: "other", UserID: "user123", Type: domain.SecretTypeText, Name: "Other", Data: domain.TextData{Content: "y"},
+		}, key)
+		require.NoError(t, err)
+
+		tampered := proto.Clone(encrypted).(*pb.Secret)
+		tampered.EncryptedMeta = other.EncryptedMeta
+
+		_, err = decryptSecretWithKey(tampered, key)
+		assert.Error(t, err)
+	})
+}
+
+// invalidCredentialsError ответ сервера на неверный пароль, в том числе на обмен SRP пользователя без верификатора
+func invalidCredentialsError(t *testing.T) error {
+	t.Helper()
+
+	st, err := status.New(codes.Unauthenticated, "invalid credentials").
+		WithDetails(&errdetails.ErrorInfo{Reason: pb.ErrorReason_INVALID_CREDENTIALS.String(), Domain: "gophkeeper"})
+	require.NoError(t, err)
+	return st.Err()
+}
@@ -12,16 +12,55 @@ type Storage interface {
 	SaveSession(session *domain.Session) error
 	GetSession() (*domain.Session, error)
 	DeleteSession() error
+	SaveDevice(device *domain.Device) error
+	GetDevice() (*domain.Device, error)
 	SaveSecret(secret *domain.SecretData) error
 	GetSecret(id string) (*domain.SecretData, error)
 	GetSecrets() ([]*domain.SecretData, error)
+	Unlock(password string) error
+	InitVault(password string) error
+	ChangeVaultPassword(password string) error
+	Lock() error
+	IsLocked() bool
 }
 
 // Transport интерфейс для транспорта
 type Transport interface {
-	Register(ctx context.Context, login, password string) (string, error)
-	Login(ctx context.Context, login, password string) (string, string, string, error)
+	Register(ctx context.Context, login, password string) (*pb.RegisterResponse, error)
+	Login(ctx context.Context, login, password, totpCode string) (*pb.LoginResponse, error)
+	SRPRegister(ctx context.Context, login string, kdf *pb.KDFParams, srp *pb.SRPVerifier) (*pb.SRPRegisterResponse, error)
+	SRPStart(ctx context.Context, login string, clientEphemeral []byte) (*pb.SRPStartResponse, error)
+	SRPLogin(ctx context.Context, handshakeID string, clientProof []byte, totpCode string) (*pb.SRPLoginResponse, error)
+	EnrollSRP(ctx context.Context, password string, srp *pb.SRPVerifier) error
+	EnableTOTP(ctx context.Context) (*pb.EnableTOTPResponse, error)
+	ConfirmTOTP(ctx context.Context, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) error
 	Logout(ctx context.Context, refreshToken string) error
+	SetProtectedVaultKey(ctx context.Context, protectedKey []byte) error
+	GetProtectedVaultKey(ctx context.Context) ([]byte, error)
+	ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error)
+	DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) error
+	ExportAccount(ctx context.Context, handle func(*pb.AccountRecord) error) error
 	Sync(ctx context.Context, userID string, lastSyncVersion int64, secrets []*pb.Secret) (*pb.SyncResponse, error)
+	SyncAtomic(ctx context.Context, userID string, lastSyncVersion int64, secrets []*pb.Secret, protectedVaultKey []byte) (*pb.SyncResponse, error)
+	ListSecrets(ctx context.Context, userID string, filterType pb.SecretType) ([]*pb.Secret, error)
+	GetVaultManifest(ctx context.Context) ([]byte, int64, error)
+	PutVaultManifest(ctx context.Context, manifest []byte, version int64) error
+	ListSessions(ctx context.Context) ([]*pb.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RegisterDevice(ctx context.Context, req *pb.RegisterDeviceRequest) (*pb.RegisterDeviceResponse, error)
+	ListDevices(ctx context.Context) ([]*pb.Device, error)
+	RenameDevice(ctx context.Context, deviceID, name string) error
+	RemoveDevice(ctx context.Context, deviceID string) error
+	ApproveDevice(ctx context.Context, deviceID string) error
+	CreateAccessToken(ctx context.Context, req *pb.CreateAccessTokenRequest) (*pb.CreateAccessTokenResponse, error)
+	ListAccessTokens(ctx context.Context) ([]*pb.AccessToken, error)
+	RevokeAccessToken(ctx context.Context, tokenID string) error
+	ListUsers(ctx context.Context, query string, limit, offset int32) ([]*pb.AdminUser, error)
+	GetUserStats(ctx context.Context, login string) (*pb.GetUserStatsResponse, error)
+	SetUserDisabled(ctx context.Context, login string, disabled bool) (*pb.AdminUser, error)
+	ForceLogout(ctx context.Context, login string, revokeAccessTokens bool) error
+	ClearLoginLockout(ctx context.Context, login, address string) error
+	RunMaintenance(ctx context.Context, job pb.MaintenanceJob) (int64, error)
 	SetToken(token string)
 }
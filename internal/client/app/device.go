@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// ErrDeviceNotApproved возвращается, если сервер требует одобрить устройство, прежде чем отдавать секреты
+var ErrDeviceNotApproved = errors.New("this device is waiting for approval: run 'devices approve' on a trusted device")
+
+// DeviceInfo отображаемое зарегистрированное устройство
+type DeviceInfo struct {
+	ID            string
+	Name          string
+	ClientVersion string
+	Approved      bool
+	CreatedAt     time.Time
+	LastSeenAt    time.Time
+	Current       bool
+}
+
+// deviceNotApproved сообщает, что сервер отказал устройству, которое еще не одобрено
+func deviceNotApproved(err error) bool {
+	return status.Code(err) == codes.FailedPrecondition && errorReason(err) == pb.ErrorReason_DEVICE_NOT_APPROVED.String()
+}
+
+// registerDevice привязывает вход к этому устройству и возвращает токены новой сессии устройства.
+// При первом входе сервер выдает устройству ID и секрет, которые сохраняются в локальном хранилище.
+// Сервер без поддержки устройств или ошибка регистрации не мешают входу: тогда возвращаются
+// прежние токены.
+func (c *Client) registerDevice(ctx context.Context, accessToken, refreshToken string) (string, string) {
+	device, err := c.storage.GetDevice()
+	if err != nil {
+		fmt.Printf("Warning: failed to read device credentials: %v\n", err)
+	}
+	if device == nil {
+		device = &domain.Device{}
+	}
+
+	resp, err := c.transport.RegisterDevice(ctx, &pb.RegisterDeviceRequest{
+		DeviceId:     device.ID,
+		DeviceSecret: device.Secret,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		return accessToken, refreshToken
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to register device: %v\n", err)
+		return accessToken, refreshToken
+	}
+
+	if resp.GetDeviceSecret() != "" {
+		device = &domain.Device{ID: resp.GetDeviceId(), Secret: resp.GetDeviceSecret()}
+		if err := c.storage.SaveDevice(device); err != nil {
+			fmt.Printf("Warning: failed to save device credentials: %v\n", err)
+		}
+	}
+	if !resp.GetApproved() {
+		fmt.Printf("This device (%s) must be approved from a trusted device before it can sync: run 'devices approve %s' there\n",
+			resp.GetDeviceId(), resp.GetDeviceId())
+	}
+
+	if resp.GetAccessToken() == "" {
+		// Клиенту, вошедшему сертификатом, сервер новую сессию не выдает
+		return accessToken, refreshToken
+	}
+	c.transport.SetToken(resp.GetAccessToken())
+	return resp.GetAccessToken(), resp.GetRefreshToken()
+}
+
+// ListDevices возвращает зарегистрированные устройства пользователя
+func (c *Client) ListDevices(ctx context.Context) ([]*DeviceInfo, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	devices, err := c.transport.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	result := make([]*DeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		result = append(result, &DeviceInfo{
+			ID:            d.GetId(),
+			Name:          d.GetName(),
+			ClientVersion: d.GetClientVersion(),
+			Approved:      d.GetApproved(),
+			CreatedAt:     time.Unix(d.GetCreatedAt(), 0),
+			LastSeenAt:    time.Unix(d.GetLastSeenAt(), 0),
+			Current:       d.GetCurrent(),
+		})
+	}
+	return result, nil
+}
+
+// RenameDevice меняет имя устройства
+func (c *Client) RenameDevice(ctx context.Context, deviceID, name string) error {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if err := c.transport.RenameDevice(ctx, deviceID, name); err != nil {
+		return fmt.Errorf("failed to rename device: %w", err)
+	}
+	return nil
+}
+
+// RemoveDevice удаляет устройство и завершает все его сессии
+func (c *Client) RemoveDevice(ctx context.Context, deviceID string) error {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if err := c.transport.RemoveDevice(ctx, deviceID); err != nil {
+		return fmt.Errorf("failed to remove device: %w", err)
+	}
+	return nil
+}
+
+// ApproveDevice одобряет новое устройство; доступно только с уже одобренного устройства
+func (c *Client) ApproveDevice(ctx context.Context, deviceID string) error {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	err := c.transport.ApproveDevice(ctx, deviceID)
+	if deviceNotApproved(err) {
+		return fmt.Errorf("failed to approve device: %w", ErrDeviceNotApproved)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to approve device: %w", err)
+	}
+	return nil
+}
@@ -2,11 +2,16 @@ package app
 
 import (
 	"context"
-	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
 	"github.com/alisaviation/GophKeeper/internal/client/domain"
 	"github.com/alisaviation/GophKeeper/internal/crypto"
 	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
@@ -15,10 +20,13 @@ import (
 func (c *Client) ensureAuthenticated(ctx context.Context) (*domain.Session, error) {
 	session, err := c.storage.GetSession()
 	if err != nil {
+		if errors.Is(err, domain.ErrVaultLocked) {
+			return nil, fmt.Errorf("%w, run 'gophkeeper unlock'", err)
+		}
 		return nil, fmt.Errorf("not authenticated: %w", err)
 	}
 
-	if session.AccessToken == "" {
+	if session == nil || session.AccessToken == "" {
 		return nil, fmt.Errorf("not authenticated")
 	}
 
@@ -26,13 +34,61 @@ func (c *Client) ensureAuthenticated(ctx context.Context) (*domain.Session, erro
 	return session, nil
 }
 
+// openLocalVault разблокирует локальное хранилище паролем, с которым только что
+// выполнен вход. Если хранилища нет, оно создается. Если оно защищено другим паролем
+// (пароль сменили на другом устройстве), локальный ключ перешифровывается новым паролем
+// после ввода прежнего: несинхронизированные секреты и учетные данные устройства сохраняются.
+func (c *Client) openLocalVault(password string) error {
+	err := c.storage.Unlock(password)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, domain.ErrWrongPassword):
+		return c.reprotectLocalVault(password)
+	case !errors.Is(err, domain.ErrVaultNotInitialized):
+		return fmt.Errorf("failed to unlock local vault: %w", err)
+	}
+
+	if err := c.storage.InitVault(password); err != nil {
+		return fmt.Errorf("failed to create local vault: %w", err)
+	}
+	return nil
+}
+
+// reprotectLocalVault открывает локальное хранилище прежним паролем и защищает его ключ
+// новым паролем password. Без прежнего пароля хранилище не пересоздается: в нем могут быть
+// секреты, еще не отправленные на сервер.
+func (c *Client) reprotectLocalVault(password string) error {
+	if c.passwordPrompt == nil {
+		return ErrPreviousPasswordRequired
+	}
+
+	previous := c.passwordPrompt("Local vault is protected by a previous password, enter it: ")
+	if err := c.storage.Unlock(previous); err != nil {
+		if errors.Is(err, domain.ErrWrongPassword) {
+			return fmt.Errorf("%w: %v", ErrPreviousPasswordRequired, err)
+		}
+		return fmt.Errorf("failed to unlock local vault: %w", err)
+	}
+
+	if err := c.storage.ChangeVaultPassword(password); err != nil {
+		return fmt.Errorf("failed to re-protect local vault: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) encryptSecret(secret *domain.SecretData) (*pb.Secret, error) {
 	session, err := c.storage.GetSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	encryptor, err := crypto.NewAESGCMEncryptor(session.EncryptionKey)
+	return encryptSecretWithKey(secret, session.EncryptionKey)
+}
+
+// encryptSecretWithKey шифрует секрет заданным ключом и помечает его идентификатором ключа
+func encryptSecretWithKey(secret *domain.SecretData, key []byte) (*pb.Secret, error) {
+	encryptor, err := crypto.NewAESGCMEncryptor(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encryptor: %w", err)
 	}
@@ -42,17 +98,28 @@ func (c *Client) encryptSecret(secret *domain.SecretData) (*pb.Secret, error) {
 		return nil, fmt.Errorf("failed to serialize data: %w", err)
 	}
 
-	encryptedData, err := encryptor.Encrypt(dataBytes)
+	encryptedData, err := encryptor.Encrypt(dataBytes, secretAssociatedData(secret.ID, secret.UserID, secret.Type))
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt data: %w", err)
 	}
 
+	metaBytes, err := proto.Marshal(metadataToProto(secret.Name, secret.Metadata))
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize metadata: %w", err)
+	}
+
+	encryptedMeta, err := encryptor.Encrypt(metaBytes, metaAssociatedData(secret.ID, secret.UserID, secret.Type))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+
 	pbSecret := &pb.Secret{
 		Id:            secret.ID,
 		UserId:        secret.UserID,
 		Type:          mapSecretTypeToProto(secret.Type),
-		Name:          secret.Name,
 		EncryptedData: encryptedData,
+		EncryptedMeta: encryptedMeta,
+		KeyId:         crypto.KeyID(key),
 		Version:       secret.Version,
 		CreatedAt:     secret.CreatedAt.Unix(),
 		UpdatedAt:     secret.UpdatedAt.Unix(),
@@ -68,18 +135,32 @@ func (c *Client) decryptSecret(pbSecret *pb.Secret) (*domain.SecretData, error)
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	encryptor, err := crypto.NewAESGCMEncryptor(session.EncryptionKey)
+	return decryptSecretWithKey(pbSecret, session.EncryptionKey, c.encryptorOpts...)
+}
+
+// decryptSecretWithKey расшифровывает секрет заданным ключом.
+// Секреты без идентификатора ключа записаны старыми версиями клиента и проверяются только расшифровкой.
+// Секрет в старом формате без заголовка конверта или с открытым названием не защищен от подмены,
+// поэтому он помечается измененным и при следующей синхронизации перешифровывается в конверт
+// с зашифрованными метаданными и пустым открытым названием.
+func decryptSecretWithKey(pbSecret *pb.Secret, key []byte, opts ...crypto.EncryptorOption) (*domain.SecretData, error) {
+	if pbSecret.KeyId != "" && pbSecret.KeyId != crypto.KeyID(key) {
+		return nil, fmt.Errorf("secret is encrypted with another key (key id %s), please log in again", pbSecret.KeyId)
+	}
+
+	encryptor, err := crypto.NewAESGCMEncryptor(key, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encryptor: %w", err)
 	}
 
-	decryptedData, err := encryptor.Decrypt(pbSecret.EncryptedData)
+	secretType := mapSecretTypeFromProto(pbSecret.Type)
+	decryptedData, err := encryptor.Decrypt(pbSecret.EncryptedData, secretAssociatedData(pbSecret.Id, pbSecret.UserId, secretType))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt data: %w", err)
 	}
 
 	var data interface{}
-	switch mapSecretTypeFromProto(pbSecret.Type) {
+	switch secretType {
 	case domain.SecretTypeLoginPassword:
 		var loginPassData domain.LoginPasswordData
 		if err := json.Unmarshal(decryptedData, &loginPassData); err != nil {
@@ -102,29 +183,191 @@ func (c *Client) decryptSecret(pbSecret *pb.Secret) (*domain.SecretData, error)
 		return nil, fmt.Errorf("unknown secret type: %v", pbSecret.Type)
 	}
 
+	// Секреты старых клиентов хранят название в открытом виде и не имеют метаданных. Запись с
+	// идентификатором ключа или конвертом создана клиентом, который всегда шифрует метаданные:
+	// без них сервер мог бы подменить название, поэтому такая запись отклоняется.
+	legacyMeta := len(pbSecret.EncryptedMeta) == 0
+	if legacyMeta && (pbSecret.KeyId != "" || crypto.IsEnvelope(pbSecret.EncryptedData)) {
+		return nil, errors.New("secret metadata is missing")
+	}
+
+	name := pbSecret.Name
+	var metadata *domain.SecretMetadata
+	if !legacyMeta {
+		metaBytes, err := encryptor.Decrypt(pbSecret.EncryptedMeta, metaAssociatedData(pbSecret.Id, pbSecret.UserId, secretType))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt metadata: %w", err)
+		}
+
+		var pbMeta pb.SecretMetadata
+		if err := proto.Unmarshal(metaBytes, &pbMeta); err != nil {
+			return nil, fmt.Errorf("failed to deserialize metadata: %w", err)
+		}
+		name, metadata = metadataFromProto(&pbMeta)
+	}
+
 	secret := &domain.SecretData{
 		ID:        pbSecret.Id,
 		UserID:    pbSecret.UserId,
-		Type:      mapSecretTypeFromProto(pbSecret.Type),
-		Name:      pbSecret.Name,
+		Type:      secretType,
+		Name:      name,
+		Metadata:  metadata,
 		Data:      data,
 		Version:   pbSecret.Version,
 		CreatedAt: time.Unix(pbSecret.CreatedAt, 0),
 		UpdatedAt: time.Unix(pbSecret.UpdatedAt, 0),
-		IsDirty:   false,
+		IsDirty:   legacyMeta || !crypto.IsEnvelope(pbSecret.EncryptedData) || !crypto.IsEnvelope(pbSecret.EncryptedMeta),
 		IsDeleted: pbSecret.IsDeleted,
 	}
 
 	return secret, nil
 }
 
-func generateEncryptionKey() ([]byte, error) {
-	key := make([]byte, 32)
-	_, err := rand.Read(key)
+// secretAssociatedData привязывает шифротекст к секрету: сервер не сможет незаметно
+// подменить данные одного секрета данными другого или изменить его тип
+func secretAssociatedData(id, userID string, secretType domain.SecretType) []byte {
+	return []byte("gophkeeper/secret/v1\x00" + id + "\x00" + userID + "\x00" + string(secretType))
+}
+
+// metaAssociatedData привязывает зашифрованные метаданные к секрету; отличается от
+// secretAssociatedData, чтобы метаданные нельзя было подставить вместо данных
+func metaAssociatedData(id, userID string, secretType domain.SecretType) []byte {
+	return []byte("gophkeeper/secret-meta/v1\x00" + id + "\x00" + userID + "\x00" + string(secretType))
+}
+
+func metadataToProto(name string, metadata *domain.SecretMetadata) *pb.SecretMetadata {
+	pbMeta := &pb.SecretMetadata{Name: name}
+	if metadata != nil {
+		pbMeta.Labels = metadata.Labels
+		pbMeta.Description = metadata.Description
+		pbMeta.Category = metadata.Category
+		pbMeta.Tags = metadata.Tags
+	}
+	return pbMeta
+}
+
+func metadataFromProto(pbMeta *pb.SecretMetadata) (string, *domain.SecretMetadata) {
+	if len(pbMeta.Labels) == 0 && pbMeta.Description == "" && pbMeta.Category == "" && len(pbMeta.Tags) == 0 {
+		return pbMeta.Name, nil
+	}
+
+	return pbMeta.Name, &domain.SecretMetadata{
+		Labels:      pbMeta.Labels,
+		Description: pbMeta.Description,
+		Category:    pbMeta.Category,
+		Tags:        pbMeta.Tags,
+	}
+}
+
+// kdfParamsFromProto преобразует параметры KDF из ответа сервера
+func kdfParamsFromProto(kdf *pb.KDFParams) (*domain.KDFParams, error) {
+	if kdf == nil {
+		return nil, fmt.Errorf("server did not provide kdf params")
+	}
+	if kdf.GetParallelism() > math.MaxUint8 {
+		return nil, fmt.Errorf("invalid kdf parallelism: %d", kdf.GetParallelism())
+	}
+
+	return &domain.KDFParams{
+		Algorithm:   kdf.GetAlgorithm(),
+		Salt:        kdf.GetSalt(),
+		Iterations:  kdf.GetIterations(),
+		Memory:      kdf.GetMemory(),
+		Parallelism: uint8(kdf.GetParallelism()),
+	}, nil
+}
+
+func kdfParamsToProto(kdf *domain.KDFParams) *pb.KDFParams {
+	return &pb.KDFParams{
+		Algorithm:   kdf.Algorithm,
+		Salt:        kdf.Salt,
+		Iterations:  kdf.Iterations,
+		Memory:      kdf.Memory,
+		Parallelism: uint32(kdf.Parallelism),
+	}
+}
+
+// deriveMasterKey получает мастер-ключ из мастер-пароля по параметрам KDF пользователя
+func deriveMasterKey(password string, kdf *domain.KDFParams) ([]byte, error) {
+	if kdf == nil {
+		return nil, fmt.Errorf("kdf params are unknown")
+	}
+
+	return crypto.DeriveKey(password, crypto.KDFParams{
+		Algorithm:   kdf.Algorithm,
+		Salt:        kdf.Salt,
+		Iterations:  kdf.Iterations,
+		Memory:      kdf.Memory,
+		Parallelism: kdf.Parallelism,
+	})
+}
+
+// resolveVaultKey получает ключ хранилища из защищенной копии на сервере.
+// Если копии еще нет, сохраняет на сервер текущий локальный ключ, а при его отсутствии —
+// мастер-ключ, которым шифровали данные предыдущие версии клиента. Если другое устройство
+// успело сохранить свой ключ раньше, используется его ключ.
+func (c *Client) resolveVaultKey(ctx context.Context, masterKey, localKey []byte) ([]byte, error) {
+	protectedKey, err := c.transport.GetProtectedVaultKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch vault key: %w", err)
+	}
+
+	if protectedKey != nil {
+		return unwrapProtectedVaultKey(masterKey, protectedKey)
+	}
+
+	vaultKey := localKey
+	if len(vaultKey) != crypto.VaultKeySize {
+		vaultKey = masterKey
+	}
+
+	protectedKey, err = crypto.WrapVaultKey(masterKey, vaultKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+		return nil, fmt.Errorf("failed to protect vault key: %w", err)
+	}
+
+	err = c.transport.SetProtectedVaultKey(ctx, protectedKey)
+	if status.Code(err) == codes.AlreadyExists {
+		protectedKey, err = c.transport.GetProtectedVaultKey(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch vault key: %w", err)
+		}
+		return unwrapProtectedVaultKey(masterKey, protectedKey)
 	}
-	return key, nil
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload vault key: %w", err)
+	}
+
+	return vaultKey, nil
+}
+
+// unwrapProtectedVaultKey расшифровывает защищенную копию ключа хранилища мастер-ключом
+func unwrapProtectedVaultKey(masterKey, protectedKey []byte) ([]byte, error) {
+	vaultKey, err := crypto.UnwrapVaultKey(masterKey, protectedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock vault key: %w", err)
+	}
+	return vaultKey, nil
+}
+
+// markSecretsDirty помечает все локальные секреты для повторной отправки на сервер
+func (c *Client) markSecretsDirty() error {
+	secrets, err := c.storage.GetSecrets()
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		if secret.IsDirty {
+			continue
+		}
+		secret.IsDirty = true
+		if err := c.storage.SaveSecret(secret); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func mapSecretTypeToProto(secretType domain.SecretType) pb.SecretType {
@@ -0,0 +1,409 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// manifestKeyInfo контекст HKDF для ключа подписи описи хранилища
+const manifestKeyInfo = "gophkeeper/manifest"
+
+// maxManifestPublishAttempts сколько раз опись сохраняется заново, если ее успело обновить другое устройство
+const maxManifestPublishAttempts = 3
+
+var errManifestSignature = errors.New("signature mismatch")
+
+// manifestPayload подписываемое содержимое описи; идентификатор пользователя не дает
+// выдать опись одного пользователя за опись другого
+type manifestPayload struct {
+	UserID   string                          `json:"user_id"`
+	Sequence int64                           `json:"sequence"`
+	Entries  map[string]domain.ManifestEntry `json:"entries"`
+}
+
+// sealManifest сериализует опись и подписывает ее HMAC-SHA256 ключом, полученным из ключа хранилища.
+// Формат: подпись (32 байта) и JSON описи.
+func sealManifest(manifest *domain.VaultManifest, userID string, vaultKey []byte) ([]byte, error) {
+	payload, err := json.Marshal(manifestPayload{
+		UserID:   userID,
+		Sequence: manifest.Sequence,
+		Entries:  manifest.Entries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+
+	mac, err := manifestMAC(vaultKey, payload)
+	if err != nil {
+		return nil, err
+	}
+	return append(mac, payload...), nil
+}
+
+// openManifest проверяет подпись описи и разбирает ее
+func openManifest(data []byte, userID string, vaultKey []byte) (*domain.VaultManifest, error) {
+	if len(data) < sha256.Size {
+		return nil, errManifestSignature
+	}
+	mac, payload := data[:sha256.Size], data[sha256.Size:]
+
+	expected, err := manifestMAC(vaultKey, payload)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, expected) {
+		return nil, errManifestSignature
+	}
+
+	var parsed manifestPayload
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if parsed.UserID != userID {
+		return nil, fmt.Errorf("manifest belongs to another user")
+	}
+	if parsed.Entries == nil {
+		parsed.Entries = make(map[string]domain.ManifestEntry)
+	}
+
+	return &domain.VaultManifest{Sequence: parsed.Sequence, Entries: parsed.Entries}, nil
+}
+
+func manifestMAC(vaultKey, payload []byte) ([]byte, error) {
+	key, err := crypto.DeriveSubKey(vaultKey, manifestKeyInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive manifest key: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// ciphertextHash хеширует зашифрованные данные и метаданные секрета в том виде,
+// в каком их хранит сервер
+func ciphertextHash(secret *pb.Secret) []byte {
+	h := sha256.New()
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(secret.EncryptedData)))
+	h.Write(size[:])
+	h.Write(secret.EncryptedData)
+	h.Write(secret.EncryptedMeta)
+	return h.Sum(nil)
+}
+
+func manifestEntryFor(secret *pb.Secret, version int64) domain.ManifestEntry {
+	return domain.ManifestEntry{
+		Version: version,
+		Hash:    ciphertextHash(secret),
+		Deleted: secret.IsDeleted,
+	}
+}
+
+// manifestCheck сверка ответа сервера с описью хранилища в ходе одной синхронизации
+type manifestCheck struct {
+	// base опись, с которой сверяется ответ сервера; nil, если описи еще нет
+	base *domain.VaultManifest
+	// remoteVersion версия описи на сервере, от которой считается версия новой описи
+	remoteVersion int64
+	// repair опись на сервере отсутствует или недостоверна и должна быть перезаписана
+	repair bool
+	// suspicious секреты, не прошедшие сверку: их записи в описи не обновляются
+	suspicious map[string]bool
+	warnings   []string
+}
+
+func (m *manifestCheck) warn(format string, args ...interface{}) {
+	m.warnings = append(m.warnings, fmt.Sprintf(format, args...))
+}
+
+// loadManifest получает опись с сервера и сверяет ее с последней описью, известной устройству.
+// Если сервер не поддерживает описи, возвращает nil.
+func (c *Client) loadManifest(ctx context.Context, session *domain.Session) (*manifestCheck, error) {
+	data, version, err := c.transport.GetVaultManifest(ctx)
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch vault manifest: %w", err)
+	}
+
+	local := session.Manifest
+	check := &manifestCheck{
+		base:          local,
+		remoteVersion: version,
+		suspicious:    make(map[string]bool),
+	}
+
+	if len(data) == 0 {
+		if local != nil {
+			check.warn("vault manifest is missing on server (last seen sequence %d)", local.Sequence)
+			check.repair = true
+		}
+		return check, nil
+	}
+
+	remote, err := openManifest(data, session.UserID, session.EncryptionKey)
+	switch {
+	case err != nil:
+		check.warn("vault manifest failed integrity check: %v", err)
+		check.repair = true
+	case remote.Sequence != version:
+		check.warn("vault manifest sequence %d does not match server version %d", remote.Sequence, version)
+		check.repair = true
+	case local != nil && remote.Sequence < local.Sequence:
+		check.warn("vault manifest rolled back from sequence %d to %d", local.Sequence, remote.Sequence)
+		check.repair = true
+	default:
+		check.base = remote
+	}
+
+	return check, nil
+}
+
+// verify сверяет полученные от сервера секреты с описью: версии не должны уменьшаться,
+// содержимое при той же версии не должно меняться, а ни одна запись описи не должна пропасть.
+// localSecrets — секреты, уже имеющиеся на устройстве.
+func (m *manifestCheck) verify(received []*pb.Secret, localSecrets []*domain.SecretData) {
+	if m.base == nil {
+		return
+	}
+
+	got := make(map[string]bool, len(received))
+	for _, secret := range received {
+		got[secret.Id] = true
+
+		entry, ok := m.base.Entries[secret.Id]
+		switch {
+		case !ok:
+			m.warn("secret %s is not recorded in vault manifest", secret.Id)
+		case secret.Version < entry.Version:
+			m.warn("secret %s rolled back: server returned version %d, manifest has %d", secret.Id, secret.Version, entry.Version)
+		case secret.Version > entry.Version && entry.TokenWritable && !bytes.Equal(ciphertextHash(secret), entry.Hash):
+			// Секрет изменен токеном доступа с правом записи
+			continue
+		case secret.Version > entry.Version:
+			m.warn("secret %s version %d is newer than vault manifest (version %d)", secret.Id, secret.Version, entry.Version)
+		case !entry.Deleted && !secret.IsDeleted && !bytes.Equal(ciphertextHash(secret), entry.Hash):
+			m.warn("secret %s was modified on server without a version change", secret.Id)
+		default:
+			continue
+		}
+		m.suspicious[secret.Id] = true
+	}
+
+	local := make(map[string]*domain.SecretData, len(localSecrets))
+	for _, secret := range localSecrets {
+		local[secret.ID] = secret
+	}
+
+	for id, entry := range m.base.Entries {
+		if entry.Deleted || got[id] {
+			continue
+		}
+		// Устройство уже получило эту версию или само изменило секрет
+		if secret, ok := local[id]; ok && (secret.Version >= entry.Version || secret.IsDirty) {
+			continue
+		}
+		m.warn("secret %s (version %d) is recorded in vault manifest but missing on server", id, entry.Version)
+	}
+}
+
+// next строит новую опись: записи прежней описи дополняются принятыми от сервера секретами
+// и отправленными на сервер изменениями, которые сервер применил
+func (m *manifestCheck) next(accepted, uploaded []*pb.Secret, conflicts []string) *domain.VaultManifest {
+	entries := make(map[string]domain.ManifestEntry)
+	if m.base != nil {
+		maps.Copy(entries, m.base.Entries)
+	}
+
+	for _, secret := range accepted {
+		if !m.suspicious[secret.Id] {
+			setManifestEntry(entries, secret.Id, manifestEntryFor(secret, secret.Version))
+		}
+	}
+
+	rejected := make(map[string]bool, len(conflicts))
+	for _, id := range conflicts {
+		rejected[id] = true
+	}
+	for _, secret := range uploaded {
+		if !rejected[secret.Id] {
+			// Сервер создает секрет с версией 1, а при изменении и удалении увеличивает версию на единицу
+			setManifestEntry(entries, secret.Id, manifestEntryFor(secret, secret.Version+1))
+		}
+	}
+
+	return &domain.VaultManifest{Sequence: m.remoteVersion + 1, Entries: entries}
+}
+
+// changed сообщает, что новая опись отличается от прежней и ее нужно сохранить
+func (m *manifestCheck) changed(manifest *domain.VaultManifest) bool {
+	if m.repair {
+		return true
+	}
+	if m.base == nil {
+		return len(manifest.Entries) > 0
+	}
+	return !maps.EqualFunc(m.base.Entries, manifest.Entries, func(a, b domain.ManifestEntry) bool {
+		return a.Version == b.Version && a.Deleted == b.Deleted && a.TokenWritable == b.TokenWritable &&
+			bytes.Equal(a.Hash, b.Hash)
+	})
+}
+
+// setManifestEntry заменяет запись секрета, сохраняя отметку о токене доступа с правом записи
+func setManifestEntry(entries map[string]domain.ManifestEntry, id string, entry domain.ManifestEntry) {
+	entry.TokenWritable = entry.TokenWritable || entries[id].TokenWritable
+	entries[id] = entry
+}
+
+// bootstrapManifest строит опись по полному списку секретов на сервере
+func (c *Client) bootstrapManifest(ctx context.Context, userID string) (*domain.VaultManifest, error) {
+	secrets, err := c.transport.ListSecrets(ctx, userID, pb.SecretType_SECRET_TYPE_UNSPECIFIED)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	entries := make(map[string]domain.ManifestEntry, len(secrets))
+	for _, secret := range secrets {
+		entries[secret.Id] = manifestEntryFor(secret, secret.Version)
+	}
+	return &domain.VaultManifest{Sequence: 1, Entries: entries}, nil
+}
+
+// updateManifest сохраняет опись, отражающую состояние хранилища после синхронизации.
+// Если описи еще нет, она строится по полному списку секретов на сервере.
+func (c *Client) updateManifest(ctx context.Context, session *domain.Session, check *manifestCheck, accepted, uploaded []*pb.Secret, conflicts []string) error {
+	var manifest *domain.VaultManifest
+	if check.base == nil {
+		bootstrap, err := c.bootstrapManifest(ctx, session.UserID)
+		if err != nil {
+			return err
+		}
+		manifest = bootstrap
+		manifest.Sequence = check.remoteVersion + 1
+	} else {
+		manifest = check.next(accepted, uploaded, conflicts)
+	}
+
+	if !check.changed(manifest) {
+		session.Manifest = check.base
+		return nil
+	}
+	return c.publishManifest(ctx, session, manifest, session.EncryptionKey)
+}
+
+// rotateManifest заменяет опись описью всех секретов, перешифрованных новым ключом хранилища
+func (c *Client) rotateManifest(ctx context.Context, session *domain.Session, secrets []*pb.Secret, vaultKey []byte) error {
+	_, version, err := c.transport.GetVaultManifest(ctx)
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch vault manifest: %w", err)
+	}
+
+	entries := make(map[string]domain.ManifestEntry, len(secrets))
+	for _, secret := range secrets {
+		entries[secret.Id] = manifestEntryFor(secret, secret.Version)
+	}
+	return c.publishManifest(ctx, session, &domain.VaultManifest{Sequence: version + 1, Entries: entries}, vaultKey)
+}
+
+// publishManifest подписывает опись и сохраняет ее на сервере. Если опись успел обновить
+// другое устройство, его опись объединяется с новой и сохранение повторяется.
+func (c *Client) publishManifest(ctx context.Context, session *domain.Session, manifest *domain.VaultManifest, vaultKey []byte) error {
+	for attempt := 1; ; attempt++ {
+		data, err := sealManifest(manifest, session.UserID, vaultKey)
+		if err != nil {
+			return err
+		}
+
+		err = c.transport.PutVaultManifest(ctx, data, manifest.Sequence)
+		if err == nil {
+			session.Manifest = manifest
+			return nil
+		}
+		if status.Code(err) != codes.Aborted || attempt == maxManifestPublishAttempts {
+			return fmt.Errorf("failed to save vault manifest: %w", err)
+		}
+
+		if manifest, err = c.mergeRemoteManifest(ctx, session, manifest, vaultKey); err != nil {
+			return err
+		}
+	}
+}
+
+// mergeRemoteManifest объединяет опись manifest с описью, которую успело сохранить другое
+// устройство: из двух записей об одном секрете остается запись о более новой версии. Опись,
+// не прошедшая проверку подписи, не учитывается и будет перезаписана.
+func (c *Client) mergeRemoteManifest(ctx context.Context, session *domain.Session, manifest *domain.VaultManifest, vaultKey []byte) (*domain.VaultManifest, error) {
+	data, version, err := c.transport.GetVaultManifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch vault manifest: %w", err)
+	}
+
+	merged := &domain.VaultManifest{Sequence: version + 1, Entries: maps.Clone(manifest.Entries)}
+	remote, err := openManifest(data, session.UserID, vaultKey)
+	if len(data) == 0 || err != nil || remote.Sequence != version {
+		return merged, nil
+	}
+
+	for id, entry := range remote.Entries {
+		if own, ok := merged.Entries[id]; ok && own.Version >= entry.Version {
+			entry = own
+		}
+		setManifestEntry(merged.Entries, id, entry)
+	}
+	return merged, nil
+}
+
+// markTokenWritable отмечает в описи секреты secretIDs, которые может изменять токен доступа
+// с правом записи
+func (c *Client) markTokenWritable(ctx context.Context, session *domain.Session, secretIDs []string) error {
+	check, err := c.loadManifest(ctx, session)
+	if err != nil || check == nil {
+		return err
+	}
+	for _, warning := range check.warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	var manifest *domain.VaultManifest
+	if check.base == nil {
+		if manifest, err = c.bootstrapManifest(ctx, session.UserID); err != nil {
+			return err
+		}
+		manifest.Sequence = check.remoteVersion + 1
+	} else {
+		manifest = check.next(nil, nil, nil)
+	}
+
+	for _, id := range secretIDs {
+		if entry, ok := manifest.Entries[id]; ok {
+			entry.TokenWritable = true
+			manifest.Entries[id] = entry
+		}
+	}
+	if !check.changed(manifest) {
+		return nil
+	}
+
+	if err := c.publishManifest(ctx, session, manifest, session.EncryptionKey); err != nil {
+		return err
+	}
+	return c.storage.SaveSession(session)
+}
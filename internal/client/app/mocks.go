@@ -31,6 +31,19 @@ func (m *MockStorage) DeleteSession() error {
 	return args.Error(0)
 }
 
+func (m *MockStorage) SaveDevice(device *domain.Device) error {
+	args := m.Called(device)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetDevice() (*domain.Device, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Device), args.Error(1)
+}
+
 func (m *MockStorage) SaveSecret(secret *domain.SecretData) error {
 	args := m.Called(secret)
 	return args.Error(0)
@@ -52,18 +65,137 @@ func (m *MockStorage) GetSecrets() ([]*domain.SecretData, error) {
 	return args.Get(0).([]*domain.SecretData), args.Error(1)
 }
 
+func (m *MockStorage) Unlock(password string) error {
+	args := m.Called(password)
+	return args.Error(0)
+}
+
+func (m *MockStorage) InitVault(password string) error {
+	args := m.Called(password)
+	return args.Error(0)
+}
+
+func (m *MockStorage) ChangeVaultPassword(password string) error {
+	args := m.Called(password)
+	return args.Error(0)
+}
+
+func (m *MockStorage) Lock() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockStorage) IsLocked() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
 type MockTransport struct {
 	mock.Mock
 }
 
-func (m *MockTransport) Register(ctx context.Context, login, password string) (string, error) {
+func (m *MockTransport) Register(ctx context.Context, login, password string) (*pb.RegisterResponse, error) {
 	args := m.Called(ctx, login, password)
-	return args.String(0), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.RegisterResponse), args.Error(1)
 }
 
-func (m *MockTransport) Login(ctx context.Context, login, password string) (string, string, string, error) {
-	args := m.Called(ctx, login, password)
-	return args.String(0), args.String(1), args.String(2), args.Error(3)
+func (m *MockTransport) SetProtectedVaultKey(ctx context.Context, protectedKey []byte) error {
+	args := m.Called(ctx, protectedKey)
+	return args.Error(0)
+}
+
+func (m *MockTransport) GetProtectedVaultKey(ctx context.Context) ([]byte, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockTransport) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.ChangePasswordResponse), args.Error(1)
+}
+
+func (m *MockTransport) DeleteAccount(ctx context.Context, req *pb.DeleteAccountRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockTransport) ExportAccount(ctx context.Context, handle func(*pb.AccountRecord) error) error {
+	args := m.Called(ctx)
+	if records, ok := args.Get(0).([]*pb.AccountRecord); ok {
+		for _, record := range records {
+			if err := handle(record); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockTransport) Login(ctx context.Context, login, password, totpCode string) (*pb.LoginResponse, error) {
+	args := m.Called(ctx, login, password, totpCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.LoginResponse), args.Error(1)
+}
+
+func (m *MockTransport) SRPRegister(ctx context.Context, login string, kdf *pb.KDFParams, srp *pb.SRPVerifier) (*pb.SRPRegisterResponse, error) {
+	args := m.Called(ctx, login, kdf, srp)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.SRPRegisterResponse), args.Error(1)
+}
+
+func (m *MockTransport) SRPStart(ctx context.Context, login string, clientEphemeral []byte) (*pb.SRPStartResponse, error) {
+	args := m.Called(ctx, login, clientEphemeral)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.SRPStartResponse), args.Error(1)
+}
+
+func (m *MockTransport) SRPLogin(ctx context.Context, handshakeID string, clientProof []byte, totpCode string) (*pb.SRPLoginResponse, error) {
+	args := m.Called(ctx, handshakeID, clientProof, totpCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.SRPLoginResponse), args.Error(1)
+}
+
+func (m *MockTransport) EnrollSRP(ctx context.Context, password string, srp *pb.SRPVerifier) error {
+	args := m.Called(ctx, password, srp)
+	return args.Error(0)
+}
+
+func (m *MockTransport) EnableTOTP(ctx context.Context) (*pb.EnableTOTPResponse, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.EnableTOTPResponse), args.Error(1)
+}
+
+func (m *MockTransport) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockTransport) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
 }
 
 func (m *MockTransport) Logout(ctx context.Context, refreshToken string) error {
@@ -79,6 +211,139 @@ func (m *MockTransport) Sync(ctx context.Context, userID string, lastSyncVersion
 	return args.Get(0).(*pb.SyncResponse), args.Error(1)
 }
 
+func (m *MockTransport) SyncAtomic(ctx context.Context, userID string, lastSyncVersion int64, secrets []*pb.Secret, protectedVaultKey []byte) (*pb.SyncResponse, error) {
+	args := m.Called(ctx, userID, lastSyncVersion, secrets, protectedVaultKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.SyncResponse), args.Error(1)
+}
+
+func (m *MockTransport) ListSecrets(ctx context.Context, userID string, filterType pb.SecretType) ([]*pb.Secret, error) {
+	args := m.Called(ctx, userID, filterType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*pb.Secret), args.Error(1)
+}
+
+func (m *MockTransport) GetVaultManifest(ctx context.Context) ([]byte, int64, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return args.Get(0).([]byte), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockTransport) PutVaultManifest(ctx context.Context, manifest []byte, version int64) error {
+	args := m.Called(ctx, manifest, version)
+	return args.Error(0)
+}
+
+func (m *MockTransport) ListSessions(ctx context.Context) ([]*pb.Session, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*pb.Session), args.Error(1)
+}
+
+func (m *MockTransport) RevokeSession(ctx context.Context, sessionID string) error {
+	args := m.Called(ctx, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockTransport) RegisterDevice(ctx context.Context, req *pb.RegisterDeviceRequest) (*pb.RegisterDeviceResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.RegisterDeviceResponse), args.Error(1)
+}
+
+func (m *MockTransport) ListDevices(ctx context.Context) ([]*pb.Device, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*pb.Device), args.Error(1)
+}
+
+func (m *MockTransport) RenameDevice(ctx context.Context, deviceID, name string) error {
+	args := m.Called(ctx, deviceID, name)
+	return args.Error(0)
+}
+
+func (m *MockTransport) RemoveDevice(ctx context.Context, deviceID string) error {
+	args := m.Called(ctx, deviceID)
+	return args.Error(0)
+}
+
+func (m *MockTransport) ApproveDevice(ctx context.Context, deviceID string) error {
+	args := m.Called(ctx, deviceID)
+	return args.Error(0)
+}
+
+func (m *MockTransport) CreateAccessToken(ctx context.Context, req *pb.CreateAccessTokenRequest) (*pb.CreateAccessTokenResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.CreateAccessTokenResponse), args.Error(1)
+}
+
+func (m *MockTransport) ListAccessTokens(ctx context.Context) ([]*pb.AccessToken, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*pb.AccessToken), args.Error(1)
+}
+
+func (m *MockTransport) RevokeAccessToken(ctx context.Context, tokenID string) error {
+	args := m.Called(ctx, tokenID)
+	return args.Error(0)
+}
+
+func (m *MockTransport) ListUsers(ctx context.Context, query string, limit, offset int32) ([]*pb.AdminUser, error) {
+	args := m.Called(ctx, query, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*pb.AdminUser), args.Error(1)
+}
+
+func (m *MockTransport) GetUserStats(ctx context.Context, login string) (*pb.GetUserStatsResponse, error) {
+	args := m.Called(ctx, login)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.GetUserStatsResponse), args.Error(1)
+}
+
+func (m *MockTransport) SetUserDisabled(ctx context.Context, login string, disabled bool) (*pb.AdminUser, error) {
+	args := m.Called(ctx, login, disabled)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.AdminUser), args.Error(1)
+}
+
+func (m *MockTransport) ForceLogout(ctx context.Context, login string, revokeAccessTokens bool) error {
+	args := m.Called(ctx, login, revokeAccessTokens)
+	return args.Error(0)
+}
+
+func (m *MockTransport) ClearLoginLockout(ctx context.Context, login, address string) error {
+	args := m.Called(ctx, login, address)
+	return args.Error(0)
+}
+
+func (m *MockTransport) RunMaintenance(ctx context.Context, job pb.MaintenanceJob) (int64, error) {
+	args := m.Called(ctx, job)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockTransport) SetToken(token string) {
 	m.Called(token)
 }
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// loginResult результат входа на сервер
+type loginResult struct {
+	accessToken  string
+	refreshToken string
+	userID       string
+	kdf          *domain.KDFParams
+	masterKey    []byte
+	// enrollSRP пользователь вошел по паролю, но сервер поддерживает SRP: после входа его нужно перевести на SRP
+	enrollSRP bool
+}
+
+// srpUnavailable сообщает, что войти по SRP нельзя и нужно использовать пароль:
+// сервер не поддерживает SRP (Unimplemented) или пользователь еще не перешел на него (FailedPrecondition)
+func srpUnavailable(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unimplemented || code == codes.FailedPrecondition
+}
+
+// srpRejected сообщает, что сервер отклонил обмен SRP как неверный пароль. Так же сервер отвечает
+// пользователю, еще не перешедшему на SRP, чтобы по ответу нельзя было перебирать логины,
+// поэтому после такого отказа пароль проверяется прежним способом.
+func srpRejected(err error) bool {
+	return status.Code(err) == codes.Unauthenticated && errorReason(err) == pb.ErrorReason_INVALID_CREDENTIALS.String()
+}
+
+// registerSRP регистрирует пользователя по верификатору SRP: параметры KDF выбираются на клиенте
+func (c *Client) registerSRP(ctx context.Context, login, password string) (string, *domain.KDFParams, error) {
+	params := crypto.DefaultKDFParams()
+	salt, err := crypto.GenerateSalt(crypto.SaltSize)
+	if err != nil {
+		return "", nil, err
+	}
+	params.Salt = salt
+
+	kdf := &domain.KDFParams{
+		Algorithm:   params.Algorithm,
+		Salt:        params.Salt,
+		Iterations:  params.Iterations,
+		Memory:      params.Memory,
+		Parallelism: params.Parallelism,
+	}
+
+	masterKey, err := deriveMasterKey(password, kdf)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+
+	verifier, err := newSRPVerifier(login, masterKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := c.transport.SRPRegister(ctx, login, kdfParamsToProto(kdf), verifier)
+	if err != nil {
+		return "", nil, err
+	}
+	return resp.GetUserId(), kdf, nil
+}
+
+// loginSRP выполняет вход по SRP: пароль не покидает клиент, а сервер в ответ доказывает,
+// что знает верификатор пользователя
+func (c *Client) loginSRP(ctx context.Context, login, password, totpCode string) (*loginResult, error) {
+	srpClient, err := crypto.NewSRPClient(login)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := c.transport.SRPStart(ctx, login, srpClient.PublicEphemeral())
+	if err != nil {
+		return nil, err
+	}
+
+	kdf, err := kdfParamsFromProto(start.GetKdf())
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := deriveMasterKey(password, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+
+	proof, err := srpProof(srpClient, masterKey, start)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.transport.SRPLogin(ctx, start.GetHandshakeId(), proof, totpCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := srpClient.VerifyServerProof(resp.GetServerProof()); err != nil {
+		return nil, fmt.Errorf("server failed to prove knowledge of the password verifier: %w", err)
+	}
+
+	return &loginResult{
+		accessToken:  resp.GetAccessToken(),
+		refreshToken: resp.GetRefreshToken(),
+		userID:       resp.GetUserId(),
+		kdf:          kdf,
+		masterKey:    masterKey,
+	}, nil
+}
+
+// loginPassword выполняет вход с передачей пароля серверу
+func (c *Client) loginPassword(ctx context.Context, login, password, totpCode string) (*loginResult, error) {
+	resp, err := c.transport.Login(ctx, login, password, totpCode)
+	if err != nil {
+		return nil, err
+	}
+
+	kdf, err := kdfParamsFromProto(resp.GetKdf())
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := deriveMasterKey(password, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive master key: %w", err)
+	}
+
+	return &loginResult{
+		accessToken:  resp.GetAccessToken(),
+		refreshToken: resp.GetRefreshToken(),
+		userID:       resp.GetUserId(),
+		kdf:          kdf,
+		masterKey:    masterKey,
+	}, nil
+}
+
+// enrollSRP переводит пользователя на вход по SRP. Ошибка не мешает работе:
+// перевод повторится при следующем входе.
+func (c *Client) enrollSRP(ctx context.Context, login, password string, masterKey []byte) {
+	verifier, err := newSRPVerifier(login, masterKey)
+	if err == nil {
+		err = c.transport.EnrollSRP(ctx, password, verifier)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to switch to password-less login: %v\n", err)
+	}
+}
+
+// srpProof вычисляет доказательство знания пароля для обмена, начатого SRPStart
+func srpProof(srpClient *crypto.SRPClient, masterKey []byte, start *pb.SRPStartResponse) ([]byte, error) {
+	passwordKey, err := crypto.SRPPasswordKey(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive srp key: %w", err)
+	}
+
+	proof, err := srpClient.Proof(passwordKey, start.GetSalt(), start.GetServerEphemeral())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute srp proof: %w", err)
+	}
+	return proof, nil
+}
+
+// newSRPVerifier вычисляет новый верификатор SRP из мастер-ключа
+func newSRPVerifier(login string, masterKey []byte) (*pb.SRPVerifier, error) {
+	passwordKey, err := crypto.SRPPasswordKey(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive srp key: %w", err)
+	}
+
+	salt, err := crypto.GenerateSalt(crypto.SRPSaltSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.SRPVerifier{
+		Salt:     salt,
+		Verifier: crypto.ComputeSRPVerifier(login, salt, passwordKey),
+	}, nil
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+)
+
+// ErrTOTPRequired возвращается при входе, если у пользователя включена 2FA, а код не передан
+var ErrTOTPRequired = errors.New("two-factor code required")
+
+// TOTPEnrollment данные для добавления аккаунта в приложение-аутентификатор
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// totpRequired сообщает, что сервер отклонил вход из-за отсутствия кода 2FA
+func totpRequired(err error) bool {
+	return status.Code(err) == codes.Unauthenticated && errorReason(err) == pb.ErrorReason_TOTP_REQUIRED.String()
+}
+
+// EnableTOTP начинает подключение двухфакторной аутентификации. Включится она только
+// после подтверждения кодом из приложения (ConfirmTOTP).
+func (c *Client) EnableTOTP(ctx context.Context) (*TOTPEnrollment, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.transport.EnableTOTP(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return &TOTPEnrollment{Secret: resp.GetSecret(), URI: resp.GetUri()}, nil
+}
+
+// ConfirmTOTP включает двухфакторную аутентификацию и возвращает одноразовые коды восстановления
+func (c *Client) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	if _, err := c.ensureAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := c.transport.ConfirmTOTP(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+	return recoveryCodes, nil
+}
+
+// DisableTOTP отключает двухфакторную аутентификацию. Отключение подтверждается текущим паролем,
+// как удаление учетной записи, и кодом: подходит и код из приложения, и код восстановления.
+func (c *Client) DisableTOTP(ctx context.Context, password, code string) error {
+	session, err := c.ensureAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := c.confirmPassword(ctx, session, password)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	req := &pb.DisableTOTPRequest{
+		Code:           code,
+		Password:       confirmation.password,
+		SrpHandshakeId: confirmation.handshakeID,
+		SrpProof:       confirmation.proof,
+	}
+	err = c.transport.DisableTOTP(ctx, req)
+	if srpRejected(err) {
+		legacyReq := &pb.DisableTOTPRequest{Code: code, Password: password}
+		if legacyErr := c.transport.DisableTOTP(ctx, legacyReq); !srpUnavailable(legacyErr) {
+			err = legacyErr
+		}
+	}
+	if err != nil {
+		if status.Code(err) == codes.Unauthenticated {
+			return fmt.Errorf("failed to disable two-factor authentication: invalid password or two-factor code")
+		}
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+)
+
+// NewAccountCommand создает команды для управления учетной записью
+func NewAccountCommand(clientApp *app.Client) *cobra.Command {
+	accountCmd := &cobra.Command{
+		Use:   "account",
+		Short: "Account management commands",
+	}
+
+	accountCmd.AddCommand(
+		&cobra.Command{
+			Use:   "delete",
+			Short: "Permanently delete the account and all its data on the server",
+			Run: func(cmd *cobra.Command, args []string) {
+				session, err := clientApp.GetSession()
+				if err != nil {
+					fmt.Println("Not logged in")
+					return
+				}
+
+				fmt.Println("This permanently deletes the account, all secrets and sessions on the server.")
+				if readLine(fmt.Sprintf("Type the login (%s) to confirm: ", session.Login)) != session.Login {
+					fmt.Println("Account deletion cancelled")
+					return
+				}
+				password := ReadPassword("Enter password: ")
+
+				ctx := context.Background()
+				err = clientApp.DeleteAccount(ctx, password, "")
+				if errors.Is(err, app.ErrTOTPRequired) {
+					code := readLine("Enter two-factor code (or recovery code): ")
+					err = clientApp.DeleteAccount(ctx, password, code)
+				}
+				if err != nil {
+					fmt.Printf("Account deletion failed: %v\n", err)
+					return
+				}
+
+				fmt.Println("Account deleted")
+			},
+		},
+		&cobra.Command{
+			Use:   "export [file]",
+			Short: "Export all account records stored on the server as JSON lines",
+			Long: "Export all account records stored on the server as JSON lines. Secrets and the vault key " +
+				"stay encrypted. Without a file the records are written to stdout.",
+			Args: cobra.MaximumNArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				out := os.Stdout
+				if len(args) == 1 {
+					file, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+					if err != nil {
+						fmt.Printf("Export failed: %v\n", err)
+						return
+					}
+					defer file.Close()
+					out = file
+				}
+
+				count, err := clientApp.ExportAccount(context.Background(), out)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+					return
+				}
+
+				fmt.Fprintf(os.Stderr, "Exported %d records\n", count)
+			},
+		},
+	)
+
+	return accountCmd
+}
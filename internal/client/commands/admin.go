@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+)
+
+// NewAdminUsersCommand создает команды администрирования учетных записей пользователей
+func NewAdminUsersCommand(clientApp *app.Client) *cobra.Command {
+	usersCmd := &cobra.Command{
+		Use:   "users",
+		Short: "Manage user accounts",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list [query]",
+		Short: "List users, optionally filtered by a login substring",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			limit, _ := cmd.Flags().GetInt("limit")
+			offset, _ := cmd.Flags().GetInt("offset")
+			query := ""
+			if len(args) > 0 {
+				query = args[0]
+			}
+
+			ctx := context.Background()
+			users, err := clientApp.ListUsers(ctx, query, limit, offset)
+			if err != nil {
+				fmt.Printf("Failed to list users: %v\n", err)
+				return
+			}
+
+			if len(users) == 0 {
+				fmt.Println("No users found")
+				return
+			}
+
+			fmt.Printf("Found %d users:\n", len(users))
+			for _, user := range users {
+				fmt.Printf("  %s %s%s\n", user.ID, user.Login, adminUserMarkers(user))
+			}
+		},
+	}
+	listCmd.Flags().Int("limit", 0, "Maximum number of users to show (0 uses the server default)")
+	listCmd.Flags().Int("offset", 0, "Number of users to skip")
+
+	logoutCmd := &cobra.Command{
+		Use:   "logout [login]",
+		Short: "End all sessions of a user",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			revokeTokens, _ := cmd.Flags().GetBool("revoke-tokens")
+
+			ctx := context.Background()
+			if err := clientApp.ForceLogout(ctx, args[0], revokeTokens); err != nil {
+				fmt.Printf("Failed to log out user: %v\n", err)
+				return
+			}
+
+			fmt.Printf("All sessions of %s ended\n", args[0])
+			if revokeTokens {
+				fmt.Println("Access tokens revoked")
+			}
+		},
+	}
+	logoutCmd.Flags().Bool("revoke-tokens", false, "Also revoke the user's automation access tokens")
+
+	unlockCmd := &cobra.Command{
+		Use:   "unlock [login]",
+		Short: "Clear the login lockout of a user or a client address",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			address, _ := cmd.Flags().GetString("address")
+			login := ""
+			if len(args) > 0 {
+				login = args[0]
+			}
+
+			ctx := context.Background()
+			if err := clientApp.ClearLoginLockout(ctx, login, address); err != nil {
+				fmt.Printf("Failed to clear login lockout: %v\n", err)
+				return
+			}
+
+			fmt.Println("Login lockout cleared")
+		},
+	}
+	unlockCmd.Flags().String("address", "", "Client IP address to clear the lockout for")
+
+	usersCmd.AddCommand(
+		listCmd,
+		&cobra.Command{
+			Use:   "show [login]",
+			Short: "Show account details and storage usage of a user",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				stats, err := clientApp.GetUserStats(ctx, args[0])
+				if err != nil {
+					fmt.Printf("Failed to get user stats: %v\n", err)
+					return
+				}
+
+				user := stats.User
+				fmt.Printf("User: %s%s\n", user.Login, adminUserMarkers(user))
+				fmt.Printf("ID: %s\n", user.ID)
+				fmt.Printf("Created: %s\n", user.CreatedAt.Format("2006-01-02 15:04:05"))
+				fmt.Printf("Disabled: %s\n", timeOrDefault(user.DisabledAt, "no"))
+				fmt.Printf("Secrets: %d (%d deleted), %d bytes\n", stats.Secrets, stats.DeletedSecrets, stats.StorageBytes)
+				fmt.Printf("Active sessions: %d\n", stats.ActiveSessions)
+				fmt.Printf("Devices: %d\n", stats.Devices)
+				fmt.Printf("Access tokens: %d\n", stats.AccessTokens)
+				fmt.Printf("Two-factor authentication: %t\n", stats.TOTPEnabled)
+			},
+		},
+		&cobra.Command{
+			Use:   "disable [login]",
+			Short: "Disable a user account and end its sessions",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				if _, err := clientApp.SetUserDisabled(ctx, args[0], true); err != nil {
+					fmt.Printf("Failed to disable user: %v\n", err)
+					return
+				}
+
+				fmt.Printf("User %s disabled\n", args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "enable [login]",
+			Short: "Enable a disabled user account",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				if _, err := clientApp.SetUserDisabled(ctx, args[0], false); err != nil {
+					fmt.Printf("Failed to enable user: %v\n", err)
+					return
+				}
+
+				fmt.Printf("User %s enabled\n", args[0])
+			},
+		},
+		logoutCmd,
+		unlockCmd,
+	)
+
+	return usersCmd
+}
+
+// NewAdminMaintenanceCommand создает команды обслуживания базы сервера
+func NewAdminMaintenanceCommand(clientApp *app.Client) *cobra.Command {
+	jobs := make([]string, 0, len(app.MaintenanceJobs))
+	for job := range app.MaintenanceJobs {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+
+	maintenanceCmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Run server maintenance jobs",
+	}
+
+	maintenanceCmd.AddCommand(
+		&cobra.Command{
+			Use:       "run [job]",
+			Short:     "Run a maintenance job: " + strings.Join(jobs, ", "),
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: jobs,
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				removed, err := clientApp.RunMaintenance(ctx, args[0])
+				if err != nil {
+					fmt.Printf("Failed to run maintenance: %v\n", err)
+					return
+				}
+
+				fmt.Printf("Maintenance job %s finished, %d records removed\n", args[0], removed)
+			},
+		},
+	)
+
+	return maintenanceCmd
+}
+
+func adminUserMarkers(user *app.AdminUserInfo) string {
+	var markers []string
+	if user.Admin {
+		markers = append(markers, "admin")
+	}
+	if user.DisabledAt != nil {
+		markers = append(markers, "disabled")
+	}
+	if len(markers) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(markers, ", ") + ")"
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/agent"
+	"github.com/alisaviation/GophKeeper/internal/client/storage"
+)
+
+// NewAgentCommand создает команду управления агентом, который держит ключ
+// разблокированного хранилища в памяти. fileCache — кеш ключа, используемый без агента:
+// при запуске агента ключ переносится из него в агента.
+func NewAgentCommand(socketPath string, fileCache storage.KeyCache, defaultTTL time.Duration) *cobra.Command {
+	var (
+		ttl        time.Duration
+		foreground bool
+	)
+
+	agentCmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Start a background agent that keeps the unlocked vault key in memory",
+		Run: func(cmd *cobra.Command, args []string) {
+			if foreground {
+				if err := runAgent(socketPath, ttl); err != nil {
+					fmt.Printf("Agent failed: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+
+			client := agent.NewClient(socketPath)
+			if client.Running() {
+				fmt.Printf("Agent is already running (socket: %s)\n", socketPath)
+				return
+			}
+
+			pid, err := agent.Spawn(socketPath, "agent", "--foreground", "--ttl", ttl.String())
+			if err != nil {
+				fmt.Printf("Failed to start agent: %v\n", err)
+				return
+			}
+
+			if key := fileCache.Load(); key != nil {
+				if err := client.Save(key); err != nil {
+					fmt.Printf("Warning: failed to hand over unlocked key: %v\n", err)
+				}
+			}
+			if err := fileCache.Clear(); err != nil {
+				fmt.Printf("Warning: failed to clear key cache: %v\n", err)
+			}
+
+			fmt.Printf("Agent started (pid %d, socket: %s)\n", pid, socketPath)
+		},
+	}
+	agentCmd.Flags().DurationVar(&ttl, "ttl", defaultTTL, "How long the unlocked key is kept without use (0 keeps it until lock or stop)")
+	agentCmd.Flags().BoolVar(&foreground, "foreground", false, "Run the agent in the foreground")
+
+	agentCmd.AddCommand(
+		newAgentStatusCommand(socketPath),
+		newAgentStopCommand(socketPath),
+	)
+
+	return agentCmd
+}
+
+func newAgentStatusCommand(socketPath string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show agent status",
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := agent.NewClient(socketPath).Status()
+			if err != nil {
+				fmt.Println("Agent is not running")
+				return
+			}
+
+			fmt.Printf("Agent is running (pid %d)\n", status.PID)
+			fmt.Printf("Socket: %s\n", socketPath)
+			if !status.Unlocked {
+				fmt.Println("Vault: locked")
+				return
+			}
+			fmt.Println("Vault: unlocked")
+			if !status.ExpiresAt.IsZero() {
+				fmt.Printf("Locks at: %s\n", status.ExpiresAt.Format("2006-01-02 15:04:05"))
+			}
+		},
+	}
+}
+
+func newAgentStopCommand(socketPath string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the agent and forget the unlocked key",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := agent.NewClient(socketPath).Stop(); err != nil {
+				fmt.Println("Agent is not running")
+				return
+			}
+
+			fmt.Println("Agent stopped")
+		},
+	}
+}
+
+// runAgent обслуживает сокет агента до команды остановки или сигнала
+func runAgent(socketPath string, ttl time.Duration) error {
+	listener, err := agent.Listen(socketPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(socketPath) }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return agent.New(ttl).Serve(ctx, listener)
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -22,6 +23,32 @@ func NewAuthCommand(clientApp *app.Client) *cobra.Command {
 		Short: "Authentication commands",
 	}
 
+	loginCmd := &cobra.Command{
+		Use:   "login [login]",
+		Short: "Login to the service",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			login := args[0]
+			password := ReadPassword("Enter password: ")
+			// С --code вход выполняется за одну попытку, без запроса сервера о том, нужен ли код
+			code, _ := cmd.Flags().GetString("code")
+
+			ctx := context.Background()
+			err := clientApp.Login(ctx, login, password, code)
+			if errors.Is(err, app.ErrTOTPRequired) {
+				code = readLine("Enter two-factor code (or recovery code): ")
+				err = clientApp.Login(ctx, login, password, code)
+			}
+			if err != nil {
+				fmt.Printf("Login failed: %v\n", err)
+				return
+			}
+
+			fmt.Printf("Successfully logged in as %s\n", login)
+		},
+	}
+	loginCmd.Flags().String("code", "", "Two-factor code (or recovery code) if two-factor authentication is enabled")
+
 	authCmd.AddCommand(
 		&cobra.Command{
 			Use:   "register [login]",
@@ -29,8 +56,8 @@ func NewAuthCommand(clientApp *app.Client) *cobra.Command {
 			Args:  cobra.ExactArgs(1),
 			Run: func(cmd *cobra.Command, args []string) {
 				login := args[0]
-				password := readPassword("Enter password: ")
-				confirmPassword := readPassword("Confirm password: ")
+				password := ReadPassword("Enter password: ")
+				confirmPassword := ReadPassword("Confirm password: ")
 
 				if password != confirmPassword {
 					fmt.Println("Error: Passwords do not match")
@@ -47,41 +74,51 @@ func NewAuthCommand(clientApp *app.Client) *cobra.Command {
 				fmt.Printf("Successfully registered user %s with ID: %s\n", login, userID)
 			},
 		},
+		loginCmd,
 		&cobra.Command{
-			Use:   "login [login]",
-			Short: "Login to the service",
-			Args:  cobra.ExactArgs(1),
+			Use:   "logout",
+			Short: "Logout from the service",
 			Run: func(cmd *cobra.Command, args []string) {
-				login := args[0]
-				password := readPassword("Enter password: ")
-
-				ctx := context.Background()
-				err := clientApp.Login(ctx, login, password)
+				err := clientApp.Logout()
 				if err != nil {
-					fmt.Printf("Login failed: %v\n", err)
+					fmt.Printf("Logout failed: %v\n", err)
 					return
 				}
 
-				fmt.Printf("Successfully logged in as %s\n", login)
+				fmt.Println("Successfully logged out")
 			},
 		},
 		&cobra.Command{
-			Use:   "logout",
-			Short: "Logout from the service",
+			Use:   "change-password",
+			Short: "Change the master password",
 			Run: func(cmd *cobra.Command, args []string) {
-				err := clientApp.Logout()
-				if err != nil {
-					fmt.Printf("Logout failed: %v\n", err)
+				oldPassword := ReadPassword("Enter current password: ")
+				newPassword := ReadPassword("Enter new password: ")
+				confirmPassword := ReadPassword("Confirm new password: ")
+
+				if newPassword != confirmPassword {
+					fmt.Println("Error: Passwords do not match")
 					return
 				}
 
-				fmt.Println("Successfully logged out")
+				ctx := context.Background()
+				if err := clientApp.ChangePassword(ctx, oldPassword, newPassword); err != nil {
+					fmt.Printf("Password change failed: %v\n", err)
+					return
+				}
+
+				fmt.Println("Password changed successfully. Other devices have to log in again.")
 			},
 		},
 		&cobra.Command{
 			Use:   "status",
 			Short: "Show current authentication status",
 			Run: func(cmd *cobra.Command, args []string) {
+				if clientApp.IsLocked() {
+					fmt.Println("Status: Locked (run 'gophkeeper unlock')")
+					return
+				}
+
 				session, err := clientApp.GetSession()
 				if err != nil || session == nil || session.AccessToken == "" {
 					fmt.Println("Status: Not authenticated")
@@ -92,12 +129,119 @@ func NewAuthCommand(clientApp *app.Client) *cobra.Command {
 				fmt.Printf("Last sync: %s\n", time.Unix(session.LastSync, 0).Format(time.RFC3339))
 			},
 		},
+		&cobra.Command{
+			Use:   "sessions",
+			Short: "List active sessions on all devices",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				sessions, err := clientApp.ListSessions(ctx)
+				if err != nil {
+					fmt.Printf("Failed to list sessions: %v\n", err)
+					return
+				}
+
+				if len(sessions) == 0 {
+					fmt.Println("No active sessions found")
+					return
+				}
+
+				fmt.Printf("Found %d active sessions:\n", len(sessions))
+				for _, session := range sessions {
+					marker := ""
+					if session.Current {
+						marker = " (current)"
+					}
+					fmt.Printf("  %s%s\n", session.ID, marker)
+					fmt.Printf("    device: %s, client: %s, ip: %s\n",
+						valueOrUnknown(session.DeviceName),
+						valueOrUnknown(session.ClientVersion),
+						valueOrUnknown(session.IPAddress))
+					fmt.Printf("    last used: %s, logged in: %s\n",
+						session.LastUsedAt.Format("2006-01-02 15:04:05"),
+						session.CreatedAt.Format("2006-01-02 15:04:05"))
+				}
+			},
+		},
+		&cobra.Command{
+			Use:   "revoke [session-id]",
+			Short: "Revoke a session on another device",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				if err := clientApp.RevokeSession(ctx, args[0]); err != nil {
+					fmt.Printf("Failed to revoke session: %v\n", err)
+					return
+				}
+
+				fmt.Printf("Session %s revoked\n", args[0])
+			},
+		},
+		newTwoFactorCommand(clientApp),
 	)
 
 	return authCmd
 }
 
-func readPassword(prompt string) string {
+// newTwoFactorCommand создает команды управления двухфакторной аутентификацией
+func newTwoFactorCommand(clientApp *app.Client) *cobra.Command {
+	twoFactorCmd := &cobra.Command{
+		Use:   "2fa",
+		Short: "Manage two-factor authentication",
+	}
+
+	twoFactorCmd.AddCommand(
+		&cobra.Command{
+			Use:   "enable",
+			Short: "Enable two-factor authentication with an authenticator app",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				enrollment, err := clientApp.EnableTOTP(ctx)
+				if err != nil {
+					fmt.Printf("Failed to enable 2FA: %v\n", err)
+					return
+				}
+
+				fmt.Println("Add this account to your authenticator app:")
+				fmt.Printf("  %s\n", enrollment.URI)
+				fmt.Printf("Or enter the secret manually: %s\n", enrollment.Secret)
+
+				code := readLine("Enter the code from the app: ")
+				recoveryCodes, err := clientApp.ConfirmTOTP(ctx, code)
+				if err != nil {
+					fmt.Printf("Failed to enable 2FA: %v\n", err)
+					return
+				}
+
+				fmt.Println("Two-factor authentication enabled.")
+				fmt.Println("Store these recovery codes in a safe place, each of them can be used once:")
+				for _, recoveryCode := range recoveryCodes {
+					fmt.Printf("  %s\n", recoveryCode)
+				}
+			},
+		},
+		&cobra.Command{
+			Use:   "disable",
+			Short: "Disable two-factor authentication",
+			Run: func(cmd *cobra.Command, args []string) {
+				password := ReadPassword("Enter password: ")
+				code := readLine("Enter two-factor code (or recovery code): ")
+
+				ctx := context.Background()
+				if err := clientApp.DisableTOTP(ctx, password, code); err != nil {
+					fmt.Printf("Failed to disable 2FA: %v\n", err)
+					return
+				}
+
+				fmt.Println("Two-factor authentication disabled")
+			},
+		},
+	)
+
+	return twoFactorCmd
+}
+
+// ReadPassword запрашивает пароль без отображения ввода в терминале
+func ReadPassword(prompt string) string {
 	fmt.Print(prompt)
 	password, err := term.ReadPassword(int(syscall.Stdin))
 	fmt.Println()
@@ -108,3 +252,17 @@ func readPassword(prompt string) string {
 	}
 	return string(password)
 }
+
+func readLine(prompt string) string {
+	fmt.Print(prompt)
+	reader := bufio.NewReader(os.Stdin)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
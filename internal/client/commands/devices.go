@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+)
+
+// NewDevicesCommand создает команды управления зарегистрированными устройствами
+func NewDevicesCommand(clientApp *app.Client) *cobra.Command {
+	devicesCmd := &cobra.Command{
+		Use:   "devices",
+		Short: "Manage registered devices",
+	}
+
+	devicesCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List devices registered to the account",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				devices, err := clientApp.ListDevices(ctx)
+				if err != nil {
+					fmt.Printf("Failed to list devices: %v\n", err)
+					return
+				}
+
+				if len(devices) == 0 {
+					fmt.Println("No registered devices found")
+					return
+				}
+
+				fmt.Printf("Found %d devices:\n", len(devices))
+				for _, device := range devices {
+					marker := ""
+					if device.Current {
+						marker = " (current)"
+					}
+					state := "approved"
+					if !device.Approved {
+						state = "waiting for approval"
+					}
+					fmt.Printf("  %s%s\n", device.ID, marker)
+					fmt.Printf("    name: %s, client: %s, %s\n",
+						valueOrUnknown(device.Name),
+						valueOrUnknown(device.ClientVersion),
+						state)
+					fmt.Printf("    last seen: %s, registered: %s\n",
+						device.LastSeenAt.Format("2006-01-02 15:04:05"),
+						device.CreatedAt.Format("2006-01-02 15:04:05"))
+				}
+			},
+		},
+		&cobra.Command{
+			Use:   "rename [device-id] [name]",
+			Short: "Rename a device",
+			Args:  cobra.MinimumNArgs(2),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				name := strings.Join(args[1:], " ")
+				if err := clientApp.RenameDevice(ctx, args[0], name); err != nil {
+					fmt.Printf("Failed to rename device: %v\n", err)
+					return
+				}
+
+				fmt.Printf("Device %s renamed to %q\n", args[0], name)
+			},
+		},
+		&cobra.Command{
+			Use:   "remove [device-id]",
+			Short: "Remove a device and end all its sessions",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				if err := clientApp.RemoveDevice(ctx, args[0]); err != nil {
+					fmt.Printf("Failed to remove device: %v\n", err)
+					return
+				}
+
+				fmt.Printf("Device %s removed\n", args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "approve [device-id]",
+			Short: "Allow a new device to sync secrets (run on an approved device)",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				if err := clientApp.ApproveDevice(ctx, args[0]); err != nil {
+					fmt.Printf("Failed to approve device: %v\n", err)
+					return
+				}
+
+				fmt.Printf("Device %s approved\n", args[0])
+			},
+		},
+	)
+
+	return devicesCmd
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+)
+
+// NewLockCommand создает команду блокировки локального хранилища
+func NewLockCommand(clientApp *app.Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lock",
+		Short: "Lock the local vault",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := clientApp.Lock(); err != nil {
+				fmt.Printf("Lock failed: %v\n", err)
+				return
+			}
+
+			fmt.Println("Vault locked")
+		},
+	}
+}
+
+// NewUnlockCommand создает команду разблокировки локального хранилища
+func NewUnlockCommand(clientApp *app.Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the local vault with the master password",
+		Run: func(cmd *cobra.Command, args []string) {
+			password := ReadPassword("Enter password: ")
+
+			if err := clientApp.Unlock(password); err != nil {
+				fmt.Printf("Unlock failed: %v\n", err)
+				return
+			}
+
+			fmt.Println("Vault unlocked")
+		},
+	}
+}
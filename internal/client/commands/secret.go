@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -68,6 +69,7 @@ func NewSecretsCommand(clientApp *app.Client) *cobra.Command {
 				fmt.Printf("ID: %s\n", secret.ID)
 				fmt.Printf("Created: %s\n", secret.CreatedAt.Format("2006-01-02 15:04:05"))
 				fmt.Printf("Updated: %s\n", secret.UpdatedAt.Format("2006-01-02 15:04:05"))
+				printMetadata(secret.Metadata)
 				fmt.Printf("Data: %+v\n", secret.Data)
 			},
 		},
@@ -82,8 +84,9 @@ func NewSecretsCommand(clientApp *app.Client) *cobra.Command {
 				notes, _ := cmd.Flags().GetString("notes")
 
 				secretData := &domain.SecretData{
-					Type: domain.SecretTypeLoginPassword,
-					Name: name,
+					Type:     domain.SecretTypeLoginPassword,
+					Name:     name,
+					Metadata: metadataFromFlags(cmd),
 					Data: domain.LoginPasswordData{
 						Login:    login,
 						Password: password,
@@ -110,8 +113,9 @@ func NewSecretsCommand(clientApp *app.Client) *cobra.Command {
 				name, content := args[0], args[1]
 
 				secretData := &domain.SecretData{
-					Type: domain.SecretTypeText,
-					Name: name,
+					Type:     domain.SecretTypeText,
+					Name:     name,
+					Metadata: metadataFromFlags(cmd),
 					Data: domain.TextData{
 						Content: content,
 					},
@@ -137,8 +141,9 @@ func NewSecretsCommand(clientApp *app.Client) *cobra.Command {
 				bankName, _ := cmd.Flags().GetString("bank")
 
 				secretData := &domain.SecretData{
-					Type: domain.SecretTypeBankCard,
-					Name: name,
+					Type:     domain.SecretTypeBankCard,
+					Name:     name,
+					Metadata: metadataFromFlags(cmd),
 					Data: domain.BankCardData{
 						CardHolder: cardholder,
 						CardNumber: number,
@@ -191,8 +196,9 @@ func NewSecretsCommand(clientApp *app.Client) *cobra.Command {
 				description, _ := cmd.Flags().GetString("description")
 
 				secretData := &domain.SecretData{
-					Type: domain.SecretTypeBinary,
-					Name: name,
+					Type:     domain.SecretTypeBinary,
+					Name:     name,
+					Metadata: metadataFromFlags(cmd),
 					Data: domain.BinaryData{
 						Data:        data,
 						Description: description,
@@ -219,5 +225,55 @@ func NewSecretsCommand(clientApp *app.Client) *cobra.Command {
 	createCardCmd := secretsCmd.Commands()[4]
 	createCardCmd.Flags().String("bank", "", "Bank name")
 
+	for _, cmd := range secretsCmd.Commands() {
+		if strings.HasPrefix(cmd.Name(), "create-") {
+			addMetadataFlags(cmd)
+		}
+	}
+
 	return secretsCmd
 }
+
+// addMetadataFlags добавляет флаги метаданных, которые шифруются вместе с названием секрета
+func addMetadataFlags(cmd *cobra.Command) {
+	cmd.Flags().String("description", "", "Description")
+	cmd.Flags().String("category", "", "Category")
+	cmd.Flags().StringSlice("tag", nil, "Tag (can be repeated)")
+	cmd.Flags().StringToString("label", nil, "Label in key=value form (can be repeated)")
+}
+
+func metadataFromFlags(cmd *cobra.Command) *domain.SecretMetadata {
+	description, _ := cmd.Flags().GetString("description")
+	category, _ := cmd.Flags().GetString("category")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	labels, _ := cmd.Flags().GetStringToString("label")
+
+	if description == "" && category == "" && len(tags) == 0 && len(labels) == 0 {
+		return nil
+	}
+
+	return &domain.SecretMetadata{
+		Labels:      labels,
+		Description: description,
+		Category:    category,
+		Tags:        tags,
+	}
+}
+
+func printMetadata(metadata *domain.SecretMetadata) {
+	if metadata == nil {
+		return
+	}
+	if metadata.Category != "" {
+		fmt.Printf("Category: %s\n", metadata.Category)
+	}
+	if metadata.Description != "" {
+		fmt.Printf("Description: %s\n", metadata.Description)
+	}
+	if len(metadata.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(metadata.Tags, ", "))
+	}
+	for key, value := range metadata.Labels {
+		fmt.Printf("Label: %s=%s\n", key, value)
+	}
+}
@@ -35,6 +35,21 @@ func NewSyncCommand(clientApp *app.Client) *cobra.Command {
 					fmt.Printf("    - %s\n", conflict)
 				}
 			}
+
+			if len(result.Rejected) > 0 {
+				fmt.Printf("  Rejected (failed integrity check): %d\n", len(result.Rejected))
+				for _, rejected := range result.Rejected {
+					fmt.Printf("    - %s\n", rejected)
+				}
+			}
+
+			if len(result.Warnings) > 0 {
+				fmt.Printf("\nWARNING: server state does not match the vault manifest (%d problems).\n", len(result.Warnings))
+				fmt.Printf("The server may have hidden secrets, restored old versions or modified data:\n")
+				for _, warning := range result.Warnings {
+					fmt.Printf("    ! %s\n", warning)
+				}
+			}
 		},
 	}
 
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+)
+
+// accessTokenEnv переменная окружения с токеном доступа для 'tokens fetch'
+const accessTokenEnv = "GOPHKEEPER_TOKEN"
+
+// NewTokensCommand создает команды управления токенами доступа для автоматизации
+func NewTokensCommand(clientApp *app.Client) *cobra.Command {
+	tokensCmd := &cobra.Command{
+		Use:   "tokens",
+		Short: "Manage scoped access tokens for CI and automation",
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create an access token for selected secrets",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			secretIDs, _ := cmd.Flags().GetStringSlice("secret")
+			tags, _ := cmd.Flags().GetStringSlice("tag")
+			write, _ := cmd.Flags().GetBool("write")
+			ttl, _ := cmd.Flags().GetDuration("ttl")
+
+			ctx := context.Background()
+			token, err := clientApp.CreateAccessToken(ctx, strings.Join(args, " "), app.AccessTokenScope{
+				SecretIDs: secretIDs,
+				Tags:      tags,
+				Write:     write,
+			}, ttl)
+			if err != nil {
+				fmt.Printf("Failed to create access token: %v\n", err)
+				return
+			}
+
+			fmt.Println("Access token (shown only once, store it in your CI secrets):")
+			fmt.Println(token)
+			fmt.Println("Note: the token carries a copy of the whole vault key; the server limits it to the selected secrets.")
+			if len(tags) > 0 {
+				fmt.Println("Tags were resolved to the secrets tagged now: secrets tagged later are not included.")
+			}
+		},
+	}
+	createCmd.Flags().StringSlice("secret", nil, "Secret ID (can be repeated)")
+	createCmd.Flags().StringSlice("tag", nil, "Include local secrets with this tag (can be repeated)")
+	createCmd.Flags().Bool("write", false, "Allow updating and deleting the secrets")
+	createCmd.Flags().Duration("ttl", 0, "Token lifetime (0 never expires)")
+
+	fetchCmd := &cobra.Command{
+		Use:   "fetch [secret-id]",
+		Short: "Fetch secrets with an access token (no master password needed)",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			token, _ := cmd.Flags().GetString("token")
+			if token == "" {
+				token = os.Getenv(accessTokenEnv)
+			}
+			if token == "" {
+				fmt.Printf("Access token is required: pass --token or set %s\n", accessTokenEnv)
+				os.Exit(1)
+			}
+
+			ctx := context.Background()
+			secrets, err := clientApp.FetchWithAccessToken(ctx, token)
+			if err != nil {
+				fmt.Printf("Failed to fetch secrets: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(args) == 0 {
+				for _, secret := range secrets {
+					fmt.Printf("%s [%s] - %s\n", secret.ID, secret.Type, secret.Name)
+				}
+				return
+			}
+
+			for _, secret := range secrets {
+				if secret.ID != args[0] {
+					continue
+				}
+				data, err := json.MarshalIndent(secret.Data, "", "  ")
+				if err != nil {
+					fmt.Printf("Failed to encode secret: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Println(string(data))
+				return
+			}
+			fmt.Printf("Secret %s is not available to this token\n", args[0])
+			os.Exit(1)
+		},
+	}
+	fetchCmd.Flags().String("token", "", "Access token (defaults to $"+accessTokenEnv+")")
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke [token-id]",
+		Short: "Revoke an access token and rotate the vault key it carried",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			noRotate, _ := cmd.Flags().GetBool("no-rotate")
+
+			ctx := context.Background()
+			if err := clientApp.RevokeAccessToken(ctx, args[0]); err != nil {
+				fmt.Printf("Failed to revoke access token: %v\n", err)
+				return
+			}
+			fmt.Printf("Access token %s revoked\n", args[0])
+
+			// Владелец токена мог сохранить делегированный ключ хранилища: без ротации он
+			// расшифрует любую копию секретов, которую получит в обход сервера
+			if noRotate {
+				fmt.Println("Warning: the vault key was not rotated, the token holder may still decrypt secrets with it")
+				return
+			}
+			password := ReadPassword("Enter password to rotate the vault key: ")
+			if err := rotateVaultKey(ctx, clientApp, password); err != nil {
+				fmt.Printf("Key rotation failed: %v\n", err)
+				fmt.Println("Run 'gophkeeper vault rotate-key' to finish revoking the token")
+			}
+		},
+	}
+	revokeCmd.Flags().Bool("no-rotate", false, "Do not rotate the vault key (the token holder keeps the old key)")
+
+	tokensCmd.AddCommand(
+		createCmd,
+		&cobra.Command{
+			Use:   "list",
+			Short: "List access tokens",
+			Run: func(cmd *cobra.Command, args []string) {
+				ctx := context.Background()
+				tokens, err := clientApp.ListAccessTokens(ctx)
+				if err != nil {
+					fmt.Printf("Failed to list access tokens: %v\n", err)
+					return
+				}
+
+				if len(tokens) == 0 {
+					fmt.Println("No access tokens found")
+					return
+				}
+
+				fmt.Printf("Found %d access tokens:\n", len(tokens))
+				for _, token := range tokens {
+					permission := "read"
+					if token.Write {
+						permission = "read-write"
+					}
+					fmt.Printf("  %s %s (%s)\n", token.ID, token.Name, permission)
+					fmt.Printf("    secrets: %s\n", strings.Join(token.SecretIDs, ", "))
+					if len(token.Tags) > 0 {
+						fmt.Printf("    tags: %s\n", strings.Join(token.Tags, ", "))
+					}
+					fmt.Printf("    created: %s, expires: %s, last used: %s\n",
+						token.CreatedAt.Format("2006-01-02 15:04:05"),
+						timeOrDefault(token.ExpiresAt, "never"),
+						timeOrDefault(token.LastUsedAt, "never"))
+				}
+			},
+		},
+		revokeCmd,
+		fetchCmd,
+	)
+
+	return tokensCmd
+}
+
+func timeOrDefault(t *time.Time, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
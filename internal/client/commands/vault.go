@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alisaviation/GophKeeper/internal/client/app"
+)
+
+// NewVaultCommand создает команды для управления ключом хранилища
+func NewVaultCommand(clientApp *app.Client) *cobra.Command {
+	vaultCmd := &cobra.Command{
+		Use:   "vault",
+		Short: "Vault key management commands",
+	}
+
+	vaultCmd.AddCommand(
+		&cobra.Command{
+			Use:   "rotate-key",
+			Short: "Replace the vault key and re-encrypt all secrets",
+			Run: func(cmd *cobra.Command, args []string) {
+				password := ReadPassword("Enter password: ")
+
+				ctx := context.Background()
+				if err := rotateVaultKey(ctx, clientApp, password); err != nil {
+					fmt.Printf("Key rotation failed: %v\n", err)
+				}
+			},
+		},
+	)
+
+	return vaultCmd
+}
+
+// rotateVaultKey выполняет ротацию ключа хранилища и печатает ее итог
+func rotateVaultKey(ctx context.Context, clientApp *app.Client, password string) error {
+	result, err := clientApp.RotateVaultKey(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Vault key rotated, %d secrets re-encrypted. Other devices have to log in again.\n", result.Secrets)
+	if result.RevokedAccessTokens > 0 {
+		fmt.Printf("%d access tokens held the old vault key and were revoked, create new ones with 'tokens create'\n", result.RevokedAccessTokens)
+	}
+	return nil
+}
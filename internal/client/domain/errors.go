@@ -0,0 +1,14 @@
+package domain
+
+import "errors"
+
+var (
+	// ErrVaultLocked локальное хранилище заблокировано, нужен мастер-пароль
+	ErrVaultLocked = errors.New("local vault is locked")
+	// ErrVaultNotInitialized локальное хранилище еще не создано (не было входа на этом устройстве)
+	ErrVaultNotInitialized = errors.New("local vault is not initialized")
+	// ErrVaultExists локальное хранилище уже создано и не может быть заменено новым
+	ErrVaultExists = errors.New("local vault already exists")
+	// ErrWrongPassword пароль не подходит к локальному хранилищу
+	ErrWrongPassword = errors.New("wrong password")
+)
@@ -18,16 +18,25 @@ const (
 
 // SecretData локальное представление секрета
 type SecretData struct {
-	ID        string      `json:"id"`
-	UserID    string      `json:"user_id"`
-	Type      SecretType  `json:"type"`
-	Name      string      `json:"name"`
-	Data      interface{} `json:"data"`
-	Version   int64       `json:"version"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
-	IsDirty   bool        `json:"is_dirty"`
-	IsDeleted bool        `json:"is_deleted"`
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	Type      SecretType      `json:"type"`
+	Name      string          `json:"name"`
+	Metadata  *SecretMetadata `json:"metadata,omitempty"`
+	Data      interface{}     `json:"data"`
+	Version   int64           `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	IsDirty   bool            `json:"is_dirty"`
+	IsDeleted bool            `json:"is_deleted"`
+}
+
+// SecretMetadata дополнительная информация о секрете; на сервер передается только в зашифрованном виде
+type SecretMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Category    string            `json:"category,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
 }
 
 // LoginPasswordData данные логина/пароля
@@ -61,13 +70,48 @@ type BinaryData struct {
 
 // Session сессия пользователя
 type Session struct {
-	UserID          string `json:"user_id"`
-	Login           string `json:"login"`
-	AccessToken     string `json:"access_token"`
-	RefreshToken    string `json:"refresh_token"`
-	LastSync        int64  `json:"last_sync"`
-	LastSyncVersion int64  `json:"last_sync_version"`
-	EncryptionKey   []byte `json:"encryption_key"`
+	UserID          string     `json:"user_id"`
+	Login           string     `json:"login"`
+	AccessToken     string     `json:"access_token"`
+	RefreshToken    string     `json:"refresh_token"`
+	LastSync        int64      `json:"last_sync"`
+	LastSyncVersion int64      `json:"last_sync_version"`
+	EncryptionKey   []byte     `json:"encryption_key"`
+	KDF             *KDFParams `json:"kdf,omitempty"`
+	// Manifest последняя проверенная или записанная этим устройством опись хранилища
+	Manifest *VaultManifest `json:"manifest,omitempty"`
+}
+
+// VaultManifest опись хранилища: версии и хеши шифротекстов всех секретов.
+// По ней клиент замечает, что сервер скрыл секрет, вернул его прежнюю версию или подменил содержимое.
+type VaultManifest struct {
+	Sequence int64                    `json:"sequence"`
+	Entries  map[string]ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry запись описи об одном секрете
+type ManifestEntry struct {
+	Version int64  `json:"version"`
+	Hash    []byte `json:"hash,omitempty"` // SHA-256 зашифрованных данных и метаданных
+	Deleted bool   `json:"deleted,omitempty"`
+	// TokenWritable секрет может изменять токен доступа с правом записи, который не обновляет
+	// опись, поэтому новые версии секрета не считаются подменой
+	TokenWritable bool `json:"token_writable,omitempty"`
+}
+
+// Device учетные данные устройства, выданные сервером при первой регистрации установки клиента
+type Device struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// KDFParams параметры получения мастер-ключа из пароля, выданные сервером
+type KDFParams struct {
+	Algorithm   string `json:"algorithm"`
+	Salt        []byte `json:"salt"`
+	Iterations  uint32 `json:"iterations"`
+	Memory      uint32 `json:"memory"`
+	Parallelism uint8  `json:"parallelism"`
 }
 
 // GenerateID генерирует уникальный ID
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoRuntimeDir каталог времени выполнения пользователя не задан: ключ негде хранить вне диска
+var ErrNoRuntimeDir = errors.New("XDG_RUNTIME_DIR is not set, start 'gophkeeper agent' to keep the vault unlocked")
+
+// KeyCache хранит локальный ключ разблокированного хранилища между запусками клиента
+type KeyCache interface {
+	// Load возвращает ключ или nil, если хранилище заблокировано
+	Load() []byte
+	Save(key []byte) error
+	Clear() error
+}
+
+// FileKeyCache хранит ключ в файле в каталоге времени выполнения пользователя
+// (XDG_RUNTIME_DIR, в памяти и только для него), а не рядом с хранилищем. Без
+// XDG_RUNTIME_DIR кеш не сохраняет ключ. Простой проверяется только при следующем
+// обращении, поэтому кеш — запасной вариант на случай, когда агент запустить не удалось.
+type FileKeyCache struct {
+	path    string
+	timeout time.Duration
+}
+
+// cachedKey содержимое файла кеша
+type cachedKey struct {
+	Key      []byte `json:"key"`
+	LastUsed int64  `json:"last_used"`
+}
+
+// NewFileKeyCache создает кеш ключа для хранилища в каталоге basePath.
+// Нулевой timeout отключает автоматическую блокировку.
+func NewFileKeyCache(basePath string, timeout time.Duration) *FileKeyCache {
+	cache := &FileKeyCache{timeout: timeout}
+	if os.Getenv("XDG_RUNTIME_DIR") != "" {
+		cache.path = RuntimePath(basePath, ".key")
+	}
+	return cache
+}
+
+// Load возвращает ключ и отмечает использование хранилища.
+// Если ключа нет или простой превысил timeout, возвращает nil.
+func (c *FileKeyCache) Load() []byte {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil
+	}
+
+	var cached cachedKey
+	if err := json.Unmarshal(data, &cached); err != nil || len(cached.Key) == 0 {
+		_ = c.Clear()
+		return nil
+	}
+
+	if c.timeout > 0 && time.Since(time.Unix(cached.LastUsed, 0)) > c.timeout {
+		_ = c.Clear()
+		return nil
+	}
+
+	// Ошибка записи лишь приблизит автоматическую блокировку
+	_ = c.Save(cached.Key)
+	return cached.Key
+}
+
+// Save сохраняет ключ разблокированного хранилища
+func (c *FileKeyCache) Save(key []byte) error {
+	if c.path == "" {
+		return ErrNoRuntimeDir
+	}
+	if err := EnsurePrivateDir(filepath.Dir(c.path)); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cachedKey{Key: key, LastUsed: time.Now().Unix()})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// Clear удаляет ключ, блокируя хранилище
+func (c *FileKeyCache) Clear() error {
+	if c.path == "" {
+		return nil
+	}
+	return removeIfExists(c.path)
+}
+
+// RuntimePath возвращает путь к файлу в каталоге времени выполнения, уникальный для хранилища basePath
+func RuntimePath(basePath, ext string) string {
+	absPath, err := filepath.Abs(basePath)
+	if err != nil {
+		absPath = basePath
+	}
+	sum := sha256.Sum256([]byte(absPath))
+
+	return filepath.Join(runtimeDir(), hex.EncodeToString(sum[:8])+ext)
+}
+
+// runtimeDir возвращает каталог для файлов, которые не должны переживать перезагрузку.
+// Каталог во временном каталоге системы подходит только для сокета агента: ключ там не хранится.
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gophkeeper")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gophkeeper-%d", os.Getuid()))
+}
+
+// EnsurePrivateDir создает каталог, доступный только владельцу, и отказывается
+// использовать существующий каталог с более широкими правами или чужой каталог,
+// заранее созданный другим пользователем в общем временном каталоге
+func EnsurePrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("runtime directory %s must be a directory accessible only by its owner", dir)
+	}
+	if !ownedByCurrentUser(info) {
+		return fmt.Errorf("runtime directory %s is owned by another user", dir)
+	}
+	return nil
+}
@@ -7,22 +7,138 @@ import (
 	"path/filepath"
 
 	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 )
 
-// FileStorage файловое хранилище
+// Имена файлов хранилища
+const (
+	keyringFile = "vault.json"
+	sessionFile = "session.json"
+	secretsFile = "secrets.json"
+	deviceFile  = "device.json"
+)
+
+// orphanedSuffix суффикс файлов, зашифрованных ключом без защищенной копии
+const orphanedSuffix = ".orphaned"
+
+// FileStorage файловое хранилище. Сессия и секреты шифруются локальным ключом,
+// который открывается мастер-паролем; до разблокировки файлы недоступны.
 type FileStorage struct {
 	basePath string
+	key      []byte
+	cache    KeyCache
 }
 
-// NewFileStorage создает новое файловое хранилище
-func NewFileStorage(basePath string) (*FileStorage, error) {
+// NewFileStorage создает новое файловое хранилище. Ключ разблокированного хранилища
+// сохраняется в cache и используется следующими запусками клиента; без кеша
+// хранилище разблокировано только до завершения процесса.
+func NewFileStorage(basePath string, cache KeyCache) (*FileStorage, error) {
 	if err := os.MkdirAll(basePath, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	return &FileStorage{
+	s := &FileStorage{
 		basePath: basePath,
-	}, nil
+		cache:    cache,
+	}
+	if s.initialized() && s.cache != nil {
+		s.key = s.cache.Load()
+	}
+
+	return s, nil
+}
+
+// Unlock открывает хранилище мастер-паролем
+func (s *FileStorage) Unlock(password string) error {
+	k, err := readKeyring(s.path(keyringFile))
+	if err != nil {
+		return err
+	}
+
+	key, err := k.open(password)
+	if err != nil {
+		return err
+	}
+
+	s.key = key
+	return s.cacheKey()
+}
+
+// InitVault создает новый локальный ключ, защищенный паролем. Существующее хранилище не
+// заменяется. Файлы, записанные прежними версиями клиента в открытом виде, шифруются; файлы,
+// зашифрованные ключом, защищенная копия которого утеряна, откладываются с суффиксом .orphaned.
+func (s *FileStorage) InitVault(password string) error {
+	if s.initialized() {
+		return domain.ErrVaultExists
+	}
+
+	key, err := crypto.GenerateVaultKey()
+	if err != nil {
+		return err
+	}
+
+	k, err := newKeyring(password, key)
+	if err != nil {
+		return err
+	}
+
+	legacy := make(map[string][]byte)
+	for _, name := range []string{sessionFile, secretsFile, deviceFile} {
+		data, err := os.ReadFile(s.path(name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if isEncryptedFile(data) {
+			if err := os.Rename(s.path(name), s.path(name+orphanedSuffix)); err != nil {
+				return fmt.Errorf("failed to set aside %s: %w", name, err)
+			}
+			continue
+		}
+		legacy[name] = data
+	}
+
+	if err := writeKeyring(s.path(keyringFile), k); err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	s.key = key
+
+	for name, data := range legacy {
+		if err := s.writeFile(name, data); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", name, err)
+		}
+	}
+
+	return s.cacheKey()
+}
+
+// ChangeVaultPassword защищает локальный ключ новым паролем
+func (s *FileStorage) ChangeVaultPassword(password string) error {
+	if s.key == nil {
+		return domain.ErrVaultLocked
+	}
+
+	k, err := newKeyring(password, s.key)
+	if err != nil {
+		return err
+	}
+	return writeKeyring(s.path(keyringFile), k)
+}
+
+// Lock блокирует хранилище до следующей разблокировки паролем
+func (s *FileStorage) Lock() error {
+	s.key = nil
+	if s.cache == nil {
+		return nil
+	}
+	return s.cache.Clear()
+}
+
+// IsLocked сообщает, что хранилище создано, но не разблокировано
+func (s *FileStorage) IsLocked() bool {
+	return s.initialized() && s.key == nil
 }
 
 // SaveSession сохраняет сессию
@@ -31,16 +147,13 @@ func (s *FileStorage) SaveSession(session *domain.Session) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.sessionPath(), data, 0600)
+	return s.writeFile(sessionFile, data)
 }
 
 // GetSession получает сессию
 func (s *FileStorage) GetSession() (*domain.Session, error) {
-	data, err := os.ReadFile(s.sessionPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
+	data, err := s.readFile(sessionFile)
+	if err != nil || data == nil {
 		return nil, err
 	}
 
@@ -54,7 +167,32 @@ func (s *FileStorage) GetSession() (*domain.Session, error) {
 
 // DeleteSession удаляет сессию
 func (s *FileStorage) DeleteSession() error {
-	return os.Remove(s.sessionPath())
+	return os.Remove(s.path(sessionFile))
+}
+
+// SaveDevice сохраняет учетные данные устройства. В отличие от сессии, они переживают выход:
+// при следующем входе сервер узнает то же устройство.
+func (s *FileStorage) SaveDevice(device *domain.Device) error {
+	data, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(deviceFile, data)
+}
+
+// GetDevice получает учетные данные устройства или nil, если устройство еще не зарегистрировано
+func (s *FileStorage) GetDevice() (*domain.Device, error) {
+	data, err := s.readFile(deviceFile)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var device domain.Device
+	if err := json.Unmarshal(data, &device); err != nil {
+		return nil, err
+	}
+
+	return &device, nil
 }
 
 // SaveSecret сохраняет секрет
@@ -81,7 +219,7 @@ func (s *FileStorage) SaveSecret(secret *domain.SecretData) error {
 		return err
 	}
 
-	return os.WriteFile(s.secretsPath(), data, 0600)
+	return s.writeFile(secretsFile, data)
 }
 
 // GetSecret получает секрет по ID
@@ -102,13 +240,13 @@ func (s *FileStorage) GetSecret(id string) (*domain.SecretData, error) {
 
 // GetSecrets получает все секреты
 func (s *FileStorage) GetSecrets() ([]*domain.SecretData, error) {
-	data, err := os.ReadFile(s.secretsPath())
+	data, err := s.readFile(secretsFile)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []*domain.SecretData{}, nil
-		}
 		return nil, err
 	}
+	if data == nil {
+		return []*domain.SecretData{}, nil
+	}
 
 	var secrets []*domain.SecretData
 	if err := json.Unmarshal(data, &secrets); err != nil {
@@ -118,10 +256,57 @@ func (s *FileStorage) GetSecrets() ([]*domain.SecretData, error) {
 	return secrets, nil
 }
 
-func (s *FileStorage) sessionPath() string {
-	return filepath.Join(s.basePath, "session.json")
+// readFile читает и расшифровывает файл хранилища. Возвращает nil без ошибки, если файла нет
+// или хранилище еще не создано: файлы прежних версий клиента шифруются при первом входе.
+func (s *FileStorage) readFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !s.initialized() {
+		return nil, nil
+	}
+	if s.key == nil {
+		return nil, domain.ErrVaultLocked
+	}
+
+	return openFile(s.key, name, data)
+}
+
+// writeFile шифрует и записывает файл хранилища
+func (s *FileStorage) writeFile(name string, data []byte) error {
+	if s.key == nil {
+		return domain.ErrVaultLocked
+	}
+
+	sealed, err := sealFile(s.key, name, data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(name), sealed)
+}
+
+// cacheKey сохраняет ключ разблокированного хранилища для следующих запусков клиента
+func (s *FileStorage) cacheKey() error {
+	if s.cache == nil {
+		return nil
+	}
+	if err := s.cache.Save(s.key); err != nil {
+		return fmt.Errorf("failed to cache unlocked key: %w", err)
+	}
+	return nil
+}
+
+// initialized сообщает, что локальный ключ хранилища уже создан
+func (s *FileStorage) initialized() bool {
+	_, err := os.Stat(s.path(keyringFile))
+	return err == nil
 }
 
-func (s *FileStorage) secretsPath() string {
-	return filepath.Join(s.basePath, "secrets.json")
+func (s *FileStorage) path(name string) string {
+	return filepath.Join(s.basePath, name)
 }
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+)
+
+func newTestStorage(t *testing.T, dir string, autoLock time.Duration) *FileStorage {
+	t.Helper()
+
+	s, err := NewFileStorage(dir, NewFileKeyCache(dir, autoLock))
+	require.NoError(t, err)
+	return s
+}
+
+func TestFileStorage_EncryptedAtRest(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	dir := t.TempDir()
+
+	s := newTestStorage(t, dir, time.Hour)
+	assert.False(t, s.IsLocked())
+	assert.ErrorIs(t, s.Unlock("password"), domain.ErrVaultNotInitialized)
+	assert.ErrorIs(t, s.SaveSession(&domain.Session{}), domain.ErrVaultLocked)
+
+	require.NoError(t, s.InitVault("password"))
+	require.NoError(t, s.SaveSession(&domain.Session{UserID: "user123", AccessToken: "secret-token"}))
+	require.NoError(t, s.SaveSecret(&domain.SecretData{ID: "s1", Name: "secret-name"}))
+
+	for _, name := range []string{sessionFile, secretsFile} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "secret-")
+		assert.NotContains(t, string(data), "user123")
+	}
+
+	// Следующий запуск клиента остается разблокированным
+	s = newTestStorage(t, dir, time.Hour)
+	session, err := s.GetSession()
+	require.NoError(t, err)
+	assert.Equal(t, "secret-token", session.AccessToken)
+
+	require.NoError(t, s.Lock())
+	assert.True(t, s.IsLocked())
+	_, err = s.GetSession()
+	assert.ErrorIs(t, err, domain.ErrVaultLocked)
+	_, err = s.GetSecrets()
+	assert.ErrorIs(t, err, domain.ErrVaultLocked)
+
+	s = newTestStorage(t, dir, time.Hour)
+	assert.True(t, s.IsLocked())
+	assert.ErrorIs(t, s.Unlock("wrong"), domain.ErrWrongPassword)
+	require.NoError(t, s.Unlock("password"))
+
+	secret, err := s.GetSecret("s1")
+	require.NoError(t, err)
+	assert.Equal(t, "secret-name", secret.Name)
+}
+
+func TestFileStorage_ChangeVaultPassword(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	dir := t.TempDir()
+
+	s := newTestStorage(t, dir, time.Hour)
+	require.NoError(t, s.InitVault("old-password"))
+	require.NoError(t, s.SaveSession(&domain.Session{UserID: "user123"}))
+	require.NoError(t, s.ChangeVaultPassword("new-password"))
+	require.NoError(t, s.Lock())
+
+	assert.ErrorIs(t, s.Unlock("old-password"), domain.ErrWrongPassword)
+	require.NoError(t, s.Unlock("new-password"))
+	session, err := s.GetSession()
+	require.NoError(t, err)
+	assert.Equal(t, "user123", session.UserID)
+
+	// Существующее хранилище не заменяется новым
+	assert.ErrorIs(t, s.InitVault("other-password"), domain.ErrVaultExists)
+	session, err = s.GetSession()
+	require.NoError(t, err)
+	assert.Equal(t, "user123", session.UserID)
+}
+
+func TestFileStorage_InitVaultSetsAsideOrphanedFiles(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	dir := t.TempDir()
+
+	s := newTestStorage(t, dir, time.Hour)
+	require.NoError(t, s.InitVault("password"))
+	require.NoError(t, s.SaveDevice(&domain.Device{ID: "device1"}))
+	require.NoError(t, os.Remove(filepath.Join(dir, keyringFile)))
+
+	s = newTestStorage(t, dir, time.Hour)
+	require.NoError(t, s.InitVault("password"))
+
+	device, err := s.GetDevice()
+	require.NoError(t, err)
+	assert.Nil(t, device)
+
+	data, err := os.ReadFile(filepath.Join(dir, deviceFile+orphanedSuffix))
+	require.NoError(t, err)
+	assert.True(t, isEncryptedFile(data))
+}
+
+func TestFileStorage_MigratesPlaintextFiles(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	dir := t.TempDir()
+
+	legacy := `[{"id":"s1","name":"legacy","is_dirty":true}]`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, secretsFile), []byte(legacy), 0600))
+
+	s := newTestStorage(t, dir, time.Hour)
+	secrets, err := s.GetSecrets()
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+
+	require.NoError(t, s.InitVault("password"))
+
+	data, err := os.ReadFile(filepath.Join(dir, secretsFile))
+	require.NoError(t, err)
+	assert.True(t, isEncryptedFile(data))
+
+	secrets, err = s.GetSecrets()
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "legacy", secrets[0].Name)
+	assert.True(t, secrets[0].IsDirty)
+}
+
+func TestFileStorage_AutoLock(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	dir := t.TempDir()
+
+	s := newTestStorage(t, dir, time.Minute)
+	require.NoError(t, s.InitVault("password"))
+
+	// Имитируем простой дольше таймаута
+	stale, err := json.Marshal(cachedKey{Key: s.key, LastUsed: time.Now().Add(-2 * time.Minute).Unix()})
+	require.NoError(t, err)
+	cachePath := NewFileKeyCache(dir, time.Minute).path
+	require.NoError(t, os.WriteFile(cachePath, stale, 0600))
+
+	s = newTestStorage(t, dir, time.Minute)
+	assert.True(t, s.IsLocked())
+	_, err = os.Stat(cachePath)
+	assert.True(t, os.IsNotExist(err))
+
+	// Без таймаута хранилище не блокируется автоматически
+	require.NoError(t, s.Unlock("password"))
+	require.NoError(t, os.WriteFile(cachePath, stale, 0600))
+	s = newTestStorage(t, dir, 0)
+	assert.False(t, s.IsLocked())
+}
+
+func TestFileKeyCache_RequiresRuntimeDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	cache := NewFileKeyCache(t.TempDir(), time.Minute)
+	assert.ErrorIs(t, cache.Save([]byte("key")), ErrNoRuntimeDir)
+	assert.Nil(t, cache.Load())
+	assert.NoError(t, cache.Clear())
+}
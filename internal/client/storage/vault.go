@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alisaviation/GophKeeper/internal/client/domain"
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+// encryptedFileMagic заголовок зашифрованного файла; файлы без него записаны
+// прежними версиями клиента в открытом виде
+var encryptedFileMagic = []byte("GKV1")
+
+// keyringVersion версия формата файла с защищенным локальным ключом
+const keyringVersion = 1
+
+// keyring защищенный локальный ключ. Файлы хранилища шифруются случайным локальным ключом,
+// а он сам — ключом, полученным из мастер-пароля, поэтому смена пароля не требует
+// перешифрования файлов.
+type keyring struct {
+	Version    int               `json:"version"`
+	KDF        *domain.KDFParams `json:"kdf"`
+	WrappedKey []byte            `json:"wrapped_key"`
+}
+
+// newKeyring защищает локальный ключ паролем с новой солью
+func newKeyring(password string, localKey []byte) (*keyring, error) {
+	params := crypto.DefaultKDFParams()
+	salt, err := crypto.GenerateSalt(crypto.SaltSize)
+	if err != nil {
+		return nil, err
+	}
+	params.Salt = salt
+
+	passwordKey, err := crypto.DeriveKey(password, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	wrapped, err := crypto.WrapVaultKey(passwordKey, localKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to protect local key: %w", err)
+	}
+
+	return &keyring{
+		Version: keyringVersion,
+		KDF: &domain.KDFParams{
+			Algorithm:   params.Algorithm,
+			Salt:        params.Salt,
+			Iterations:  params.Iterations,
+			Memory:      params.Memory,
+			Parallelism: params.Parallelism,
+		},
+		WrappedKey: wrapped,
+	}, nil
+}
+
+// open расшифровывает локальный ключ паролем
+func (k *keyring) open(password string) ([]byte, error) {
+	if k.Version != keyringVersion || k.KDF == nil {
+		return nil, fmt.Errorf("unsupported keyring version %d", k.Version)
+	}
+
+	passwordKey, err := crypto.DeriveKey(password, crypto.KDFParams{
+		Algorithm:   k.KDF.Algorithm,
+		Salt:        k.KDF.Salt,
+		Iterations:  k.KDF.Iterations,
+		Memory:      k.KDF.Memory,
+		Parallelism: k.KDF.Parallelism,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	localKey, err := crypto.UnwrapVaultKey(passwordKey, k.WrappedKey)
+	if err != nil {
+		return nil, domain.ErrWrongPassword
+	}
+	return localKey, nil
+}
+
+// readKeyring читает защищенный локальный ключ
+func readKeyring(path string) (*keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, domain.ErrVaultNotInitialized
+		}
+		return nil, err
+	}
+
+	var k keyring
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	return &k, nil
+}
+
+// writeKeyring сохраняет защищенный локальный ключ
+func writeKeyring(path string, k *keyring) error {
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// sealFile шифрует содержимое файла; имя файла аутентифицируется вместе с ним,
+// чтобы файлы нельзя было незаметно поменять местами
+func sealFile(localKey []byte, name string, plaintext []byte) ([]byte, error) {
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(localKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := encryptor.Encrypt(plaintext, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", name, err)
+	}
+	return append(append([]byte{}, encryptedFileMagic...), ciphertext...), nil
+}
+
+// openFile расшифровывает содержимое файла
+func openFile(localKey []byte, name string, data []byte) ([]byte, error) {
+	if !isEncryptedFile(data) {
+		return nil, fmt.Errorf("%s is not encrypted", name)
+	}
+
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(localKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := encryptor.Decrypt(data[len(encryptedFileMagic):], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", name, err)
+	}
+	return plaintext, nil
+}
+
+func isEncryptedFile(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedFileMagic)
+}
+
+// writeFileAtomic записывает файл через временный, чтобы сбой не оставил его обрезанным
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeIfExists удаляет файл, если он существует
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -2,9 +2,15 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
+	"os"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	grpc2 "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 )
@@ -13,13 +19,23 @@ import (
 type GRPCClient struct {
 	authClient   grpc2.AuthServiceClient
 	secretClient grpc2.SecretServiceClient
+	adminClient  grpc2.AdminServiceClient
 	conn         *grpc.ClientConn
 	token        string
 }
 
-// NewGRPCClient создает новый gRPC клиент
-func NewGRPCClient(serverAddr string) (*GRPCClient, error) {
-	conn, err := grpc.Dial(serverAddr, grpc.WithInsecure())
+// NewGRPCClient создает новый gRPC клиент. Без tlsConfig соединение не шифруется.
+// Имя устройства и версия клиента передаются серверу с каждым запросом и отображаются в списке сессий.
+func NewGRPCClient(serverAddr, clientVersion string, tlsConfig *tls.Config) (*GRPCClient, error) {
+	creds := grpc.WithInsecure()
+	if tlsConfig != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	device := deviceName()
+	conn, err := grpc.Dial(serverAddr, creds,
+		grpc.WithUnaryInterceptor(clientInfoInterceptor(device, clientVersion)),
+		grpc.WithStreamInterceptor(clientInfoStreamInterceptor(device, clientVersion)))
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +43,7 @@ func NewGRPCClient(serverAddr string) (*GRPCClient, error) {
 	return &GRPCClient{
 		authClient:   grpc2.NewAuthServiceClient(conn),
 		secretClient: grpc2.NewSecretServiceClient(conn),
+		adminClient:  grpc2.NewAdminServiceClient(conn),
 		conn:         conn,
 	}, nil
 }
@@ -37,27 +54,141 @@ func (c *GRPCClient) SetToken(token string) {
 }
 
 // Register регистрирует нового пользователя
-func (c *GRPCClient) Register(ctx context.Context, login, password string) (string, error) {
-	resp, err := c.authClient.Register(ctx, &grpc2.RegisterRequest{
+func (c *GRPCClient) Register(ctx context.Context, login, password string) (*grpc2.RegisterResponse, error) {
+	return c.authClient.Register(ctx, &grpc2.RegisterRequest{
 		Login:    login,
 		Password: password,
 	})
+}
+
+// Login выполняет вход. Код 2FA передается, только если она включена.
+func (c *GRPCClient) Login(ctx context.Context, login, password, totpCode string) (*grpc2.LoginResponse, error) {
+	return c.authClient.Login(ctx, &grpc2.LoginRequest{
+		Login:    login,
+		Password: password,
+		TotpCode: totpCode,
+	})
+}
+
+// SetProtectedVaultKey сохраняет на сервере зашифрованный ключ хранилища
+func (c *GRPCClient) SetProtectedVaultKey(ctx context.Context, protectedKey []byte) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.SetProtectedVaultKey(ctx, &grpc2.SetProtectedVaultKeyRequest{
+		ProtectedKey: protectedKey,
+	})
+	return err
+}
+
+// GetProtectedVaultKey получает с сервера зашифрованный ключ хранилища.
+// Возвращает nil без ошибки, если ключ еще не сохранен.
+func (c *GRPCClient) GetProtectedVaultKey(ctx context.Context) ([]byte, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.authClient.GetProtectedVaultKey(ctx, &grpc2.GetProtectedVaultKeyRequest{})
 	if err != nil {
-		return "", err
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
 	}
-	return resp.GetUserId(), nil
+	return resp.GetProtectedKey(), nil
 }
 
-// Login выполняет вход
-func (c *GRPCClient) Login(ctx context.Context, login, password string) (string, string, string, error) {
-	resp, err := c.authClient.Login(ctx, &grpc2.LoginRequest{
-		Login:    login,
+// SRPRegister регистрирует нового пользователя по верификатору SRP
+func (c *GRPCClient) SRPRegister(ctx context.Context, login string, kdf *grpc2.KDFParams, srp *grpc2.SRPVerifier) (*grpc2.SRPRegisterResponse, error) {
+	return c.authClient.SRPRegister(ctx, &grpc2.SRPRegisterRequest{
+		Login: login,
+		Kdf:   kdf,
+		Srp:   srp,
+	})
+}
+
+// SRPStart начинает вход по SRP
+func (c *GRPCClient) SRPStart(ctx context.Context, login string, clientEphemeral []byte) (*grpc2.SRPStartResponse, error) {
+	return c.authClient.SRPStart(ctx, &grpc2.SRPStartRequest{
+		Login:           login,
+		ClientEphemeral: clientEphemeral,
+	})
+}
+
+// SRPLogin завершает вход по SRP
+func (c *GRPCClient) SRPLogin(ctx context.Context, handshakeID string, clientProof []byte, totpCode string) (*grpc2.SRPLoginResponse, error) {
+	return c.authClient.SRPLogin(ctx, &grpc2.SRPLoginRequest{
+		HandshakeId: handshakeID,
+		ClientProof: clientProof,
+		TotpCode:    totpCode,
+	})
+}
+
+// EnrollSRP переводит текущего пользователя на вход по SRP
+func (c *GRPCClient) EnrollSRP(ctx context.Context, password string, srp *grpc2.SRPVerifier) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.EnrollSRP(ctx, &grpc2.EnrollSRPRequest{
 		Password: password,
+		Srp:      srp,
+	})
+	return err
+}
+
+// EnableTOTP начинает подключение двухфакторной аутентификации
+func (c *GRPCClient) EnableTOTP(ctx context.Context) (*grpc2.EnableTOTPResponse, error) {
+	ctx = c.createAuthContext(ctx)
+	return c.authClient.EnableTOTP(ctx, &grpc2.EnableTOTPRequest{})
+}
+
+// ConfirmTOTP подтверждает подключение 2FA кодом из приложения и возвращает коды восстановления
+func (c *GRPCClient) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.authClient.ConfirmTOTP(ctx, &grpc2.ConfirmTOTPRequest{
+		Code: code,
 	})
 	if err != nil {
-		return "", "", "", err
+		return nil, err
+	}
+	return resp.GetRecoveryCodes(), nil
+}
+
+// DisableTOTP отключает двухфакторную аутентификацию
+func (c *GRPCClient) DisableTOTP(ctx context.Context, req *grpc2.DisableTOTPRequest) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.DisableTOTP(ctx, req)
+	return err
+}
+
+// ChangePassword меняет мастер-пароль и сохраняет перешифрованный ключ хранилища
+func (c *GRPCClient) ChangePassword(ctx context.Context, req *grpc2.ChangePasswordRequest) (*grpc2.ChangePasswordResponse, error) {
+	ctx = c.createAuthContext(ctx)
+	return c.authClient.ChangePassword(ctx, req)
+}
+
+// DeleteAccount удаляет учетную запись текущего пользователя
+func (c *GRPCClient) DeleteAccount(ctx context.Context, req *grpc2.DeleteAccountRequest) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.DeleteAccount(ctx, req)
+	return err
+}
+
+// ExportAccount получает все записи учетной записи и передает их handle по мере получения
+func (c *GRPCClient) ExportAccount(ctx context.Context, handle func(*grpc2.AccountRecord) error) error {
+	ctx, cancel := context.WithCancel(c.createAuthContext(ctx))
+	defer cancel()
+
+	stream, err := c.authClient.ExportAccount(ctx, &grpc2.ExportAccountRequest{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(record); err != nil {
+			return err
+		}
 	}
-	return resp.GetAccessToken(), resp.GetRefreshToken(), resp.GetUserId(), nil
 }
 
 // RefreshToken обновляет токены
@@ -89,6 +220,19 @@ func (c *GRPCClient) Sync(ctx context.Context, userID string, lastSyncVersion in
 	})
 }
 
+// SyncAtomic синхронизирует данные одной транзакцией: при конфликте сервер не применяет ни одного изменения.
+// Вместе с секретами можно передать новый защищенный ключ хранилища.
+func (c *GRPCClient) SyncAtomic(ctx context.Context, userID string, lastSyncVersion int64, secrets []*grpc2.Secret, protectedVaultKey []byte) (*grpc2.SyncResponse, error) {
+	ctx = c.createAuthContext(ctx)
+	return c.secretClient.Sync(ctx, &grpc2.SyncRequest{
+		UserId:            userID,
+		LastSyncVersion:   lastSyncVersion,
+		Secrets:           secrets,
+		Atomic:            true,
+		ProtectedVaultKey: protectedVaultKey,
+	})
+}
+
 // GetSecret получает секрет по ID
 func (c *GRPCClient) GetSecret(ctx context.Context, secretID string) (*grpc2.Secret, error) {
 	ctx = c.createAuthContext(ctx)
@@ -114,6 +258,26 @@ func (c *GRPCClient) ListSecrets(ctx context.Context, userID string, filterType
 	return resp.GetSecrets(), nil
 }
 
+// GetVaultManifest возвращает опись хранилища и ее версию на сервере
+func (c *GRPCClient) GetVaultManifest(ctx context.Context) ([]byte, int64, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.secretClient.GetVaultManifest(ctx, &grpc2.GetVaultManifestRequest{})
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.GetManifest(), resp.GetVersion(), nil
+}
+
+// PutVaultManifest сохраняет опись хранилища следующей версии
+func (c *GRPCClient) PutVaultManifest(ctx context.Context, manifest []byte, version int64) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.secretClient.PutVaultManifest(ctx, &grpc2.PutVaultManifestRequest{
+		Manifest: manifest,
+		Version:  version,
+	})
+	return err
+}
+
 // UpdateSecret обновляет секрет
 func (c *GRPCClient) UpdateSecret(ctx context.Context, secret *grpc2.Secret) error {
 	ctx = c.createAuthContext(ctx)
@@ -132,6 +296,161 @@ func (c *GRPCClient) DeleteSecret(ctx context.Context, secretID string) error {
 	return err
 }
 
+// ListSessions получает список активных сессий пользователя
+func (c *GRPCClient) ListSessions(ctx context.Context) ([]*grpc2.Session, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.authClient.ListSessions(ctx, &grpc2.ListSessionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSessions(), nil
+}
+
+// RevokeSession завершает сессию по ID
+func (c *GRPCClient) RevokeSession(ctx context.Context, sessionID string) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.RevokeSession(ctx, &grpc2.RevokeSessionRequest{
+		SessionId: sessionID,
+	})
+	return err
+}
+
+// RegisterDevice привязывает текущий вход к устройству
+func (c *GRPCClient) RegisterDevice(ctx context.Context, req *grpc2.RegisterDeviceRequest) (*grpc2.RegisterDeviceResponse, error) {
+	ctx = c.createAuthContext(ctx)
+	return c.authClient.RegisterDevice(ctx, req)
+}
+
+// ListDevices получает список устройств пользователя
+func (c *GRPCClient) ListDevices(ctx context.Context) ([]*grpc2.Device, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.authClient.ListDevices(ctx, &grpc2.ListDevicesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetDevices(), nil
+}
+
+// RenameDevice меняет имя устройства
+func (c *GRPCClient) RenameDevice(ctx context.Context, deviceID, name string) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.RenameDevice(ctx, &grpc2.RenameDeviceRequest{
+		DeviceId: deviceID,
+		Name:     name,
+	})
+	return err
+}
+
+// RemoveDevice удаляет устройство и завершает его сессии
+func (c *GRPCClient) RemoveDevice(ctx context.Context, deviceID string) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.RemoveDevice(ctx, &grpc2.RemoveDeviceRequest{
+		DeviceId: deviceID,
+	})
+	return err
+}
+
+// ApproveDevice одобряет новое устройство
+func (c *GRPCClient) ApproveDevice(ctx context.Context, deviceID string) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.ApproveDevice(ctx, &grpc2.ApproveDeviceRequest{
+		DeviceId: deviceID,
+	})
+	return err
+}
+
+// CreateAccessToken выпускает токен доступа для автоматизации
+func (c *GRPCClient) CreateAccessToken(ctx context.Context, req *grpc2.CreateAccessTokenRequest) (*grpc2.CreateAccessTokenResponse, error) {
+	ctx = c.createAuthContext(ctx)
+	return c.authClient.CreateAccessToken(ctx, req)
+}
+
+// ListAccessTokens получает список токенов доступа пользователя
+func (c *GRPCClient) ListAccessTokens(ctx context.Context) ([]*grpc2.AccessToken, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.authClient.ListAccessTokens(ctx, &grpc2.ListAccessTokensRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetAccessTokens(), nil
+}
+
+// RevokeAccessToken отзывает токен доступа
+func (c *GRPCClient) RevokeAccessToken(ctx context.Context, tokenID string) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.authClient.RevokeAccessToken(ctx, &grpc2.RevokeAccessTokenRequest{
+		TokenId: tokenID,
+	})
+	return err
+}
+
+// ListUsers ищет пользователей по подстроке логина; доступно только администраторам
+func (c *GRPCClient) ListUsers(ctx context.Context, query string, limit, offset int32) ([]*grpc2.AdminUser, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.adminClient.ListUsers(ctx, &grpc2.ListUsersRequest{
+		Query:  query,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetUsers(), nil
+}
+
+// GetUserStats получает сводку по учетной записи пользователя
+func (c *GRPCClient) GetUserStats(ctx context.Context, login string) (*grpc2.GetUserStatsResponse, error) {
+	ctx = c.createAuthContext(ctx)
+	return c.adminClient.GetUserStats(ctx, &grpc2.GetUserStatsRequest{
+		Login: login,
+	})
+}
+
+// SetUserDisabled блокирует или разблокирует учетную запись пользователя
+func (c *GRPCClient) SetUserDisabled(ctx context.Context, login string, disabled bool) (*grpc2.AdminUser, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.adminClient.SetUserDisabled(ctx, &grpc2.SetUserDisabledRequest{
+		Login:    login,
+		Disabled: disabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetUser(), nil
+}
+
+// ForceLogout завершает все сессии пользователя
+func (c *GRPCClient) ForceLogout(ctx context.Context, login string, revokeAccessTokens bool) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.adminClient.ForceLogout(ctx, &grpc2.ForceLogoutRequest{
+		Login:              login,
+		RevokeAccessTokens: revokeAccessTokens,
+	})
+	return err
+}
+
+// ClearLoginLockout снимает блокировку входа по логину и (или) адресу клиента
+func (c *GRPCClient) ClearLoginLockout(ctx context.Context, login, address string) error {
+	ctx = c.createAuthContext(ctx)
+	_, err := c.adminClient.ClearLoginLockout(ctx, &grpc2.ClearLoginLockoutRequest{
+		Login:   login,
+		Address: address,
+	})
+	return err
+}
+
+// RunMaintenance запускает задачу обслуживания базы и возвращает число удаленных записей
+func (c *GRPCClient) RunMaintenance(ctx context.Context, job grpc2.MaintenanceJob) (int64, error) {
+	ctx = c.createAuthContext(ctx)
+	resp, err := c.adminClient.RunMaintenance(ctx, &grpc2.RunMaintenanceRequest{
+		Job: job,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetRemoved(), nil
+}
+
 // Close закрывает соединение
 func (c *GRPCClient) Close() error {
 	return c.conn.Close()
@@ -144,3 +463,33 @@ func (c *GRPCClient) createAuthContext(ctx context.Context) context.Context {
 	}
 	return metadata.NewOutgoingContext(ctx, metadata.Pairs("authorization", "Bearer "+c.token))
 }
+
+// clientInfoInterceptor добавляет к каждому запросу имя устройства и версию клиента
+func clientInfoInterceptor(device, version string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withClientInfo(ctx, device, version), method, req, reply, cc, opts...)
+	}
+}
+
+// clientInfoStreamInterceptor добавляет имя устройства и версию клиента к потоковым вызовам
+func clientInfoStreamInterceptor(device, version string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withClientInfo(ctx, device, version), desc, cc, method, opts...)
+	}
+}
+
+func withClientInfo(ctx context.Context, device, version string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx,
+		"x-device-name", device,
+		"x-client-version", version,
+	)
+}
+
+// deviceName возвращает имя устройства для списка сессий
+func deviceName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
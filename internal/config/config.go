@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,9 +18,10 @@ func SetClientConfig() ClientConfig {
 	var configFile string
 
 	defaultConfig := ClientConfig{
-		ServerAddress: "localhost:8080",
-		StoragePath:   getDefaultStoragePath(),
-		AutoSync:      true,
+		ServerAddress:   "localhost:8080",
+		StoragePath:     getDefaultStoragePath(),
+		AutoSync:        true,
+		AutoLockTimeout: 15 * time.Minute,
 	}
 
 	config = defaultConfig
@@ -62,11 +65,45 @@ func SetServerConfig() ServerConfig {
 	dbMaxConns := flag.Int("db-max-conns", 25, "Database max connections")
 	dbMaxIdleTime := flag.String("db-max-idle-time", "1m", "Database max idle time")
 
-	jwtSecret := flag.String("jwt-secret", "default-jwt-secret-key-change-in-production", "JWT secret")
+	jwtSecret := flag.String("jwt-secret", "", "Legacy JWT HS256 secret")
 	jwtAccessExpiry := flag.String("jwt-access-expiry", "15m", "JWT access token expiry")
 	jwtRefreshExpiry := flag.String("jwt-refresh-expiry", "168h", "JWT refresh token expiry")
+	jwtSigningKey := flag.String("jwt-signing-key", "", "Path to a PEM Ed25519 or RSA private key for signing JWT")
+	jwtVerificationKeys := flag.String("jwt-verification-keys", "", "Comma-separated paths to PEM public keys of previous JWT signing keys")
 
 	encryptionKey := flag.String("encryption-key", "", "Encryption key")
+	encryptionKeyFile := flag.String("encryption-key-file", "", "Path to a file with a hex or base64 encoded encryption key")
+	encryptionKeyEnv := flag.String("encryption-key-env", "", "Environment variable with a hex or base64 encoded encryption key")
+	encryptionKeyCommand := flag.String("encryption-key-command", "", "Command that prints a hex or base64 encoded encryption key")
+	rejectUnencrypted := flag.Bool("reject-unencrypted-secrets", false, "Reject stored records that are not encrypted with the encryption key (enable after running encrypt-legacy)")
+
+	kdfIterations := flag.Uint("kdf-iterations", 3, "Argon2id iterations for new users")
+	kdfMemory := flag.Uint("kdf-memory", 64*1024, "Argon2id memory in KiB for new users")
+	kdfParallelism := flag.Uint("kdf-parallelism", 4, "Argon2id parallelism for new users")
+
+	passwordHashIterations := flag.Uint("password-hash-iterations", 2, "Argon2id iterations for login password hashes")
+	passwordHashMemory := flag.Uint("password-hash-memory", 19*1024, "Argon2id memory in KiB for login password hashes")
+	passwordHashParallelism := flag.Uint("password-hash-parallelism", 1, "Argon2id parallelism for login password hashes")
+
+	legacyPasswordAuth := flag.Bool("legacy-password-auth", true, "Allow registration and login that send the password to the server (disable once all clients use SRP)")
+	requireDeviceApproval := flag.Bool("require-device-approval", false, "Require new devices to be approved from an already trusted device before they can sync")
+
+	loginMaxFailures := flag.Int("login-max-failures", 5, "Failed logins for one account before login is delayed (0 disables)")
+	loginMaxAddressFailures := flag.Int("login-max-address-failures", 50, "Failed logins from one client address before login is delayed (0 disables)")
+	loginLockoutBaseDelay := flag.String("login-lockout-base-delay", "1s", "Login delay after the failure threshold is reached, doubled on every further failure")
+	loginLockoutMaxDelay := flag.String("login-lockout-max-delay", "15m", "Maximum login delay (lockout duration)")
+	loginFailureWindow := flag.String("login-failure-window", "1h", "How long failed login attempts are remembered")
+
+	rateLimit := flag.String("rate-limit", defaultRateLimit, "Default per-caller rate limit as rate:burst, requests per second (0 disables)")
+	rateLimitMethods := flag.String("rate-limit-methods", defaultRateLimitMethods, "Comma-separated per-method rate limits as Method=rate:burst")
+	addressRateLimit := flag.String("address-rate-limit", defaultAddressRateLimit, "Rate limit of all calls from one client address, checked before authentication, as rate:burst (0 disables)")
+
+	tlsCert := flag.String("tls-cert", "", "Path to a PEM server certificate (enables TLS)")
+	tlsKey := flag.String("tls-key", "", "Path to a PEM server private key")
+	tlsClientCA := flag.String("tls-client-ca", "", "Path to PEM CA certificates that issue client certificates")
+	tlsCRL := flag.String("tls-crl", "", "Path to a PEM or DER CRL with revoked client certificates")
+	tlsCRLReloadInterval := flag.String("tls-crl-reload-interval", "5m", "How often the CRL file is reloaded")
+	authMode := flag.String("auth-mode", "jwt", "Client authentication: jwt, mtls, either or both")
 
 	flag.Parse()
 
@@ -86,14 +123,39 @@ func SetServerConfig() ServerConfig {
 			ConnMaxIdleTime: time.Minute,
 		},
 		JWT: JWTConfig{
-			Secret:        "default-jwt-secret-key-change-in-production",
+			Secret:        "",
 			AccessExpiry:  15 * time.Minute,
 			RefreshExpiry: 7 * 24 * time.Hour,
 		},
 		Encryption: EncryptionConfig{
 			Key: "",
 		},
+		KDF: KDFConfig{
+			Iterations:  3,
+			Memory:      64 * 1024,
+			Parallelism: 4,
+		},
+		PasswordHash: PasswordHashConfig{
+			Iterations:  2,
+			Memory:      19 * 1024,
+			Parallelism: 1,
+		},
+		LegacyPasswordAuth: true,
+		LoginLockout: LoginLockoutConfig{
+			MaxFailures:        5,
+			MaxAddressFailures: 50,
+			BaseDelay:          time.Second,
+			MaxDelay:           15 * time.Minute,
+			FailureWindow:      time.Hour,
+		},
+		TLS: TLSConfig{
+			CRLReloadInterval: 5 * time.Minute,
+		},
+		AuthMode: "jwt",
 	}
+	defaultConfig.RateLimit.Default = parseRateLimit(defaultRateLimit, RateLimit{})
+	defaultConfig.RateLimit.Methods = parseRateLimitMethods(defaultRateLimitMethods)
+	defaultConfig.RateLimit.Address = parseRateLimit(defaultAddressRateLimit, RateLimit{})
 
 	config = defaultConfig
 
@@ -125,8 +187,66 @@ func SetServerConfig() ServerConfig {
 	if *jwtRefreshExpiry != "" {
 		config.JWT.RefreshExpiry = parseDuration(*jwtRefreshExpiry, config.JWT.RefreshExpiry)
 	}
+	if *jwtSigningKey != "" {
+		config.JWT.SigningKeyFile = *jwtSigningKey
+	}
+	if *jwtVerificationKeys != "" {
+		config.JWT.VerificationKeyFiles = splitList(*jwtVerificationKeys)
+	}
 
 	config.Encryption.Key = *encryptionKey
+	config.Encryption.KeyFile = *encryptionKeyFile
+	config.Encryption.KeyEnv = *encryptionKeyEnv
+	config.Encryption.KeyCommand = *encryptionKeyCommand
+	config.Encryption.RejectUnencrypted = *rejectUnencrypted
+
+	if fitsUint("kdf-iterations", *kdfIterations, 32) {
+		config.KDF.Iterations = uint32(*kdfIterations)
+	}
+	if fitsUint("kdf-memory", *kdfMemory, 32) {
+		config.KDF.Memory = uint32(*kdfMemory)
+	}
+	if fitsUint("kdf-parallelism", *kdfParallelism, 8) {
+		config.KDF.Parallelism = uint8(*kdfParallelism)
+	}
+
+	if fitsUint("password-hash-iterations", *passwordHashIterations, 32) {
+		config.PasswordHash.Iterations = uint32(*passwordHashIterations)
+	}
+	if fitsUint("password-hash-memory", *passwordHashMemory, 32) {
+		config.PasswordHash.Memory = uint32(*passwordHashMemory)
+	}
+	if fitsUint("password-hash-parallelism", *passwordHashParallelism, 8) {
+		config.PasswordHash.Parallelism = uint8(*passwordHashParallelism)
+	}
+
+	config.LegacyPasswordAuth = *legacyPasswordAuth
+	config.RequireDeviceApproval = *requireDeviceApproval
+
+	config.LoginLockout.MaxFailures = *loginMaxFailures
+	config.LoginLockout.MaxAddressFailures = *loginMaxAddressFailures
+	config.LoginLockout.BaseDelay = parseDuration(*loginLockoutBaseDelay, config.LoginLockout.BaseDelay)
+	config.LoginLockout.MaxDelay = parseDuration(*loginLockoutMaxDelay, config.LoginLockout.MaxDelay)
+	config.LoginLockout.FailureWindow = parseDuration(*loginFailureWindow, config.LoginLockout.FailureWindow)
+
+	config.RateLimit.Default = parseRateLimit(*rateLimit, config.RateLimit.Default)
+	config.RateLimit.Methods = parseRateLimitMethods(*rateLimitMethods)
+	config.RateLimit.Address = parseRateLimit(*addressRateLimit, config.RateLimit.Address)
+
+	if *tlsCert != "" {
+		config.TLS.CertFile = *tlsCert
+	}
+	if *tlsKey != "" {
+		config.TLS.KeyFile = *tlsKey
+	}
+	if *tlsClientCA != "" {
+		config.TLS.ClientCAFile = *tlsClientCA
+	}
+	if *tlsCRL != "" {
+		config.TLS.CRLFile = *tlsCRL
+	}
+	config.TLS.CRLReloadInterval = parseDuration(*tlsCRLReloadInterval, config.TLS.CRLReloadInterval)
+	config.AuthMode = *authMode
 
 	applyEnvToServer(&config)
 
@@ -148,6 +268,27 @@ func getDefaultStoragePath() string {
 	return configDir + "/gophkeeper"
 }
 
+// fitsUint reports whether a numeric flag value fits into an unsigned integer of the given bit size,
+// warning that the default is kept otherwise
+func fitsUint(name string, value uint, bits int) bool {
+	if uint64(value)>>bits == 0 {
+		return true
+	}
+	fmt.Printf("Warning: %s %d is out of range, using default\n", name, value)
+	return false
+}
+
+// parseUint parses an unsigned integer of the given bit size, warning that the default is kept
+// when the value is invalid or out of range
+func parseUint(name, value string, bits int) (uint64, bool) {
+	parsed, err := strconv.ParseUint(value, 10, bits)
+	if err != nil {
+		fmt.Printf("Warning: invalid %s '%s', using default\n", name, value)
+		return 0, false
+	}
+	return parsed, true
+}
+
 func parseDuration(value string, defaultValue time.Duration) time.Duration {
 	if value == "" {
 		return defaultValue
@@ -168,6 +309,24 @@ func applyFileConfigToClient(config *ClientConfig, fileConfig FileConfig) {
 		config.StoragePath = fileConfig.StoragePath
 	}
 	config.AutoSync = fileConfig.AutoSync
+	if fileConfig.AutoLockTimeout != "" {
+		config.AutoLockTimeout = parseDuration(fileConfig.AutoLockTimeout, config.AutoLockTimeout)
+	}
+	if fileConfig.TLS {
+		config.TLS.Enabled = true
+	}
+	if fileConfig.TLSCAFile != "" {
+		config.TLS.CAFile = fileConfig.TLSCAFile
+	}
+	if fileConfig.TLSClientCertFile != "" {
+		config.TLS.CertFile = fileConfig.TLSClientCertFile
+	}
+	if fileConfig.TLSClientKeyFile != "" {
+		config.TLS.KeyFile = fileConfig.TLSClientKeyFile
+	}
+	if fileConfig.RejectLegacyCiphertexts {
+		config.RejectLegacyCiphertexts = true
+	}
 }
 
 func applyFileConfigToServer(config *ServerConfig, fileConfig FileConfig) {
@@ -216,10 +375,90 @@ func applyFileConfigToServer(config *ServerConfig, fileConfig FileConfig) {
 	if fileConfig.JWTRefreshExpiry != "" {
 		config.JWT.RefreshExpiry = parseDuration(fileConfig.JWTRefreshExpiry, config.JWT.RefreshExpiry)
 	}
+	if fileConfig.JWTSigningKey != "" {
+		config.JWT.SigningKeyFile = fileConfig.JWTSigningKey
+	}
+	if len(fileConfig.JWTVerificationKeys) > 0 {
+		config.JWT.VerificationKeyFiles = fileConfig.JWTVerificationKeys
+	}
 
 	if fileConfig.EncryptionKey != "" {
 		config.Encryption.Key = fileConfig.EncryptionKey
 	}
+	if fileConfig.EncryptionKeyFile != "" {
+		config.Encryption.KeyFile = fileConfig.EncryptionKeyFile
+	}
+	if fileConfig.EncryptionKeyEnv != "" {
+		config.Encryption.KeyEnv = fileConfig.EncryptionKeyEnv
+	}
+	if fileConfig.EncryptionKeyCommand != "" {
+		config.Encryption.KeyCommand = fileConfig.EncryptionKeyCommand
+	}
+
+	if fileConfig.KDFIterations != 0 {
+		config.KDF.Iterations = fileConfig.KDFIterations
+	}
+	if fileConfig.KDFMemory != 0 {
+		config.KDF.Memory = fileConfig.KDFMemory
+	}
+	if fileConfig.KDFParallelism != 0 {
+		config.KDF.Parallelism = fileConfig.KDFParallelism
+	}
+
+	if fileConfig.PasswordHashIterations != 0 {
+		config.PasswordHash.Iterations = fileConfig.PasswordHashIterations
+	}
+	if fileConfig.PasswordHashMemory != 0 {
+		config.PasswordHash.Memory = fileConfig.PasswordHashMemory
+	}
+	if fileConfig.PasswordHashParallelism != 0 {
+		config.PasswordHash.Parallelism = fileConfig.PasswordHashParallelism
+	}
+
+	if fileConfig.LoginMaxFailures != 0 {
+		config.LoginLockout.MaxFailures = fileConfig.LoginMaxFailures
+	}
+	if fileConfig.LoginMaxAddressFailures != 0 {
+		config.LoginLockout.MaxAddressFailures = fileConfig.LoginMaxAddressFailures
+	}
+	if fileConfig.LoginLockoutBaseDelay != "" {
+		config.LoginLockout.BaseDelay = parseDuration(fileConfig.LoginLockoutBaseDelay, config.LoginLockout.BaseDelay)
+	}
+	if fileConfig.LoginLockoutMaxDelay != "" {
+		config.LoginLockout.MaxDelay = parseDuration(fileConfig.LoginLockoutMaxDelay, config.LoginLockout.MaxDelay)
+	}
+	if fileConfig.LoginFailureWindow != "" {
+		config.LoginLockout.FailureWindow = parseDuration(fileConfig.LoginFailureWindow, config.LoginLockout.FailureWindow)
+	}
+
+	if fileConfig.RateLimit != "" {
+		config.RateLimit.Default = parseRateLimit(fileConfig.RateLimit, config.RateLimit.Default)
+	}
+	if fileConfig.RateLimitMethods != "" {
+		config.RateLimit.Methods = parseRateLimitMethods(fileConfig.RateLimitMethods)
+	}
+	if fileConfig.AddressRateLimit != "" {
+		config.RateLimit.Address = parseRateLimit(fileConfig.AddressRateLimit, config.RateLimit.Address)
+	}
+
+	if fileConfig.TLSCertFile != "" {
+		config.TLS.CertFile = fileConfig.TLSCertFile
+	}
+	if fileConfig.TLSKeyFile != "" {
+		config.TLS.KeyFile = fileConfig.TLSKeyFile
+	}
+	if fileConfig.TLSClientCAFile != "" {
+		config.TLS.ClientCAFile = fileConfig.TLSClientCAFile
+	}
+	if fileConfig.TLSCRLFile != "" {
+		config.TLS.CRLFile = fileConfig.TLSCRLFile
+	}
+	if fileConfig.TLSCRLReloadInterval != "" {
+		config.TLS.CRLReloadInterval = parseDuration(fileConfig.TLSCRLReloadInterval, config.TLS.CRLReloadInterval)
+	}
+	if fileConfig.AuthMode != "" {
+		config.AuthMode = fileConfig.AuthMode
+	}
 }
 
 func applyEnvToClient(config *ClientConfig) {
@@ -234,6 +473,28 @@ func applyEnvToClient(config *ClientConfig) {
 			config.AutoSync = autoSync
 		}
 	}
+	if envAutoLockTimeout, exists := os.LookupEnv("AUTO_LOCK_TIMEOUT"); exists {
+		config.AutoLockTimeout = parseDuration(envAutoLockTimeout, config.AutoLockTimeout)
+	}
+	if envTLS, exists := os.LookupEnv("TLS"); exists {
+		if enabled, err := strconv.ParseBool(envTLS); err == nil {
+			config.TLS.Enabled = enabled
+		}
+	}
+	if envCAFile, exists := os.LookupEnv("TLS_CA_FILE"); exists {
+		config.TLS.CAFile = envCAFile
+	}
+	if envCertFile, exists := os.LookupEnv("TLS_CLIENT_CERT_FILE"); exists {
+		config.TLS.CertFile = envCertFile
+	}
+	if envKeyFile, exists := os.LookupEnv("TLS_CLIENT_KEY_FILE"); exists {
+		config.TLS.KeyFile = envKeyFile
+	}
+	if envRejectLegacy, exists := os.LookupEnv("REJECT_LEGACY_CIPHERTEXTS"); exists {
+		if reject, err := strconv.ParseBool(envRejectLegacy); err == nil {
+			config.RejectLegacyCiphertexts = reject
+		}
+	}
 }
 
 func applyEnvToServer(config *ServerConfig) {
@@ -284,10 +545,181 @@ func applyEnvToServer(config *ServerConfig) {
 	if envJWTRefreshExpiry, exists := os.LookupEnv("JWT_REFRESH_EXPIRY"); exists {
 		config.JWT.RefreshExpiry = parseDuration(envJWTRefreshExpiry, config.JWT.RefreshExpiry)
 	}
+	if envJWTSigningKey, exists := os.LookupEnv("JWT_SIGNING_KEY"); exists {
+		config.JWT.SigningKeyFile = envJWTSigningKey
+	}
+	if envJWTVerificationKeys, exists := os.LookupEnv("JWT_VERIFICATION_KEYS"); exists {
+		config.JWT.VerificationKeyFiles = splitList(envJWTVerificationKeys)
+	}
 
 	if envEncryptionKey, exists := os.LookupEnv("ENCRYPTION_KEY"); exists {
 		config.Encryption.Key = envEncryptionKey
 	}
+	if envEncryptionKeyFile, exists := os.LookupEnv("ENCRYPTION_KEY_FILE"); exists {
+		config.Encryption.KeyFile = envEncryptionKeyFile
+	}
+	if envEncryptionKeyEnv, exists := os.LookupEnv("ENCRYPTION_KEY_ENV"); exists {
+		config.Encryption.KeyEnv = envEncryptionKeyEnv
+	}
+	if envEncryptionKeyCommand, exists := os.LookupEnv("ENCRYPTION_KEY_COMMAND"); exists {
+		config.Encryption.KeyCommand = envEncryptionKeyCommand
+	}
+	if envRejectUnencrypted, exists := os.LookupEnv("REJECT_UNENCRYPTED_SECRETS"); exists {
+		if reject, err := strconv.ParseBool(envRejectUnencrypted); err == nil {
+			config.Encryption.RejectUnencrypted = reject
+		}
+	}
+
+	if envKDFIterations, exists := os.LookupEnv("KDF_ITERATIONS"); exists {
+		if iterations, ok := parseUint("KDF_ITERATIONS", envKDFIterations, 32); ok {
+			config.KDF.Iterations = uint32(iterations)
+		}
+	}
+	if envKDFMemory, exists := os.LookupEnv("KDF_MEMORY"); exists {
+		if memory, ok := parseUint("KDF_MEMORY", envKDFMemory, 32); ok {
+			config.KDF.Memory = uint32(memory)
+		}
+	}
+	if envKDFParallelism, exists := os.LookupEnv("KDF_PARALLELISM"); exists {
+		if parallelism, ok := parseUint("KDF_PARALLELISM", envKDFParallelism, 8); ok {
+			config.KDF.Parallelism = uint8(parallelism)
+		}
+	}
+
+	if envIterations, exists := os.LookupEnv("PASSWORD_HASH_ITERATIONS"); exists {
+		if iterations, ok := parseUint("PASSWORD_HASH_ITERATIONS", envIterations, 32); ok {
+			config.PasswordHash.Iterations = uint32(iterations)
+		}
+	}
+	if envMemory, exists := os.LookupEnv("PASSWORD_HASH_MEMORY"); exists {
+		if memory, ok := parseUint("PASSWORD_HASH_MEMORY", envMemory, 32); ok {
+			config.PasswordHash.Memory = uint32(memory)
+		}
+	}
+	if envParallelism, exists := os.LookupEnv("PASSWORD_HASH_PARALLELISM"); exists {
+		if parallelism, ok := parseUint("PASSWORD_HASH_PARALLELISM", envParallelism, 8); ok {
+			config.PasswordHash.Parallelism = uint8(parallelism)
+		}
+	}
+
+	if envLegacyPasswordAuth, exists := os.LookupEnv("LEGACY_PASSWORD_AUTH"); exists {
+		if legacyPasswordAuth, err := strconv.ParseBool(envLegacyPasswordAuth); err == nil {
+			config.LegacyPasswordAuth = legacyPasswordAuth
+		}
+	}
+	if envRequireDeviceApproval, exists := os.LookupEnv("REQUIRE_DEVICE_APPROVAL"); exists {
+		if requireDeviceApproval, err := strconv.ParseBool(envRequireDeviceApproval); err == nil {
+			config.RequireDeviceApproval = requireDeviceApproval
+		}
+	}
+
+	if envMaxFailures, exists := os.LookupEnv("LOGIN_MAX_FAILURES"); exists {
+		if maxFailures, err := strconv.Atoi(envMaxFailures); err == nil {
+			config.LoginLockout.MaxFailures = maxFailures
+		}
+	}
+	if envMaxAddressFailures, exists := os.LookupEnv("LOGIN_MAX_ADDRESS_FAILURES"); exists {
+		if maxFailures, err := strconv.Atoi(envMaxAddressFailures); err == nil {
+			config.LoginLockout.MaxAddressFailures = maxFailures
+		}
+	}
+	if envBaseDelay, exists := os.LookupEnv("LOGIN_LOCKOUT_BASE_DELAY"); exists {
+		config.LoginLockout.BaseDelay = parseDuration(envBaseDelay, config.LoginLockout.BaseDelay)
+	}
+	if envMaxDelay, exists := os.LookupEnv("LOGIN_LOCKOUT_MAX_DELAY"); exists {
+		config.LoginLockout.MaxDelay = parseDuration(envMaxDelay, config.LoginLockout.MaxDelay)
+	}
+	if envFailureWindow, exists := os.LookupEnv("LOGIN_FAILURE_WINDOW"); exists {
+		config.LoginLockout.FailureWindow = parseDuration(envFailureWindow, config.LoginLockout.FailureWindow)
+	}
+
+	if envRateLimit, exists := os.LookupEnv("RATE_LIMIT"); exists {
+		config.RateLimit.Default = parseRateLimit(envRateLimit, config.RateLimit.Default)
+	}
+	if envRateLimitMethods, exists := os.LookupEnv("RATE_LIMIT_METHODS"); exists {
+		config.RateLimit.Methods = parseRateLimitMethods(envRateLimitMethods)
+	}
+	if envAddressRateLimit, exists := os.LookupEnv("ADDRESS_RATE_LIMIT"); exists {
+		config.RateLimit.Address = parseRateLimit(envAddressRateLimit, config.RateLimit.Address)
+	}
+
+	if envCertFile, exists := os.LookupEnv("TLS_CERT_FILE"); exists {
+		config.TLS.CertFile = envCertFile
+	}
+	if envKeyFile, exists := os.LookupEnv("TLS_KEY_FILE"); exists {
+		config.TLS.KeyFile = envKeyFile
+	}
+	if envClientCAFile, exists := os.LookupEnv("TLS_CLIENT_CA_FILE"); exists {
+		config.TLS.ClientCAFile = envClientCAFile
+	}
+	if envCRLFile, exists := os.LookupEnv("TLS_CRL_FILE"); exists {
+		config.TLS.CRLFile = envCRLFile
+	}
+	if envCRLReloadInterval, exists := os.LookupEnv("TLS_CRL_RELOAD_INTERVAL"); exists {
+		config.TLS.CRLReloadInterval = parseDuration(envCRLReloadInterval, config.TLS.CRLReloadInterval)
+	}
+	if envAuthMode, exists := os.LookupEnv("AUTH_MODE"); exists {
+		config.AuthMode = envAuthMode
+	}
+}
+
+// Default rate limits: registration and sync are the most expensive calls for the server, and
+// logins are limited as tightly to slow down password guessing. The address limit only stops floods
+// before authentication, so it leaves room for several users behind one address.
+const (
+	defaultRateLimit        = "20:40"
+	defaultRateLimitMethods = "Register=0.05:5,SRPRegister=0.05:5,Login=0.05:5,SRPStart=0.05:5,SRPLogin=0.05:5,Sync=1:10"
+	defaultAddressRateLimit = "100:200"
+)
+
+// parseRateLimit parses a rate limit in the rate:burst form; burst defaults to the rounded-up rate
+func parseRateLimit(value string, defaultValue RateLimit) RateLimit {
+	rateValue, burstValue, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	rate, err := strconv.ParseFloat(rateValue, 64)
+	if err != nil || rate < 0 {
+		fmt.Printf("Warning: invalid rate limit '%s', using default\n", value)
+		return defaultValue
+	}
+
+	limit := RateLimit{Rate: rate, Burst: int(math.Ceil(rate))}
+	if hasBurst {
+		burst, err := strconv.Atoi(burstValue)
+		if err != nil || burst < 0 {
+			fmt.Printf("Warning: invalid rate limit burst '%s', using default\n", value)
+			return defaultValue
+		}
+		limit.Burst = burst
+	}
+	return limit
+}
+
+// parseRateLimitMethods parses a comma-separated list of Method=rate:burst items, skipping invalid ones
+func parseRateLimitMethods(value string) map[string]RateLimit {
+	methods := make(map[string]RateLimit)
+	for _, item := range splitList(value) {
+		method, limitValue, ok := strings.Cut(item, "=")
+		if !ok || method == "" {
+			fmt.Printf("Warning: invalid method rate limit '%s', skipping\n", item)
+			continue
+		}
+		limit := parseRateLimit(limitValue, RateLimit{Rate: -1})
+		if limit.Rate < 0 {
+			continue
+		}
+		methods[strings.TrimSpace(method)] = limit
+	}
+	return methods
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func loadConfigFromFile(filePath string, config *FileConfig) error {
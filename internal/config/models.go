@@ -9,6 +9,21 @@ type ClientConfig struct {
 	ServerAddress string
 	StoragePath   string
 	AutoSync      bool
+	// AutoLockTimeout locks the local vault after this much inactivity; zero disables auto-lock
+	AutoLockTimeout time.Duration
+	TLS             ClientTLSConfig
+	// RejectLegacyCiphertexts refuses secrets encrypted in the legacy format without an envelope header
+	RejectLegacyCiphertexts bool
+}
+
+// ClientTLSConfig represents TLS settings of the client. TLS is used when Enabled is set or any file is
+// given; without CAFile the server certificate is verified against the system roots.
+type ClientTLSConfig struct {
+	Enabled bool
+	CAFile  string
+	// CertFile and KeyFile are the client certificate and key presented to servers that require mTLS
+	CertFile string
+	KeyFile  string
 }
 
 // ServerConfig represents configuration for GophKeeper server
@@ -18,6 +33,20 @@ type ServerConfig struct {
 	Database      DatabaseConfig
 	JWT           JWTConfig
 	Encryption    EncryptionConfig
+	KDF           KDFConfig
+	PasswordHash  PasswordHashConfig
+	// LegacyPasswordAuth keeps Register/Login/ChangePassword with the raw password enabled
+	// while clients migrate to SRP
+	LegacyPasswordAuth bool
+	// RequireDeviceApproval makes new devices wait for approval from an already trusted device
+	// before they can read secrets
+	RequireDeviceApproval bool
+	LoginLockout          LoginLockoutConfig
+	RateLimit             RateLimitConfig
+	TLS                   TLSConfig
+	// AuthMode selects how clients authenticate: jwt (bearer token), mtls (client certificate),
+	// either of them or both
+	AuthMode string
 }
 
 // DatabaseConfig represents database configuration
@@ -37,14 +66,82 @@ type DatabaseConfig struct {
 
 // JWTConfig represents JWT configuration
 type JWTConfig struct {
+	// Secret is the legacy HS256 secret; with a signing key it only verifies tokens issued before the switch
 	Secret        string
 	AccessExpiry  time.Duration
 	RefreshExpiry time.Duration
+	// SigningKeyFile is a PEM file with the Ed25519 or RSA private key used to sign tokens
+	SigningKeyFile string
+	// VerificationKeyFiles are PEM files with public keys of previous signing keys that are still accepted
+	VerificationKeyFiles []string
 }
 
 // EncryptionConfig represents encryption configuration
 type EncryptionConfig struct {
+	// Key is the raw master key; its bytes are used as is (legacy)
 	Key string
+	// KeyFile is a path to a file holding a hex or base64 encoded master key
+	KeyFile string
+	// KeyEnv is the name of an environment variable holding a hex or base64 encoded master key
+	KeyEnv string
+	// KeyCommand is an external command that prints a hex or base64 encoded master key
+	KeyCommand string
+	// RejectUnencrypted treats records stored before server-side encryption as tampered;
+	// enable it once encrypt-legacy has encrypted them
+	RejectUnencrypted bool
+}
+
+// KDFConfig represents Argon2id parameters issued to newly registered users
+type KDFConfig struct {
+	Iterations  uint32
+	Memory      uint32
+	Parallelism uint8
+}
+
+// PasswordHashConfig represents Argon2id parameters used to hash login passwords on the server.
+// Hashes made with weaker parameters or with bcrypt are rehashed on the next successful login.
+type PasswordHashConfig struct {
+	Iterations  uint32
+	Memory      uint32
+	Parallelism uint8
+}
+
+// LoginLockoutConfig represents brute-force protection of login. After MaxFailures failed attempts
+// for one login (or MaxAddressFailures from one client address) the next attempt is delayed by
+// BaseDelay, doubling with every further failure up to MaxDelay. A zero threshold disables the check.
+type LoginLockoutConfig struct {
+	MaxFailures        int
+	MaxAddressFailures int
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	// FailureWindow is how long a failed attempt is remembered
+	FailureWindow time.Duration
+}
+
+// RateLimit allows Rate requests per second on average and at most Burst in a row; zero Rate means unlimited
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig represents per-caller rate limits of gRPC methods. Methods are keyed by the full
+// ("/gophkeeper.v1.SecretService/Sync") or the short ("Sync") method name; the rest share Default.
+type RateLimitConfig struct {
+	Default RateLimit
+	Methods map[string]RateLimit
+	// Address limits all calls from one client IP address before authentication
+	Address RateLimit
+}
+
+// TLSConfig represents TLS settings of the server. Without CertFile the server accepts plaintext connections.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM file with CA certificates that issue client certificates
+	ClientCAFile string
+	// CRLFile is a PEM or DER CRL with revoked client certificates, reloaded every CRLReloadInterval
+	CRLFile           string
+	CRLReloadInterval time.Duration
 }
 
 // FileConfig represents configuration file structure
@@ -53,6 +150,15 @@ type FileConfig struct {
 	StoragePath   string `json:"storage_path"`
 	AutoSync      bool   `json:"auto_sync"`
 
+	AutoLockTimeout string `json:"auto_lock_timeout"`
+
+	TLS               bool   `json:"tls"`
+	TLSCAFile         string `json:"tls_ca_file"`
+	TLSClientCertFile string `json:"tls_client_cert_file"`
+	TLSClientKeyFile  string `json:"tls_client_key_file"`
+
+	RejectLegacyCiphertexts bool `json:"reject_legacy_ciphertexts"`
+
 	GRPCPort int `json:"grpc_port"`
 
 	DatabaseHost        string `json:"database_host"`
@@ -64,9 +170,39 @@ type FileConfig struct {
 	DatabaseMaxConns    int    `json:"database_max_conns"`
 	DatabaseMaxIdleTime string `json:"database_max_idle_time"`
 
-	JWTSecret        string `json:"jwt_secret"`
-	JWTAccessExpiry  string `json:"jwt_access_expiry"`
-	JWTRefreshExpiry string `json:"jwt_refresh_expiry"`
+	JWTSecret           string   `json:"jwt_secret"`
+	JWTAccessExpiry     string   `json:"jwt_access_expiry"`
+	JWTRefreshExpiry    string   `json:"jwt_refresh_expiry"`
+	JWTSigningKey       string   `json:"jwt_signing_key"`
+	JWTVerificationKeys []string `json:"jwt_verification_keys"`
+
+	EncryptionKey        string `json:"encryption_key"`
+	EncryptionKeyFile    string `json:"encryption_key_file"`
+	EncryptionKeyEnv     string `json:"encryption_key_env"`
+	EncryptionKeyCommand string `json:"encryption_key_command"`
+
+	KDFIterations  uint32 `json:"kdf_iterations"`
+	KDFMemory      uint32 `json:"kdf_memory"`
+	KDFParallelism uint8  `json:"kdf_parallelism"`
+
+	PasswordHashIterations  uint32 `json:"password_hash_iterations"`
+	PasswordHashMemory      uint32 `json:"password_hash_memory"`
+	PasswordHashParallelism uint8  `json:"password_hash_parallelism"`
+
+	LoginMaxFailures        int    `json:"login_max_failures"`
+	LoginMaxAddressFailures int    `json:"login_max_address_failures"`
+	LoginLockoutBaseDelay   string `json:"login_lockout_base_delay"`
+	LoginLockoutMaxDelay    string `json:"login_lockout_max_delay"`
+	LoginFailureWindow      string `json:"login_failure_window"`
+
+	RateLimit        string `json:"rate_limit"`
+	RateLimitMethods string `json:"rate_limit_methods"`
+	AddressRateLimit string `json:"address_rate_limit"`
 
-	EncryptionKey string `json:"encryption_key"`
+	TLSCertFile          string `json:"tls_cert_file"`
+	TLSKeyFile           string `json:"tls_key_file"`
+	TLSClientCAFile      string `json:"tls_client_ca_file"`
+	TLSCRLFile           string `json:"tls_crl_file"`
+	TLSCRLReloadInterval string `json:"tls_crl_reload_interval"`
+	AuthMode             string `json:"auth_mode"`
 }
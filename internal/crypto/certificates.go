@@ -0,0 +1,191 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCRLNotTrusted список отзыва подписан не одним из доверенных удостоверяющих центров
+	ErrCRLNotTrusted = errors.New("crl is not signed by a trusted CA")
+	// ErrCRLExpired срок действия списка отзыва (NextUpdate) истек: удостоверяющий центр мог
+	// отозвать сертификаты, о которых список не знает
+	ErrCRLExpired = errors.New("crl is expired")
+)
+
+// LoadCACertificates читает сертификаты удостоверяющих центров из PEM-файла
+func LoadCACertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+	return ParseCACertificates(data)
+}
+
+// ParseCACertificates разбирает один или несколько сертификатов в формате PEM
+func ParseCACertificates(pemData []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded CA certificates found")
+	}
+	return certs, nil
+}
+
+// RevocationList отозванные сертификаты клиентов из файла CRL. Файл может содержать по списку
+// на каждый удостоверяющий центр (PEM) или один список в DER. Списки принимаются, только если
+// подписаны одним из issuers и еще не истекли.
+type RevocationList struct {
+	path    string
+	issuers []*x509.Certificate
+
+	mu      sync.RWMutex
+	revoked map[string]struct{}
+	// nextUpdate самый ранний срок обновления из загруженных списков; нулевой — срок не задан
+	nextUpdate time.Time
+}
+
+// LoadRevocationList читает список отзыва из файла path и проверяет его подпись
+func LoadRevocationList(path string, issuers []*x509.Certificate) (*RevocationList, error) {
+	list := &RevocationList{path: path, issuers: issuers}
+	if err := list.Reload(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Reload перечитывает файл. Если файл не читается, подпись неверна или список истек, прежний
+// список остается в силе.
+func (l *RevocationList) Reload() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to read crl: %w", err)
+	}
+
+	revoked, nextUpdate, err := parseRevocations(data, l.issuers, time.Now())
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.revoked = revoked
+	l.nextUpdate = nextUpdate
+	l.mu.Unlock()
+	return nil
+}
+
+// Watch перечитывает файл каждые interval, пока не отменен ctx. Ошибки передаются в onError.
+func (l *RevocationList) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// IsRevoked сообщает, что сертификат отозван. Пустой список не отзывает ничего, а истекший,
+// который не удалось вовремя обновить, считает отозванными все сертификаты.
+func (l *RevocationList) IsRevoked(cert *x509.Certificate) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.nextUpdate.IsZero() && time.Now().After(l.nextUpdate) {
+		return true
+	}
+	_, revoked := l.revoked[revocationKey(cert.RawIssuer, cert.SerialNumber.String())]
+	return revoked
+}
+
+// parseRevocations разбирает списки отзыва и возвращает ключи отозванных сертификатов и самый
+// ранний срок обновления списков
+func parseRevocations(data []byte, issuers []*x509.Certificate, now time.Time) (map[string]struct{}, time.Time, error) {
+	var ders [][]byte
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			if block.Type == "X509 CRL" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+		if len(ders) == 0 {
+			return nil, time.Time{}, errors.New("no PEM encoded CRL found")
+		}
+	} else {
+		ders = append(ders, data)
+	}
+
+	revoked := make(map[string]struct{})
+	var nextUpdate time.Time
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to parse crl: %w", err)
+		}
+		if !signedByIssuer(crl, issuers) {
+			return nil, time.Time{}, ErrCRLNotTrusted
+		}
+		if !crl.NextUpdate.IsZero() {
+			if now.After(crl.NextUpdate) {
+				return nil, time.Time{}, ErrCRLExpired
+			}
+			if nextUpdate.IsZero() || crl.NextUpdate.Before(nextUpdate) {
+				nextUpdate = crl.NextUpdate
+			}
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			revoked[revocationKey(crl.RawIssuer, entry.SerialNumber.String())] = struct{}{}
+		}
+	}
+	return revoked, nextUpdate, nil
+}
+
+func signedByIssuer(crl *x509.RevocationList, issuers []*x509.Certificate) bool {
+	for _, issuer := range issuers {
+		if bytes.Equal(issuer.RawSubject, crl.RawIssuer) && crl.CheckSignatureFrom(issuer) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// revocationKey серийные номера уникальны только в пределах удостоверяющего центра
+func revocationKey(rawIssuer []byte, serial string) string {
+	return string(rawIssuer) + "/" + serial
+}
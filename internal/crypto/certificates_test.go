@@ -0,0 +1,155 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+// testCA удостоверяющий центр для тестов
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, login string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: login},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func (ca *testCA) crl(t *testing.T, revoked ...*x509.Certificate) []byte {
+	t.Helper()
+	return ca.crlUntil(t, time.Now().Add(time.Hour), revoked...)
+}
+
+func (ca *testCA) crlUntil(t *testing.T, nextUpdate time.Time, revoked ...*x509.Certificate) []byte {
+	t.Helper()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(time.Now().UnixNano()),
+		ThisUpdate: time.Now().Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, cert := range revoked {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca.cert, ca.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func TestLoadCACertificates(t *testing.T) {
+	first := newTestCA(t, "first")
+	second := newTestCA(t, "second")
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: first.cert.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: second.cert.Raw})...)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	certs, err := crypto.LoadCACertificates(path)
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	assert.Equal(t, "second", certs[1].Subject.CommonName)
+
+	_, err = crypto.ParseCACertificates([]byte("not a certificate"))
+	assert.Error(t, err)
+}
+
+func TestRevocationList(t *testing.T) {
+	ca := newTestCA(t, "agents")
+	revoked := ca.issue(t, "agent-1", 10)
+	valid := ca.issue(t, "agent-2", 11)
+	path := filepath.Join(t.TempDir(), "agents.crl")
+	require.NoError(t, os.WriteFile(path, ca.crl(t, revoked), 0600))
+
+	list, err := crypto.LoadRevocationList(path, []*x509.Certificate{ca.cert})
+	require.NoError(t, err)
+	assert.True(t, list.IsRevoked(revoked))
+	assert.False(t, list.IsRevoked(valid))
+
+	t.Run("same serial from another CA is not revoked", func(t *testing.T) {
+		other := newTestCA(t, "other").issue(t, "agent-1", 10)
+		assert.False(t, list.IsRevoked(other))
+	})
+
+	t.Run("reload picks up new revocations", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, ca.crl(t, revoked, valid), 0600))
+		require.NoError(t, list.Reload())
+		assert.True(t, list.IsRevoked(valid))
+	})
+
+	t.Run("untrusted crl keeps the previous list", func(t *testing.T) {
+		forged := newTestCA(t, "agents")
+		require.NoError(t, os.WriteFile(path, forged.crl(t), 0600))
+		assert.ErrorIs(t, list.Reload(), crypto.ErrCRLNotTrusted)
+		assert.True(t, list.IsRevoked(valid))
+
+		require.NoError(t, os.WriteFile(path, []byte("garbage"), 0600))
+		assert.Error(t, list.Reload())
+		assert.True(t, list.IsRevoked(valid))
+	})
+
+	t.Run("expired crl is rejected", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, ca.crlUntil(t, time.Now().Add(-time.Minute)), 0600))
+		assert.ErrorIs(t, list.Reload(), crypto.ErrCRLExpired)
+		assert.True(t, list.IsRevoked(valid))
+
+		_, err := crypto.LoadRevocationList(path, []*x509.Certificate{ca.cert})
+		assert.ErrorIs(t, err, crypto.ErrCRLExpired)
+	})
+
+	t.Run("nil list revokes nothing", func(t *testing.T) {
+		var empty *crypto.RevocationList
+		assert.False(t, empty.IsRevoked(revoked))
+	})
+}
@@ -3,56 +3,98 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"errors"
 	"fmt"
-	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
-// Encryptor определяет контракт для шифрования данных
+// Encryptor определяет контракт для шифрования данных.
+// Дополнительные данные (associated data) не шифруются, но аутентифицируются вместе
+// с шифротекстом: расшифровка с другими дополнительными данными завершится ошибкой.
 type Encryptor interface {
-	Encrypt(plaintext []byte) ([]byte, error)
-	Decrypt(ciphertext []byte) ([]byte, error)
+	Encrypt(plaintext, additionalData []byte) ([]byte, error)
+	Decrypt(ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// EncryptorOption настраивает необязательные параметры шифровальщика
+type EncryptorOption func(*encryptorOptions)
+
+type encryptorOptions struct {
+	rejectLegacy bool
+}
+
+// WithoutLegacyCiphertexts запрещает расшифровку старых шифротекстов без заголовка:
+// Decrypt возвращает для них ErrLegacyCiphertext
+func WithoutLegacyCiphertexts() EncryptorOption {
+	return func(o *encryptorOptions) {
+		o.rejectLegacy = true
+	}
+}
+
+func newEncryptorOptions(opts []EncryptorOption) encryptorOptions {
+	var o encryptorOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
 // AESGCMEncryptor реализует шифрование с использованием AES-GCM
 type AESGCMEncryptor struct {
-	key []byte
+	key  []byte
+	opts encryptorOptions
 }
 
 // NewAESGCMEncryptor создает новый шифровальщик с заданным ключом
-func NewAESGCMEncryptor(key []byte) (*AESGCMEncryptor, error) {
+func NewAESGCMEncryptor(key []byte, opts ...EncryptorOption) (*AESGCMEncryptor, error) {
 	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
 		return nil, errors.New("key must be 16, 24 or 32 bytes long")
 	}
 
-	return &AESGCMEncryptor{key: key}, nil
+	return &AESGCMEncryptor{key: key, opts: newEncryptorOptions(opts)}, nil
 }
 
-// Encrypt шифрует данные с использованием AES-GCM
-func (e *AESGCMEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
+// Encrypt шифрует данные с использованием AES-GCM и упаковывает их в конверт
+func (e *AESGCMEncryptor) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	return sealEnvelope(AlgorithmAESGCM, e.key, plaintext, additionalData)
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
-	}
+// Decrypt расшифровывает конверт любого поддерживаемого алгоритма,
+// а также, если они не запрещены, старые шифротексты AES-GCM без заголовка
+func (e *AESGCMEncryptor) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	return decrypt(AlgorithmAESGCM, !e.opts.rejectLegacy, e.key, ciphertext, additionalData)
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	_, err = io.ReadFull(rand.Reader, nonce)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+// XChaCha20Poly1305Encryptor реализует шифрование с использованием XChaCha20-Poly1305.
+// Увеличенный nonce позволяет безопасно генерировать его случайно для любого объема данных.
+type XChaCha20Poly1305Encryptor struct {
+	key  []byte
+	opts encryptorOptions
+}
+
+// NewXChaCha20Poly1305Encryptor создает новый шифровальщик с 32-байтовым ключом
+func NewXChaCha20Poly1305Encryptor(key []byte, opts ...EncryptorOption) (*XChaCha20Poly1305Encryptor, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("key must be %d bytes long", chacha20poly1305.KeySize)
 	}
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return ciphertext, nil
+
+	return &XChaCha20Poly1305Encryptor{key: key, opts: newEncryptorOptions(opts)}, nil
 }
 
-// Decrypt расшифровывает данные, зашифрованные с помощью AES-GCM
-func (e *AESGCMEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
+// Encrypt шифрует данные с использованием XChaCha20-Poly1305 и упаковывает их в конверт
+func (e *XChaCha20Poly1305Encryptor) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	return sealEnvelope(AlgorithmXChaCha20Poly1305, e.key, plaintext, additionalData)
+}
+
+// Decrypt расшифровывает конверт любого поддерживаемого алгоритма,
+// а также, если они не запрещены, старые шифротексты XChaCha20-Poly1305 без заголовка
+func (e *XChaCha20Poly1305Encryptor) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	return decrypt(AlgorithmXChaCha20Poly1305, !e.opts.rejectLegacy, e.key, ciphertext, additionalData)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
@@ -62,28 +104,25 @@ func (e *AESGCMEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	if len(ciphertext) < gcm.NonceSize() {
-		return nil, errors.New("ciphertext too short")
-	}
-
-	nonce := ciphertext[:gcm.NonceSize()]
-	ciphertext = ciphertext[gcm.NonceSize():]
+	return gcm, nil
+}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+func newXChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	aead, err := chacha20poly1305.NewX(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+		return nil, fmt.Errorf("failed to create XChaCha20-Poly1305: %w", err)
 	}
 
-	return plaintext, nil
+	return aead, nil
 }
 
 // NoopEncryptor заглушка для тестов
 type NoopEncryptor struct{}
 
-func (e *NoopEncryptor) Encrypt(data []byte) ([]byte, error) {
+func (e *NoopEncryptor) Encrypt(data, additionalData []byte) ([]byte, error) {
 	return data, nil
 }
 
-func (e *NoopEncryptor) Decrypt(encryptedData []byte) ([]byte, error) {
+func (e *NoopEncryptor) Decrypt(encryptedData, additionalData []byte) ([]byte, error) {
 	return encryptedData, nil
 }
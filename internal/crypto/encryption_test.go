@@ -1,6 +1,9 @@
 package crypto_test
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,20 +21,20 @@ func TestAESGCMEncryptor(t *testing.T) {
 
 	plaintext := []byte("Hello, World! This is a secret message.")
 
-	ciphertext, err := encryptor.Encrypt(plaintext)
+	ciphertext, err := encryptor.Encrypt(plaintext, nil)
 	require.NoError(t, err)
 	assert.NotEmpty(t, ciphertext)
 	assert.NotEqual(t, plaintext, ciphertext)
 
-	decrypted, err := encryptor.Decrypt(ciphertext)
+	decrypted, err := encryptor.Decrypt(ciphertext, nil)
 	require.NoError(t, err)
 	assert.Equal(t, plaintext, decrypted)
 
-	ciphertext2, err := encryptor.Encrypt(plaintext)
+	ciphertext2, err := encryptor.Encrypt(plaintext, nil)
 	require.NoError(t, err)
 	assert.NotEqual(t, ciphertext, ciphertext2)
 
-	decrypted2, err := encryptor.Decrypt(ciphertext2)
+	decrypted2, err := encryptor.Decrypt(ciphertext2, nil)
 	require.NoError(t, err)
 	assert.Equal(t, plaintext, decrypted2)
 }
@@ -48,9 +51,178 @@ func TestAESGCMEncryptor_InvalidCiphertext(t *testing.T) {
 	encryptor, err := crypto.NewAESGCMEncryptor(key)
 	require.NoError(t, err)
 
-	_, err = encryptor.Decrypt([]byte("short"))
+	_, err = encryptor.Decrypt([]byte("short"), nil)
 	require.Error(t, err)
 
-	_, err = encryptor.Decrypt([]byte("this-is-not-valid-ciphertext-data"))
+	_, err = encryptor.Decrypt([]byte("this-is-not-valid-ciphertext-data"), nil)
 	require.Error(t, err)
 }
+
+func TestXChaCha20Poly1305Encryptor(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	require.NoError(t, err)
+
+	plaintext := []byte("Hello, World! This is a secret message.")
+
+	ciphertext, err := encryptor.Encrypt(plaintext, nil)
+	require.NoError(t, err)
+
+	header, err := crypto.ParseEnvelope(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.EnvelopeVersion, header.Version)
+	assert.Equal(t, crypto.AlgorithmXChaCha20Poly1305, header.Algorithm)
+	assert.Equal(t, crypto.KeyID(key), header.KeyID)
+
+	decrypted, err := encryptor.Decrypt(ciphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = crypto.NewXChaCha20Poly1305Encryptor(key[:16])
+	require.Error(t, err)
+}
+
+func TestEnvelope_DispatchOnHeader(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	aesEncryptor, err := crypto.NewAESGCMEncryptor(key)
+	require.NoError(t, err)
+	chachaEncryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	require.NoError(t, err)
+
+	plaintext := []byte("cross-algorithm")
+
+	aesCiphertext, err := aesEncryptor.Encrypt(plaintext, nil)
+	require.NoError(t, err)
+	decrypted, err := chachaEncryptor.Decrypt(aesCiphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	chachaCiphertext, err := chachaEncryptor.Encrypt(plaintext, nil)
+	require.NoError(t, err)
+	decrypted, err = aesEncryptor.Decrypt(chachaCiphertext, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
+
+func TestEnvelope_LegacyHeaderless(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+	legacy := gcm.Seal(nonce, nonce, []byte("old data"), nil)
+
+	_, err = crypto.ParseEnvelope(legacy)
+	assert.ErrorIs(t, err, crypto.ErrUnsupportedEnvelope)
+
+	encryptor, err := crypto.NewAESGCMEncryptor(key)
+	require.NoError(t, err)
+
+	decrypted, err := encryptor.Decrypt(legacy, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old data"), decrypted)
+	assert.False(t, crypto.IsEnvelope(legacy))
+
+	t.Run("legacy disabled", func(t *testing.T) {
+		strict, err := crypto.NewAESGCMEncryptor(key, crypto.WithoutLegacyCiphertexts())
+		require.NoError(t, err)
+
+		_, err = strict.Decrypt(legacy, nil)
+		assert.ErrorIs(t, err, crypto.ErrLegacyCiphertext)
+	})
+
+	t.Run("nonce starting with envelope magic", func(t *testing.T) {
+		magicNonce := append([]byte("GK"), nonce[2:]...)
+		magicNonce[2] = 0xFF
+		disguised := gcm.Seal(append([]byte(nil), magicNonce...), magicNonce, []byte("old data"), nil)
+
+		assert.False(t, crypto.IsEnvelope(disguised))
+		decrypted, err := encryptor.Decrypt(disguised, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old data"), decrypted)
+	})
+
+	t.Run("valid envelope header is never opened as legacy", func(t *testing.T) {
+		magicNonce := append([]byte("GK"), nonce[2:]...)
+		magicNonce[2] = crypto.EnvelopeVersion
+		magicNonce[3] = byte(crypto.AlgorithmAESGCM)
+		disguised := gcm.Seal(append([]byte(nil), magicNonce...), magicNonce, []byte("old data"), nil)
+
+		assert.True(t, crypto.IsEnvelope(disguised))
+		_, err := encryptor.Decrypt(disguised, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestEnvelope_Rejects(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	encryptor, err := crypto.NewAESGCMEncryptor(key)
+	require.NoError(t, err)
+	otherEncryptor, err := crypto.NewAESGCMEncryptor(otherKey)
+	require.NoError(t, err)
+
+	ciphertext, err := encryptor.Encrypt([]byte("secret"), nil)
+	require.NoError(t, err)
+
+	t.Run("another key", func(t *testing.T) {
+		_, err := otherEncryptor.Decrypt(ciphertext, nil)
+		assert.ErrorIs(t, err, crypto.ErrKeyIDMismatch)
+	})
+
+	t.Run("tampered header", func(t *testing.T) {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[3] = byte(crypto.AlgorithmXChaCha20Poly1305)
+
+		_, err := encryptor.Decrypt(tampered, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[2] = 99
+
+		_, err := crypto.ParseEnvelope(tampered)
+		assert.ErrorIs(t, err, crypto.ErrUnsupportedEnvelope)
+		_, err = encryptor.Decrypt(tampered, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptor_AssociatedData(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	aesEncryptor, err := crypto.NewAESGCMEncryptor(key)
+	require.NoError(t, err)
+	chachaEncryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	require.NoError(t, err)
+
+	for _, encryptor := range []crypto.Encryptor{aesEncryptor, chachaEncryptor} {
+		ciphertext, err := encryptor.Encrypt([]byte("secret"), []byte("secret-1"))
+		require.NoError(t, err)
+
+		decrypted, err := encryptor.Decrypt(ciphertext, []byte("secret-1"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("secret"), decrypted)
+
+		_, err = encryptor.Decrypt(ciphertext, []byte("secret-2"))
+		assert.Error(t, err)
+
+		_, err = encryptor.Decrypt(ciphertext, nil)
+		assert.Error(t, err)
+	}
+}
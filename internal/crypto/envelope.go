@@ -0,0 +1,177 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Algorithm идентификатор алгоритма AEAD в заголовке шифротекста
+type Algorithm byte
+
+const (
+	// AlgorithmAESGCM AES-GCM с 12-байтовым nonce
+	AlgorithmAESGCM Algorithm = 1
+	// AlgorithmXChaCha20Poly1305 XChaCha20-Poly1305 с 24-байтовым nonce
+	AlgorithmXChaCha20Poly1305 Algorithm = 2
+)
+
+// EnvelopeVersion текущая версия формата конверта
+const EnvelopeVersion byte = 1
+
+// envelopeMagic отличает конверт от старых шифротекстов без заголовка
+var envelopeMagic = []byte("GK")
+
+// envelopeHeaderSize размер заголовка: magic, версия, алгоритм, идентификатор ключа
+const envelopeHeaderSize = 2 + 1 + 1 + keyIDSize
+
+var (
+	// ErrUnsupportedEnvelope неизвестная версия конверта или алгоритм
+	ErrUnsupportedEnvelope = errors.New("unsupported ciphertext envelope")
+	// ErrKeyIDMismatch шифротекст зашифрован другим ключом
+	ErrKeyIDMismatch = errors.New("ciphertext was encrypted with another key")
+	// ErrLegacyCiphertext шифротекст без заголовка, а старый формат отключен
+	ErrLegacyCiphertext = errors.New("legacy ciphertext without envelope is not allowed")
+)
+
+// EnvelopeHeader заголовок шифротекста.
+// Формат: "GK" | версия (1 байт) | алгоритм (1 байт) | идентификатор ключа (8 байт) | nonce | шифротекст.
+// Заголовок аутентифицируется вместе с дополнительными данными вызывающего кода.
+type EnvelopeHeader struct {
+	Version   byte
+	Algorithm Algorithm
+	KeyID     string
+}
+
+// IsEnvelope сообщает, что шифротекст начинается с действительного заголовка конверта:
+// magic, известные версия и алгоритм. Такой шифротекст никогда не расшифровывается как
+// старый формат. Случайный nonce старого шифротекста может начинаться с "GK", поэтому
+// одного magic недостаточно.
+func IsEnvelope(ciphertext []byte) bool {
+	_, err := ParseEnvelope(ciphertext)
+	return err == nil
+}
+
+// ParseEnvelope разбирает заголовок шифротекста.
+// Для старых шифротекстов без заголовка возвращает ErrUnsupportedEnvelope.
+func ParseEnvelope(ciphertext []byte) (*EnvelopeHeader, error) {
+	if len(ciphertext) < envelopeHeaderSize || !bytes.HasPrefix(ciphertext, envelopeMagic) {
+		return nil, ErrUnsupportedEnvelope
+	}
+
+	header := &EnvelopeHeader{
+		Version:   ciphertext[2],
+		Algorithm: Algorithm(ciphertext[3]),
+		KeyID:     hex.EncodeToString(ciphertext[4:envelopeHeaderSize]),
+	}
+	if header.Version != EnvelopeVersion {
+		return nil, ErrUnsupportedEnvelope
+	}
+	if _, ok := aeadConstructors[header.Algorithm]; !ok {
+		return nil, ErrUnsupportedEnvelope
+	}
+
+	return header, nil
+}
+
+// aeadConstructors создают AEAD для каждого поддерживаемого алгоритма
+var aeadConstructors = map[Algorithm]func(key []byte) (cipher.AEAD, error){
+	AlgorithmAESGCM:            newAESGCM,
+	AlgorithmXChaCha20Poly1305: newXChaCha20Poly1305,
+}
+
+// envelopeAAD объединяет заголовок конверта с дополнительными данными вызывающего кода
+func envelopeAAD(header, additionalData []byte) []byte {
+	aad := make([]byte, 0, len(header)+len(additionalData))
+	aad = append(aad, header...)
+	return append(aad, additionalData...)
+}
+
+// sealEnvelope шифрует данные и упаковывает их в конверт с заголовком
+func sealEnvelope(alg Algorithm, key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := aeadConstructors[alg](key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, envelopeHeaderSize+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	header = append(header, envelopeMagic...)
+	header = append(header, EnvelopeVersion, byte(alg))
+	header = append(header, keyIDBytes(key)...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, envelopeAAD(out[:envelopeHeaderSize], additionalData)), nil
+}
+
+// openEnvelope расшифровывает конверт, выбирая алгоритм по заголовку
+func openEnvelope(key, ciphertext, additionalData []byte) ([]byte, error) {
+	header, err := ParseEnvelope(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if header.KeyID != KeyID(key) {
+		return nil, ErrKeyIDMismatch
+	}
+
+	aead, err := aeadConstructors[header.Algorithm](key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ciphertext[envelopeHeaderSize:]
+	if len(body) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	plaintext, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], envelopeAAD(ciphertext[:envelopeHeaderSize], additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// decrypt расшифровывает конверт любого поддерживаемого алгоритма.
+// Старые шифротексты без заголовка (nonce||ciphertext) расшифровываются алгоритмом legacy,
+// если allowLegacy. Шифротекст с действительным заголовком считается конвертом и при ошибке
+// не проверяется как старый формат: иначе подмена заголовка отключала бы проверку
+// дополнительных данных, которых у старых шифротекстов нет. Шифротекст, заголовок которого
+// ParseEnvelope отклоняет, проверяется как старый формат: его nonce мог случайно начаться с "GK".
+func decrypt(legacy Algorithm, allowLegacy bool, key, ciphertext, additionalData []byte) ([]byte, error) {
+	if IsEnvelope(ciphertext) {
+		return openEnvelope(key, ciphertext, additionalData)
+	}
+	if !allowLegacy {
+		return nil, ErrLegacyCiphertext
+	}
+	return openLegacy(legacy, key, ciphertext)
+}
+
+// openLegacy расшифровывает шифротекст без заголовка в формате nonce||ciphertext
+func openLegacy(alg Algorithm, key, ciphertext []byte) ([]byte, error) {
+	aead, err := aeadConstructors[alg](key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce := ciphertext[:aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
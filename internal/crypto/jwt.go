@@ -1,73 +1,122 @@
 package crypto
 
 import (
+	gocrypto "crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 )
 
 type JWTManagerInterface interface {
-	GenerateAccessToken(userID, login string) (string, error)
-	GenerateRefreshToken(userID string) (string, error)
+	GenerateAccessToken(userID, login, sessionID, deviceID string) (string, error)
+	GenerateRefreshToken(userID, sessionID, deviceID string) (string, error)
 	ValidateToken(tokenString string) (*TokenClaims, error)
 	IsAccessToken(claims *TokenClaims) bool
 	IsRefreshToken(claims *TokenClaims) bool
+	PublicKeys() []JWK
 }
 
-// JWTManager управляет JWT токенами
+// JWTManager управляет JWT токенами.
+// Токены подписываются ключом Ed25519 или RSA и содержат в заголовке kid ключа подписи;
+// проверяются они любым из активных ключей проверки, что позволяет менять ключ подписи,
+// не завершая сессии пользователей.
 type JWTManager struct {
-	secret        []byte
-	accessExpiry  time.Duration
-	refreshExpiry time.Duration
+	secret           []byte
+	signingKey       *JWTSigningKey
+	signingMethod    jwt.SigningMethod
+	verificationKeys map[string]*JWTVerificationKey
+	accessExpiry     time.Duration
+	refreshExpiry    time.Duration
 }
 
 // JWTConfig конфигурация для JWT менеджера
 type JWTConfig struct {
+	// Secret общий секрет HS256 (устаревший способ). Если задан SigningKey, секрет используется
+	// только для проверки токенов, выданных до перехода на асимметричную подпись.
 	Secret        string        `yaml:"secret" env:"JWT_SECRET"`
 	AccessExpiry  time.Duration `yaml:"access_expiry" env:"JWT_ACCESS_EXPIRY" default:"15m"`
 	RefreshExpiry time.Duration `yaml:"refresh_expiry" env:"JWT_REFRESH_EXPIRY" default:"168h"`
+	// SigningKey текущий ключ подписи
+	SigningKey *JWTSigningKey
+	// VerificationKeys прежние ключи, токены которых еще принимаются
+	VerificationKeys []*JWTVerificationKey
 }
 
 // DefaultConfig возвращает конфигурацию по умолчанию
 func DefaultConfig() *JWTConfig {
 	return &JWTConfig{
-		Secret:        "default-jwt-secret-key-change-in-production",
 		AccessExpiry:  15 * time.Minute,
 		RefreshExpiry: 7 * 24 * time.Hour,
 	}
 }
 
-// NewJWTManager создает новый JWT менеджер
+// NewJWTManager создает новый JWT менеджер.
+// Если не задан ни ключ подписи, ни секрет, генерируется временный ключ Ed25519:
+// выданные им токены перестанут приниматься после перезапуска.
 func NewJWTManager(config *JWTConfig) *JWTManager {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	return &JWTManager{
-		secret:        []byte(config.Secret),
-		accessExpiry:  config.AccessExpiry,
-		refreshExpiry: config.RefreshExpiry,
+	m := &JWTManager{
+		signingKey:       config.SigningKey,
+		verificationKeys: make(map[string]*JWTVerificationKey),
+		accessExpiry:     config.AccessExpiry,
+		refreshExpiry:    config.RefreshExpiry,
 	}
+	if config.Secret != "" {
+		m.secret = []byte(config.Secret)
+	}
+
+	if m.signingKey == nil && m.secret == nil {
+		signingKey, err := GenerateJWTSigningKey()
+		if err != nil {
+			panic(fmt.Sprintf("failed to generate jwt signing key: %v", err))
+		}
+		m.signingKey = signingKey
+	}
+
+	for _, key := range config.VerificationKeys {
+		m.verificationKeys[key.ID] = key
+	}
+
+	if m.signingKey != nil {
+		public := m.signingKey.Public()
+		m.verificationKeys[public.ID] = public
+		m.signingMethod = signingMethodForKey(public.PublicKey)
+	} else {
+		m.signingMethod = jwt.SigningMethodHS256
+	}
+
+	return m
 }
 
 // TokenClaims кастомные claims для JWT токенов
 type TokenClaims struct {
-	UserID string `json:"user_id"`
-	Login  string `json:"login,omitempty"`
-	Type   string `json:"type"`
+	UserID    string `json:"user_id"`
+	Login     string `json:"login,omitempty"`
+	Type      string `json:"type"`
+	SessionID string `json:"sid,omitempty"`
+	DeviceID  string `json:"did,omitempty"` // Устройство, с которого выполнен вход
 	jwt.RegisteredClaims
 }
 
-// GenerateAccessToken генерирует access token
-func (m *JWTManager) GenerateAccessToken(userID, login string) (string, error) {
+// GenerateAccessToken генерирует access token сессии sessionID на устройстве deviceID
+func (m *JWTManager) GenerateAccessToken(userID, login, sessionID, deviceID string) (string, error) {
 	claims := &TokenClaims{
-		UserID: userID,
-		Login:  login,
-		Type:   "access",
+		UserID:    userID,
+		Login:     login,
+		Type:      "access",
+		SessionID: sessionID,
+		DeviceID:  deviceID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -76,20 +125,23 @@ func (m *JWTManager) GenerateAccessToken(userID, login string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(m.secret)
+	tokenString, err := m.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign access token: %w", err)
 	}
 	return tokenString, nil
 }
 
-// GenerateRefreshToken генерирует refresh token
-func (m *JWTManager) GenerateRefreshToken(userID string) (string, error) {
+// GenerateRefreshToken генерирует refresh token сессии sessionID на устройстве deviceID
+func (m *JWTManager) GenerateRefreshToken(userID, sessionID, deviceID string) (string, error) {
 	claims := &TokenClaims{
-		UserID: userID,
-		Type:   "refresh",
+		UserID:    userID,
+		Type:      "refresh",
+		SessionID: sessionID,
+		DeviceID:  deviceID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// Уникальный ID, чтобы токены, выданные в одну секунду, не совпадали
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.refreshExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -97,8 +149,7 @@ func (m *JWTManager) GenerateRefreshToken(userID string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(m.secret)
+	tokenString, err := m.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign refresh token: %w", err)
 	}
@@ -107,12 +158,7 @@ func (m *JWTManager) GenerateRefreshToken(userID string) (string, error) {
 
 // ValidateToken проверяет и парсит JWT токен
 func (m *JWTManager) ValidateToken(tokenString string) (*TokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return m.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, m.verificationKey)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -129,6 +175,63 @@ func (m *JWTManager) ValidateToken(tokenString string) (*TokenClaims, error) {
 	return claims, nil
 }
 
+// PublicKeys возвращает открытые ключи проверки токенов в формате JWK
+func (m *JWTManager) PublicKeys() []JWK {
+	keys := make([]JWK, 0, len(m.verificationKeys))
+	for _, key := range m.verificationKeys {
+		keys = append(keys, key.JWK())
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].KeyID < keys[j].KeyID
+	})
+	return keys
+}
+
+// sign подписывает claims текущим ключом
+func (m *JWTManager) sign(claims *TokenClaims) (string, error) {
+	token := jwt.NewWithClaims(m.signingMethod, claims)
+	if m.signingKey == nil {
+		return token.SignedString(m.secret)
+	}
+
+	token.Header["kid"] = m.signingKey.ID
+	return token.SignedString(m.signingKey.PrivateKey)
+}
+
+// verificationKey выбирает ключ проверки по kid и убеждается, что алгоритм токена ему соответствует
+func (m *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && m.secret != nil {
+			return m.secret, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	key, ok := m.verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if token.Method != signingMethodForKey(key.PublicKey) {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+// signingMethodForKey возвращает алгоритм подписи для открытого ключа
+func signingMethodForKey(publicKey gocrypto.PublicKey) jwt.SigningMethod {
+	switch publicKey.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256
+	default:
+		return nil
+	}
+}
+
 // IsAccessToken проверяет, является ли токен access token'ом
 func (m *JWTManager) IsAccessToken(claims *TokenClaims) bool {
 	return claims.Type == "access"
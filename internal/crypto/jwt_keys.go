@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	gocrypto "crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// minRSAKeyBits минимальный допустимый размер ключа RSA
+const minRSAKeyBits = 2048
+
+// JWTSigningKey закрытый ключ подписи токенов (Ed25519 или RSA)
+type JWTSigningKey struct {
+	ID         string
+	PrivateKey gocrypto.Signer
+}
+
+// JWTVerificationKey открытый ключ проверки токенов
+type JWTVerificationKey struct {
+	ID        string
+	PublicKey gocrypto.PublicKey
+}
+
+// JWK открытый ключ в формате JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// GenerateJWTSigningKey генерирует новый ключ подписи Ed25519
+func GenerateJWTSigningKey() (*JWTSigningKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ed25519 key: %w", err)
+	}
+	return newJWTSigningKey(privateKey)
+}
+
+// LoadJWTSigningKey читает ключ подписи из PEM-файла (PKCS#8 или PKCS#1 для RSA)
+func LoadJWTSigningKey(path string) (*JWTSigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt signing key: %w", err)
+	}
+	return ParseJWTSigningKey(data)
+}
+
+// LoadJWTVerificationKey читает ключ проверки из PEM-файла с открытым ключом (PKIX)
+func LoadJWTVerificationKey(path string) (*JWTVerificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt verification key: %w", err)
+	}
+	return ParseJWTVerificationKey(data)
+}
+
+// ParseJWTSigningKey разбирает закрытый ключ в формате PEM
+func ParseJWTSigningKey(pemData []byte) (*JWTSigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("jwt signing key is not PEM encoded")
+	}
+
+	var privateKey any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwt signing key: %w", err)
+	}
+
+	signer, ok := privateKey.(gocrypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported jwt signing key type")
+	}
+	return newJWTSigningKey(signer)
+}
+
+// ParseJWTVerificationKey разбирает открытый ключ в формате PEM
+func ParseJWTVerificationKey(pemData []byte) (*JWTVerificationKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("jwt verification key is not PEM encoded")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwt verification key: %w", err)
+	}
+	return newJWTVerificationKey(publicKey)
+}
+
+// Public возвращает открытую часть ключа подписи
+func (k *JWTSigningKey) Public() *JWTVerificationKey {
+	return &JWTVerificationKey{
+		ID:        k.ID,
+		PublicKey: k.PrivateKey.Public(),
+	}
+}
+
+// JWK возвращает ключ в формате JSON Web Key
+func (k *JWTVerificationKey) JWK() JWK {
+	jwk := JWK{
+		KeyID: k.ID,
+		Use:   "sig",
+	}
+
+	switch publicKey := k.PublicKey.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Algorithm = "EdDSA"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.Algorithm = "RS256"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	}
+
+	return jwk
+}
+
+func newJWTSigningKey(privateKey gocrypto.Signer) (*JWTSigningKey, error) {
+	public, err := newJWTVerificationKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTSigningKey{
+		ID:         public.ID,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+func newJWTVerificationKey(publicKey any) (*JWTVerificationKey, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSAKeyBits)
+		}
+	default:
+		return nil, errors.New("jwt keys must be Ed25519 or RSA")
+	}
+
+	id, err := jwtKeyID(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTVerificationKey{
+		ID:        id,
+		PublicKey: publicKey,
+	}, nil
+}
+
+// jwtKeyID вычисляет kid из отпечатка открытого ключа, чтобы он не зависел от конфигурации
+func jwtKeyID(publicKey any) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:keyIDSize]), nil
+}
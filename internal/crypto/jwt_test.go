@@ -1,9 +1,14 @@
 package crypto_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -23,12 +28,12 @@ func TestJWTManager(t *testing.T) {
 	login := "testuser"
 
 	// Генерируем access token
-	accessToken, err := jwtManager.GenerateAccessToken(userID, login)
+	accessToken, err := jwtManager.GenerateAccessToken(userID, login, "session-1", "device-1")
 	require.NoError(t, err)
 	assert.NotEmpty(t, accessToken)
 
 	// Генерируем refresh token
-	refreshToken, err := jwtManager.GenerateRefreshToken(userID)
+	refreshToken, err := jwtManager.GenerateRefreshToken(userID, "", "")
 	require.NoError(t, err)
 	assert.NotEmpty(t, refreshToken)
 
@@ -38,6 +43,8 @@ func TestJWTManager(t *testing.T) {
 	assert.Equal(t, userID, accessClaims.UserID)
 	assert.Equal(t, login, accessClaims.Login)
 	assert.Equal(t, "access", accessClaims.Type)
+	assert.Equal(t, "session-1", accessClaims.SessionID)
+	assert.Equal(t, "device-1", accessClaims.DeviceID)
 	assert.True(t, jwtManager.IsAccessToken(accessClaims))
 	assert.False(t, jwtManager.IsRefreshToken(accessClaims))
 
@@ -53,3 +60,146 @@ func TestJWTManager(t *testing.T) {
 	_, err = jwtManager.ValidateToken("invalid-token")
 	require.Error(t, err)
 }
+
+func TestJWTManager_AsymmetricKeys(t *testing.T) {
+	ed25519Key, err := crypto.GenerateJWTSigningKey()
+	require.NoError(t, err)
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaKey, err := crypto.ParseJWTSigningKey(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate),
+	}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  *crypto.JWTSigningKey
+		alg  string
+	}{
+		{name: "ed25519", key: ed25519Key, alg: "EdDSA"},
+		{name: "rsa", key: rsaKey, alg: "RS256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := crypto.NewJWTManager(&crypto.JWTConfig{
+				AccessExpiry:  time.Minute,
+				RefreshExpiry: time.Hour,
+				SigningKey:    tt.key,
+			})
+
+			token, err := manager.GenerateAccessToken("user-1", "alice", "", "")
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &crypto.TokenClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.key.ID, parsed.Header["kid"])
+			assert.Equal(t, tt.alg, parsed.Header["alg"])
+
+			claims, err := manager.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.UserID)
+
+			keys := manager.PublicKeys()
+			require.Len(t, keys, 1)
+			assert.Equal(t, tt.key.ID, keys[0].KeyID)
+			assert.Equal(t, tt.alg, keys[0].Algorithm)
+		})
+	}
+}
+
+func TestJWTManager_KeyRotation(t *testing.T) {
+	oldKey, err := crypto.GenerateJWTSigningKey()
+	require.NoError(t, err)
+	newKey, err := crypto.GenerateJWTSigningKey()
+	require.NoError(t, err)
+
+	oldManager := crypto.NewJWTManager(&crypto.JWTConfig{AccessExpiry: time.Minute, SigningKey: oldKey})
+	oldToken, err := oldManager.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+
+	// Прежний ключ передается в виде открытого ключа из PEM, как в конфигурации сервера
+	der, err := x509.MarshalPKIXPublicKey(oldKey.PrivateKey.Public())
+	require.NoError(t, err)
+	oldPublic, err := crypto.ParseJWTVerificationKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.ID, oldPublic.ID)
+
+	rotated := crypto.NewJWTManager(&crypto.JWTConfig{
+		AccessExpiry:     time.Minute,
+		SigningKey:       newKey,
+		VerificationKeys: []*crypto.JWTVerificationKey{oldPublic},
+	})
+
+	claims, err := rotated.ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Len(t, rotated.PublicKeys(), 2)
+
+	// Без прежнего ключа токен больше не принимается
+	withoutOld := crypto.NewJWTManager(&crypto.JWTConfig{AccessExpiry: time.Minute, SigningKey: newKey})
+	_, err = withoutOld.ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestJWTManager_RejectsForeignTokens(t *testing.T) {
+	signingKey, err := crypto.GenerateJWTSigningKey()
+	require.NoError(t, err)
+	manager := crypto.NewJWTManager(&crypto.JWTConfig{AccessExpiry: time.Minute, SigningKey: signingKey})
+
+	claims := &crypto.TokenClaims{
+		UserID: "user-1",
+		Type:   "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	// HS256 без секрета на сервере
+	hsToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = manager.ValidateToken(hsToken)
+	assert.Error(t, err)
+
+	// HS256 с kid ключа Ed25519 (подмена алгоритма)
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["kid"] = signingKey.ID
+	confusedToken, err := confused.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = manager.ValidateToken(confusedToken)
+	assert.Error(t, err)
+
+	// Неизвестный kid
+	otherKey, err := crypto.GenerateJWTSigningKey()
+	require.NoError(t, err)
+	otherToken, err := crypto.NewJWTManager(&crypto.JWTConfig{AccessExpiry: time.Minute, SigningKey: otherKey}).
+		GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	_, err = manager.ValidateToken(otherToken)
+	assert.Error(t, err)
+}
+
+func TestJWTManager_LegacySecretStillVerified(t *testing.T) {
+	legacy := crypto.NewJWTManager(&crypto.JWTConfig{Secret: "legacy-secret", AccessExpiry: time.Minute})
+	legacyToken, err := legacy.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+
+	signingKey, err := crypto.GenerateJWTSigningKey()
+	require.NoError(t, err)
+	manager := crypto.NewJWTManager(&crypto.JWTConfig{
+		Secret:       "legacy-secret",
+		AccessExpiry: time.Minute,
+		SigningKey:   signingKey,
+	})
+
+	_, err = manager.ValidateToken(legacyToken)
+	require.NoError(t, err)
+
+	newToken, err := manager.GenerateAccessToken("user-1", "alice", "", "")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &crypto.TokenClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Header["alg"])
+}
@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KDFAlgorithmArgon2id идентификатор алгоритма Argon2id
+const KDFAlgorithmArgon2id = "argon2id"
+
+const (
+	// DerivedKeySize размер ключа, получаемого из мастер-пароля
+	DerivedKeySize = 32
+	// SaltSize размер соли по умолчанию
+	SaltSize = 16
+
+	minKDFIterations = 1
+	maxKDFIterations = 64
+	minKDFMemory     = 16 * 1024
+	maxKDFMemory     = 4 * 1024 * 1024
+)
+
+// KDFParams параметры формирования ключа из мастер-пароля
+type KDFParams struct {
+	Algorithm   string
+	Salt        []byte
+	Iterations  uint32
+	Memory      uint32 // объем памяти в KiB
+	Parallelism uint8
+}
+
+// DefaultKDFParams возвращает параметры Argon2id по умолчанию (без соли)
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		Algorithm:   KDFAlgorithmArgon2id,
+		Iterations:  3,
+		Memory:      64 * 1024,
+		Parallelism: 4,
+	}
+}
+
+// GenerateSalt генерирует случайную соль заданного размера
+func GenerateSalt(size int) ([]byte, error) {
+	if size < SaltSize {
+		return nil, fmt.Errorf("salt size must be at least %d bytes", SaltSize)
+	}
+
+	salt := make([]byte, size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return salt, nil
+}
+
+// Validate проверяет, что параметры не ослаблены ниже допустимого минимума
+// и не настолько велики, чтобы сделать вычисление ключа невозможным
+func (p KDFParams) Validate() error {
+	if p.Algorithm != KDFAlgorithmArgon2id {
+		return fmt.Errorf("unsupported kdf algorithm: %q", p.Algorithm)
+	}
+	if len(p.Salt) < SaltSize {
+		return fmt.Errorf("kdf salt must be at least %d bytes", SaltSize)
+	}
+	if p.Iterations < minKDFIterations || p.Iterations > maxKDFIterations {
+		return fmt.Errorf("kdf iterations must be between %d and %d", minKDFIterations, maxKDFIterations)
+	}
+	if p.Memory < minKDFMemory || p.Memory > maxKDFMemory {
+		return fmt.Errorf("kdf memory must be between %d and %d KiB", minKDFMemory, maxKDFMemory)
+	}
+	if p.Parallelism == 0 {
+		return errors.New("kdf parallelism must be positive")
+	}
+	return nil
+}
+
+// DeriveKey детерминированно получает ключ хранилища из мастер-пароля
+func DeriveKey(password string, params KDFParams) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kdf params: %w", err)
+	}
+
+	return argon2.IDKey([]byte(password), params.Salt, params.Iterations, params.Memory, params.Parallelism, DerivedKeySize), nil
+}
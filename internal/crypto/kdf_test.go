@@ -0,0 +1,73 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+func testKDFParams(t *testing.T) crypto.KDFParams {
+	t.Helper()
+
+	salt, err := crypto.GenerateSalt(crypto.SaltSize)
+	require.NoError(t, err)
+
+	return crypto.KDFParams{
+		Algorithm:   crypto.KDFAlgorithmArgon2id,
+		Salt:        salt,
+		Iterations:  1,
+		Memory:      16 * 1024,
+		Parallelism: 1,
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	params := testKDFParams(t)
+
+	key1, err := crypto.DeriveKey("master-password", params)
+	require.NoError(t, err)
+	assert.Len(t, key1, crypto.DerivedKeySize)
+
+	key2, err := crypto.DeriveKey("master-password", params)
+	require.NoError(t, err)
+	assert.Equal(t, key1, key2, "key must be deterministic for the same password and salt")
+
+	other, err := crypto.DeriveKey("other-password", params)
+	require.NoError(t, err)
+	assert.NotEqual(t, key1, other)
+
+	params.Salt, err = crypto.GenerateSalt(crypto.SaltSize)
+	require.NoError(t, err)
+	salted, err := crypto.DeriveKey("master-password", params)
+	require.NoError(t, err)
+	assert.NotEqual(t, key1, salted)
+}
+
+func TestDeriveKey_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *crypto.KDFParams)
+	}{
+		{"unknown algorithm", func(p *crypto.KDFParams) { p.Algorithm = "pbkdf2" }},
+		{"short salt", func(p *crypto.KDFParams) { p.Salt = []byte("short") }},
+		{"zero iterations", func(p *crypto.KDFParams) { p.Iterations = 0 }},
+		{"weak memory", func(p *crypto.KDFParams) { p.Memory = 1024 }},
+		{"zero parallelism", func(p *crypto.KDFParams) { p.Parallelism = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testKDFParams(t)
+			tt.modify(&params)
+
+			_, err := crypto.DeriveKey("master-password", params)
+			require.Error(t, err)
+		})
+	}
+
+	_, err := crypto.DeriveKey("", testKDFParams(t))
+	require.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// KeyProvider источник мастер-ключа сервера
+type KeyProvider interface {
+	// Key возвращает ключ; реализация не проверяет его стойкость (см. LoadKey)
+	Key(ctx context.Context) ([]byte, error)
+}
+
+// KeySource описывает, откуда взять мастер-ключ. Должно быть задано не более одного поля.
+type KeySource struct {
+	// Raw ключ в виде строки, байты которой используются как есть (устаревший способ)
+	Raw string
+	// File путь к файлу с ключом в hex или base64
+	File string
+	// Env имя переменной окружения с ключом в hex или base64
+	Env string
+	// Command внешняя команда, печатающая ключ в hex или base64 в stdout
+	Command string
+}
+
+// defaultKeyCommandTimeout ограничивает время работы внешней команды
+const defaultKeyCommandTimeout = 10 * time.Second
+
+var (
+	// ErrInvalidKeyLength ключ неподходящей длины
+	ErrInvalidKeyLength = errors.New("key must be 16, 24 or 32 bytes long")
+	// ErrWeakKey ключ с недостаточной энтропией
+	ErrWeakKey = errors.New("key has too little entropy")
+)
+
+// NewKeyProvider создает провайдер по описанию источника.
+// Возвращает nil без ошибки, если источник не задан.
+func NewKeyProvider(source KeySource) (KeyProvider, error) {
+	var providers []KeyProvider
+	if source.Raw != "" {
+		providers = append(providers, StaticKeyProvider([]byte(source.Raw)))
+	}
+	if source.File != "" {
+		providers = append(providers, &FileKeyProvider{Path: source.File})
+	}
+	if source.Env != "" {
+		providers = append(providers, &EnvKeyProvider{Name: source.Env})
+	}
+	if source.Command != "" {
+		providers = append(providers, &ExecKeyProvider{Command: source.Command})
+	}
+
+	switch len(providers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return providers[0], nil
+	default:
+		return nil, errors.New("only one encryption key source can be configured")
+	}
+}
+
+// LoadKey получает ключ у провайдера и проверяет его длину и энтропию
+func LoadKey(ctx context.Context, provider KeyProvider) ([]byte, error) {
+	key, err := provider.Key(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ValidateKey проверяет, что ключ подходит для AES и не выглядит как пароль или шаблон.
+// Случайный ключ проходит проверку всегда; строки вроде "aaaa..." или "passwordpassword" — нет.
+func ValidateKey(key []byte) error {
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return fmt.Errorf("%w, got %d", ErrInvalidKeyLength, len(key))
+	}
+
+	counts := make(map[byte]int, len(key))
+	for _, b := range key {
+		counts[b]++
+	}
+
+	if len(counts) < len(key)/2 {
+		return ErrWeakKey
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(key))
+		entropy -= p * math.Log2(p)
+	}
+	if entropy < 3 {
+		return ErrWeakKey
+	}
+
+	return nil
+}
+
+// DecodeKey декодирует ключ, записанный в hex или base64 (стандартном или URL-safe, с дополнением или без)
+func DecodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, errors.New("key is empty")
+	}
+
+	if key, err := hex.DecodeString(encoded); err == nil {
+		return key, nil
+	}
+
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if key, err := encoding.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("key must be hex or base64 encoded")
+}
+
+// StaticKeyProvider возвращает заранее известный ключ
+type StaticKeyProvider []byte
+
+// Key возвращает ключ
+func (p StaticKeyProvider) Key(ctx context.Context) ([]byte, error) {
+	return []byte(p), nil
+}
+
+// FileKeyProvider читает ключ в hex или base64 из файла
+type FileKeyProvider struct {
+	Path string
+}
+
+// Key читает и декодирует ключ из файла
+func (p *FileKeyProvider) Key(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := DecodeKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", p.Path, err)
+	}
+	return key, nil
+}
+
+// EnvKeyProvider читает ключ в hex или base64 из переменной окружения
+type EnvKeyProvider struct {
+	Name string
+}
+
+// Key читает и декодирует ключ из переменной окружения
+func (p *EnvKeyProvider) Key(ctx context.Context) ([]byte, error) {
+	value, exists := os.LookupEnv(p.Name)
+	if !exists {
+		return nil, fmt.Errorf("environment variable %s is not set", p.Name)
+	}
+
+	key, err := DecodeKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %w", p.Name, err)
+	}
+	return key, nil
+}
+
+// ExecKeyProvider получает ключ от внешней программы (например, обертки над HSM или KMS).
+// Команда запускается без оболочки; аргументы разделяются пробелами.
+// Программа должна напечатать ключ в hex или base64 в stdout и завершиться с кодом 0.
+type ExecKeyProvider struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Key запускает команду и декодирует ее вывод
+func (p *ExecKeyProvider) Key(ctx context.Context) ([]byte, error) {
+	args := strings.Fields(p.Command)
+	if len(args) == 0 {
+		return nil, errors.New("key command is empty")
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = defaultKeyCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("key command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	key, err := DecodeKey(stdout.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid key command output: %w", err)
+	}
+	return key, nil
+}
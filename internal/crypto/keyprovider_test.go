@@ -0,0 +1,118 @@
+package crypto_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+func TestDecodeKey(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "hex", encoded: hex.EncodeToString(key)},
+		{name: "base64", encoded: base64.StdEncoding.EncodeToString(key)},
+		{name: "raw base64", encoded: base64.RawStdEncoding.EncodeToString(key)},
+		{name: "url base64", encoded: base64.URLEncoding.EncodeToString(key)},
+		{name: "trailing newline", encoded: hex.EncodeToString(key) + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := crypto.DecodeKey(tt.encoded)
+			require.NoError(t, err)
+			assert.Equal(t, key, decoded)
+		})
+	}
+
+	_, err = crypto.DecodeKey("not a key!")
+	assert.Error(t, err)
+	_, err = crypto.DecodeKey("  ")
+	assert.Error(t, err)
+}
+
+func TestValidateKey(t *testing.T) {
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+	assert.NoError(t, crypto.ValidateKey(key))
+
+	assert.ErrorIs(t, crypto.ValidateKey(key[:20]), crypto.ErrInvalidKeyLength)
+	assert.ErrorIs(t, crypto.ValidateKey(make([]byte, 32)), crypto.ErrWeakKey)
+	assert.ErrorIs(t, crypto.ValidateKey([]byte(strings.Repeat("ab", 16))), crypto.ErrWeakKey)
+	assert.ErrorIs(t, crypto.ValidateKey([]byte("passwordpassword")), crypto.ErrWeakKey)
+}
+
+func TestKeyProviders(t *testing.T) {
+	ctx := context.Background()
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+	encoded := hex.EncodeToString(key)
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "master.key")
+		require.NoError(t, os.WriteFile(path, []byte(encoded+"\n"), 0o600))
+
+		provider, err := crypto.NewKeyProvider(crypto.KeySource{File: path})
+		require.NoError(t, err)
+		loaded, err := crypto.LoadKey(ctx, provider)
+		require.NoError(t, err)
+		assert.Equal(t, key, loaded)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("GOPHKEEPER_TEST_MASTER_KEY", base64.StdEncoding.EncodeToString(key))
+
+		provider, err := crypto.NewKeyProvider(crypto.KeySource{Env: "GOPHKEEPER_TEST_MASTER_KEY"})
+		require.NoError(t, err)
+		loaded, err := crypto.LoadKey(ctx, provider)
+		require.NoError(t, err)
+		assert.Equal(t, key, loaded)
+
+		missing := &crypto.EnvKeyProvider{Name: "GOPHKEEPER_TEST_MISSING_KEY"}
+		_, err = missing.Key(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		provider, err := crypto.NewKeyProvider(crypto.KeySource{Command: "echo " + encoded})
+		require.NoError(t, err)
+		loaded, err := crypto.LoadKey(ctx, provider)
+		require.NoError(t, err)
+		assert.Equal(t, key, loaded)
+
+		failing := &crypto.ExecKeyProvider{Command: "false"}
+		_, err = failing.Key(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("weak raw key is rejected", func(t *testing.T) {
+		provider, err := crypto.NewKeyProvider(crypto.KeySource{Raw: strings.Repeat("a", 32)})
+		require.NoError(t, err)
+		_, err = crypto.LoadKey(ctx, provider)
+		assert.ErrorIs(t, err, crypto.ErrWeakKey)
+	})
+
+	t.Run("no source", func(t *testing.T) {
+		provider, err := crypto.NewKeyProvider(crypto.KeySource{})
+		require.NoError(t, err)
+		assert.Nil(t, provider)
+	})
+
+	t.Run("several sources", func(t *testing.T) {
+		_, err := crypto.NewKeyProvider(crypto.KeySource{Raw: "x", Env: "Y"})
+		assert.Error(t, err)
+	})
+}
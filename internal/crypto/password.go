@@ -1,48 +1,186 @@
 package crypto
 
 import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// PasswordHasher предоставляет методы для работы с паролями
-type PasswordHasher struct {
-	cost int
+// argon2idHashPrefix префикс хеша Argon2id в формате PHC:
+// $argon2id$v=19$m=<память KiB>,t=<итерации>,p=<потоки>$<соль>$<хеш>
+const argon2idHashPrefix = "$argon2id$"
+
+// ErrUnsupportedPasswordHash возвращается для хеша неизвестного формата
+var ErrUnsupportedPasswordHash = errors.New("unsupported password hash format")
+
+// PasswordHasher хеширует пароли и проверяет их.
+// Проверка принимает хеши всех поддерживаемых форматов, а NeedsRehash сообщает,
+// что хеш следует пересчитать с текущими параметрами.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Check(password, hash string) bool
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idParams параметры хеширования паролей Argon2id
+type Argon2idParams struct {
+	Iterations  uint32
+	Memory      uint32 // объем памяти в KiB
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams возвращает параметры хеширования паролей по умолчанию
+// (рекомендация OWASP для серверной проверки паролей)
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Iterations:  2,
+		Memory:      19 * 1024,
+		Parallelism: 1,
+		SaltLength:  SaltSize,
+		KeyLength:   32,
+	}
 }
 
-// NewPasswordHasher создает новый хешер паролей
-func NewPasswordHasher(cost int) *PasswordHasher {
-	if cost == 0 {
-		cost = bcrypt.DefaultCost
+// Validate проверяет, что параметры не ниже допустимого минимума
+func (p Argon2idParams) Validate() error {
+	if p.Iterations < minKDFIterations || p.Iterations > maxKDFIterations {
+		return fmt.Errorf("argon2id iterations must be between %d and %d", minKDFIterations, maxKDFIterations)
+	}
+	if p.Memory < minKDFMemory || p.Memory > maxKDFMemory {
+		return fmt.Errorf("argon2id memory must be between %d and %d KiB", minKDFMemory, maxKDFMemory)
+	}
+	if p.Parallelism == 0 {
+		return errors.New("argon2id parallelism must be positive")
 	}
-	return &PasswordHasher{cost: cost}
+	if p.SaltLength < SaltSize {
+		return fmt.Errorf("argon2id salt must be at least %d bytes", SaltSize)
+	}
+	if p.KeyLength < 16 {
+		return errors.New("argon2id key length must be at least 16 bytes")
+	}
+	return nil
 }
 
-// Hash создает хеш пароля
-func (h *PasswordHasher) Hash(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+// weakerThan сообщает, что хотя бы один параметр слабее заданного
+func (p Argon2idParams) weakerThan(target Argon2idParams) bool {
+	return p.Iterations < target.Iterations ||
+		p.Memory < target.Memory ||
+		p.Parallelism < target.Parallelism ||
+		p.SaltLength < target.SaltLength ||
+		p.KeyLength < target.KeyLength
+}
+
+// Argon2idHasher хеширует пароли алгоритмом Argon2id.
+// Хеши bcrypt, созданные прежними версиями сервера, по-прежнему проверяются.
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher создает хешер паролей Argon2id
+func NewArgon2idHasher(params Argon2idParams) (*Argon2idHasher, error) {
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid password hash params: %w", err)
+	}
+	return &Argon2idHasher{params: params}, nil
+}
+
+// Hash создает хеш пароля в формате PHC
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := GenerateSalt(int(h.params.SaltLength))
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
-	return string(bytes), nil
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idHashPrefix, argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Check проверяет пароль против хеша Argon2id или bcrypt
+func (h *Argon2idHasher) Check(password, hash string) bool {
+	return CheckPasswordHash(password, hash)
+}
+
+// NeedsRehash сообщает, что хеш создан другим алгоритмом или с более слабыми параметрами
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return params.weakerThan(h.params)
 }
 
-// Check проверяет пароль против хеша
-func (h *PasswordHasher) Check(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// parseArgon2idHash разбирает хеш Argon2id в формате PHC
+func parseArgon2idHash(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	if !strings.HasPrefix(hash, argon2idHashPrefix) {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+
+	parts := strings.Split(strings.TrimPrefix(hash, argon2idHashPrefix), "$")
+	if len(parts) != 4 {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	if err := params.Validate(); err != nil {
+		return params, nil, nil, ErrUnsupportedPasswordHash
+	}
+
+	return params, salt, key, nil
 }
 
 // DefaultPasswordHasher хешер с настройками по умолчанию
-var DefaultPasswordHasher = NewPasswordHasher(bcrypt.DefaultCost)
+var DefaultPasswordHasher PasswordHasher = &Argon2idHasher{params: DefaultArgon2idParams()}
 
 // HashPassword хеширует пароль с настройками по умолчанию
 func HashPassword(password string) (string, error) {
 	return DefaultPasswordHasher.Hash(password)
 }
 
-// CheckPasswordHash проверяет пароль против хеша
+// CheckPasswordHash проверяет пароль против хеша Argon2id или bcrypt.
+// Формат определяется по префиксу хеша, поэтому параметры хешера не важны.
 func CheckPasswordHash(password, hash string) bool {
-	return DefaultPasswordHasher.Check(password, hash)
+	if !strings.HasPrefix(hash, argon2idHashPrefix) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	params, salt, key, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(computed, key) == 1
 }
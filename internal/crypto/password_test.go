@@ -1,10 +1,12 @@
 package crypto_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/alisaviation/GophKeeper/internal/crypto"
 )
@@ -30,3 +32,65 @@ func TestPasswordHasher(t *testing.T) {
 	valid = crypto.CheckPasswordHash(password, hash2)
 	assert.True(t, valid)
 }
+
+func TestArgon2idHasher(t *testing.T) {
+	params := crypto.DefaultArgon2idParams()
+	hasher, err := crypto.NewArgon2idHasher(params)
+	require.NoError(t, err)
+
+	t.Run("self-describing hash", func(t *testing.T) {
+		hash, err := hasher.Hash("password")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$"))
+		assert.True(t, hasher.Check("password", hash))
+		assert.False(t, hasher.Check("wrong", hash))
+		assert.False(t, hasher.NeedsRehash(hash))
+	})
+
+	t.Run("long passwords are not truncated", func(t *testing.T) {
+		long := strings.Repeat("a", 100)
+		hash, err := hasher.Hash(long)
+		require.NoError(t, err)
+		assert.False(t, hasher.Check(long[:72], hash))
+		assert.True(t, hasher.Check(long, hash))
+	})
+
+	t.Run("legacy bcrypt hash", func(t *testing.T) {
+		legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		require.NoError(t, err)
+		assert.True(t, hasher.Check("password", string(legacy)))
+		assert.False(t, hasher.Check("wrong", string(legacy)))
+		assert.True(t, hasher.NeedsRehash(string(legacy)))
+	})
+
+	t.Run("weaker parameters need rehash", func(t *testing.T) {
+		weakParams := params
+		weakParams.Memory = 16 * 1024
+		weak, err := crypto.NewArgon2idHasher(weakParams)
+		require.NoError(t, err)
+
+		hash, err := weak.Hash("password")
+		require.NoError(t, err)
+		assert.True(t, hasher.Check("password", hash))
+		assert.True(t, hasher.NeedsRehash(hash))
+
+		// Более сильные параметры не понижаются
+		assert.False(t, weak.NeedsRehash(hash))
+		strongHash, err := hasher.Hash("password")
+		require.NoError(t, err)
+		assert.False(t, weak.NeedsRehash(strongHash))
+	})
+
+	t.Run("malformed hash", func(t *testing.T) {
+		assert.False(t, hasher.Check("password", "$argon2id$v=19$m=19456,t=2,p=1$bad"))
+		assert.False(t, hasher.Check("password", ""))
+		assert.True(t, hasher.NeedsRehash("$argon2id$v=18$m=19456,t=2,p=1$c2FsdA$aGFzaA"))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		invalid := params
+		invalid.Memory = 1024
+		_, err := crypto.NewArgon2idHasher(invalid)
+		assert.Error(t, err)
+	})
+}
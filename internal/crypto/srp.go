@@ -0,0 +1,291 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Протокол SRP-6a (RFC 5054) с группой 3072 бит и SHA-256. Клиент доказывает знание
+// мастер-пароля, не передавая его: сервер хранит только верификатор v = g^x mod N,
+// по которому нельзя войти и из которого нельзя получить ключ хранилища иначе как
+// перебором паролей через Argon2id.
+
+// srpPasswordInfo контекст HKDF для секрета SRP, получаемого из мастер-ключа
+const srpPasswordInfo = "gophkeeper/srp-password"
+
+// SRPSaltSize размер соли верификатора
+const SRPSaltSize = 16
+
+// srpEphemeralSize размер секретных эфемерных значений a и b
+const srpEphemeralSize = 32
+
+var (
+	// ErrSRPInvalidEphemeral недопустимое открытое эфемерное значение собеседника
+	ErrSRPInvalidEphemeral = errors.New("invalid srp ephemeral value")
+	// ErrSRPProofMismatch доказательство собеседника не совпало: неверный пароль или подмена сервера
+	ErrSRPProofMismatch = errors.New("srp proof mismatch")
+)
+
+// Группа 3072 бит из RFC 5054, приложение A
+var (
+	srpN = mustParseHex(strings.Join([]string{
+		"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74",
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437",
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED",
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05",
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB",
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B",
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718",
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33",
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7",
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864",
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2",
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF",
+	}, ""))
+	srpG = big.NewInt(5)
+	srpK = new(big.Int).SetBytes(srpHash(srpPad(srpN), srpPad(srpG)))
+)
+
+// srpLen длина элемента группы в байтах
+var srpLen = (srpN.BitLen() + 7) / 8
+
+// SRPPasswordKey получает из мастер-ключа секрет, от которого вычисляется верификатор.
+// Он независим от ключа, которым защищен ключ хранилища.
+func SRPPasswordKey(masterKey []byte) ([]byte, error) {
+	return DeriveSubKey(masterKey, srpPasswordInfo)
+}
+
+// ComputeSRPVerifier вычисляет верификатор, который сохраняется на сервере вместо пароля
+func ComputeSRPVerifier(login string, salt, passwordKey []byte) []byte {
+	x := srpPrivateKey(login, salt, passwordKey)
+	return srpPad(new(big.Int).Exp(srpG, x, srpN))
+}
+
+// ValidateSRPVerifier проверяет, что верификатор является элементом группы
+func ValidateSRPVerifier(verifier []byte) error {
+	if len(verifier) == 0 || len(verifier) > srpLen {
+		return fmt.Errorf("srp verifier must be 1..%d bytes", srpLen)
+	}
+	v := new(big.Int).SetBytes(verifier)
+	if v.Cmp(big.NewInt(1)) <= 0 || v.Cmp(srpN) >= 0 {
+		return errors.New("srp verifier is out of range")
+	}
+	return nil
+}
+
+// SRPClient клиентская сторона одного обмена SRP
+type SRPClient struct {
+	login   string
+	a       *big.Int
+	publicA []byte
+	key     []byte
+	proof   []byte
+}
+
+// NewSRPClient начинает обмен. Значение A не зависит от пароля, поэтому его можно отправить
+// до получения от сервера соли и параметров KDF, нужных для вычисления секрета.
+func NewSRPClient(login string) (*SRPClient, error) {
+	a, err := srpRandomEphemeral()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SRPClient{
+		login:   login,
+		a:       a,
+		publicA: srpPad(new(big.Int).Exp(srpG, a, srpN)),
+	}, nil
+}
+
+// PublicEphemeral возвращает открытое значение A для отправки серверу
+func (c *SRPClient) PublicEphemeral() []byte {
+	return c.publicA
+}
+
+// Proof вычисляет доказательство знания пароля M1 по секрету из мастер-ключа,
+// соли и открытому значению B сервера
+func (c *SRPClient) Proof(passwordKey, salt, serverEphemeral []byte) ([]byte, error) {
+	B, err := srpParseEphemeral(serverEphemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	u := srpScramble(c.publicA, srpPad(B))
+	if u.Sign() == 0 {
+		return nil, ErrSRPInvalidEphemeral
+	}
+
+	// S = (B - k*g^x)^(a + u*x) mod N
+	x := srpPrivateKey(c.login, salt, passwordKey)
+	kgx := new(big.Int).Mul(srpK, new(big.Int).Exp(srpG, x, srpN))
+	base := new(big.Int).Mod(new(big.Int).Sub(B, kgx), srpN)
+	exp := new(big.Int).Add(c.a, new(big.Int).Mul(u, x))
+	S := new(big.Int).Exp(base, exp, srpN)
+
+	c.key = srpHash(srpPad(S))
+	c.proof = srpClientProof(c.login, salt, c.publicA, srpPad(B), c.key)
+	return c.proof, nil
+}
+
+// VerifyServerProof проверяет доказательство M2: сервер действительно знает верификатор
+func (c *SRPClient) VerifyServerProof(proof []byte) error {
+	if c.proof == nil {
+		return errors.New("client proof is not computed")
+	}
+	expected := srpServerProof(c.publicA, c.proof, c.key)
+	if subtle.ConstantTimeCompare(expected, proof) != 1 {
+		return ErrSRPProofMismatch
+	}
+	return nil
+}
+
+// SessionKey возвращает общий ключ обмена
+func (c *SRPClient) SessionKey() []byte {
+	return c.key
+}
+
+// SRPServer серверная сторона одного обмена SRP
+type SRPServer struct {
+	login    string
+	salt     []byte
+	verifier *big.Int
+	b        *big.Int
+	publicA  []byte
+	publicB  []byte
+	key      []byte
+}
+
+// NewSRPServer начинает обмен для пользователя с верификатором verifier
+// по открытому значению A клиента
+func NewSRPServer(login string, salt, verifier, clientEphemeral []byte) (*SRPServer, error) {
+	if err := ValidateSRPVerifier(verifier); err != nil {
+		return nil, err
+	}
+	A, err := srpParseEphemeral(clientEphemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := srpRandomEphemeral()
+	if err != nil {
+		return nil, err
+	}
+
+	// B = (k*v + g^b) mod N
+	v := new(big.Int).SetBytes(verifier)
+	B := new(big.Int).Add(new(big.Int).Mul(srpK, v), new(big.Int).Exp(srpG, b, srpN))
+	B.Mod(B, srpN)
+
+	return &SRPServer{
+		login:    login,
+		salt:     salt,
+		verifier: v,
+		b:        b,
+		publicA:  srpPad(A),
+		publicB:  srpPad(B),
+	}, nil
+}
+
+// PublicEphemeral возвращает открытое значение B для отправки клиенту
+func (s *SRPServer) PublicEphemeral() []byte {
+	return s.publicB
+}
+
+// VerifyClientProof проверяет доказательство клиента M1 и возвращает доказательство сервера M2
+func (s *SRPServer) VerifyClientProof(proof []byte) ([]byte, error) {
+	u := srpScramble(s.publicA, s.publicB)
+	if u.Sign() == 0 {
+		return nil, ErrSRPInvalidEphemeral
+	}
+
+	// S = (A * v^u)^b mod N
+	A := new(big.Int).SetBytes(s.publicA)
+	base := new(big.Int).Mul(A, new(big.Int).Exp(s.verifier, u, srpN))
+	S := new(big.Int).Exp(base.Mod(base, srpN), s.b, srpN)
+	key := srpHash(srpPad(S))
+
+	expected := srpClientProof(s.login, s.salt, s.publicA, s.publicB, key)
+	if subtle.ConstantTimeCompare(expected, proof) != 1 {
+		return nil, ErrSRPProofMismatch
+	}
+
+	s.key = key
+	return srpServerProof(s.publicA, proof, key), nil
+}
+
+// SessionKey возвращает общий ключ обмена после успешной проверки клиента
+func (s *SRPServer) SessionKey() []byte {
+	return s.key
+}
+
+// srpPrivateKey x = H(s | H(I | ":" | P)), где вместо пароля используется секрет из мастер-ключа
+func srpPrivateKey(login string, salt, passwordKey []byte) *big.Int {
+	inner := srpHash([]byte(login), []byte(":"), passwordKey)
+	return new(big.Int).SetBytes(srpHash(salt, inner))
+}
+
+// srpScramble u = H(PAD(A) | PAD(B))
+func srpScramble(publicA, publicB []byte) *big.Int {
+	return new(big.Int).SetBytes(srpHash(publicA, publicB))
+}
+
+// srpClientProof M1 = H(H(N) xor H(g) | H(I) | s | A | B | K)
+func srpClientProof(login string, salt, publicA, publicB, key []byte) []byte {
+	hN := srpHash(srpPad(srpN))
+	hG := srpHash(srpPad(srpG))
+	for i := range hN {
+		hN[i] ^= hG[i]
+	}
+	return srpHash(hN, srpHash([]byte(login)), salt, publicA, publicB, key)
+}
+
+// srpServerProof M2 = H(A | M1 | K)
+func srpServerProof(publicA, clientProof, key []byte) []byte {
+	return srpHash(publicA, clientProof, key)
+}
+
+// srpParseEphemeral разбирает открытое значение собеседника; значения, кратные N, запрещены
+func srpParseEphemeral(data []byte) (*big.Int, error) {
+	if len(data) == 0 || len(data) > srpLen {
+		return nil, ErrSRPInvalidEphemeral
+	}
+	value := new(big.Int).SetBytes(data)
+	if new(big.Int).Mod(value, srpN).Sign() == 0 {
+		return nil, ErrSRPInvalidEphemeral
+	}
+	return value, nil
+}
+
+func srpRandomEphemeral() (*big.Int, error) {
+	buf := make([]byte, srpEphemeralSize)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate srp ephemeral: %w", err)
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// srpPad дополняет число нулями слева до длины N
+func srpPad(value *big.Int) []byte {
+	return value.FillBytes(make([]byte, srpLen))
+}
+
+func srpHash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+func mustParseHex(s string) *big.Int {
+	value, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex constant")
+	}
+	return value
+}
@@ -0,0 +1,76 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+func TestSRP(t *testing.T) {
+	masterKey := make([]byte, crypto.DerivedKeySize)
+	passwordKey, err := crypto.SRPPasswordKey(masterKey)
+	require.NoError(t, err)
+
+	salt, err := crypto.GenerateSalt(crypto.SRPSaltSize)
+	require.NoError(t, err)
+	verifier := crypto.ComputeSRPVerifier("alice", salt, passwordKey)
+	require.NoError(t, crypto.ValidateSRPVerifier(verifier))
+
+	t.Run("matching password", func(t *testing.T) {
+		client, err := crypto.NewSRPClient("alice")
+		require.NoError(t, err)
+
+		server, err := crypto.NewSRPServer("alice", salt, verifier, client.PublicEphemeral())
+		require.NoError(t, err)
+
+		clientProof, err := client.Proof(passwordKey, salt, server.PublicEphemeral())
+		require.NoError(t, err)
+
+		serverProof, err := server.VerifyClientProof(clientProof)
+		require.NoError(t, err)
+		require.NoError(t, client.VerifyServerProof(serverProof))
+		assert.Equal(t, client.SessionKey(), server.SessionKey())
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		wrongKey, err := crypto.SRPPasswordKey(append(make([]byte, crypto.DerivedKeySize-1), 1))
+		require.NoError(t, err)
+
+		client, err := crypto.NewSRPClient("alice")
+		require.NoError(t, err)
+
+		server, err := crypto.NewSRPServer("alice", salt, verifier, client.PublicEphemeral())
+		require.NoError(t, err)
+
+		clientProof, err := client.Proof(wrongKey, salt, server.PublicEphemeral())
+		require.NoError(t, err)
+
+		_, err = server.VerifyClientProof(clientProof)
+		assert.ErrorIs(t, err, crypto.ErrSRPProofMismatch)
+	})
+
+	t.Run("forged server proof", func(t *testing.T) {
+		client, err := crypto.NewSRPClient("alice")
+		require.NoError(t, err)
+
+		server, err := crypto.NewSRPServer("alice", salt, verifier, client.PublicEphemeral())
+		require.NoError(t, err)
+
+		_, err = client.Proof(passwordKey, salt, server.PublicEphemeral())
+		require.NoError(t, err)
+		assert.ErrorIs(t, client.VerifyServerProof(make([]byte, 32)), crypto.ErrSRPProofMismatch)
+	})
+
+	t.Run("zero ephemeral is rejected", func(t *testing.T) {
+		_, err := crypto.NewSRPServer("alice", salt, verifier, make([]byte, 384))
+		assert.ErrorIs(t, err, crypto.ErrSRPInvalidEphemeral)
+
+		client, err := crypto.NewSRPClient("alice")
+		require.NoError(t, err)
+		_, err = client.Proof(passwordKey, salt, []byte{0})
+		assert.ErrorIs(t, err, crypto.ErrSRPInvalidEphemeral)
+	})
+}
@@ -0,0 +1,171 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Одноразовые пароли TOTP (RFC 6238) с параметрами, которые понимают все приложения-аутентификаторы:
+// HMAC-SHA1, 6 цифр, шаг 30 секунд.
+
+const (
+	// TOTPSecretSize размер секрета TOTP (160 бит, как рекомендует RFC 4226)
+	TOTPSecretSize = 20
+	// TOTPDigits число цифр в коде
+	TOTPDigits = 6
+	// TOTPPeriod длительность шага времени
+	TOTPPeriod = 30 * time.Second
+	// totpSkew число соседних шагов, коды которых еще принимаются, на случай расхождения часов
+	totpSkew = 1
+)
+
+// recoveryCodeAlphabet алфавит кодов восстановления без похожих символов (0/O, 1/I/L)
+const recoveryCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// recoveryCodeLength число символов кода восстановления (около 50 бит)
+const recoveryCodeLength = 10
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret генерирует новый секрет TOTP
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, TOTPSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret кодирует секрет в base32 для ручного ввода в приложение-аутентификатор
+func EncodeTOTPSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPURI формирует ссылку otpauth:// для добавления секрета в приложение-аутентификатор
+func TOTPURI(issuer, account string, secret []byte) string {
+	params := url.Values{}
+	params.Set("secret", EncodeTOTPSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: params.Encode(),
+	}
+	return uri.String()
+}
+
+// TOTPStep возвращает номер шага времени для момента t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode вычисляет код для шага времени step (RFC 4226, раздел 5.3)
+func TOTPCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range TOTPDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
+
+// ValidateTOTP проверяет код на момент now с допуском в один шаг в каждую сторону.
+// Возвращает шаг, которому соответствует код: повторно код этого шага принимать нельзя.
+func ValidateTOTP(secret []byte, code string, now time.Time) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for delta := int64(-totpSkew); delta <= totpSkew; delta++ {
+		step := current + delta
+		if subtle.ConstantTimeCompare([]byte(TOTPCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode сообщает, что строка похожа на код приложения, а не на код восстановления
+func IsTOTPCode(code string) bool {
+	if len(code) != TOTPDigits {
+		return false
+	}
+	for _, char := range code {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes генерирует count одноразовых кодов восстановления вида XXXXX-XXXXX
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, 0, count)
+	for range count {
+		var code strings.Builder
+		for code.Len() < recoveryCodeLength+1 {
+			if code.Len() == recoveryCodeLength/2 {
+				code.WriteByte('-')
+			}
+			char, err := randomRecoveryChar()
+			if err != nil {
+				return nil, err
+			}
+			code.WriteByte(char)
+		}
+		codes = append(codes, code.String())
+	}
+	return codes, nil
+}
+
+// randomRecoveryChar выбирает случайный символ алфавита кодов восстановления без смещения:
+// байты за пределами наибольшего кратного длине алфавита отбрасываются
+func randomRecoveryChar() (byte, error) {
+	limit := 256 - 256%len(recoveryCodeAlphabet)
+	var b [1]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		if int(b[0]) < limit {
+			return recoveryCodeAlphabet[int(b[0])%len(recoveryCodeAlphabet)], nil
+		}
+	}
+}
+
+// NormalizeRecoveryCode приводит введенный код восстановления к каноническому виду:
+// без разделителей и пробелов, в верхнем регистре
+func NormalizeRecoveryCode(code string) string {
+	return strings.Map(func(char rune) rune {
+		switch {
+		case char == '-' || char == ' ':
+			return -1
+		case char >= 'a' && char <= 'z':
+			return char - 'a' + 'A'
+		}
+		return char
+	}, code)
+}
@@ -0,0 +1,93 @@
+package crypto_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Тестовые векторы RFC 6238, приложение B (SHA-1), последние 6 цифр
+	secret := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		step := crypto.TOTPStep(time.Unix(unix, 0))
+		assert.Equal(t, expected, crypto.TOTPCode(secret, step), "time %d", unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := crypto.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	current := crypto.TOTPStep(now)
+
+	tests := []struct {
+		name   string
+		code   string
+		valid  bool
+		expect int64
+	}{
+		{name: "current step", code: crypto.TOTPCode(secret, current), valid: true, expect: current},
+		{name: "previous step", code: crypto.TOTPCode(secret, current-1), valid: true, expect: current - 1},
+		{name: "next step", code: crypto.TOTPCode(secret, current+1), valid: true, expect: current + 1},
+		{name: "expired code", code: crypto.TOTPCode(secret, current-2), valid: false},
+		{name: "wrong length", code: "12345", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := crypto.ValidateTOTP(secret, tt.code, now)
+			assert.Equal(t, tt.valid, ok)
+			if tt.valid {
+				assert.Equal(t, tt.expect, step)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	uri, err := url.Parse(crypto.TOTPURI("GophKeeper", "alice", secret))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/GophKeeper:alice", uri.Path)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", uri.Query().Get("secret"))
+	assert.Equal(t, "GophKeeper", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := crypto.GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		assert.Regexp(t, `^[2-9A-HJKMNP-Z]{5}-[2-9A-HJKMNP-Z]{5}$`, code)
+		assert.False(t, seen[code], "duplicate code %s", code)
+		seen[code] = true
+
+		assert.Equal(t, strings.ReplaceAll(code, "-", ""), crypto.NormalizeRecoveryCode(strings.ToLower(code)))
+		assert.False(t, crypto.IsTOTPCode(code))
+	}
+
+	assert.True(t, crypto.IsTOTPCode("012345"))
+}
@@ -0,0 +1,97 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// VaultKeySize размер ключа хранилища
+const VaultKeySize = 32
+
+// kekInfo контекст HKDF для ключа, которым шифруется ключ хранилища
+const kekInfo = "gophkeeper/vault-key-encryption-key"
+
+// keyIDInfo контекст HKDF для идентификатора ключа
+const keyIDInfo = "gophkeeper/key-id"
+
+// keyIDSize длина идентификатора ключа в байтах
+const keyIDSize = 8
+
+// ErrVaultKeyUnwrap ошибка расшифровки защищенного ключа (например, неверный мастер-пароль)
+var ErrVaultKeyUnwrap = errors.New("failed to unwrap vault key")
+
+// GenerateVaultKey генерирует новый случайный ключ хранилища
+func GenerateVaultKey() ([]byte, error) {
+	return GenerateKey(VaultKeySize)
+}
+
+// DeriveSubKey получает из мастер-ключа независимый подключ для заданного назначения
+func DeriveSubKey(masterKey []byte, info string) ([]byte, error) {
+	if len(masterKey) != DerivedKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes", DerivedKeySize)
+	}
+
+	subKey := make([]byte, DerivedKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, masterKey, nil, []byte(info)), subKey); err != nil {
+		return nil, fmt.Errorf("failed to derive sub key: %w", err)
+	}
+
+	return subKey, nil
+}
+
+// KeyID возвращает короткий идентификатор ключа, не раскрывающий сам ключ.
+// Идентификатор сохраняется рядом с шифротекстом и позволяет понять,
+// каким ключом он был зашифрован.
+func KeyID(key []byte) string {
+	return hex.EncodeToString(keyIDBytes(key))
+}
+
+func keyIDBytes(key []byte) []byte {
+	id := make([]byte, keyIDSize)
+	// HKDF не может вернуть ошибку при запросе 8 байт
+	_, _ = io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(keyIDInfo)), id)
+	return id
+}
+
+// WrapVaultKey шифрует ключ хранилища ключом, полученным из мастер-ключа пользователя
+func WrapVaultKey(masterKey, vaultKey []byte) ([]byte, error) {
+	if len(vaultKey) != VaultKeySize {
+		return nil, fmt.Errorf("vault key must be %d bytes", VaultKeySize)
+	}
+
+	encryptor, err := newKEKEncryptor(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptor.Encrypt(vaultKey, nil)
+}
+
+// UnwrapVaultKey расшифровывает защищенный ключ хранилища мастер-ключом пользователя
+func UnwrapVaultKey(masterKey, protectedKey []byte) ([]byte, error) {
+	encryptor, err := newKEKEncryptor(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	vaultKey, err := encryptor.Decrypt(protectedKey, nil)
+	if err != nil || len(vaultKey) != VaultKeySize {
+		return nil, ErrVaultKeyUnwrap
+	}
+
+	return vaultKey, nil
+}
+
+func newKEKEncryptor(masterKey []byte) (*AESGCMEncryptor, error) {
+	kek, err := DeriveSubKey(masterKey, kekInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAESGCMEncryptor(kek)
+}
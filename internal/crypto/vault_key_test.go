@@ -0,0 +1,77 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+)
+
+func TestWrapUnwrapVaultKey(t *testing.T) {
+	params := testKDFParams(t)
+	masterKey, err := crypto.DeriveKey("master-password", params)
+	require.NoError(t, err)
+
+	vaultKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+
+	protected, err := crypto.WrapVaultKey(masterKey, vaultKey)
+	require.NoError(t, err)
+	assert.NotContains(t, string(protected), string(vaultKey))
+
+	unwrapped, err := crypto.UnwrapVaultKey(masterKey, protected)
+	require.NoError(t, err)
+	assert.Equal(t, vaultKey, unwrapped)
+
+	t.Run("wrong password", func(t *testing.T) {
+		otherKey, err := crypto.DeriveKey("other-password", params)
+		require.NoError(t, err)
+
+		_, err = crypto.UnwrapVaultKey(otherKey, protected)
+		assert.ErrorIs(t, err, crypto.ErrVaultKeyUnwrap)
+	})
+
+	t.Run("tampered blob", func(t *testing.T) {
+		tampered := append([]byte(nil), protected...)
+		tampered[len(tampered)-1] ^= 0xff
+
+		_, err := crypto.UnwrapVaultKey(masterKey, tampered)
+		assert.ErrorIs(t, err, crypto.ErrVaultKeyUnwrap)
+	})
+
+	t.Run("invalid vault key size", func(t *testing.T) {
+		_, err := crypto.WrapVaultKey(masterKey, []byte("short"))
+		assert.Error(t, err)
+	})
+}
+
+func TestDeriveSubKey(t *testing.T) {
+	masterKey, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+
+	a, err := crypto.DeriveSubKey(masterKey, "purpose-a")
+	require.NoError(t, err)
+	b, err := crypto.DeriveSubKey(masterKey, "purpose-b")
+	require.NoError(t, err)
+
+	assert.Len(t, a, crypto.DerivedKeySize)
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, masterKey, a)
+
+	_, err = crypto.DeriveSubKey([]byte("short"), "purpose-a")
+	assert.Error(t, err)
+}
+
+func TestKeyID(t *testing.T) {
+	key, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+	other, err := crypto.GenerateVaultKey()
+	require.NoError(t, err)
+
+	id := crypto.KeyID(key)
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, crypto.KeyID(key))
+	assert.NotEqual(t, id, crypto.KeyID(other))
+}
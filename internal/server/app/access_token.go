@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// AccessTokenPrefix отличает токены доступа от JWT: токен имеет вид "gkp_<id>.<секрет>"
+const AccessTokenPrefix = "gkp_"
+
+// accessTokenSecretSize размер секрета токена доступа в байтах
+const accessTokenSecretSize = 32
+
+// maxAccessTokenNameLength ограничивает длину имени токена доступа
+const maxAccessTokenNameLength = 64
+
+var errAccessTokensNotConfigured = errors.New("access token repository is not configured")
+
+// AccessTokenRequest параметры нового токена доступа
+type AccessTokenRequest struct {
+	Name       string
+	SecretIDs  []string
+	Tags       []string
+	Permission domain.AccessTokenPermission
+	ExpiresAt  *time.Time // nil — бессрочный токен
+	// DelegatedKey ключ хранилища, зашифрованный клиентом ключом, который остается у владельца токена
+	DelegatedKey []byte
+}
+
+// WithAccessTokens задает хранилище токенов доступа для автоматизации
+func WithAccessTokens(accessTokens interfaces.AccessTokenRepository) AuthServiceOption {
+	return func(s *AuthService) {
+		s.accessTokens = accessTokens
+	}
+}
+
+// CreateAccessToken выпускает токен доступа к секретам req.SecretIDs и возвращает его вместе
+// со строкой токена. Строка показывается один раз: сервер хранит только хеш ее секрета.
+func (s *AuthService) CreateAccessToken(ctx context.Context, userID string, req AccessTokenRequest) (*domain.AccessToken, string, error) {
+	if s.accessTokens == nil {
+		return nil, "", errAccessTokensNotConfigured
+	}
+
+	now := time.Now()
+	if err := validateAccessTokenRequest(&req, now); err != nil {
+		return nil, "", err
+	}
+
+	secret := make([]byte, accessTokenSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate access token secret: %w", err)
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	token := &domain.AccessToken{
+		ID:           domain.GenerateID(),
+		UserID:       userID,
+		Name:         req.Name,
+		SecretHash:   hashAccessTokenSecret(encodedSecret),
+		SecretIDs:    req.SecretIDs,
+		Tags:         req.Tags,
+		Permission:   req.Permission,
+		DelegatedKey: req.DelegatedKey,
+		CreatedAt:    now,
+		ExpiresAt:    req.ExpiresAt,
+	}
+	if err := s.accessTokens.Create(ctx, token); err != nil {
+		return nil, "", fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	return token, AccessTokenPrefix + token.ID + "." + encodedSecret, nil
+}
+
+// ListAccessTokens возвращает токены доступа пользователя, включая истекшие
+func (s *AuthService) ListAccessTokens(ctx context.Context, userID string) ([]*domain.AccessToken, error) {
+	if s.accessTokens == nil {
+		return nil, errAccessTokensNotConfigured
+	}
+
+	tokens, err := s.accessTokens.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeAccessToken отзывает токен доступа пользователя; токен сразу перестает приниматься
+func (s *AuthService) RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	if s.accessTokens == nil {
+		return errAccessTokensNotConfigured
+	}
+
+	token, err := s.accessTokens.GetByID(ctx, tokenID)
+	if err != nil {
+		if err == domain.ErrAccessTokenNotFound {
+			return err
+		}
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	// Чужие токены считаются несуществующими
+	if token.UserID != userID {
+		return domain.ErrAccessTokenNotFound
+	}
+
+	if err := s.accessTokens.Delete(ctx, tokenID); err != nil {
+		return fmt.Errorf("failed to delete access token: %w", err)
+	}
+	return nil
+}
+
+// authenticateAccessToken проверяет токен доступа. Вход токеном не привязан к сессии
+// и устройству; что доступно токену, решает перехватчик аутентификации.
+func (s *AuthService) authenticateAccessToken(ctx context.Context, tokenString string) (*Principal, error) {
+	if s.accessTokens == nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	tokenID, secret, ok := strings.Cut(strings.TrimPrefix(tokenString, AccessTokenPrefix), ".")
+	if !ok || tokenID == "" || secret == "" {
+		return nil, domain.ErrInvalidToken
+	}
+
+	token, err := s.accessTokens.GetByID(ctx, tokenID)
+	if err != nil {
+		if err == domain.ErrAccessTokenNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAccessTokenSecret(secret)), []byte(token.SecretHash)) != 1 {
+		return nil, domain.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && !now.Before(*token.ExpiresAt) {
+		return nil, domain.ErrTokenExpired
+	}
+
+	user, err := s.users.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if user.Disabled() {
+		return nil, domain.ErrAccountDisabled
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= sessionTouchInterval {
+		if err := s.accessTokens.UpdateLastUsed(ctx, token.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to update access token: %w", err)
+		}
+		token.LastUsedAt = &now
+	}
+
+	return &Principal{User: user, AccessToken: token, DeviceTrusted: true}, nil
+}
+
+// validateAccessTokenRequest проверяет и нормализует параметры нового токена
+func validateAccessTokenRequest(req *AccessTokenRequest, now time.Time) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return domain.ValidationError{Field: "name", Message: "is required"}
+	}
+	if utf8.RuneCountInString(req.Name) > maxAccessTokenNameLength {
+		return domain.ValidationError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxAccessTokenNameLength)}
+	}
+
+	req.SecretIDs = uniqueNonEmpty(req.SecretIDs)
+	if len(req.SecretIDs) == 0 {
+		return domain.ValidationError{Field: "secret_ids", Message: "at least one secret is required"}
+	}
+	req.Tags = uniqueNonEmpty(req.Tags)
+
+	switch req.Permission {
+	case domain.AccessTokenRead, domain.AccessTokenReadWrite:
+	default:
+		return domain.ValidationError{Field: "permission", Message: "must be read or read_write"}
+	}
+
+	if len(req.DelegatedKey) == 0 {
+		return domain.ValidationError{Field: "delegated_key", Message: "is required"}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return domain.ValidationError{Field: "expires_at", Message: "must be in the future"}
+	}
+	return nil
+}
+
+// uniqueNonEmpty убирает пустые строки и повторы, сохраняя порядок
+func uniqueNonEmpty(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	return result
+}
+
+// hashAccessTokenSecret возвращает SHA-256 секрета токена доступа: в хранилище попадает только хеш
+func hashAccessTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
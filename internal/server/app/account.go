@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+)
+
+// AccountExport все, что сервер хранит о пользователе. Секреты и ключ хранилища остаются
+// зашифрованными на клиенте; хеш пароля, верификатор SRP и секрет TOTP в выгрузку не входят.
+type AccountExport struct {
+	User              *domain.User
+	KDF               crypto.KDFParams
+	TOTPEnabled       bool
+	ProtectedVaultKey []byte                // nil, если ключ хранилища не сохранен
+	Manifest          *domain.VaultManifest // nil, если опись не сохранена
+	Secrets           []*domain.Secret
+	Sessions          []*domain.Session
+}
+
+// DeleteAccount удаляет учетную запись пользователя со всеми секретами, версиями и сессиями
+// в одной транзакции. Удаление подтверждается текущим паролем: password для пользователей,
+// входящих по паролю, или обменом SRP (handshakeID, clientProof) для перешедших на SRP,
+// а также кодом 2FA, если она включена.
+func (s *AuthService) DeleteAccount(ctx context.Context, userID, password, handshakeID string, clientProof []byte, totpCode string) error {
+	if s.txManager == nil {
+		return errTransactionsNotConfigured
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	attempt, err := s.beginLoginAttempt(ctx, user.Login)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCurrentPassword(user, password, handshakeID, clientProof); err != nil {
+		return attempt.fail(ctx, err)
+	}
+	if err := s.checkSecondFactor(ctx, user.ID, totpCode); err != nil {
+		return attempt.fail(ctx, err)
+	}
+	attempt.succeed(ctx)
+
+	tx, err := s.txManager.BeginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := tx.SecretRepository().DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete secrets: %w", err)
+	}
+	if err := tx.SessionRepository().DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	if err := tx.UserRepository().Delete(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit account deletion: %w", err)
+	}
+
+	return nil
+}
+
+// ExportAccount возвращает все записи пользователя, прочитанные в одной транзакции
+func (s *AuthService) ExportAccount(ctx context.Context, userID string) (*AccountExport, error) {
+	if s.txManager == nil {
+		return nil, errTransactionsNotConfigured
+	}
+
+	tx, err := s.txManager.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Транзакция только читает, поэтому всегда откатывается
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	user, err := tx.UserRepository().GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	export := &AccountExport{User: user, KDF: userKDFParams(user)}
+
+	totp, err := tx.TOTPRepository().GetByUserID(ctx, userID)
+	switch {
+	case err == nil:
+		export.TOTPEnabled = totp.ConfirmedAt != nil
+	case err != domain.ErrTOTPNotEnabled:
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+
+	vaultKey, err := tx.VaultKeyRepository().GetByUserID(ctx, userID)
+	switch {
+	case err == nil:
+		export.ProtectedVaultKey = vaultKey.ProtectedKey
+	case err != domain.ErrVaultKeyNotFound:
+		return nil, fmt.Errorf("failed to get vault key: %w", err)
+	}
+
+	if export.Manifest, err = tx.ManifestRepository().GetByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get vault manifest: %w", err)
+	}
+	if export.Secrets, err = tx.SecretRepository().ListByUser(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	if export.Sessions, err = tx.SessionRepository().ListActiveByUser(ctx, userID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return export, nil
+}
+
+// verifyCurrentPassword проверяет текущий пароль пользователя: по хешу, если пользователь входит
+// по паролю, или по обмену SRP, начатому через SRPStart
+func (s *AuthService) verifyCurrentPassword(user *domain.User, password, handshakeID string, clientProof []byte) error {
+	if handshakeID != "" {
+		handshakeUserID, _, err := s.finishSRPHandshake(handshakeID, clientProof)
+		if err != nil {
+			return err
+		}
+		if handshakeUserID != user.ID {
+			return domain.ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	if !s.legacyPasswordAuth {
+		return domain.ErrLegacyAuthDisabled
+	}
+	if user.PasswordHash == "" || !s.hasher.Check(password, user.PasswordHash) {
+		return domain.ErrInvalidCredentials
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// Ограничения размера страницы списка пользователей
+const (
+	defaultUserListLimit = 50
+	maxUserListLimit     = 500
+)
+
+// MaintenanceJob задача обслуживания базы, которую администратор запускает вручную
+type MaintenanceJob string
+
+const (
+	// MaintenancePurgeSessions удаляет истекшие и отозванные сессии и истекшие refresh-токены
+	MaintenancePurgeSessions MaintenanceJob = "purge-sessions"
+	// MaintenancePurgeLoginAttempts удаляет счетчики неудачных входов старше окна учета
+	MaintenancePurgeLoginAttempts MaintenanceJob = "purge-login-attempts"
+	// MaintenancePurgeAccessTokens удаляет истекшие токены доступа
+	MaintenancePurgeAccessTokens MaintenanceJob = "purge-access-tokens"
+)
+
+// UserStats сводка по учетной записи для администратора. Содержимое секретов в нее
+// не входит: только их число и объем.
+type UserStats struct {
+	Secrets        domain.SecretUsage
+	ActiveSessions int
+	Devices        int
+	AccessTokens   int
+	TOTPEnabled    bool
+}
+
+// AdminService операции администрирования сервера: поиск пользователей, блокировка учетных
+// записей, принудительный выход и обслуживание базы. Доступ к нему проверяет перехватчик
+// аутентификации по роли пользователя; сам сервис секреты не читает.
+type AdminService struct {
+	users         interfaces.UserRepository
+	secrets       interfaces.SecretRepository
+	sessions      interfaces.SessionRepository
+	refreshTokens interfaces.RefreshTokenRepository
+	devices       interfaces.DeviceRepository
+	accessTokens  interfaces.AccessTokenRepository
+	totp          interfaces.TOTPRepository
+	loginAttempts interfaces.LoginAttemptRepository
+
+	lockoutPolicy LockoutPolicy
+}
+
+// NewAdminService создает сервис администрирования над хранилищем storage. Политика
+// lockoutPolicy определяет, какие счетчики неудачных входов уже не нужны.
+func NewAdminService(storage interfaces.Storage, lockoutPolicy LockoutPolicy) *AdminService {
+	return &AdminService{
+		users:         storage.UserRepository(),
+		secrets:       storage.SecretRepository(),
+		sessions:      storage.SessionRepository(),
+		refreshTokens: storage.RefreshTokenRepository(),
+		devices:       storage.DeviceRepository(),
+		accessTokens:  storage.AccessTokenRepository(),
+		totp:          storage.TOTPRepository(),
+		loginAttempts: storage.LoginAttemptRepository(),
+		lockoutPolicy: lockoutPolicy,
+	}
+}
+
+// ListUsers возвращает страницу пользователей, логин которых содержит query.
+// Нулевой limit означает размер страницы по умолчанию.
+func (s *AdminService) ListUsers(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	switch {
+	case limit < 0 || offset < 0:
+		return nil, domain.ValidationError{Field: "limit", Message: "limit and offset must not be negative"}
+	case limit == 0:
+		limit = defaultUserListLimit
+	case limit > maxUserListLimit:
+		limit = maxUserListLimit
+	}
+
+	users, err := s.users.List(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// GetUserStats возвращает пользователя с логином login и сводку по его учетной записи
+func (s *AdminService) GetUserStats(ctx context.Context, login string) (*domain.User, *UserStats, error) {
+	user, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usage, err := s.secrets.GetUsage(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get secret usage: %w", err)
+	}
+	stats := &UserStats{Secrets: *usage}
+
+	sessions, err := s.sessions.ListActiveByUser(ctx, user.ID, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	stats.ActiveSessions = len(sessions)
+
+	devices, err := s.devices.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	stats.Devices = len(devices)
+
+	tokens, err := s.accessTokens.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	stats.AccessTokens = len(tokens)
+
+	totp, err := s.totp.GetByUserID(ctx, user.ID)
+	switch {
+	case err == nil:
+		stats.TOTPEnabled = totp.ConfirmedAt != nil
+	case err != domain.ErrTOTPNotEnabled:
+		return nil, nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+
+	return user, stats, nil
+}
+
+// SetUserDisabled блокирует или разблокирует учетную запись с логином login. Заблокированный
+// пользователь не может войти, его сессии завершаются, а токены доступа перестают приниматься.
+// Администратор adminID не может заблокировать сам себя.
+func (s *AdminService) SetUserDisabled(ctx context.Context, adminID, login string, disabled bool) (*domain.User, error) {
+	user, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if disabled && user.ID == adminID {
+		return nil, domain.ValidationError{Field: "login", Message: "cannot disable your own account"}
+	}
+	if user.Disabled() == disabled {
+		return user, nil
+	}
+
+	now := time.Now()
+	updated := *user
+	updated.UpdatedAt = now
+	updated.DisabledAt = nil
+	if disabled {
+		updated.DisabledAt = &now
+	}
+	if err := s.users.Update(ctx, &updated); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if disabled {
+		if err := s.revokeUserSessions(ctx, user.ID, now); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
+
+// ForceLogout завершает все сессии пользователя с логином login: его refresh-токены отзываются,
+// а access-токены перестают приниматься сразу. Если revokeAccessTokens, удаляются и токены доступа
+// для автоматизации.
+func (s *AdminService) ForceLogout(ctx context.Context, login string, revokeAccessTokens bool) error {
+	user, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	if err := s.revokeUserSessions(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+	if !revokeAccessTokens {
+		return nil
+	}
+
+	_, err = deleteAccessTokens(ctx, s.accessTokens, user.ID)
+	return err
+}
+
+// ClearLoginLockout снимает блокировку входа под логином login и (или) с адреса address
+func (s *AdminService) ClearLoginLockout(ctx context.Context, login, address string) error {
+	if login == "" && address == "" {
+		return domain.ValidationError{Field: "login", Message: "login or address is required"}
+	}
+	return ClearLoginLockout(ctx, s.loginAttempts, login, address)
+}
+
+// RunMaintenance выполняет задачу обслуживания и возвращает число удаленных записей
+func (s *AdminService) RunMaintenance(ctx context.Context, job MaintenanceJob) (int64, error) {
+	now := time.Now()
+
+	switch job {
+	case MaintenancePurgeSessions:
+		sessions, err := s.sessions.DeleteExpired(ctx, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge sessions: %w", err)
+		}
+		tokens, err := s.refreshTokens.DeleteExpired(ctx, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge refresh tokens: %w", err)
+		}
+		return sessions + tokens, nil
+	case MaintenancePurgeLoginAttempts:
+		// Неудачи старше окна учета уже не влияют на блокировку входа
+		removed, err := s.loginAttempts.DeleteStale(ctx, now.Add(-s.lockoutPolicy.FailureWindow))
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge login attempts: %w", err)
+		}
+		return removed, nil
+	case MaintenancePurgeAccessTokens:
+		removed, err := s.accessTokens.DeleteExpired(ctx, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge access tokens: %w", err)
+		}
+		return removed, nil
+	default:
+		return 0, domain.ValidationError{Field: "job", Message: fmt.Sprintf("unknown maintenance job %q", job)}
+	}
+}
+
+// revokeUserSessions отзывает все сессии и refresh-токены пользователя
+func (s *AdminService) revokeUserSessions(ctx context.Context, userID string, now time.Time) error {
+	if err := s.refreshTokens.RevokeByUser(ctx, userID, now); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if err := s.sessions.RevokeByUser(ctx, userID, now); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// SetUserRole назначает пользователю с логином login роль role. Роль меняется только
+// напрямую в базе (командой grant-admin), чтобы учетная запись администратора
+// не могла выдать права другим.
+func SetUserRole(ctx context.Context, users interfaces.UserRepository, login string, role domain.UserRole) error {
+	switch role {
+	case domain.RoleUser, domain.RoleAdmin:
+	default:
+		return domain.ValidationError{Field: "role", Message: "must be user or admin"}
+	}
+
+	user, err := users.GetByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	updated := *user
+	updated.Role = role
+	updated.UpdatedAt = time.Now()
+	if err := users.Update(ctx, &updated); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
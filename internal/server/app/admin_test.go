@@ -0,0 +1,191 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/mocks"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
+)
+
+func TestAdminService(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (interfaces.Storage, *app.AuthService, *app.AdminService, string) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithRefreshTokens(storage.RefreshTokenRepository()),
+			app.WithSessions(storage.SessionRepository()),
+			app.WithAccessTokens(storage.AccessTokenRepository()))
+		adminService := app.NewAdminService(storage, app.DefaultLockoutPolicy())
+
+		adminID, err := authService.Register(ctx, "admin", "password123")
+		require.NoError(t, err)
+		require.NoError(t, app.SetUserRole(ctx, storage.UserRepository(), "admin", domain.RoleAdmin))
+		_, err = authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+		return storage, authService, adminService, adminID
+	}
+
+	t.Run("list and search users", func(t *testing.T) {
+		_, authService, adminService, _ := setup(t)
+		for i := 0; i < 3; i++ {
+			_, err := authService.Register(ctx, fmt.Sprintf("Test_%d", i), "password123")
+			require.NoError(t, err)
+		}
+
+		users, err := adminService.ListUsers(ctx, "", 0, 0)
+		require.NoError(t, err)
+		require.Len(t, users, 5)
+		assert.Equal(t, "Test_0", users[0].Login)
+		assert.True(t, users[3].IsAdmin())
+		assert.False(t, users[4].IsAdmin())
+
+		users, err = adminService.ListUsers(ctx, "test_", 2, 1)
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, "Test_1", users[0].Login)
+		assert.Equal(t, "Test_2", users[1].Login)
+
+		_, err = adminService.ListUsers(ctx, "", -1, 0)
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("stats count secrets without reading them", func(t *testing.T) {
+		storage, _, adminService, _ := setup(t)
+		user, err := storage.UserRepository().GetByLogin(ctx, "testuser")
+		require.NoError(t, err)
+		for i, deleted := range []bool{false, false, true} {
+			require.NoError(t, storage.SecretRepository().Create(ctx, &domain.Secret{
+				ID:            fmt.Sprintf("secret-%d", i),
+				UserID:        user.ID,
+				Type:          domain.TextData,
+				EncryptedData: make([]byte, 10),
+				EncryptedMeta: make([]byte, 5),
+				IsDeleted:     deleted,
+			}))
+		}
+
+		found, stats, err := adminService.GetUserStats(ctx, "testuser")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, found.ID)
+		assert.Equal(t, domain.SecretUsage{Secrets: 2, DeletedSecrets: 1, Bytes: 45}, stats.Secrets)
+		assert.False(t, stats.TOTPEnabled)
+
+		_, _, err = adminService.GetUserStats(ctx, "missing")
+		assert.Equal(t, domain.ErrUserNotFound, err)
+	})
+
+	t.Run("disabled account cannot log in", func(t *testing.T) {
+		_, authService, adminService, adminID := setup(t)
+		access, refresh, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		user, err := adminService.SetUserDisabled(ctx, adminID, "testuser", true)
+		require.NoError(t, err)
+		assert.True(t, user.Disabled())
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		assert.Equal(t, domain.ErrAccountDisabled, err)
+		_, err = authService.Authenticate(ctx, access)
+		assert.Error(t, err)
+		_, _, err = authService.RefreshTokens(ctx, refresh)
+		assert.Error(t, err)
+
+		user, err = adminService.SetUserDisabled(ctx, adminID, "testuser", false)
+		require.NoError(t, err)
+		assert.False(t, user.Disabled())
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("admin cannot disable own account", func(t *testing.T) {
+		_, _, adminService, adminID := setup(t)
+		_, err := adminService.SetUserDisabled(ctx, adminID, "admin", true)
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("force logout ends sessions and revokes access tokens", func(t *testing.T) {
+		_, authService, adminService, _ := setup(t)
+		access, refresh, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+		_, tokenString, err := authService.CreateAccessToken(ctx, principal.User.ID, app.AccessTokenRequest{
+			Name:         "ci",
+			SecretIDs:    []string{"secret-1"},
+			Permission:   domain.AccessTokenRead,
+			DelegatedKey: []byte("delegated"),
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, adminService.ForceLogout(ctx, "testuser", false))
+		_, err = authService.Authenticate(ctx, access)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+		_, _, err = authService.RefreshTokens(ctx, refresh)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+		_, err = authService.Authenticate(ctx, tokenString)
+		require.NoError(t, err)
+
+		require.NoError(t, adminService.ForceLogout(ctx, "testuser", true))
+		_, err = authService.Authenticate(ctx, tokenString)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+
+		_, stats, err := adminService.GetUserStats(ctx, "testuser")
+		require.NoError(t, err)
+		assert.Zero(t, stats.ActiveSessions)
+		assert.Zero(t, stats.AccessTokens)
+	})
+
+	t.Run("clear login lockout", func(t *testing.T) {
+		storage, _, adminService, _ := setup(t)
+		attempts := storage.LoginAttemptRepository()
+		for _, key := range []string{"login:testuser", "ip:203.0.113.7"} {
+			_, err := attempts.RecordFailure(ctx, key, time.Now(), time.Hour)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, adminService.ClearLoginLockout(ctx, "testuser", "203.0.113.7"))
+		for _, key := range []string{"login:testuser", "ip:203.0.113.7"} {
+			_, err := attempts.Get(ctx, key)
+			assert.Equal(t, domain.ErrLoginAttemptNotFound, err, key)
+		}
+
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, adminService.ClearLoginLockout(ctx, "", ""), &validationErr)
+	})
+
+	t.Run("maintenance purges revoked sessions", func(t *testing.T) {
+		_, authService, adminService, _ := setup(t)
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+		require.NoError(t, adminService.ForceLogout(ctx, "testuser", false))
+
+		removed, err := adminService.RunMaintenance(ctx, app.MaintenancePurgeSessions)
+		require.NoError(t, err)
+		// Отозванный refresh-токен хранится до истечения, чтобы распознать его повторное использование
+		assert.Equal(t, int64(1), removed)
+
+		removed, err = adminService.RunMaintenance(ctx, app.MaintenancePurgeSessions)
+		require.NoError(t, err)
+		assert.Zero(t, removed)
+
+		for _, job := range []app.MaintenanceJob{app.MaintenancePurgeLoginAttempts, app.MaintenancePurgeAccessTokens} {
+			_, err := adminService.RunMaintenance(ctx, job)
+			assert.NoError(t, err, job)
+		}
+
+		_, err = adminService.RunMaintenance(ctx, "vacuum")
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/alisaviation/GophKeeper/internal/crypto"
@@ -12,20 +13,149 @@ import (
 
 // AuthService предоставляет методы для аутентификации и авторизации
 type AuthService struct {
-	users      interfaces.UserRepository
-	jwtManager crypto.JWTManagerInterface
+	users         interfaces.UserRepository
+	jwtManager    crypto.JWTManagerInterface
+	vaultKeys     interfaces.VaultKeyRepository
+	txManager     interfaces.TransactionManager
+	refreshTokens interfaces.RefreshTokenRepository
+	sessions      interfaces.SessionRepository
+	totp          interfaces.TOTPRepository
+	loginAttempts interfaces.LoginAttemptRepository
+	devices       interfaces.DeviceRepository
+	accessTokens  interfaces.AccessTokenRepository
+	hasher        crypto.PasswordHasher
+	kdfParams     crypto.KDFParams
+
+	lockoutPolicy         LockoutPolicy
+	legacyPasswordAuth    bool
+	totpEnrollmentClosed  bool
+	requireDeviceApproval bool
+	srpHandshakes         *srpHandshakes
+	srpFakeSeed           []byte
+}
+
+// Principal аутентифицированный пользователь, сессия, которой принадлежит его токен,
+// и устройство, с которого выполнен вход
+type Principal struct {
+	User      *domain.User
+	SessionID string
+	DeviceID  string
+	// DeviceTrusted устройству доступны секреты: оно одобрено или одобрение не требуется
+	DeviceTrusted bool
+	// AccessToken токен доступа, если вход выполнен им, а не access-токеном сессии
+	AccessToken *domain.AccessToken
+	// CertificateOnly вход выполнен только клиентским сертификатом: сессии, которую можно
+	// завершить, нет, поэтому такому клиенту доступны только секреты
+	CertificateOnly bool
+}
+
+// AuthServiceOption настраивает необязательные параметры AuthService
+type AuthServiceOption func(*AuthService)
+
+// WithKDFParams задает параметры KDF, выдаваемые новым пользователям
+func WithKDFParams(params crypto.KDFParams) AuthServiceOption {
+	return func(s *AuthService) {
+		s.kdfParams = params
+	}
+}
+
+// WithPasswordHasher задает хешер паролей. Хеши, созданные другим алгоритмом
+// или с более слабыми параметрами, пересчитываются при следующем входе.
+func WithPasswordHasher(hasher crypto.PasswordHasher) AuthServiceOption {
+	return func(s *AuthService) {
+		s.hasher = hasher
+	}
+}
+
+// WithLegacyPasswordAuth разрешает или запрещает регистрацию, вход и смену пароля
+// с передачей пароля на сервер. Разрешено по умолчанию, пока клиенты переходят на SRP.
+func WithLegacyPasswordAuth(enabled bool) AuthServiceOption {
+	return func(s *AuthService) {
+		s.legacyPasswordAuth = enabled
+	}
+}
+
+// WithVaultKeys задает хранилище защищенных ключей хранилища пользователей
+func WithVaultKeys(vaultKeys interfaces.VaultKeyRepository) AuthServiceOption {
+	return func(s *AuthService) {
+		s.vaultKeys = vaultKeys
+	}
+}
+
+// WithTransactionManager задает менеджер транзакций для операций над несколькими репозиториями
+func WithTransactionManager(txManager interfaces.TransactionManager) AuthServiceOption {
+	return func(s *AuthService) {
+		s.txManager = txManager
+	}
+}
+
+// WithRefreshTokens задает хранилище выданных refresh-токенов. С ним refresh-токены
+// одноразовые и отзываются при выходе; без него любой неистекший токен остается действительным.
+func WithRefreshTokens(refreshTokens interfaces.RefreshTokenRepository) AuthServiceOption {
+	return func(s *AuthService) {
+		s.refreshTokens = refreshTokens
+	}
+}
+
+// WithSessions задает хранилище сессий. Используется вместе с WithRefreshTokens:
+// access-токены отозванной сессии отклоняются сразу, не дожидаясь истечения срока.
+func WithSessions(sessions interfaces.SessionRepository) AuthServiceOption {
+	return func(s *AuthService) {
+		s.sessions = sessions
+	}
+}
+
+// WithTOTP задает хранилище настроек двухфакторной аутентификации. Без него 2FA недоступна,
+// а вход код не требует.
+func WithTOTP(totp interfaces.TOTPRepository) AuthServiceOption {
+	return func(s *AuthService) {
+		s.totp = totp
+	}
+}
+
+// WithSRPFakeSeed задает секрет, из которого получаются ложные ответы SRPStart. Секрет должен
+// переживать перезапуск сервера: иначе ответ для несуществующего логина после перезапуска
+// меняется, а для настоящего — нет, и логины можно отличить.
+func WithSRPFakeSeed(seed []byte) AuthServiceOption {
+	return func(s *AuthService) {
+		s.srpFakeSeed = seed
+	}
+}
+
+// WithoutTOTPEnrollment запрещает подключать 2FA: без ключа шифрования сервера секрет TOTP
+// хранился бы в базе в открытом виде. Уже подключенная 2FA по-прежнему проверяется при входе.
+func WithoutTOTPEnrollment() AuthServiceOption {
+	return func(s *AuthService) {
+		s.totpEnrollmentClosed = true
+	}
 }
 
 // NewAuthService создает новый сервис аутентификации
-func NewAuthService(users interfaces.UserRepository, jwtManager crypto.JWTManagerInterface) *AuthService {
-	return &AuthService{
+func NewAuthService(users interfaces.UserRepository, jwtManager crypto.JWTManagerInterface, opts ...AuthServiceOption) *AuthService {
+	s := &AuthService{
 		users:      users,
 		jwtManager: jwtManager,
+		hasher:     crypto.DefaultPasswordHasher,
+		kdfParams:  crypto.DefaultKDFParams(),
+
+		legacyPasswordAuth: true,
+		srpHandshakes:      newSRPHandshakes(),
+		srpFakeSeed:        newSRPFakeSeed(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // Register регистрирует нового пользователя
 func (s *AuthService) Register(ctx context.Context, login, password string) (string, error) {
+	if !s.legacyPasswordAuth {
+		return "", domain.ErrLegacyAuthDisabled
+	}
+
 	err := validateCredentials(login, password)
 	if err != nil {
 		return "", fmt.Errorf("invalid credentials: %w", err)
@@ -39,7 +169,7 @@ func (s *AuthService) Register(ctx context.Context, login, password string) (str
 		return "", fmt.Errorf("failed to check user existence: %w", err)
 	}
 
-	passwordHash, err := crypto.HashPassword(password)
+	passwordHash, err := s.hasher.Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -48,9 +178,13 @@ func (s *AuthService) Register(ctx context.Context, login, password string) (str
 		ID:           generateUserID(),
 		Login:        login,
 		PasswordHash: passwordHash,
+		Role:         domain.RoleUser,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
+	if err := s.assignKDFParams(user); err != nil {
+		return "", err
+	}
 
 	if err := s.users.Create(ctx, user); err != nil {
 		return "", fmt.Errorf("failed to create user: %w", err)
@@ -59,40 +193,182 @@ func (s *AuthService) Register(ctx context.Context, login, password string) (str
 	return user.ID, nil
 }
 
-// Login аутентифицирует пользователя и возвращает JWT токен
-func (s *AuthService) Login(ctx context.Context, login, password string) (string, string, string, error) {
+// Login аутентифицирует пользователя и возвращает JWT токен. Если у пользователя включена 2FA,
+// нужен также код totpCode. Пользователи, перешедшие на SRP, войти по паролю не могут.
+func (s *AuthService) Login(ctx context.Context, login, password, totpCode string) (string, string, string, error) {
+	if !s.legacyPasswordAuth {
+		return "", "", "", domain.ErrLegacyAuthDisabled
+	}
+
 	err := validateCredentials(login, password)
 	if err != nil {
 		return "", "", "", fmt.Errorf("invalid credentials: %w", err)
 	}
 
+	attempt, err := s.beginLoginAttempt(ctx, login)
+	if err != nil {
+		return "", "", "", err
+	}
+
 	user, err := s.users.GetByLogin(ctx, login)
 	if err != nil {
 		if err == domain.ErrUserNotFound {
-			return "", "", "", domain.ErrInvalidCredentials
+			return "", "", "", attempt.fail(ctx, domain.ErrInvalidCredentials)
 		}
-		return "", "", "", fmt.Errorf("failed to get user: %w", err)
+		return "", "", "", attempt.fail(ctx, fmt.Errorf("failed to get user: %w", err))
 	}
 
-	if !crypto.CheckPasswordHash(password, user.PasswordHash) {
-		return "", "", "", domain.ErrInvalidCredentials
+	if user.PasswordHash == "" || !s.hasher.Check(password, user.PasswordHash) {
+		return "", "", "", attempt.fail(ctx, domain.ErrInvalidCredentials)
 	}
 
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Login)
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to generate access token: %w", err)
+	if err := s.checkSecondFactor(ctx, user.ID, totpCode); err != nil {
+		return "", "", "", attempt.fail(ctx, err)
+	}
+	attempt.succeed(ctx)
+
+	if user.Disabled() {
+		return "", "", "", domain.ErrAccountDisabled
+	}
+
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		user = s.rehashPassword(ctx, user, password)
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
+	accessToken, refreshToken, err := s.issueTokens(ctx, user, "", "")
 	if err != nil {
-		return "", "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return "", "", "", err
 	}
 
 	return accessToken, refreshToken, user.ID, nil
 }
 
+// GetKDFParams возвращает параметры формирования ключа хранилища пользователя.
+// Пользователям, зарегистрированным до появления KDF, соль выдается при первом обращении.
+func (s *AuthService) GetKDFParams(ctx context.Context, login string) (crypto.KDFParams, error) {
+	user, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	if len(user.KDFSalt) == 0 {
+		if err := s.assignKDFParams(user); err != nil {
+			return crypto.KDFParams{}, err
+		}
+		if err := s.users.Update(ctx, user); err != nil {
+			return crypto.KDFParams{}, fmt.Errorf("failed to save kdf params: %w", err)
+		}
+	}
+
+	return userKDFParams(user), nil
+}
+
+// SetProtectedVaultKey сохраняет ключ хранилища, зашифрованный на клиенте, если его еще нет.
+// Сервер хранит его как непрозрачный блоб и не может расшифровать. Заменить сохраненный ключ
+// можно только вместе с перешифровкой: сменой пароля или ротацией ключа (атомарной синхронизацией),
+// иначе похищенной сессии хватило бы, чтобы подложить ключ, известный злоумышленнику.
+func (s *AuthService) SetProtectedVaultKey(ctx context.Context, userID string, protectedKey []byte) error {
+	if s.vaultKeys == nil {
+		return errVaultKeysNotConfigured
+	}
+	if err := validateProtectedVaultKey(protectedKey); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	key := &domain.VaultKey{
+		UserID:       userID,
+		ProtectedKey: protectedKey,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := s.vaultKeys.Create(ctx, key); err != nil {
+		if err == domain.ErrVaultKeyExists {
+			return err
+		}
+		return fmt.Errorf("failed to save vault key: %w", err)
+	}
+
+	return nil
+}
+
+// GetProtectedVaultKey возвращает сохраненный защищенный ключ хранилища пользователя
+func (s *AuthService) GetProtectedVaultKey(ctx context.Context, userID string) ([]byte, error) {
+	if s.vaultKeys == nil {
+		return nil, errVaultKeysNotConfigured
+	}
+
+	key, err := s.vaultKeys.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.ProtectedKey, nil
+}
+
+// ChangePassword меняет мастер-пароль пользователя и в той же транзакции сохраняет
+// ключ хранилища, перешифрованный клиентом под новый пароль. Токены, выданные
+// до смены пароля, перестают действовать; текущее устройство получает новую пару.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, deviceID, oldPassword, newPassword string, protectedVaultKey []byte) (string, string, error) {
+	if !s.legacyPasswordAuth {
+		return "", "", domain.ErrLegacyAuthDisabled
+	}
+	if s.txManager == nil {
+		return "", "", errTransactionsNotConfigured
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Подбор текущего пароля по украденному токену учитывается так же, как подбор при входе
+	attempt, err := s.beginLoginAttempt(ctx, user.Login)
+	if err != nil {
+		return "", "", err
+	}
+	if user.PasswordHash == "" || !s.hasher.Check(oldPassword, user.PasswordHash) {
+		return "", "", attempt.fail(ctx, domain.ErrInvalidCredentials)
+	}
+	attempt.succeed(ctx)
+
+	if err := validateNewPassword(oldPassword, newPassword); err != nil {
+		return "", "", err
+	}
+	if err := validateProtectedVaultKey(protectedVaultKey); err != nil {
+		return "", "", err
+	}
+
+	passwordHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	updated := *user
+	updated.PasswordHash = passwordHash
+	updated.PasswordChangedAt = now.Truncate(time.Second)
+	updated.UpdatedAt = now
+
+	return s.commitPasswordChange(ctx, &updated, deviceID, protectedVaultKey)
+}
+
 // ValidateToken проверяет валидность JWT токена
 func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*domain.User, error) {
+	principal, err := s.Authenticate(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return principal.User, nil
+}
+
+// Authenticate проверяет access-токен и его сессию или токен доступа для автоматизации
+func (s *AuthService) Authenticate(ctx context.Context, tokenString string) (*Principal, error) {
+	if strings.HasPrefix(tokenString, AccessTokenPrefix) {
+		return s.authenticateAccessToken(ctx, tokenString)
+	}
+
 	claims, err := s.jwtManager.ValidateToken(tokenString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate token: %w", err)
@@ -107,7 +383,53 @@ func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*d
 		return nil, domain.ErrUserNotFound
 	}
 
-	return user, nil
+	if issuedBeforePasswordChange(claims, user) {
+		return nil, domain.ErrInvalidToken
+	}
+	if user.Disabled() {
+		return nil, domain.ErrAccountDisabled
+	}
+
+	principal := &Principal{User: user, SessionID: claims.SessionID, DeviceID: claims.DeviceID}
+	if s.sessions != nil {
+		session, err := s.checkSession(ctx, user.ID, claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		// Устройство могло быть привязано к сессии после выдачи токена
+		principal.DeviceID = session.DeviceID
+	}
+
+	if principal.DeviceTrusted, err = s.deviceTrusted(ctx, user.ID, principal.DeviceID); err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+// AuthenticateCertificate аутентифицирует клиента по сертификату, уже проверенному TLS, чей субъект
+// соответствует логину login. Сертификат выдан доверенным удостоверяющим центром, поэтому клиент
+// считается одобренным устройством; сессии у такого входа нет, и отозвать его можно только списком
+// отзыва сертификатов или блокировкой учетной записи.
+func (s *AuthService) AuthenticateCertificate(ctx context.Context, login string) (*Principal, error) {
+	if login == "" {
+		return nil, domain.ErrInvalidCertificate
+	}
+
+	user, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, domain.ErrInvalidCertificate
+	}
+	if user.Disabled() {
+		return nil, domain.ErrAccountDisabled
+	}
+
+	return &Principal{User: user, DeviceTrusted: true, CertificateOnly: true}, nil
+}
+
+// PublicKeys возвращает открытые ключи, которыми можно проверить выданные сервером access-токены
+func (s *AuthService) PublicKeys() []crypto.JWK {
+	return s.jwtManager.PublicKeys()
 }
 
 // RefreshTokens обновляет пару токенов
@@ -126,15 +448,69 @@ func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (s
 		return "", "", domain.ErrUserNotFound
 	}
 
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Login)
+	if issuedBeforePasswordChange(claims, user) {
+		return "", "", domain.ErrInvalidToken
+	}
+	if user.Disabled() {
+		return "", "", domain.ErrAccountDisabled
+	}
+
+	var familyID string
+	if s.refreshTokens != nil {
+		familyID, err = s.rotateRefreshToken(ctx, user.ID, refreshToken)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return s.issueTokens(ctx, user, familyID, claims.DeviceID)
+}
+
+// Logout отзывает сессию, к которой относится refresh-токен: сам токен и все,
+// что будут или были выданы в той же цепочке обновлений
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	if s.refreshTokens == nil {
+		return nil
+	}
+
+	stored, err := s.refreshTokens.GetByHash(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if err == domain.ErrRefreshTokenNotFound {
+			return domain.ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return s.revokeSession(ctx, stored.FamilyID)
+}
+
+// ListSessions возвращает активные сессии пользователя
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	if s.sessions == nil {
+		return nil, errSessionsNotConfigured
+	}
+
+	sessions, err := s.sessions.ListActiveByUser(ctx, userID, time.Now())
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession завершает сессию пользователя: ее refresh-токены отзываются,
+// а access-токены перестают приниматься сразу
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if s.sessions == nil {
+		return errSessionsNotConfigured
 	}
 
-	newRefreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
+	session, err := s.sessions.GetByID(ctx, sessionID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return err
+	}
+	if session.UserID != userID {
+		return domain.ErrSessionNotFound
 	}
 
-	return accessToken, newRefreshToken, nil
+	return s.revokeSession(ctx, sessionID)
 }
@@ -2,14 +2,24 @@ package app_test
 
 import (
 	"context"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 	"github.com/alisaviation/GophKeeper/internal/server/mocks"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
 )
 
 func TestAuthService_Register(t *testing.T) {
@@ -73,7 +83,7 @@ func TestAuthService_Login(t *testing.T) {
 	require.NoError(t, err)
 
 	t.Run("successful login", func(t *testing.T) {
-		accessToken, refreshToken, returnedUserID, err := authService.Login(ctx, "testuser", "password123")
+		accessToken, refreshToken, returnedUserID, err := authService.Login(ctx, "testuser", "password123", "")
 		require.NoError(t, err)
 		assert.NotEmpty(t, accessToken)
 		assert.NotEmpty(t, refreshToken)
@@ -92,24 +102,242 @@ func TestAuthService_Login(t *testing.T) {
 	})
 
 	t.Run("wrong password", func(t *testing.T) {
-		_, _, _, err = authService.Login(ctx, "testuser", "wrongpassword")
+		_, _, _, err = authService.Login(ctx, "testuser", "wrongpassword", "")
 		require.Error(t, err)
 		assert.Equal(t, domain.ErrInvalidCredentials, err)
 	})
 
 	t.Run("nonexistent user", func(t *testing.T) {
-		_, _, _, err = authService.Login(ctx, "nonexistent", "password123")
+		_, _, _, err = authService.Login(ctx, "nonexistent", "password123", "")
 		require.Error(t, err)
 		assert.Equal(t, domain.ErrInvalidCredentials, err)
 	})
 
 	t.Run("invalid credentials format", func(t *testing.T) {
-		_, _, _, err = authService.Login(ctx, "ab", "password123")
+		_, _, _, err = authService.Login(ctx, "ab", "password123", "")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid credentials")
 	})
 }
 
+func TestAuthService_GetKDFParams(t *testing.T) {
+	userRepo := mocks.NewMockUserRepository()
+	jwtManager := mocks.NewMockJWTManager()
+	configured := crypto.KDFParams{Iterations: 2, Memory: 32 * 1024, Parallelism: 2}
+	authService := app.NewAuthService(userRepo, jwtManager, app.WithKDFParams(configured))
+	ctx := context.Background()
+
+	t.Run("params assigned on registration", func(t *testing.T) {
+		_, err := authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+
+		params, err := authService.GetKDFParams(ctx, "testuser")
+		require.NoError(t, err)
+		assert.Equal(t, crypto.KDFAlgorithmArgon2id, params.Algorithm)
+		assert.Len(t, params.Salt, crypto.SaltSize)
+		assert.Equal(t, configured.Iterations, params.Iterations)
+		assert.Equal(t, configured.Memory, params.Memory)
+		assert.Equal(t, configured.Parallelism, params.Parallelism)
+		require.NoError(t, params.Validate())
+
+		again, err := authService.GetKDFParams(ctx, "testuser")
+		require.NoError(t, err)
+		assert.Equal(t, params, again)
+	})
+
+	t.Run("legacy user gets salt on first request", func(t *testing.T) {
+		legacy := &domain.User{ID: "legacy-id", Login: "legacy", PasswordHash: "hash"}
+		require.NoError(t, userRepo.Create(ctx, legacy))
+
+		params, err := authService.GetKDFParams(ctx, "legacy")
+		require.NoError(t, err)
+		assert.Len(t, params.Salt, crypto.SaltSize)
+
+		stored, err := userRepo.GetByID(ctx, "legacy-id")
+		require.NoError(t, err)
+		assert.Equal(t, params.Salt, stored.KDFSalt)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := authService.GetKDFParams(ctx, "nonexistent")
+		require.Error(t, err)
+	})
+}
+
+func TestAuthService_ProtectedVaultKey(t *testing.T) {
+	storage := memory.NewStorage()
+	jwtManager := mocks.NewMockJWTManager()
+	authService := app.NewAuthService(storage.UserRepository(), jwtManager,
+		app.WithVaultKeys(storage.VaultKeyRepository()))
+	ctx := context.Background()
+
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+
+	t.Run("not uploaded yet", func(t *testing.T) {
+		_, err := authService.GetProtectedVaultKey(ctx, userID)
+		assert.ErrorIs(t, err, domain.ErrVaultKeyNotFound)
+	})
+
+	t.Run("upload and fetch", func(t *testing.T) {
+		require.NoError(t, authService.SetProtectedVaultKey(ctx, userID, []byte("wrapped-key")))
+
+		protectedKey, err := authService.GetProtectedVaultKey(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("wrapped-key"), protectedKey)
+	})
+
+	t.Run("existing key is not replaced", func(t *testing.T) {
+		err := authService.SetProtectedVaultKey(ctx, userID, []byte("attacker-key"))
+		assert.ErrorIs(t, err, domain.ErrVaultKeyExists)
+
+		protectedKey, err := authService.GetProtectedVaultKey(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("wrapped-key"), protectedKey)
+	})
+
+	t.Run("empty key rejected", func(t *testing.T) {
+		err := authService.SetProtectedVaultKey(ctx, userID, nil)
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("oversized key rejected", func(t *testing.T) {
+		err := authService.SetProtectedVaultKey(ctx, userID, make([]byte, 4096))
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	storage := memory.NewStorage()
+	jwtManager := mocks.NewMockJWTManager()
+	authService := app.NewAuthService(storage.UserRepository(), jwtManager,
+		app.WithVaultKeys(storage.VaultKeyRepository()),
+		app.WithTransactionManager(storage.TransactionManager()))
+	ctx := context.Background()
+
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+	require.NoError(t, authService.SetProtectedVaultKey(ctx, userID, []byte("wrapped-old")))
+
+	oldAccess, oldRefresh, _, err := authService.Login(ctx, "testuser", "password123", "")
+	require.NoError(t, err)
+	// Токены другого устройства выданы заметно раньше смены пароля
+	jwtManager.Tokens[oldAccess].IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	jwtManager.Tokens[oldRefresh].IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	t.Run("wrong old password", func(t *testing.T) {
+		_, _, err := authService.ChangePassword(ctx, userID, "", "wrongpassword", "newpassword456", []byte("wrapped-new"))
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("weak new password", func(t *testing.T) {
+		_, _, err := authService.ChangePassword(ctx, userID, "", "password123", "short", []byte("wrapped-new"))
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("missing vault key", func(t *testing.T) {
+		_, _, err := authService.ChangePassword(ctx, userID, "", "password123", "newpassword456", nil)
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("successful change", func(t *testing.T) {
+		access, refresh, err := authService.ChangePassword(ctx, userID, "", "password123", "newpassword456", []byte("wrapped-new"))
+		require.NoError(t, err)
+
+		protectedKey, err := authService.GetProtectedVaultKey(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("wrapped-new"), protectedKey)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		_, _, _, err = authService.Login(ctx, "testuser", "newpassword456", "")
+		require.NoError(t, err)
+
+		_, err = authService.ValidateToken(ctx, access)
+		require.NoError(t, err)
+		_, _, err = authService.RefreshTokens(ctx, refresh)
+		require.NoError(t, err)
+	})
+
+	t.Run("tokens of other devices invalidated", func(t *testing.T) {
+		_, err := authService.ValidateToken(ctx, oldAccess)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+
+		_, _, err = authService.RefreshTokens(ctx, oldRefresh)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+	})
+
+	t.Run("wrong old passwords lock the account", func(t *testing.T) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithVaultKeys(storage.VaultKeyRepository()),
+			app.WithTransactionManager(storage.TransactionManager()),
+			app.WithLoginLockout(storage.LoginAttemptRepository(), app.LockoutPolicy{
+				MaxFailures:   2,
+				BaseDelay:     time.Minute,
+				MaxDelay:      time.Hour,
+				FailureWindow: time.Hour,
+			}))
+		userID, err := authService.Register(ctx, "lockeduser", "password123")
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, _, err := authService.ChangePassword(ctx, userID, "", "wrongpassword", "newpassword456", []byte("wrapped-new"))
+			assert.Equal(t, domain.ErrInvalidCredentials, err)
+		}
+
+		var locked domain.LoginLockedError
+		_, _, err = authService.ChangePassword(ctx, userID, "", "password123", "newpassword456", []byte("wrapped-new"))
+		assert.ErrorAs(t, err, &locked)
+		_, _, _, err = authService.Login(ctx, "lockeduser", "password123", "")
+		assert.ErrorAs(t, err, &locked)
+	})
+
+	t.Run("wrong srp proofs lock the account", func(t *testing.T) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithVaultKeys(storage.VaultKeyRepository()),
+			app.WithTransactionManager(storage.TransactionManager()),
+			app.WithLoginLockout(storage.LoginAttemptRepository(), app.LockoutPolicy{
+				MaxFailures:   2,
+				BaseDelay:     time.Minute,
+				MaxDelay:      time.Hour,
+				FailureWindow: time.Hour,
+			}))
+		kdf := crypto.DefaultKDFParams()
+		kdf.Salt = []byte("0123456789abcdef")
+		salt := []byte("fedcba9876543210")
+		passwordKey := []byte("srp-password-key-0123456789abcde")
+		userID, err := authService.SRPRegister(ctx, "srpuser", kdf, salt, crypto.ComputeSRPVerifier("srpuser", salt, passwordKey))
+		require.NoError(t, err)
+
+		changePassword := func(key []byte) error {
+			client, err := crypto.NewSRPClient("srpuser")
+			require.NoError(t, err)
+			challenge, err := authService.SRPStart(ctx, "srpuser", client.PublicEphemeral())
+			if err != nil {
+				return err
+			}
+			proof, err := client.Proof(key, challenge.Salt, challenge.ServerEphemeral)
+			require.NoError(t, err)
+			_, _, _, err = authService.ChangePasswordSRP(ctx, userID, "", challenge.HandshakeID, proof,
+				salt, crypto.ComputeSRPVerifier("srpuser", salt, []byte("new-srp-password-key-0123456789a")), []byte("wrapped-new"))
+			return err
+		}
+
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, domain.ErrInvalidCredentials, changePassword([]byte("wrong-password-key-0123456789abc")))
+		}
+
+		var locked domain.LoginLockedError
+		assert.ErrorAs(t, changePassword(passwordKey), &locked)
+	})
+}
+
 func TestAuthService_ValidateToken(t *testing.T) {
 	userRepo := mocks.NewMockUserRepository()
 	jwtManager := mocks.NewMockJWTManager()
@@ -119,7 +347,7 @@ func TestAuthService_ValidateToken(t *testing.T) {
 	_, err := authService.Register(ctx, "testuser", "password123")
 	require.NoError(t, err)
 
-	accessToken, _, _, err := authService.Login(ctx, "testuser", "password123")
+	accessToken, _, _, err := authService.Login(ctx, "testuser", "password123", "")
 	require.NoError(t, err)
 
 	t.Run("successful token validation", func(t *testing.T) {
@@ -135,7 +363,7 @@ func TestAuthService_ValidateToken(t *testing.T) {
 	})
 
 	t.Run("user not found during validation", func(t *testing.T) {
-		accessToken, err := jwtManager.GenerateAccessToken("non-existent-user", "testuser")
+		accessToken, err := jwtManager.GenerateAccessToken("non-existent-user", "testuser", "", "")
 		require.NoError(t, err)
 
 		_, err = authService.ValidateToken(ctx, accessToken)
@@ -153,7 +381,7 @@ func TestAuthService_RefreshTokens(t *testing.T) {
 	_, err := authService.Register(ctx, "testuser", "password123")
 	require.NoError(t, err)
 
-	_, refreshToken, _, err := authService.Login(ctx, "testuser", "password123")
+	_, refreshToken, _, err := authService.Login(ctx, "testuser", "password123", "")
 	require.NoError(t, err)
 
 	t.Run("successful token refresh", func(t *testing.T) {
@@ -179,7 +407,7 @@ func TestAuthService_RefreshTokens(t *testing.T) {
 	})
 
 	t.Run("user not found during refresh", func(t *testing.T) {
-		refreshToken, err := jwtManager.GenerateRefreshToken("non-existent-user")
+		refreshToken, err := jwtManager.GenerateRefreshToken("non-existent-user", "", "")
 		require.NoError(t, err)
 
 		_, _, err = authService.RefreshTokens(ctx, refreshToken)
@@ -197,7 +425,7 @@ func TestAuthService_ValidateToken_WrongTokenType(t *testing.T) {
 	_, err := authService.Register(ctx, "testuser", "password123")
 	require.NoError(t, err)
 
-	_, refreshToken, _, err := authService.Login(ctx, "testuser", "password123")
+	_, refreshToken, _, err := authService.Login(ctx, "testuser", "password123", "")
 	require.NoError(t, err)
 
 	t.Run("refresh token as access token", func(t *testing.T) {
@@ -207,7 +435,7 @@ func TestAuthService_ValidateToken_WrongTokenType(t *testing.T) {
 	})
 
 	t.Run("access token as refresh token", func(t *testing.T) {
-		accessToken, _, _, err := authService.Login(ctx, "testuser", "password123")
+		accessToken, _, _, err := authService.Login(ctx, "testuser", "password123", "")
 		require.NoError(t, err)
 
 		_, _, err = authService.RefreshTokens(ctx, accessToken)
@@ -215,3 +443,1069 @@ func TestAuthService_ValidateToken_WrongTokenType(t *testing.T) {
 		assert.Equal(t, domain.ErrInvalidToken, err)
 	})
 }
+
+func TestAuthService_RefreshTokenRotation(t *testing.T) {
+	setup := func(t *testing.T) (*app.AuthService, string) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithRefreshTokens(storage.RefreshTokenRepository()))
+		_, err := authService.Register(context.Background(), "testuser", "password123")
+		require.NoError(t, err)
+		_, refresh, _, err := authService.Login(context.Background(), "testuser", "password123", "")
+		require.NoError(t, err)
+		return authService, refresh
+	}
+	ctx := context.Background()
+
+	t.Run("refresh token is single use", func(t *testing.T) {
+		authService, refresh := setup(t)
+
+		_, rotated, err := authService.RefreshTokens(ctx, refresh)
+		require.NoError(t, err)
+		_, _, err = authService.RefreshTokens(ctx, rotated)
+		require.NoError(t, err)
+	})
+
+	t.Run("reuse revokes the whole family", func(t *testing.T) {
+		authService, refresh := setup(t)
+		_, otherSession, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		_, rotated, err := authService.RefreshTokens(ctx, refresh)
+		require.NoError(t, err)
+
+		_, _, err = authService.RefreshTokens(ctx, refresh)
+		assert.Equal(t, domain.ErrRefreshTokenReused, err)
+
+		// Токен, выданный взамен украденного, тоже отозван
+		_, _, err = authService.RefreshTokens(ctx, rotated)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+
+		// Другие сессии пользователя не затронуты
+		_, _, err = authService.RefreshTokens(ctx, otherSession)
+		require.NoError(t, err)
+	})
+
+	t.Run("logout revokes session", func(t *testing.T) {
+		authService, refresh := setup(t)
+		_, rotated, err := authService.RefreshTokens(ctx, refresh)
+		require.NoError(t, err)
+
+		require.NoError(t, authService.Logout(ctx, rotated))
+		_, _, err = authService.RefreshTokens(ctx, rotated)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+
+		// Повторный выход не считается ошибкой
+		require.NoError(t, authService.Logout(ctx, rotated))
+		assert.Equal(t, domain.ErrInvalidToken, authService.Logout(ctx, "unknown-token"))
+	})
+
+	t.Run("tokens not issued by the store are rejected", func(t *testing.T) {
+		storage := memory.NewStorage()
+		jwtManager := mocks.NewMockJWTManager()
+		authService := app.NewAuthService(storage.UserRepository(), jwtManager,
+			app.WithRefreshTokens(storage.RefreshTokenRepository()))
+		userID, err := authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+
+		forged, err := jwtManager.GenerateRefreshToken(userID, "", "")
+		require.NoError(t, err)
+		_, _, err = authService.RefreshTokens(ctx, forged)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+	})
+}
+
+func TestAuthService_Sessions(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*app.AuthService, string) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithRefreshTokens(storage.RefreshTokenRepository()),
+			app.WithSessions(storage.SessionRepository()))
+		userID, err := authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+		return authService, userID
+	}
+	login := func(t *testing.T, authService *app.AuthService, device string) (string, string) {
+		ctx := app.ContextWithClientInfo(ctx, app.ClientInfo{
+			DeviceName:    device,
+			ClientVersion: "v1.0.0",
+			IPAddress:     "192.0.2.1",
+		})
+		access, refresh, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+		return access, refresh
+	}
+
+	t.Run("login creates a session with client info", func(t *testing.T) {
+		authService, userID := setup(t)
+		access, _ := login(t, authService, "laptop")
+		login(t, authService, "phone")
+
+		sessions, err := authService.ListSessions(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+
+		var current *domain.Session
+		for _, session := range sessions {
+			if session.ID == principal.SessionID {
+				current = session
+			}
+		}
+		require.NotNil(t, current)
+		assert.Equal(t, "laptop", current.DeviceName)
+		assert.Equal(t, "v1.0.0", current.ClientVersion)
+		assert.Equal(t, "192.0.2.1", current.IPAddress)
+	})
+
+	t.Run("refresh keeps the session", func(t *testing.T) {
+		authService, userID := setup(t)
+		_, refresh := login(t, authService, "laptop")
+
+		access, _, err := authService.RefreshTokens(ctx, refresh)
+		require.NoError(t, err)
+
+		sessions, err := authService.ListSessions(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+		assert.Equal(t, sessions[0].ID, principal.SessionID)
+	})
+
+	t.Run("revoked session rejects access and refresh tokens", func(t *testing.T) {
+		authService, userID := setup(t)
+		access, refresh := login(t, authService, "laptop")
+		otherAccess, _ := login(t, authService, "phone")
+
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+		require.NoError(t, authService.RevokeSession(ctx, userID, principal.SessionID))
+
+		_, err = authService.Authenticate(ctx, access)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+		_, _, err = authService.RefreshTokens(ctx, refresh)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+
+		_, err = authService.Authenticate(ctx, otherAccess)
+		require.NoError(t, err)
+
+		sessions, err := authService.ListSessions(ctx, userID)
+		require.NoError(t, err)
+		assert.Len(t, sessions, 1)
+	})
+
+	t.Run("logout revokes the session", func(t *testing.T) {
+		authService, _ := setup(t)
+		access, refresh := login(t, authService, "laptop")
+
+		require.NoError(t, authService.Logout(ctx, refresh))
+		_, err := authService.Authenticate(ctx, access)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+	})
+
+	t.Run("cannot revoke another user's session", func(t *testing.T) {
+		authService, _ := setup(t)
+		access, _ := login(t, authService, "laptop")
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+
+		err = authService.RevokeSession(ctx, "other-user", principal.SessionID)
+		assert.Equal(t, domain.ErrSessionNotFound, err)
+		err = authService.RevokeSession(ctx, principal.User.ID, "missing")
+		assert.Equal(t, domain.ErrSessionNotFound, err)
+
+		_, err = authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+	})
+}
+
+func TestAuthService_LoginRehashesPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("legacy bcrypt hash is upgraded", func(t *testing.T) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager())
+
+		legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		require.NoError(t, err)
+		changedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, storage.UserRepository().Create(ctx, &domain.User{
+			ID:                "user-1",
+			Login:             "testuser",
+			PasswordHash:      string(legacy),
+			PasswordChangedAt: changedAt,
+		}))
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		user, err := storage.UserRepository().GetByLogin(ctx, "testuser")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+		assert.Equal(t, changedAt, user.PasswordChangedAt)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("weaker argon2id parameters are upgraded", func(t *testing.T) {
+		storage := memory.NewStorage()
+		weakParams := crypto.DefaultArgon2idParams()
+		weakParams.Iterations = 1
+		weak, err := crypto.NewArgon2idHasher(weakParams)
+		require.NoError(t, err)
+
+		weakService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithPasswordHasher(weak))
+		_, err = weakService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+		user, err := storage.UserRepository().GetByLogin(ctx, "testuser")
+		require.NoError(t, err)
+		weakHash := user.PasswordHash
+
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager())
+		_, _, _, err = authService.Login(ctx, "testuser", "wrong-password", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		user, err = storage.UserRepository().GetByLogin(ctx, "testuser")
+		require.NoError(t, err)
+		assert.Equal(t, weakHash, user.PasswordHash)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+		user, err = storage.UserRepository().GetByLogin(ctx, "testuser")
+		require.NoError(t, err)
+		assert.NotEqual(t, weakHash, user.PasswordHash)
+		assert.False(t, crypto.DefaultPasswordHasher.NeedsRehash(user.PasswordHash))
+	})
+}
+
+func TestAuthService_SRP(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithVaultKeys(storage.VaultKeyRepository()),
+		app.WithTransactionManager(storage.TransactionManager()))
+
+	kdf := crypto.DefaultKDFParams()
+	kdf.Salt = []byte("0123456789abcdef")
+	salt := []byte("fedcba9876543210")
+	passwordKey := []byte("srp-password-key-0123456789abcde")
+
+	// srpLogin выполняет обмен SRP с секретом key и возвращает ответ SRPLogin
+	srpLogin := func(t *testing.T, login string, key []byte) (string, []byte, error) {
+		client, err := crypto.NewSRPClient(login)
+		require.NoError(t, err)
+
+		challenge, err := authService.SRPStart(ctx, login, client.PublicEphemeral())
+		if err != nil {
+			return "", nil, err
+		}
+		proof, err := client.Proof(key, challenge.Salt, challenge.ServerEphemeral)
+		require.NoError(t, err)
+
+		access, _, _, serverProof, err := authService.SRPLogin(ctx, challenge.HandshakeID, proof, "")
+		if err != nil {
+			return "", nil, err
+		}
+		require.NoError(t, client.VerifyServerProof(serverProof))
+		return access, proof, nil
+	}
+
+	userID, err := authService.SRPRegister(ctx, "srpuser", kdf, salt, crypto.ComputeSRPVerifier("srpuser", salt, passwordKey))
+	require.NoError(t, err)
+
+	t.Run("invalid verifier rejected", func(t *testing.T) {
+		_, err := authService.SRPRegister(ctx, "other", kdf, salt, []byte{1})
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("successful login", func(t *testing.T) {
+		access, _, err := srpLogin(t, "srpuser", passwordKey)
+		require.NoError(t, err)
+
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+		assert.Equal(t, userID, principal.User.ID)
+	})
+
+	t.Run("challenge carries kdf params", func(t *testing.T) {
+		client, err := crypto.NewSRPClient("srpuser")
+		require.NoError(t, err)
+		challenge, err := authService.SRPStart(ctx, "srpuser", client.PublicEphemeral())
+		require.NoError(t, err)
+		assert.Equal(t, kdf.Salt, challenge.KDF.Salt)
+		assert.Equal(t, salt, challenge.Salt)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, _, err := srpLogin(t, "srpuser", []byte("wrong-password-key-0123456789abc"))
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("handshake is single use", func(t *testing.T) {
+		client, err := crypto.NewSRPClient("srpuser")
+		require.NoError(t, err)
+		challenge, err := authService.SRPStart(ctx, "srpuser", client.PublicEphemeral())
+		require.NoError(t, err)
+		proof, err := client.Proof(passwordKey, challenge.Salt, challenge.ServerEphemeral)
+		require.NoError(t, err)
+
+		_, _, _, _, err = authService.SRPLogin(ctx, challenge.HandshakeID, proof, "")
+		require.NoError(t, err)
+		_, _, _, _, err = authService.SRPLogin(ctx, challenge.HandshakeID, proof, "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("unknown login looks like existing one", func(t *testing.T) {
+		client, err := crypto.NewSRPClient("nobody")
+		require.NoError(t, err)
+		first, err := authService.SRPStart(ctx, "nobody", client.PublicEphemeral())
+		require.NoError(t, err)
+		second, err := authService.SRPStart(ctx, "nobody", client.PublicEphemeral())
+		require.NoError(t, err)
+		assert.Equal(t, first.Salt, second.Salt)
+		assert.Equal(t, first.KDF, second.KDF)
+
+		_, _, err = srpLogin(t, "nobody", passwordKey)
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("fake credentials survive restart with the same seed", func(t *testing.T) {
+		seed := []byte("persistent-srp-seed-0123456789ab")
+		challenge := func() *app.SRPChallenge {
+			restarted := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(), app.WithSRPFakeSeed(seed))
+			client, err := crypto.NewSRPClient("nobody")
+			require.NoError(t, err)
+			challenge, err := restarted.SRPStart(ctx, "nobody", client.PublicEphemeral())
+			require.NoError(t, err)
+			return challenge
+		}
+
+		first, second := challenge(), challenge()
+		assert.Equal(t, first.Salt, second.Salt)
+		assert.Equal(t, first.KDF, second.KDF)
+	})
+
+	t.Run("password login is impossible", func(t *testing.T) {
+		_, _, _, err := authService.Login(ctx, "srpuser", "password123", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("password change", func(t *testing.T) {
+		newKey := []byte("new-srp-password-key-0123456789a")
+		client, err := crypto.NewSRPClient("srpuser")
+		require.NoError(t, err)
+		challenge, err := authService.SRPStart(ctx, "srpuser", client.PublicEphemeral())
+		require.NoError(t, err)
+		proof, err := client.Proof(passwordKey, challenge.Salt, challenge.ServerEphemeral)
+		require.NoError(t, err)
+
+		_, _, serverProof, err := authService.ChangePasswordSRP(ctx, userID, "", challenge.HandshakeID, proof,
+			salt, crypto.ComputeSRPVerifier("srpuser", salt, newKey), []byte("wrapped-new"))
+		require.NoError(t, err)
+		require.NoError(t, client.VerifyServerProof(serverProof))
+
+		_, _, err = srpLogin(t, "srpuser", passwordKey)
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		_, _, err = srpLogin(t, "srpuser", newKey)
+		require.NoError(t, err)
+	})
+
+	t.Run("password user is enrolled", func(t *testing.T) {
+		legacyID, err := authService.Register(ctx, "legacyuser", "password123")
+		require.NoError(t, err)
+
+		// Пользователь без верификатора неотличим от несуществующего
+		client, err := crypto.NewSRPClient("legacyuser")
+		require.NoError(t, err)
+		first, err := authService.SRPStart(ctx, "legacyuser", client.PublicEphemeral())
+		require.NoError(t, err)
+		second, err := authService.SRPStart(ctx, "legacyuser", client.PublicEphemeral())
+		require.NoError(t, err)
+		assert.Equal(t, first.Salt, second.Salt)
+		assert.Equal(t, first.KDF, second.KDF)
+
+		_, _, err = srpLogin(t, "legacyuser", passwordKey)
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+
+		verifier := crypto.ComputeSRPVerifier("legacyuser", salt, passwordKey)
+		err = authService.EnrollSRP(ctx, legacyID, "wrongpassword", salt, verifier)
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		require.NoError(t, authService.EnrollSRP(ctx, legacyID, "password123", salt, verifier))
+
+		_, _, err = srpLogin(t, "legacyuser", passwordKey)
+		require.NoError(t, err)
+		_, _, _, err = authService.Login(ctx, "legacyuser", "password123", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("password authentication disabled", func(t *testing.T) {
+		strict := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithLegacyPasswordAuth(false))
+
+		_, err := strict.Register(ctx, "newuser", "password123")
+		assert.Equal(t, domain.ErrLegacyAuthDisabled, err)
+		_, _, _, err = strict.Login(ctx, "legacyuser", "password123", "")
+		assert.Equal(t, domain.ErrLegacyAuthDisabled, err)
+	})
+}
+
+func TestAuthService_TOTP(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithTOTP(storage.TOTPRepository()))
+
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+
+	enrollment, err := authService.EnableTOTP(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/GophKeeper:testuser?"))
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(t, err)
+
+	step := crypto.TOTPStep(time.Now())
+	var recoveryCodes []string
+
+	t.Run("unconfirmed 2fa does not affect login", func(t *testing.T) {
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("confirm with wrong code", func(t *testing.T) {
+		_, err := authService.ConfirmTOTP(ctx, userID, crypto.TOTPCode(secret, step-5))
+		assert.Equal(t, domain.ErrInvalidTOTPCode, err)
+	})
+
+	t.Run("confirm", func(t *testing.T) {
+		recoveryCodes, err = authService.ConfirmTOTP(ctx, userID, crypto.TOTPCode(secret, step))
+		require.NoError(t, err)
+		assert.Len(t, recoveryCodes, 10)
+
+		_, err = authService.EnableTOTP(ctx, userID)
+		assert.Equal(t, domain.ErrTOTPAlreadyEnabled, err)
+	})
+
+	t.Run("login requires code", func(t *testing.T) {
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		assert.Equal(t, domain.ErrTOTPRequired, err)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "wrongpassword", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err, "2fa status must not leak without the password")
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "000000")
+		assert.Equal(t, domain.ErrInvalidTOTPCode, err)
+	})
+
+	t.Run("code is single use", func(t *testing.T) {
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", crypto.TOTPCode(secret, step))
+		assert.Equal(t, domain.ErrInvalidTOTPCode, err)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", crypto.TOTPCode(secret, step+1))
+		require.NoError(t, err)
+	})
+
+	t.Run("recovery code", func(t *testing.T) {
+		code := strings.ToLower(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", code)
+		require.NoError(t, err)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", recoveryCodes[0])
+		assert.Equal(t, domain.ErrInvalidTOTPCode, err)
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		err := authService.DisableTOTP(ctx, userID, "wrongpassword", "", nil, recoveryCodes[1])
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+
+		err = authService.DisableTOTP(ctx, userID, "password123", "", nil, "AAAAA-AAAAA")
+		assert.Equal(t, domain.ErrInvalidTOTPCode, err)
+
+		require.NoError(t, authService.DisableTOTP(ctx, userID, "password123", "", nil, recoveryCodes[1]))
+
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		err = authService.DisableTOTP(ctx, userID, "password123", "", nil, "")
+		assert.Equal(t, domain.ErrTOTPNotEnabled, err)
+	})
+}
+
+func TestAuthService_TOTPWithoutEncryption(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	attempts := storage.LoginAttemptRepository()
+	lockout := app.WithLoginLockout(attempts, app.LockoutPolicy{
+		MaxFailures:        1,
+		MaxAddressFailures: 1,
+		BaseDelay:          time.Minute,
+		MaxDelay:           time.Hour,
+		FailureWindow:      time.Hour,
+	})
+
+	enrolled := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithTOTP(storage.TOTPRepository()), lockout)
+	userID, err := enrolled.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+	enrollment, err := enrolled.EnableTOTP(ctx, userID)
+	require.NoError(t, err)
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	require.NoError(t, err)
+	_, err = enrolled.ConfirmTOTP(ctx, userID, crypto.TOTPCode(secret, crypto.TOTPStep(time.Now())))
+	require.NoError(t, err)
+
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithTOTP(storage.TOTPRepository()), app.WithoutTOTPEnrollment(), lockout)
+
+	t.Run("enrollment is refused", func(t *testing.T) {
+		otherID, err := authService.Register(ctx, "otheruser", "password123")
+		require.NoError(t, err)
+
+		_, err = authService.EnableTOTP(ctx, otherID)
+		assert.Equal(t, domain.ErrTOTPUnavailable, err)
+		_, err = authService.ConfirmTOTP(ctx, otherID, "000000")
+		assert.Equal(t, domain.ErrTOTPUnavailable, err)
+	})
+
+	t.Run("enabled 2fa is still required", func(t *testing.T) {
+		// Запрос кода не считается неудачной попыткой: иначе первый же вход без кода
+		// заблокировал бы учетную запись при MaxFailures = 1
+		for i := 0; i < 3; i++ {
+			_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+			assert.Equal(t, domain.ErrTOTPRequired, err)
+		}
+
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", crypto.TOTPCode(secret, crypto.TOTPStep(time.Now())+1))
+		require.NoError(t, err)
+	})
+
+	t.Run("failed disable attempts lock the account", func(t *testing.T) {
+		err := authService.DisableTOTP(ctx, userID, "password123", "", nil, "000000")
+		assert.Equal(t, domain.ErrInvalidTOTPCode, err)
+
+		err = authService.DisableTOTP(ctx, userID, "password123", "", nil, crypto.TOTPCode(secret, crypto.TOTPStep(time.Now())-1))
+		var locked domain.LoginLockedError
+		assert.ErrorAs(t, err, &locked)
+	})
+}
+
+func TestAuthService_LoginLockout(t *testing.T) {
+	storage := memory.NewStorage()
+	attempts := storage.LoginAttemptRepository()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithLoginLockout(attempts, app.LockoutPolicy{
+			MaxFailures:        3,
+			MaxAddressFailures: 5,
+			BaseDelay:          time.Minute,
+			MaxDelay:           time.Hour,
+			FailureWindow:      time.Hour,
+		}))
+
+	ctx := context.Background()
+	_, err := authService.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+
+	t.Run("lockout after failures", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, _, _, err := authService.Login(ctx, "testuser", "wrongpassword", "")
+			assert.Equal(t, domain.ErrInvalidCredentials, err)
+		}
+
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		var locked domain.LoginLockedError
+		require.ErrorAs(t, err, &locked)
+		assert.Equal(t, time.Minute, locked.RetryAfter)
+
+		_, err = authService.SRPStart(ctx, "testuser", []byte{1})
+		assert.ErrorAs(t, err, &locked)
+	})
+
+	t.Run("admin clears lockout", func(t *testing.T) {
+		require.NoError(t, app.ClearLoginLockout(ctx, attempts, "testuser", ""))
+
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("success resets counter", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, _, _, err := authService.Login(ctx, "testuser", "wrongpassword", "")
+			assert.Equal(t, domain.ErrInvalidCredentials, err)
+		}
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		_, _, _, err = authService.Login(ctx, "testuser", "wrongpassword", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		_, _, _, err = authService.Login(ctx, "testuser", "password123", "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("delay doubles with every failure", func(t *testing.T) {
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			_, err := attempts.RecordFailure(ctx, "login:ghost", now, time.Hour)
+			require.NoError(t, err)
+		}
+
+		_, _, _, err := authService.Login(ctx, "ghost", "password123", "")
+		var locked domain.LoginLockedError
+		require.ErrorAs(t, err, &locked)
+		assert.Equal(t, 4*time.Minute, locked.RetryAfter)
+	})
+
+	t.Run("lockout by client address", func(t *testing.T) {
+		attacker := app.ContextWithClientInfo(ctx, app.ClientInfo{IPAddress: "203.0.113.7"})
+		for i := 0; i < 5; i++ {
+			_, _, _, err := authService.Login(attacker, fmt.Sprintf("victim%d", i), "password123", "")
+			assert.Equal(t, domain.ErrInvalidCredentials, err)
+		}
+
+		_, _, _, err := authService.Login(attacker, "testuser", "password123", "")
+		var locked domain.LoginLockedError
+		assert.ErrorAs(t, err, &locked)
+
+		other := app.ContextWithClientInfo(ctx, app.ClientInfo{IPAddress: "198.51.100.1"})
+		_, _, _, err = authService.Login(other, "testuser", "password123", "")
+		assert.NoError(t, err)
+
+		require.NoError(t, app.ClearLoginLockout(ctx, attempts, "", "203.0.113.7"))
+		_, _, _, err = authService.Login(attacker, "testuser", "password123", "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("own login does not reset address counter", func(t *testing.T) {
+		attacker := app.ContextWithClientInfo(ctx, app.ClientInfo{IPAddress: "203.0.113.8"})
+		for i := 0; i < 4; i++ {
+			_, _, _, err := authService.Login(attacker, fmt.Sprintf("victim%d", i), "password123", "")
+			assert.Equal(t, domain.ErrInvalidCredentials, err)
+		}
+		_, _, _, err := authService.Login(attacker, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		_, _, _, err = authService.Login(attacker, "victim4", "password123", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		_, _, _, err = authService.Login(attacker, "testuser", "password123", "")
+		var locked domain.LoginLockedError
+		assert.ErrorAs(t, err, &locked)
+	})
+
+	t.Run("parallel attempts do not exceed threshold", func(t *testing.T) {
+		_, err := authService.Register(ctx, "parallel", "password123")
+		require.NoError(t, err)
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			checked  int
+			rejected int
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, _, err := authService.Login(ctx, "parallel", "wrongpassword", "")
+				mu.Lock()
+				defer mu.Unlock()
+				var locked domain.LoginLockedError
+				switch {
+				case err == domain.ErrInvalidCredentials:
+					checked++
+				case assert.ErrorAs(t, err, &locked):
+					rejected++
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, checked, 3)
+		assert.Equal(t, 20, checked+rejected)
+	})
+}
+
+func TestAuthService_DeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*app.AuthService, interfaces.Storage) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithVaultKeys(storage.VaultKeyRepository()),
+			app.WithTransactionManager(storage.TransactionManager()),
+			app.WithRefreshTokens(storage.RefreshTokenRepository()),
+			app.WithSessions(storage.SessionRepository()))
+		return authService, storage
+	}
+
+	t.Run("password user", func(t *testing.T) {
+		authService, storage := setup(t)
+		userID, err := authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+		require.NoError(t, authService.SetProtectedVaultKey(ctx, userID, []byte("wrapped")))
+		require.NoError(t, storage.SecretRepository().Create(ctx, &domain.Secret{ID: "secret-1", UserID: userID, Version: 1}))
+		access, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		err = authService.DeleteAccount(ctx, userID, "wrongpassword", "", nil, "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		_, err = storage.UserRepository().GetByID(ctx, userID)
+		require.NoError(t, err)
+
+		require.NoError(t, authService.DeleteAccount(ctx, userID, "password123", "", nil, ""))
+
+		_, err = storage.UserRepository().GetByID(ctx, userID)
+		assert.Equal(t, domain.ErrUserNotFound, err)
+		secrets, err := storage.SecretRepository().ListByUser(ctx, userID)
+		require.NoError(t, err)
+		assert.Empty(t, secrets)
+		sessions, err := storage.SessionRepository().ListActiveByUser(ctx, userID, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+		_, err = storage.VaultKeyRepository().GetByUserID(ctx, userID)
+		assert.Equal(t, domain.ErrVaultKeyNotFound, err)
+
+		_, err = authService.Authenticate(ctx, access)
+		assert.Error(t, err)
+
+		// Логин освобождается для новой регистрации
+		_, err = authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+	})
+
+	t.Run("srp user", func(t *testing.T) {
+		authService, storage := setup(t)
+		kdf := crypto.DefaultKDFParams()
+		kdf.Salt = []byte("0123456789abcdef")
+		salt := []byte("fedcba9876543210")
+		passwordKey := []byte("srp-password-key-0123456789abcde")
+		userID, err := authService.SRPRegister(ctx, "srpuser", kdf, salt, crypto.ComputeSRPVerifier("srpuser", salt, passwordKey))
+		require.NoError(t, err)
+
+		handshake := func(t *testing.T, key []byte) (string, []byte) {
+			client, err := crypto.NewSRPClient("srpuser")
+			require.NoError(t, err)
+			challenge, err := authService.SRPStart(ctx, "srpuser", client.PublicEphemeral())
+			require.NoError(t, err)
+			proof, err := client.Proof(key, challenge.Salt, challenge.ServerEphemeral)
+			require.NoError(t, err)
+			return challenge.HandshakeID, proof
+		}
+
+		handshakeID, proof := handshake(t, []byte("wrong-password-key-0123456789abc"))
+		err = authService.DeleteAccount(ctx, userID, "", handshakeID, proof, "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+
+		handshakeID, proof = handshake(t, passwordKey)
+		require.NoError(t, authService.DeleteAccount(ctx, userID, "", handshakeID, proof, ""))
+
+		_, err = storage.UserRepository().GetByID(ctx, userID)
+		assert.Equal(t, domain.ErrUserNotFound, err)
+	})
+
+	t.Run("handshake of another user rejected", func(t *testing.T) {
+		authService, _ := setup(t)
+		victimID, err := authService.Register(ctx, "victim", "password123")
+		require.NoError(t, err)
+
+		kdf := crypto.DefaultKDFParams()
+		kdf.Salt = []byte("0123456789abcdef")
+		salt := []byte("fedcba9876543210")
+		passwordKey := []byte("srp-password-key-0123456789abcde")
+		_, err = authService.SRPRegister(ctx, "attacker", kdf, salt, crypto.ComputeSRPVerifier("attacker", salt, passwordKey))
+		require.NoError(t, err)
+
+		client, err := crypto.NewSRPClient("attacker")
+		require.NoError(t, err)
+		challenge, err := authService.SRPStart(ctx, "attacker", client.PublicEphemeral())
+		require.NoError(t, err)
+		proof, err := client.Proof(passwordKey, challenge.Salt, challenge.ServerEphemeral)
+		require.NoError(t, err)
+
+		err = authService.DeleteAccount(ctx, victimID, "", challenge.HandshakeID, proof, "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+}
+
+func TestAuthService_ExportAccount(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithVaultKeys(storage.VaultKeyRepository()),
+		app.WithTransactionManager(storage.TransactionManager()),
+		app.WithRefreshTokens(storage.RefreshTokenRepository()),
+		app.WithSessions(storage.SessionRepository()))
+
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+
+	t.Run("new account", func(t *testing.T) {
+		export, err := authService.ExportAccount(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, "testuser", export.User.Login)
+		assert.Nil(t, export.ProtectedVaultKey)
+		assert.Nil(t, export.Manifest)
+		assert.Empty(t, export.Secrets)
+		assert.Empty(t, export.Sessions)
+	})
+
+	t.Run("all records", func(t *testing.T) {
+		require.NoError(t, authService.SetProtectedVaultKey(ctx, userID, []byte("wrapped")))
+		require.NoError(t, storage.ManifestRepository().Save(ctx, &domain.VaultManifest{UserID: userID, Data: []byte("manifest"), Version: 1}))
+		require.NoError(t, storage.SecretRepository().Create(ctx, &domain.Secret{ID: "secret-1", UserID: userID, Version: 1}))
+		require.NoError(t, storage.SecretRepository().Create(ctx, &domain.Secret{ID: "secret-2", UserID: userID, Version: 1}))
+		require.NoError(t, storage.SecretRepository().Create(ctx, &domain.Secret{ID: "secret-3", UserID: "other", Version: 1}))
+		_, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+
+		export, err := authService.ExportAccount(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("wrapped"), export.ProtectedVaultKey)
+		require.NotNil(t, export.Manifest)
+		assert.Equal(t, []byte("manifest"), export.Manifest.Data)
+		assert.Len(t, export.Secrets, 2)
+		assert.Len(t, export.Sessions, 1)
+		assert.False(t, export.TOTPEnabled)
+	})
+}
+
+func TestAuthService_Devices(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T, requireApproval bool) (*app.AuthService, string) {
+		storage := memory.NewStorage()
+		authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+			app.WithRefreshTokens(storage.RefreshTokenRepository()),
+			app.WithSessions(storage.SessionRepository()),
+			app.WithDevices(storage.DeviceRepository(), requireApproval))
+		userID, err := authService.Register(ctx, "testuser", "password123")
+		require.NoError(t, err)
+		return authService, userID
+	}
+	login := func(t *testing.T, authService *app.AuthService) *app.Principal {
+		access, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		require.NoError(t, err)
+		principal, err := authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+		return principal
+	}
+
+	t.Run("approval required", func(t *testing.T) {
+		authService, userID := setup(t, true)
+
+		principal := login(t, authService)
+		assert.False(t, principal.DeviceTrusted, "login without a registered device must not be trusted")
+
+		laptop, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "laptop", "", "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, laptop.Secret)
+		assert.NotNil(t, laptop.Device.ApprovedAt, "first device is approved automatically")
+
+		// Сессия входа заменяется сессией устройства
+		sessions, err := authService.ListSessions(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, laptop.Device.ID, sessions[0].DeviceID)
+		laptopPrincipal, err := authService.Authenticate(ctx, laptop.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, laptop.Device.ID, laptopPrincipal.DeviceID)
+		assert.True(t, laptopPrincipal.DeviceTrusted)
+
+		principal = login(t, authService)
+		phone, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "phone", "", "")
+		require.NoError(t, err)
+		assert.Nil(t, phone.Device.ApprovedAt)
+		phonePrincipal, err := authService.Authenticate(ctx, phone.AccessToken)
+		require.NoError(t, err)
+		assert.False(t, phonePrincipal.DeviceTrusted)
+
+		err = authService.ApproveDevice(ctx, userID, phone.Device.ID, phone.Device.ID)
+		assert.Equal(t, domain.ErrDeviceNotApproved, err)
+
+		// Устройство узнается по своему секрету, но не по одному ID
+		principal = login(t, authService)
+		again, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "", laptop.Device.ID, laptop.Secret)
+		require.NoError(t, err)
+		assert.Equal(t, laptop.Device.ID, again.Device.ID)
+		assert.Empty(t, again.Secret)
+		principal = login(t, authService)
+		forged, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "", laptop.Device.ID, "wrong-secret")
+		require.NoError(t, err)
+		assert.NotEqual(t, laptop.Device.ID, forged.Device.ID)
+		assert.Nil(t, forged.Device.ApprovedAt)
+
+		require.NoError(t, authService.ApproveDevice(ctx, userID, laptop.Device.ID, phone.Device.ID))
+		phonePrincipal, err = authService.Authenticate(ctx, phone.AccessToken)
+		require.NoError(t, err)
+		assert.True(t, phonePrincipal.DeviceTrusted)
+
+		// Обновленные токены остаются привязаны к устройству
+		access, _, err := authService.RefreshTokens(ctx, phone.RefreshToken)
+		require.NoError(t, err)
+		phonePrincipal, err = authService.Authenticate(ctx, access)
+		require.NoError(t, err)
+		assert.Equal(t, phone.Device.ID, phonePrincipal.DeviceID)
+
+		require.NoError(t, authService.RenameDevice(ctx, userID, phone.Device.ID, "my phone"))
+		devices, err := authService.ListDevices(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, devices, 3)
+		names := make(map[string]string)
+		for _, device := range devices {
+			names[device.ID] = device.Name
+		}
+		assert.Equal(t, "my phone", names[phone.Device.ID])
+		assert.Equal(t, "laptop", names[laptop.Device.ID])
+
+		require.NoError(t, authService.RemoveDevice(ctx, userID, phone.Device.ID))
+		_, err = authService.Authenticate(ctx, access)
+		assert.Error(t, err, "sessions of a removed device must be revoked")
+		assert.Equal(t, domain.ErrDeviceNotFound, authService.RemoveDevice(ctx, userID, phone.Device.ID))
+		assert.Equal(t, domain.ErrDeviceNotFound, authService.RenameDevice(ctx, "another-user", laptop.Device.ID, "stolen"))
+
+		// Удаление всех одобренных устройств не открывает путь к автоматическому одобрению
+		require.NoError(t, authService.RemoveDevice(ctx, userID, laptop.Device.ID))
+		principal = login(t, authService)
+		intruder, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "intruder", "", "")
+		require.NoError(t, err)
+		assert.Nil(t, intruder.Device.ApprovedAt)
+	})
+
+	t.Run("concurrent first devices", func(t *testing.T) {
+		authService, userID := setup(t, true)
+
+		const devices = 8
+		principals := make([]*app.Principal, devices)
+		for i := range principals {
+			principals[i] = login(t, authService)
+		}
+
+		var wg sync.WaitGroup
+		approved := make(chan bool, devices)
+		for _, principal := range principals {
+			wg.Add(1)
+			go func(sessionID string) {
+				defer wg.Done()
+				registration, err := authService.RegisterDevice(ctx, userID, sessionID, "device", "", "")
+				if assert.NoError(t, err) {
+					approved <- registration.Device.ApprovedAt != nil
+				}
+			}(principal.SessionID)
+		}
+		wg.Wait()
+		close(approved)
+
+		count := 0
+		for ok := range approved {
+			if ok {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count, "only one of concurrent first devices is approved")
+	})
+
+	t.Run("approval not required", func(t *testing.T) {
+		authService, userID := setup(t, false)
+
+		principal := login(t, authService)
+		assert.True(t, principal.DeviceTrusted)
+
+		first, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "laptop", "", "")
+		require.NoError(t, err)
+		principal = login(t, authService)
+		second, err := authService.RegisterDevice(ctx, userID, principal.SessionID, "phone", "", "")
+		require.NoError(t, err)
+		assert.NotNil(t, first.Device.ApprovedAt)
+		assert.NotNil(t, second.Device.ApprovedAt)
+	})
+}
+
+func TestAuthService_AccessTokens(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithAccessTokens(storage.AccessTokenRepository()))
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	require.NoError(t, err)
+	otherID, err := authService.Register(ctx, "otheruser", "password123")
+	require.NoError(t, err)
+
+	request := app.AccessTokenRequest{
+		Name:         " ci ",
+		SecretIDs:    []string{"secret-1", "secret-2", "secret-1", ""},
+		Tags:         []string{"deploy"},
+		Permission:   domain.AccessTokenRead,
+		DelegatedKey: []byte("delegated"),
+	}
+	token, tokenString, err := authService.CreateAccessToken(ctx, userID, request)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", token.Name)
+	assert.Equal(t, []string{"secret-1", "secret-2"}, token.SecretIDs)
+	assert.True(t, strings.HasPrefix(tokenString, app.AccessTokenPrefix+token.ID+"."))
+
+	principal, err := authService.Authenticate(ctx, tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, userID, principal.User.ID)
+	require.NotNil(t, principal.AccessToken)
+	assert.Equal(t, token.ID, principal.AccessToken.ID)
+	assert.Equal(t, []byte("delegated"), principal.AccessToken.DelegatedKey)
+	assert.Empty(t, principal.SessionID)
+
+	_, err = authService.Authenticate(ctx, app.AccessTokenPrefix+token.ID+".wrong-secret")
+	assert.Equal(t, domain.ErrInvalidToken, err)
+	_, err = authService.Authenticate(ctx, app.AccessTokenPrefix+"malformed")
+	assert.Equal(t, domain.ErrInvalidToken, err)
+
+	tokens, err := authService.ListAccessTokens(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	t.Run("invalid requests", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		for name, modify := range map[string]func(*app.AccessTokenRequest){
+			"no name":          func(r *app.AccessTokenRequest) { r.Name = " " },
+			"empty scope":      func(r *app.AccessTokenRequest) { r.SecretIDs = nil },
+			"no permission":    func(r *app.AccessTokenRequest) { r.Permission = "" },
+			"no delegated key": func(r *app.AccessTokenRequest) { r.DelegatedKey = nil },
+			"already expired":  func(r *app.AccessTokenRequest) { r.ExpiresAt = &past },
+		} {
+			req := request
+			modify(&req)
+			_, _, err := authService.CreateAccessToken(ctx, userID, req)
+			var validationErr domain.ValidationError
+			assert.ErrorAs(t, err, &validationErr, name)
+		}
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Minute)
+		req := request
+		req.ExpiresAt = &expiresAt
+		expiring, tokenString, err := authService.CreateAccessToken(ctx, userID, req)
+		require.NoError(t, err)
+
+		stored, err := storage.AccessTokenRepository().GetByID(ctx, expiring.ID)
+		require.NoError(t, err)
+		past := time.Now().Add(-time.Second)
+		stored.ExpiresAt = &past
+		require.NoError(t, storage.AccessTokenRepository().Delete(ctx, expiring.ID))
+		require.NoError(t, storage.AccessTokenRepository().Create(ctx, stored))
+
+		_, err = authService.Authenticate(ctx, tokenString)
+		assert.Equal(t, domain.ErrTokenExpired, err)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		err := authService.RevokeAccessToken(ctx, otherID, token.ID)
+		assert.Equal(t, domain.ErrAccessTokenNotFound, err, "other users cannot revoke the token")
+
+		require.NoError(t, authService.RevokeAccessToken(ctx, userID, token.ID))
+		_, err = authService.Authenticate(ctx, tokenString)
+		assert.Equal(t, domain.ErrInvalidToken, err)
+		assert.Equal(t, domain.ErrAccessTokenNotFound, authService.RevokeAccessToken(ctx, userID, token.ID))
+	})
+}
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"context"
+)
+
+// ClientInfo сведения об устройстве, с которого выполняется запрос
+type ClientInfo struct {
+	DeviceName    string
+	ClientVersion string
+	IPAddress     string
+}
+
+type clientInfoKey struct{}
+
+// ContextWithClientInfo добавляет в контекст сведения об устройстве клиента
+func ContextWithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFromContext возвращает сведения об устройстве клиента из контекста
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
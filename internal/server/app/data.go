@@ -4,16 +4,32 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/alisaviation/GophKeeper/internal/crypto"
-
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
 )
 
 // DataService предоставляет методы для управления секретами
 type DataService struct {
-	secrets interfaces.SecretRepository
-	crypto  crypto.Encryptor
+	secrets   interfaces.SecretRepository
+	manifests interfaces.ManifestRepository
+	txManager interfaces.TransactionManager
+}
+
+// DataServiceOption настраивает необязательные параметры DataService
+type DataServiceOption func(*DataService)
+
+// WithAtomicSync задает менеджер транзакций для атомарной синхронизации
+func WithAtomicSync(txManager interfaces.TransactionManager) DataServiceOption {
+	return func(s *DataService) {
+		s.txManager = txManager
+	}
+}
+
+// WithManifests задает репозиторий описей хранилищ
+func WithManifests(manifests interfaces.ManifestRepository) DataServiceOption {
+	return func(s *DataService) {
+		s.manifests = manifests
+	}
 }
 
 // SyncResult представляет результат синхронизации
@@ -21,34 +37,72 @@ type SyncResult struct {
 	CurrentVersion int64
 	ServerSecrets  []*domain.Secret
 	Conflicts      []string
+	// RevokedAccessTokens число токенов доступа, отозванных атомарной синхронизацией с новым ключом хранилища
+	RevokedAccessTokens int
 }
 
-// NewDataService создает новый сервис управления данными
-func NewDataService(secrets interfaces.SecretRepository, crypto crypto.Encryptor) *DataService {
-	return &DataService{
+// NewDataService создает новый сервис управления данными.
+// Шифрование секретов на стороне сервера выполняет хранилище (см. пакет storage/encrypted).
+func NewDataService(secrets interfaces.SecretRepository, opts ...DataServiceOption) *DataService {
+	s := &DataService{
 		secrets: secrets,
-		crypto:  crypto,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
-// Sync синхронизирует данные между клиентом и сервером
+// Sync синхронизирует данные между клиентом и сервером. Если задан менеджер транзакций,
+// версии проверяются и секреты записываются в транзакции после блокировки секретов
+// пользователя, как в SyncAtomic: иначе запись, проверившая версию до ротации ключа,
+// могла бы сохранить шифротекст прежним ключом уже после нее.
 func (s *DataService) Sync(ctx context.Context, userID string, clientSecrets []*domain.Secret, lastSyncVersion int64) (*SyncResult, error) {
 	for _, secret := range clientSecrets {
 		if secret.UserID != userID {
 			return nil, domain.ErrAccessDenied
 		}
 	}
-	serverVersion, err := s.secrets.GetUserSecretsVersion(ctx, userID)
+	if s.txManager == nil {
+		return s.syncSecrets(ctx, s.secrets, userID, clientSecrets, lastSyncVersion)
+	}
+
+	tx, err := s.txManager.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := tx.LockUserSecrets(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock secrets: %w", err)
+	}
+
+	result, err := s.syncSecrets(ctx, tx.SecretRepository(), userID, clientSecrets, lastSyncVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit sync: %w", err)
+	}
+	return result, nil
+}
+
+// syncSecrets применяет изменения клиента через переданный репозиторий и возвращает изменения сервера
+func (s *DataService) syncSecrets(ctx context.Context, secrets interfaces.SecretRepository, userID string, clientSecrets []*domain.Secret, lastSyncVersion int64) (*SyncResult, error) {
+	serverVersion, err := secrets.GetUserSecretsVersion(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get server version: %w", err)
 	}
 
-	serverChanges, err := s.secrets.GetChangedSecrets(ctx, userID, lastSyncVersion)
+	serverChanges, err := secrets.GetChangedSecrets(ctx, userID, lastSyncVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get server changes: %w", err)
 	}
 
-	conflicts, err := s.processClientChanges(ctx, userID, clientSecrets)
+	conflicts, err := s.processClientChanges(ctx, secrets, userID, clientSecrets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process client changes: %w", err)
 	}
@@ -62,6 +116,131 @@ func (s *DataService) Sync(ctx context.Context, userID string, clientSecrets []*
 	return result, nil
 }
 
+// SyncAtomic применяет изменения клиента одной транзакцией вместе с новым защищенным
+// ключом хранилища (если он передан). При конфликте версий не применяется ни одно
+// изменение, а результат содержит список конфликтующих секретов. Версии проверяются внутри
+// транзакции после блокировки секретов пользователя, поэтому параллельная синхронизация
+// не может изменить их между проверкой и записью.
+//
+// Новый ключ хранилища означает ротацию: пакет должен перешифровывать все секреты пользователя,
+// а lastSyncVersion — совпадать с текущей версией его секретов. Токены доступа хранят
+// делегированный прежний ключ и удаляются той же транзакцией, а результат содержит все секреты.
+func (s *DataService) SyncAtomic(ctx context.Context, userID string, clientSecrets []*domain.Secret, lastSyncVersion int64, protectedVaultKey []byte) (*SyncResult, error) {
+	if s.txManager == nil {
+		return nil, errTransactionsNotConfigured
+	}
+
+	for _, secret := range clientSecrets {
+		if secret.UserID != userID {
+			return nil, domain.ErrAccessDenied
+		}
+	}
+	rotation := len(protectedVaultKey) > 0
+	if rotation {
+		if err := validateProtectedVaultKey(protectedVaultKey); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.txManager.BeginTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := tx.LockUserSecrets(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock secrets: %w", err)
+	}
+	secrets := tx.SecretRepository()
+
+	conflicts, err := findVersionConflicts(ctx, secrets, userID, clientSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check versions: %w", err)
+	}
+	if len(conflicts) > 0 {
+		return &SyncResult{Conflicts: conflicts}, nil
+	}
+
+	if rotation {
+		conflicts, err = findRotationConflicts(ctx, secrets, userID, clientSecrets, lastSyncVersion)
+		if err == domain.ErrVersionConflict {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check rotation: %w", err)
+		}
+		if len(conflicts) > 0 {
+			return &SyncResult{Conflicts: conflicts}, nil
+		}
+	}
+
+	conflicts, err = s.processClientChanges(ctx, secrets, userID, clientSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process client changes: %w", err)
+	}
+	if len(conflicts) > 0 {
+		return &SyncResult{Conflicts: conflicts}, nil
+	}
+
+	revokedTokens := 0
+	if rotation {
+		now := domain.Now()
+		err = tx.VaultKeyRepository().Save(ctx, &domain.VaultKey{
+			UserID:       userID,
+			ProtectedKey: protectedVaultKey,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to save vault key: %w", err)
+		}
+
+		revokedTokens, err = deleteAccessTokens(ctx, tx.AccessTokenRepository(), userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit sync: %w", err)
+	}
+
+	serverVersion, err := s.secrets.GetUserSecretsVersion(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	// После ротации клиенту нужны все секреты, перешифрованные новым ключом
+	changedSince := lastSyncVersion
+	if rotation {
+		changedSince = 0
+	}
+	serverChanges, err := s.secrets.GetChangedSecrets(ctx, userID, changedSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server changes: %w", err)
+	}
+
+	return &SyncResult{
+		CurrentVersion:      serverVersion,
+		ServerSecrets:       serverChanges,
+		RevokedAccessTokens: revokedTokens,
+	}, nil
+}
+
+// deleteAccessTokens удаляет все токены доступа пользователя и возвращает их число
+func deleteAccessTokens(ctx context.Context, accessTokens interfaces.AccessTokenRepository, userID string) (int, error) {
+	tokens, err := accessTokens.ListByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	for _, token := range tokens {
+		if err := accessTokens.Delete(ctx, token.ID); err != nil && err != domain.ErrAccessTokenNotFound {
+			return 0, fmt.Errorf("failed to delete access token: %w", err)
+		}
+	}
+	return len(tokens), nil
+}
+
 // GetSecret возвращает секрет по ID
 func (s *DataService) GetSecret(ctx context.Context, userID, secretID string) (*domain.Secret, error) {
 	secret, err := s.secrets.GetByID(ctx, secretID, userID)
@@ -145,3 +324,51 @@ func (s *DataService) DeleteSecret(ctx context.Context, userID, secretID string)
 	}
 	return nil
 }
+
+// GetVaultManifest возвращает опись хранилища пользователя и ее версию.
+// Если опись еще не сохранена, возвращаются пустые данные и версия 0.
+func (s *DataService) GetVaultManifest(ctx context.Context, userID string) ([]byte, int64, error) {
+	if s.manifests == nil {
+		return nil, 0, errManifestsNotConfigured
+	}
+
+	manifest, err := s.manifests.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get vault manifest: %w", err)
+	}
+	if manifest == nil {
+		return nil, 0, nil
+	}
+	return manifest.Data, manifest.Version, nil
+}
+
+// PutVaultManifest сохраняет опись хранилища. Версия должна быть на единицу больше сохраненной:
+// так два устройства не перезапишут опись друг друга незаметно.
+func (s *DataService) PutVaultManifest(ctx context.Context, userID string, data []byte, version int64) error {
+	if s.manifests == nil {
+		return errManifestsNotConfigured
+	}
+	if len(data) == 0 {
+		return domain.ValidationError{Field: "manifest", Message: "is required"}
+	}
+	if len(data) > maxVaultManifestSize {
+		return domain.ValidationError{Field: "manifest", Message: fmt.Sprintf("must be at most %d bytes", maxVaultManifestSize)}
+	}
+	if version < 1 {
+		return domain.ValidationError{Field: "version", Message: "must be positive"}
+	}
+
+	err := s.manifests.Save(ctx, &domain.VaultManifest{
+		UserID:    userID,
+		Data:      data,
+		Version:   version,
+		UpdatedAt: domain.Now(),
+	})
+	if err != nil {
+		if err == domain.ErrManifestConflict {
+			return err
+		}
+		return fmt.Errorf("failed to save vault manifest: %w", err)
+	}
+	return nil
+}
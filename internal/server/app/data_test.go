@@ -7,17 +7,15 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
-	"github.com/alisaviation/GophKeeper/internal/crypto"
-
 	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
 	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
 )
 
 func TestDataService_CreateAndGetSecret(t *testing.T) {
 	storage := memory.NewStorage()
-	crypto := &crypto.NoopEncryptor{}
-	dataService := app.NewDataService(storage.SecretRepository(), crypto)
+	dataService := app.NewDataService(storage.SecretRepository())
 	ctx := context.Background()
 
 	userID := domain.GenerateID()
@@ -40,8 +38,7 @@ func TestDataService_CreateAndGetSecret(t *testing.T) {
 
 func TestDataService_Sync(t *testing.T) {
 	storage := memory.NewStorage()
-	crypto := &crypto.NoopEncryptor{}
-	dataService := app.NewDataService(storage.SecretRepository(), crypto)
+	dataService := app.NewDataService(storage.SecretRepository())
 	ctx := context.Background()
 
 	userID := domain.GenerateID()
@@ -61,10 +58,208 @@ func TestDataService_Sync(t *testing.T) {
 	assert.Equal(t, serverSecret.ID, result.ServerSecrets[0].ID)
 }
 
+// recordingTxManager запоминает, блокировались ли секреты пользователя и была ли зафиксирована транзакция
+type recordingTxManager struct {
+	interfaces.TransactionManager
+	locked    []string
+	committed int
+}
+
+type recordingTx struct {
+	interfaces.Transaction
+	manager *recordingTxManager
+}
+
+func (m *recordingTxManager) BeginTx(ctx context.Context) (interfaces.Transaction, error) {
+	tx, err := m.TransactionManager.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingTx{Transaction: tx, manager: m}, nil
+}
+
+func (tx *recordingTx) LockUserSecrets(ctx context.Context, userID string) error {
+	tx.manager.locked = append(tx.manager.locked, userID)
+	return tx.Transaction.LockUserSecrets(ctx, userID)
+}
+
+func (tx *recordingTx) Commit(ctx context.Context) error {
+	tx.manager.committed++
+	return tx.Transaction.Commit(ctx)
+}
+
+func TestDataService_SyncLocksUserSecrets(t *testing.T) {
+	storage := memory.NewStorage()
+	txManager := &recordingTxManager{TransactionManager: storage.TransactionManager()}
+	dataService := app.NewDataService(storage.SecretRepository(), app.WithAtomicSync(txManager))
+	ctx := context.Background()
+
+	userID := domain.GenerateID()
+	secret := &domain.Secret{
+		Type:          domain.TextData,
+		Name:          "Note",
+		EncryptedData: []byte("data"),
+	}
+	require.NoError(t, dataService.CreateSecret(ctx, userID, secret))
+
+	updated := *secret
+	updated.EncryptedData = []byte("updated data")
+
+	result, err := dataService.Sync(ctx, userID, []*domain.Secret{&updated}, 1)
+	require.NoError(t, err)
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, []string{userID}, txManager.locked)
+	assert.Equal(t, 1, txManager.committed)
+
+	stored, err := storage.SecretRepository().GetByID(ctx, secret.ID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("updated data"), stored.EncryptedData)
+	assert.Equal(t, int64(2), stored.Version)
+}
+
+func TestDataService_SyncAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (interfaces.Storage, *app.DataService, string, *domain.Secret) {
+		storage := memory.NewStorage()
+		dataService := app.NewDataService(storage.SecretRepository(),
+			app.WithAtomicSync(storage.TransactionManager()),
+		)
+
+		userID := domain.GenerateID()
+		secret := &domain.Secret{
+			Type:          domain.TextData,
+			Name:          "Note",
+			EncryptedData: []byte("old key data"),
+			KeyID:         "old",
+		}
+		require.NoError(t, dataService.CreateSecret(ctx, userID, secret))
+		return storage, dataService, userID, secret
+	}
+
+	t.Run("applies secrets and vault key together", func(t *testing.T) {
+		storage, dataService, userID, secret := setup(t)
+
+		updated := *secret
+		updated.EncryptedData = []byte("new key data")
+		updated.KeyID = "new"
+
+		result, err := dataService.SyncAtomic(ctx, userID, []*domain.Secret{&updated}, 1, []byte("new protected key"))
+		require.NoError(t, err)
+		assert.Empty(t, result.Conflicts)
+		require.Len(t, result.ServerSecrets, 1)
+		assert.Equal(t, "new", result.ServerSecrets[0].KeyID)
+		assert.Equal(t, int64(2), result.ServerSecrets[0].Version)
+
+		vaultKey, err := storage.VaultKeyRepository().GetByUserID(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new protected key"), vaultKey.ProtectedKey)
+	})
+
+	t.Run("new vault key revokes access tokens", func(t *testing.T) {
+		storage, dataService, userID, secret := setup(t)
+
+		require.NoError(t, storage.AccessTokenRepository().Create(ctx, &domain.AccessToken{
+			ID:           domain.GenerateID(),
+			UserID:       userID,
+			SecretHash:   "hash",
+			SecretIDs:    []string{secret.ID},
+			Permission:   domain.AccessTokenRead,
+			DelegatedKey: []byte("old delegated key"),
+			CreatedAt:    domain.Now(),
+		}))
+
+		updated := *secret
+		updated.KeyID = "new"
+
+		result, err := dataService.SyncAtomic(ctx, userID, []*domain.Secret{&updated}, 1, []byte("new protected key"))
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.RevokedAccessTokens)
+
+		tokens, err := storage.AccessTokenRepository().ListByUser(ctx, userID)
+		require.NoError(t, err)
+		assert.Empty(t, tokens)
+	})
+
+	t.Run("conflict applies nothing", func(t *testing.T) {
+		storage, dataService, userID, secret := setup(t)
+
+		other := &domain.Secret{
+			Type:          domain.TextData,
+			Name:          "Other",
+			EncryptedData: []byte("old key data"),
+		}
+		require.NoError(t, dataService.CreateSecret(ctx, userID, other))
+
+		first := *secret
+		first.KeyID = "new"
+		stale := *other
+		stale.Version = 0
+		stale.KeyID = "new"
+
+		result, err := dataService.SyncAtomic(ctx, userID, []*domain.Secret{&first, &stale}, 0, []byte("new protected key"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{other.ID}, result.Conflicts)
+
+		current, err := dataService.GetSecret(ctx, userID, secret.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "old", current.KeyID)
+		assert.Equal(t, int64(1), current.Version)
+
+		_, err = storage.VaultKeyRepository().GetByUserID(ctx, userID)
+		assert.ErrorIs(t, err, domain.ErrVaultKeyNotFound)
+	})
+
+	t.Run("rotation must cover every secret", func(t *testing.T) {
+		storage, dataService, userID, secret := setup(t)
+
+		other := &domain.Secret{
+			Type:          domain.TextData,
+			Name:          "Other",
+			EncryptedData: []byte("old key data"),
+		}
+		require.NoError(t, dataService.CreateSecret(ctx, userID, other))
+
+		updated := *secret
+		updated.KeyID = "new"
+
+		result, err := dataService.SyncAtomic(ctx, userID, []*domain.Secret{&updated}, 1, []byte("new protected key"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{other.ID}, result.Conflicts)
+
+		current, err := dataService.GetSecret(ctx, userID, secret.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "old", current.KeyID)
+
+		_, err = storage.VaultKeyRepository().GetByUserID(ctx, userID)
+		assert.ErrorIs(t, err, domain.ErrVaultKeyNotFound)
+	})
+
+	t.Run("rotation with stale version", func(t *testing.T) {
+		storage, dataService, userID, secret := setup(t)
+
+		updated := *secret
+		updated.KeyID = "new"
+
+		_, err := dataService.SyncAtomic(ctx, userID, []*domain.Secret{&updated}, 0, []byte("new protected key"))
+		assert.ErrorIs(t, err, domain.ErrVersionConflict)
+
+		_, err = storage.VaultKeyRepository().GetByUserID(ctx, userID)
+		assert.ErrorIs(t, err, domain.ErrVaultKeyNotFound)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		storage := memory.NewStorage()
+		dataService := app.NewDataService(storage.SecretRepository())
+
+		_, err := dataService.SyncAtomic(ctx, "user", nil, 0, nil)
+		assert.Error(t, err)
+	})
+}
+
 func TestDataService_UpdateSecret(t *testing.T) {
 	storage := memory.NewStorage()
-	crypto := &crypto.NoopEncryptor{}
-	dataService := app.NewDataService(storage.SecretRepository(), crypto)
+	dataService := app.NewDataService(storage.SecretRepository())
 	ctx := context.Background()
 
 	userID := domain.GenerateID()
@@ -96,8 +291,7 @@ func TestDataService_UpdateSecret(t *testing.T) {
 
 func TestDataService_UpdateSecret_VersionConflict(t *testing.T) {
 	storage := memory.NewStorage()
-	crypto := &crypto.NoopEncryptor{}
-	dataService := app.NewDataService(storage.SecretRepository(), crypto)
+	dataService := app.NewDataService(storage.SecretRepository())
 	ctx := context.Background()
 
 	userID := domain.GenerateID()
@@ -144,8 +338,7 @@ func TestDataService_UpdateSecret_VersionConflict(t *testing.T) {
 
 func TestDataService_DeleteSecret(t *testing.T) {
 	storage := memory.NewStorage()
-	encryptor := &crypto.NoopEncryptor{}
-	dataService := app.NewDataService(storage.SecretRepository(), encryptor)
+	dataService := app.NewDataService(storage.SecretRepository())
 	ctx := context.Background()
 
 	userID := domain.GenerateID()
@@ -169,8 +362,7 @@ func TestDataService_DeleteSecret(t *testing.T) {
 
 func TestDataService_ListSecrets(t *testing.T) {
 	storage := memory.NewStorage()
-	encryptor := &crypto.NoopEncryptor{}
-	dataService := app.NewDataService(storage.SecretRepository(), encryptor)
+	dataService := app.NewDataService(storage.SecretRepository())
 	ctx := context.Background()
 
 	userID := domain.GenerateID()
@@ -211,3 +403,39 @@ func TestDataService_ListSecrets(t *testing.T) {
 	assert.Len(t, textSecrets, 1)
 	assert.Equal(t, secret2.ID, textSecrets[0].ID)
 }
+
+func TestDataService_VaultManifest(t *testing.T) {
+	storage := memory.NewStorage()
+	dataService := app.NewDataService(storage.SecretRepository(),
+		app.WithManifests(storage.ManifestRepository()))
+	ctx := context.Background()
+	userID := domain.GenerateID()
+
+	t.Run("not saved yet", func(t *testing.T) {
+		data, version, err := dataService.GetVaultManifest(ctx, userID)
+		require.NoError(t, err)
+		assert.Nil(t, data)
+		assert.Zero(t, version)
+	})
+
+	t.Run("versions must be consecutive", func(t *testing.T) {
+		err := dataService.PutVaultManifest(ctx, userID, []byte("manifest-2"), 2)
+		assert.Equal(t, domain.ErrManifestConflict, err)
+
+		require.NoError(t, dataService.PutVaultManifest(ctx, userID, []byte("manifest-1"), 1))
+		err = dataService.PutVaultManifest(ctx, userID, []byte("manifest-1b"), 1)
+		assert.Equal(t, domain.ErrManifestConflict, err)
+		require.NoError(t, dataService.PutVaultManifest(ctx, userID, []byte("manifest-2"), 2))
+
+		data, version, err := dataService.GetVaultManifest(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("manifest-2"), data)
+		assert.Equal(t, int64(2), version)
+	})
+
+	t.Run("empty manifest rejected", func(t *testing.T) {
+		err := dataService.PutVaultManifest(ctx, userID, nil, 3)
+		var validationErr domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// deviceSecretSize размер секрета устройства в байтах
+const deviceSecretSize = 32
+
+// maxDeviceNameLength ограничивает длину имени устройства
+const maxDeviceNameLength = 64
+
+var errDevicesNotConfigured = errors.New("device repository is not configured")
+
+// DeviceRegistration результат регистрации устройства
+type DeviceRegistration struct {
+	Device *domain.Device
+	// Secret секрет нового устройства; для уже зарегистрированного устройства пуст
+	Secret       string
+	AccessToken  string
+	RefreshToken string
+}
+
+// WithDevices задает хранилище устройств пользователей. Если requireApproval, секреты доступны
+// только устройствам, одобренным с уже доверенного устройства; автоматически одобряется только
+// самое первое устройство пользователя.
+func WithDevices(devices interfaces.DeviceRepository, requireApproval bool) AuthServiceOption {
+	return func(s *AuthService) {
+		s.devices = devices
+		s.requireDeviceApproval = requireApproval
+	}
+}
+
+// RegisterDevice привязывает вход к устройству. Если deviceID и deviceSecret подтверждают
+// зарегистрированное устройство пользователя, используется оно, иначе заводится новое
+// с именем name (или именем из сведений о клиенте).
+// Текущая сессия sessionID завершается: вместо нее выдается сессия с ID устройства в токенах.
+func (s *AuthService) RegisterDevice(ctx context.Context, userID, sessionID, name, deviceID, deviceSecret string) (*DeviceRegistration, error) {
+	registration, user, err := s.registerDevice(ctx, userID, name, deviceID, deviceSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	registration.AccessToken, registration.RefreshToken, err = s.issueTokens(ctx, user, "", registration.Device.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sessionID != "" && s.refreshTokens != nil {
+		if err := s.revokeSession(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	return registration, nil
+}
+
+// RegisterCertificateDevice регистрирует устройство клиента, вошедшего только сертификатом, так же,
+// как RegisterDevice, но без выдачи токенов: иначе сертификат открывал бы методы, требующие сессии.
+func (s *AuthService) RegisterCertificateDevice(ctx context.Context, userID, name, deviceID, deviceSecret string) (*DeviceRegistration, error) {
+	registration, _, err := s.registerDevice(ctx, userID, name, deviceID, deviceSecret)
+	return registration, err
+}
+
+// registerDevice находит устройство, подтвержденное deviceID и deviceSecret, или заводит новое
+func (s *AuthService) registerDevice(ctx context.Context, userID, name, deviceID, deviceSecret string) (*DeviceRegistration, *domain.User, error) {
+	if s.devices == nil {
+		return nil, nil, errDevicesNotConfigured
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = ClientInfoFromContext(ctx).DeviceName
+	}
+	if err := validateDeviceName(name); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registration := &DeviceRegistration{}
+	registration.Device, err = s.knownDevice(ctx, userID, deviceID, deviceSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if registration.Device == nil {
+		registration.Device, registration.Secret, err = s.createDevice(ctx, userID, name, now)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		// Имя уже известного устройства меняется только через RenameDevice
+		device := registration.Device
+		device.ClientVersion = ClientInfoFromContext(ctx).ClientVersion
+		device.LastSeenAt = now
+		if err := s.devices.Update(ctx, device); err != nil {
+			return nil, nil, fmt.Errorf("failed to update device: %w", err)
+		}
+	}
+
+	return registration, user, nil
+}
+
+// ListDevices возвращает устройства пользователя. Время последнего использования учитывает
+// и активные сессии устройства.
+func (s *AuthService) ListDevices(ctx context.Context, userID string) ([]*domain.Device, error) {
+	if s.devices == nil {
+		return nil, errDevicesNotConfigured
+	}
+
+	devices, err := s.devices.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	if s.sessions != nil {
+		sessions, err := s.sessions.ListActiveByUser(ctx, userID, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		for _, device := range devices {
+			for _, session := range sessions {
+				if session.DeviceID == device.ID && session.LastUsedAt.After(device.LastSeenAt) {
+					device.LastSeenAt = session.LastUsedAt
+				}
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// RenameDevice меняет имя устройства пользователя
+func (s *AuthService) RenameDevice(ctx context.Context, userID, deviceID, name string) error {
+	if s.devices == nil {
+		return errDevicesNotConfigured
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return domain.ValidationError{Field: "name", Message: "is required"}
+	}
+	if err := validateDeviceName(name); err != nil {
+		return err
+	}
+
+	device, err := s.userDevice(ctx, userID, deviceID)
+	if err != nil {
+		return err
+	}
+
+	device.Name = name
+	if err := s.devices.Update(ctx, device); err != nil {
+		return fmt.Errorf("failed to update device: %w", err)
+	}
+	return nil
+}
+
+// RemoveDevice удаляет устройство пользователя и завершает все его сессии.
+// Чтобы снова войти с этого устройства, его придется зарегистрировать и одобрить заново.
+func (s *AuthService) RemoveDevice(ctx context.Context, userID, deviceID string) error {
+	if s.devices == nil {
+		return errDevicesNotConfigured
+	}
+
+	if _, err := s.userDevice(ctx, userID, deviceID); err != nil {
+		return err
+	}
+
+	if err := s.devices.Delete(ctx, deviceID); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	if s.sessions == nil || s.refreshTokens == nil {
+		return nil
+	}
+
+	sessions, err := s.sessions.ListActiveByUser(ctx, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, session := range sessions {
+		if session.DeviceID != deviceID {
+			continue
+		}
+		if err := s.revokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApproveDevice одобряет устройство deviceID. Одобрять можно только с уже одобренного
+// устройства callerDeviceID, иначе возвращается domain.ErrDeviceNotApproved.
+func (s *AuthService) ApproveDevice(ctx context.Context, userID, callerDeviceID, deviceID string) error {
+	if s.devices == nil {
+		return errDevicesNotConfigured
+	}
+
+	caller, err := s.userDevice(ctx, userID, callerDeviceID)
+	if err == domain.ErrDeviceNotFound {
+		return domain.ErrDeviceNotApproved
+	}
+	if err != nil {
+		return err
+	}
+	if caller.ApprovedAt == nil {
+		return domain.ErrDeviceNotApproved
+	}
+
+	device, err := s.userDevice(ctx, userID, deviceID)
+	if err != nil {
+		return err
+	}
+	if device.ApprovedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	device.ApprovedAt = &now
+	if err := s.devices.Update(ctx, device); err != nil {
+		return fmt.Errorf("failed to update device: %w", err)
+	}
+	return nil
+}
+
+// deviceTrusted сообщает, доступны ли секреты входу с устройства deviceID
+func (s *AuthService) deviceTrusted(ctx context.Context, userID, deviceID string) (bool, error) {
+	if s.devices == nil || !s.requireDeviceApproval {
+		return true, nil
+	}
+	if deviceID == "" {
+		return false, nil
+	}
+
+	device, err := s.userDevice(ctx, userID, deviceID)
+	if err == domain.ErrDeviceNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return device.ApprovedAt != nil, nil
+}
+
+// userDevice возвращает устройство пользователя; чужие устройства считаются несуществующими
+func (s *AuthService) userDevice(ctx context.Context, userID, deviceID string) (*domain.Device, error) {
+	if deviceID == "" {
+		return nil, domain.ErrDeviceNotFound
+	}
+
+	device, err := s.devices.GetByID(ctx, deviceID)
+	if err != nil {
+		if err == domain.ErrDeviceNotFound {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	if device.UserID != userID {
+		return nil, domain.ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+// knownDevice возвращает устройство, если deviceSecret подтверждает его, или nil.
+// Неизвестное или удаленное устройство регистрируется заново.
+func (s *AuthService) knownDevice(ctx context.Context, userID, deviceID, deviceSecret string) (*domain.Device, error) {
+	if deviceID == "" || deviceSecret == "" {
+		return nil, nil
+	}
+
+	device, err := s.userDevice(ctx, userID, deviceID)
+	if err == domain.ErrDeviceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashDeviceSecret(deviceSecret)), []byte(device.SecretHash)) != 1 {
+		return nil, nil
+	}
+	return device, nil
+}
+
+// createDevice заводит новое устройство и возвращает его вместе с секретом. Без обязательного
+// одобрения, а также самое первое устройство пользователя одобряется сразу.
+func (s *AuthService) createDevice(ctx context.Context, userID, name string, now time.Time) (*domain.Device, string, error) {
+	secret := make([]byte, deviceSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate device secret: %w", err)
+	}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	device := &domain.Device{
+		ID:            domain.GenerateID(),
+		UserID:        userID,
+		Name:          name,
+		ClientVersion: ClientInfoFromContext(ctx).ClientVersion,
+		SecretHash:    hashDeviceSecret(encodedSecret),
+		CreatedAt:     now,
+		LastSeenAt:    now,
+	}
+
+	approved, err := s.autoApproveDevice(ctx, userID, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if approved {
+		device.ApprovedAt = &now
+	}
+
+	if err := s.devices.Create(ctx, device); err != nil {
+		return nil, "", fmt.Errorf("failed to create device: %w", err)
+	}
+	return device, encodedSecret, nil
+}
+
+// autoApproveDevice сообщает, одобряется ли новое устройство без участия пользователя: одобрение
+// не требуется или это самое первое устройство пользователя. Отметка о первом одобрении хранится
+// отдельно от устройств, поэтому удаление всех одобренных устройств не открывает путь к
+// автоматическому одобрению, а из одновременных первых регистраций одобряется только одна.
+func (s *AuthService) autoApproveDevice(ctx context.Context, userID string, now time.Time) (bool, error) {
+	first, err := s.devices.ClaimFirstApproval(ctx, userID, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim first device approval: %w", err)
+	}
+	return first || !s.requireDeviceApproval, nil
+}
+
+func validateDeviceName(name string) error {
+	if utf8.RuneCountInString(name) > maxDeviceNameLength {
+		return domain.ValidationError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxDeviceNameLength)}
+	}
+	return nil
+}
+
+// hashDeviceSecret возвращает SHA-256 секрета устройства: в хранилище попадает только хеш
+func hashDeviceSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,17 +2,20 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
 )
 
 func validateCredentials(login, password string) error {
-	if len(login) < 3 || len(login) > 50 {
-		return &domain.ValidationError{
-			Field:   "login",
-			Message: "must be between 3 and 50 characters",
-		}
+	if err := validateLogin(login); err != nil {
+		return err
 	}
 
 	if len(password) < 8 {
@@ -22,6 +25,17 @@ func validateCredentials(login, password string) error {
 		}
 	}
 
+	return nil
+}
+
+func validateLogin(login string) error {
+	if len(login) < 3 || len(login) > 50 {
+		return &domain.ValidationError{
+			Field:   "login",
+			Message: "must be between 3 and 50 characters",
+		}
+	}
+
 	for _, char := range login {
 		if !isValidLoginChar(char) {
 			return &domain.ValidationError{
@@ -34,6 +48,16 @@ func validateCredentials(login, password string) error {
 	return nil
 }
 
+func validateNewPassword(oldPassword, newPassword string) error {
+	if len(newPassword) < 8 {
+		return domain.ValidationError{Field: "new_password", Message: "must be at least 8 characters"}
+	}
+	if oldPassword == newPassword {
+		return domain.ValidationError{Field: "new_password", Message: "must differ from the current password"}
+	}
+	return nil
+}
+
 func isValidLoginChar(char rune) bool {
 	return (char >= 'a' && char <= 'z') ||
 		(char >= 'A' && char <= 'Z') ||
@@ -45,11 +69,327 @@ func generateUserID() string {
 	return domain.GenerateID()
 }
 
-func (s *DataService) processClientChanges(ctx context.Context, userID string, clientSecrets []*domain.Secret) ([]string, error) {
+// assignKDFParams генерирует пользователю соль и фиксирует текущие параметры KDF
+func (s *AuthService) assignKDFParams(user *domain.User) error {
+	salt, err := crypto.GenerateSalt(crypto.SaltSize)
+	if err != nil {
+		return fmt.Errorf("failed to generate kdf salt: %w", err)
+	}
+
+	user.KDFSalt = salt
+	user.KDFIterations = s.kdfParams.Iterations
+	user.KDFMemory = s.kdfParams.Memory
+	user.KDFParallelism = s.kdfParams.Parallelism
+	return nil
+}
+
+// maxProtectedVaultKeySize ограничивает размер защищенного ключа хранилища
+const maxProtectedVaultKeySize = 1024
+
+// maxVaultManifestSize ограничивает размер описи хранилища
+const maxVaultManifestSize = 2 << 20
+
+var (
+	errVaultKeysNotConfigured    = errors.New("vault key repository is not configured")
+	errTransactionsNotConfigured = errors.New("transaction manager is not configured")
+	errSessionsNotConfigured     = errors.New("session repository is not configured")
+	errManifestsNotConfigured    = errors.New("manifest repository is not configured")
+)
+
+func validateProtectedVaultKey(protectedKey []byte) error {
+	if len(protectedKey) == 0 {
+		return domain.ValidationError{Field: "protected_key", Message: "is required"}
+	}
+	if len(protectedKey) > maxProtectedVaultKeySize {
+		return domain.ValidationError{Field: "protected_key", Message: fmt.Sprintf("must be at most %d bytes", maxProtectedVaultKeySize)}
+	}
+	return nil
+}
+
+// commitPasswordChange в одной транзакции сохраняет пользователя с новым паролем и ключ хранилища,
+// перешифрованный клиентом, и отзывает все сессии. Текущее устройство deviceID получает новую пару токенов.
+func (s *AuthService) commitPasswordChange(ctx context.Context, updated *domain.User, deviceID string, protectedVaultKey []byte) (string, string, error) {
+	tx, err := s.txManager.BeginTx(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := tx.UserRepository().Update(ctx, updated); err != nil {
+		return "", "", fmt.Errorf("failed to update password: %w", err)
+	}
+
+	now := updated.UpdatedAt
+	err = tx.VaultKeyRepository().Save(ctx, &domain.VaultKey{
+		UserID:       updated.ID,
+		ProtectedKey: protectedVaultKey,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save vault key: %w", err)
+	}
+
+	if s.refreshTokens != nil {
+		if err := tx.RefreshTokenRepository().RevokeByUser(ctx, updated.ID, now); err != nil {
+			return "", "", fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+	}
+	if s.sessions != nil {
+		if err := tx.SessionRepository().RevokeByUser(ctx, updated.ID, now); err != nil {
+			return "", "", fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", "", fmt.Errorf("failed to commit password change: %w", err)
+	}
+
+	return s.issueTokens(ctx, updated, "", deviceID)
+}
+
+// issuedBeforePasswordChange сообщает, что токен выдан до последней смены пароля
+func issuedBeforePasswordChange(claims *crypto.TokenClaims, user *domain.User) bool {
+	if user.PasswordChangedAt.IsZero() {
+		return false
+	}
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return claims.IssuedAt.Time.Before(user.PasswordChangedAt)
+}
+
+// rehashPassword пересчитывает устаревший хеш пароля после успешной проверки.
+// Время смены пароля не меняется, поэтому выданные токены остаются действительными.
+// Ошибка не мешает входу: хеш будет пересчитан при следующей попытке.
+func (s *AuthService) rehashPassword(ctx context.Context, user *domain.User, password string) *domain.User {
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return user
+	}
+
+	updated := *user
+	updated.PasswordHash = passwordHash
+	updated.UpdatedAt = time.Now()
+
+	if err := s.users.Update(ctx, &updated); err != nil {
+		return user
+	}
+	return &updated
+}
+
+// issueTokens выдает пару токенов сессии sessionID на устройстве deviceID; пустой sessionID
+// начинает новую сессию. Refresh-токены сессии сохраняются одним семейством.
+func (s *AuthService) issueTokens(ctx context.Context, user *domain.User, sessionID, deviceID string) (string, string, error) {
+	newSession := sessionID == ""
+	if newSession {
+		sessionID = domain.GenerateID()
+	}
+
+	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Login, sessionID, deviceID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, sessionID, deviceID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if s.refreshTokens == nil {
+		return accessToken, refreshToken, nil
+	}
+
+	expiresAt, err := s.storeRefreshToken(ctx, user.ID, sessionID, refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	if s.sessions != nil {
+		if err := s.saveSession(ctx, user.ID, sessionID, deviceID, newSession, expiresAt); err != nil {
+			return "", "", err
+		}
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// storeRefreshToken сохраняет хеш выданного refresh-токена и возвращает срок его действия
+func (s *AuthService) storeRefreshToken(ctx context.Context, userID, familyID, refreshToken string) (time.Time, error) {
+	claims, err := s.jwtManager.ValidateToken(refreshToken)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse refresh token: %w", err)
+	}
+
+	token := &domain.RefreshToken{
+		ID:        domain.GenerateID(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(refreshToken),
+		CreatedAt: time.Now(),
+	}
+	if claims.ExpiresAt != nil {
+		token.ExpiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := s.refreshTokens.Create(ctx, token); err != nil {
+		return time.Time{}, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+	return token.ExpiresAt, nil
+}
+
+// saveSession создает сессию при входе или продлевает ее при обновлении токенов
+func (s *AuthService) saveSession(ctx context.Context, userID, sessionID, deviceID string, newSession bool, expiresAt time.Time) error {
+	info := ClientInfoFromContext(ctx)
+	now := time.Now()
+
+	if !newSession {
+		session, err := s.sessions.GetByID(ctx, sessionID)
+		if err == nil {
+			touchSession(session, info, now)
+			session.ExpiresAt = expiresAt
+			if err := s.sessions.Update(ctx, session); err != nil {
+				return fmt.Errorf("failed to update session: %w", err)
+			}
+			return nil
+		}
+		// Семейства токенов, выданных до появления сессий, получают сессию при первом обновлении
+		if err != domain.ErrSessionNotFound {
+			return fmt.Errorf("failed to get session: %w", err)
+		}
+	}
+
+	err := s.sessions.Create(ctx, &domain.Session{
+		ID:            sessionID,
+		UserID:        userID,
+		DeviceID:      deviceID,
+		DeviceName:    info.DeviceName,
+		ClientVersion: info.ClientVersion,
+		IPAddress:     info.IPAddress,
+		CreatedAt:     now,
+		LastUsedAt:    now,
+		ExpiresAt:     expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// checkSession проверяет, что сессия access-токена не отозвана, отмечает ее использование
+// и возвращает ее
+func (s *AuthService) checkSession(ctx context.Context, userID, sessionID string) (*domain.Session, error) {
+	if sessionID == "" {
+		return nil, domain.ErrInvalidToken
+	}
+
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		if err == domain.ErrSessionNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if session.UserID != userID || session.RevokedAt != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastUsedAt) < sessionTouchInterval {
+		return session, nil
+	}
+
+	touchSession(session, ClientInfoFromContext(ctx), now)
+	if err := s.sessions.Update(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+	return session, nil
+}
+
+// revokeSession отзывает сессию и все ее refresh-токены
+func (s *AuthService) revokeSession(ctx context.Context, sessionID string) error {
+	now := time.Now()
+
+	if err := s.refreshTokens.RevokeFamily(ctx, sessionID, now); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if s.sessions != nil {
+		err := s.sessions.Revoke(ctx, sessionID, now)
+		if err != nil && err != domain.ErrSessionNotFound {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sessionTouchInterval ограничивает частоту записи времени последнего использования сессии
+const sessionTouchInterval = time.Minute
+
+// touchSession отмечает использование сессии с адреса и версии клиента из запроса
+func touchSession(session *domain.Session, info ClientInfo, now time.Time) {
+	session.LastUsedAt = now
+	if info.IPAddress != "" {
+		session.IPAddress = info.IPAddress
+	}
+	if info.ClientVersion != "" {
+		session.ClientVersion = info.ClientVersion
+	}
+}
+
+// rotateRefreshToken помечает refresh-токен использованным и возвращает его семейство.
+// Повторное предъявление уже использованного токена означает, что токен украден:
+// тогда отзывается все семейство, включая токены, выданные взамен.
+func (s *AuthService) rotateRefreshToken(ctx context.Context, userID, refreshToken string) (string, error) {
+	stored, err := s.refreshTokens.GetByHash(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if err == domain.ErrRefreshTokenNotFound {
+			return "", domain.ErrInvalidToken
+		}
+		return "", fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if stored.UserID != userID || stored.RevokedAt != nil {
+		return "", domain.ErrInvalidToken
+	}
+
+	err = s.refreshTokens.MarkRotated(ctx, stored.ID, time.Now())
+	if err == domain.ErrRefreshTokenReused {
+		if err := s.revokeSession(ctx, stored.FamilyID); err != nil {
+			return "", err
+		}
+		return "", domain.ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return stored.FamilyID, nil
+}
+
+// hashRefreshToken возвращает SHA-256 токена: в хранилище попадает только хеш
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func userKDFParams(user *domain.User) crypto.KDFParams {
+	return crypto.KDFParams{
+		Algorithm:   crypto.KDFAlgorithmArgon2id,
+		Salt:        user.KDFSalt,
+		Iterations:  user.KDFIterations,
+		Memory:      user.KDFMemory,
+		Parallelism: user.KDFParallelism,
+	}
+}
+
+func (s *DataService) processClientChanges(ctx context.Context, secrets interfaces.SecretRepository, userID string, clientSecrets []*domain.Secret) ([]string, error) {
 	var conflicts []string
 
 	for _, clientSecret := range clientSecrets {
-		err := s.processSingleSecret(ctx, clientSecret, userID, &conflicts)
+		err := s.processSingleSecret(ctx, secrets, clientSecret, userID, &conflicts)
 		if err != nil {
 			return nil, err
 		}
@@ -57,17 +397,79 @@ func (s *DataService) processClientChanges(ctx context.Context, userID string, c
 
 	return conflicts, nil
 }
-func (s *DataService) processSingleSecret(ctx context.Context, clientSecret *domain.Secret, userID string, conflicts *[]string) error {
+
+// findVersionConflicts проверяет версии всех изменяемых секретов до начала записи
+func findVersionConflicts(ctx context.Context, secrets interfaces.SecretRepository, userID string, clientSecrets []*domain.Secret) ([]string, error) {
+	var conflicts []string
+
+	for _, clientSecret := range clientSecrets {
+		existing, err := secrets.GetByID(ctx, clientSecret.ID, userID)
+		if err == domain.ErrSecretNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !clientSecret.IsDeleted && existing.Version != clientSecret.Version {
+			conflicts = append(conflicts, clientSecret.ID)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// findRotationConflicts проверяет, что ротация ключа перешифровывает ровно те секреты, которые
+// видел клиент: версия секретов пользователя равна lastSyncVersion, а пакет содержит все
+// неудаленные секреты и только их. Секрет, пропущенный ротацией, остался бы зашифрован прежним ключом.
+func findRotationConflicts(ctx context.Context, secrets interfaces.SecretRepository, userID string, clientSecrets []*domain.Secret, lastSyncVersion int64) ([]string, error) {
+	live, err := secrets.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make(map[string]bool, len(clientSecrets))
+	for _, clientSecret := range clientSecrets {
+		batch[clientSecret.ID] = true
+	}
+
+	var conflicts []string
+	for _, secret := range live {
+		if !batch[secret.ID] {
+			conflicts = append(conflicts, secret.ID)
+		}
+		delete(batch, secret.ID)
+	}
+	// Оставшиеся в пакете секреты удалены на сервере после того, как клиент их получил
+	for _, clientSecret := range clientSecrets {
+		if batch[clientSecret.ID] {
+			conflicts = append(conflicts, clientSecret.ID)
+		}
+	}
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	version, err := secrets.GetUserSecretsVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if version != lastSyncVersion {
+		return nil, domain.ErrVersionConflict
+	}
+	return nil, nil
+}
+
+func (s *DataService) processSingleSecret(ctx context.Context, secrets interfaces.SecretRepository, clientSecret *domain.Secret, userID string, conflicts *[]string) error {
 	err := s.validateSecretOwnership(clientSecret, userID)
 	if err != nil {
 		return err
 	}
 
 	if clientSecret.IsDeleted {
-		return s.processDeleteOperation(ctx, clientSecret, userID, conflicts)
+		return s.processDeleteOperation(ctx, secrets, clientSecret, userID, conflicts)
 	}
 
-	return s.processUpsertOperation(ctx, clientSecret, userID, conflicts)
+	return s.processUpsertOperation(ctx, secrets, clientSecret, userID, conflicts)
 }
 
 func (s *DataService) validateSecretOwnership(secret *domain.Secret, userID string) error {
@@ -77,41 +479,41 @@ func (s *DataService) validateSecretOwnership(secret *domain.Secret, userID stri
 	return nil
 }
 
-func (s *DataService) processDeleteOperation(ctx context.Context, clientSecret *domain.Secret, userID string, conflicts *[]string) error {
-	err := s.secrets.SoftDelete(ctx, clientSecret.ID, userID)
+func (s *DataService) processDeleteOperation(ctx context.Context, secrets interfaces.SecretRepository, clientSecret *domain.Secret, userID string, conflicts *[]string) error {
+	err := secrets.SoftDelete(ctx, clientSecret.ID, userID)
 	if err != nil && err != domain.ErrSecretNotFound {
 		*conflicts = append(*conflicts, clientSecret.ID)
 	}
 	return nil
 }
 
-func (s *DataService) processUpsertOperation(ctx context.Context, clientSecret *domain.Secret, userID string, conflicts *[]string) error {
-	existing, err := s.secrets.GetByID(ctx, clientSecret.ID, userID)
+func (s *DataService) processUpsertOperation(ctx context.Context, secrets interfaces.SecretRepository, clientSecret *domain.Secret, userID string, conflicts *[]string) error {
+	existing, err := secrets.GetByID(ctx, clientSecret.ID, userID)
 	if err != nil && err != domain.ErrSecretNotFound {
 		*conflicts = append(*conflicts, clientSecret.ID)
 		return nil
 	}
 
 	if existing == nil {
-		return s.processCreateOperation(ctx, clientSecret, conflicts)
+		return s.processCreateOperation(ctx, secrets, clientSecret, conflicts)
 	}
 
-	return s.processUpdateOperation(ctx, clientSecret, existing, conflicts)
+	return s.processUpdateOperation(ctx, secrets, clientSecret, existing, conflicts)
 }
 
-func (s *DataService) processCreateOperation(ctx context.Context, clientSecret *domain.Secret, conflicts *[]string) error {
+func (s *DataService) processCreateOperation(ctx context.Context, secrets interfaces.SecretRepository, clientSecret *domain.Secret, conflicts *[]string) error {
 	clientSecret.Version = 1
 	clientSecret.CreatedAt = domain.Now()
 	clientSecret.UpdatedAt = domain.Now()
 
-	err := s.secrets.Create(ctx, clientSecret)
+	err := secrets.Create(ctx, clientSecret)
 	if err != nil {
 		*conflicts = append(*conflicts, clientSecret.ID)
 	}
 	return nil
 }
 
-func (s *DataService) processUpdateOperation(ctx context.Context, clientSecret *domain.Secret, existing *domain.Secret, conflicts *[]string) error {
+func (s *DataService) processUpdateOperation(ctx context.Context, secrets interfaces.SecretRepository, clientSecret *domain.Secret, existing *domain.Secret, conflicts *[]string) error {
 	if existing.Version != clientSecret.Version {
 		*conflicts = append(*conflicts, clientSecret.ID)
 		return nil
@@ -120,7 +522,7 @@ func (s *DataService) processUpdateOperation(ctx context.Context, clientSecret *
 	clientSecret.Version = existing.Version
 	clientSecret.UpdatedAt = domain.Now()
 
-	err := s.secrets.Update(ctx, clientSecret)
+	err := secrets.Update(ctx, clientSecret)
 	if err != nil {
 		*conflicts = append(*conflicts, clientSecret.ID)
 	}
@@ -135,7 +537,8 @@ func (s *DataService) validateSecret(secret *domain.Secret) error {
 		}
 	}
 
-	if secret.Name == "" {
+	// Новые клиенты передают название только в зашифрованных метаданных
+	if secret.Name == "" && len(secret.EncryptedMeta) == 0 {
 		return &domain.ValidationError{
 			Field:   "name",
 			Message: "is required",
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// Префиксы ключей учета неудачных попыток входа
+const (
+	loginAttemptKeyPrefix   = "login:"
+	addressAttemptKeyPrefix = "ip:"
+)
+
+// LockoutPolicy пороги защиты входа от перебора паролей. После MaxFailures неудачных попыток
+// подряд следующая попытка возможна только через BaseDelay, и каждая новая неудача удваивает
+// задержку вплоть до MaxDelay. Нулевой порог отключает соответствующую проверку.
+type LockoutPolicy struct {
+	// MaxFailures порог для одного логина
+	MaxFailures int
+	// MaxAddressFailures порог для одного адреса клиента, с которого перебирают разные логины
+	MaxAddressFailures int
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	// FailureWindow неудачи старше окна забываются, и счетчик начинается заново
+	FailureWindow time.Duration
+}
+
+// DefaultLockoutPolicy возвращает пороги по умолчанию
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxFailures:        5,
+		MaxAddressFailures: 50,
+		BaseDelay:          time.Second,
+		MaxDelay:           15 * time.Minute,
+		FailureWindow:      time.Hour,
+	}
+}
+
+// retryAfter возвращает, сколько еще ждать до следующей попытки, или ноль, если вход разрешен
+func (p LockoutPolicy) retryAfter(attempt *domain.LoginAttempt, threshold int, now time.Time) time.Duration {
+	if threshold <= 0 || attempt.Failures < threshold || now.Sub(attempt.LastFailureAt) > p.FailureWindow {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := threshold; i < attempt.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	wait := attempt.LastFailureAt.Add(delay).Sub(now)
+	if wait <= 0 {
+		return 0
+	}
+	// Клиенту сообщаются целые секунды, чтобы повтор не пришел чуть раньше срока
+	return (wait + time.Second - 1).Truncate(time.Second)
+}
+
+// WithLoginLockout включает защиту входа от перебора паролей: неудачные попытки учитываются
+// по логину и по адресу клиента, а после порога вход задерживается с экспоненциальным ростом задержки
+func WithLoginLockout(attempts interfaces.LoginAttemptRepository, policy LockoutPolicy) AuthServiceOption {
+	return func(s *AuthService) {
+		s.loginAttempts = attempts
+		s.lockoutPolicy = policy
+	}
+}
+
+// attemptKey ключ учета неудачных попыток и его порог
+type attemptKey struct {
+	key       string
+	threshold int
+}
+
+// attemptKeys возвращает ключи учета попыток входа под логином login с адреса клиента из контекста
+func (s *AuthService) attemptKeys(ctx context.Context, login string) []attemptKey {
+	keys := []attemptKey{{key: loginAttemptKeyPrefix + login, threshold: s.lockoutPolicy.MaxFailures}}
+	if address := ClientInfoFromContext(ctx).IPAddress; address != "" {
+		keys = append(keys, attemptKey{key: addressAttemptKeyPrefix + address, threshold: s.lockoutPolicy.MaxAddressFailures})
+	}
+	return keys
+}
+
+// checkLoginLockout возвращает domain.LoginLockedError, если вход под логином или с адреса
+// клиента временно заблокирован. Попытку не резервирует: используется там, где пароль не проверяется.
+func (s *AuthService) checkLoginLockout(ctx context.Context, login string) error {
+	if s.loginAttempts == nil {
+		return nil
+	}
+
+	_, err := s.lockoutState(ctx, s.attemptKeys(ctx, login), time.Now())
+	return err
+}
+
+// lockoutState возвращает текущее число неудач по каждому ключу (неудачи за пределами окна
+// не считаются) или domain.LoginLockedError, если вход заблокирован
+func (s *AuthService) lockoutState(ctx context.Context, keys []attemptKey, now time.Time) ([]int, error) {
+	failures := make([]int, len(keys))
+	var retryAfter time.Duration
+	for i, key := range keys {
+		attempt, err := s.loginAttempts.Get(ctx, key.key)
+		if err == domain.ErrLoginAttemptNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check login attempts: %w", err)
+		}
+		if !attempt.LastFailureAt.Before(now.Add(-s.lockoutPolicy.FailureWindow)) {
+			failures[i] = attempt.Failures
+		}
+		retryAfter = max(retryAfter, s.lockoutPolicy.retryAfter(attempt, key.threshold, now))
+	}
+
+	if retryAfter > 0 {
+		return nil, domain.LoginLockedError{RetryAfter: retryAfter}
+	}
+	return failures, nil
+}
+
+// loginAttempt попытка входа, заранее учтенная как неудачная. Проверка блокировки и учет
+// неудачи разнесены во времени на проверку пароля, и без резервирования параллельные попытки
+// проходили бы проверку все разом.
+type loginAttempt struct {
+	service *AuthService // nil, если защита от перебора выключена
+	login   string
+	keys    []attemptKey
+}
+
+// beginLoginAttempt проверяет блокировку входа под логином login и резервирует попытку:
+// атомарно увеличивает счетчики неудач. Если после порога параллельные попытки опередили
+// эту, она отклоняется как заблокированная и остается учтенной.
+func (s *AuthService) beginLoginAttempt(ctx context.Context, login string) (*loginAttempt, error) {
+	if s.loginAttempts == nil {
+		return &loginAttempt{login: login}, nil
+	}
+
+	now := time.Now()
+	keys := s.attemptKeys(ctx, login)
+	failures, err := s.lockoutState(ctx, keys, now)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &loginAttempt{service: s, login: login}
+	var retryAfter time.Duration
+	for i, key := range keys {
+		reserved, err := s.loginAttempts.RecordFailure(ctx, key.key, now, s.lockoutPolicy.FailureWindow)
+		if err != nil {
+			attempt.release(ctx, "")
+			return nil, fmt.Errorf("failed to record login attempt: %w", err)
+		}
+		attempt.keys = append(attempt.keys, key)
+
+		if key.threshold > 0 && reserved.Failures > key.threshold && reserved.Failures != failures[i]+1 {
+			retryAfter = max(retryAfter, s.lockoutPolicy.retryAfter(reserved, key.threshold, now))
+		}
+	}
+
+	if retryAfter > 0 {
+		return nil, domain.LoginLockedError{RetryAfter: retryAfter}
+	}
+	return attempt, nil
+}
+
+// fail завершает неудавшуюся попытку и возвращает err без изменений. Неверный пароль или код 2FA
+// остаются учтенными неудачами; прочие ошибки (в том числе запрос кода 2FA) неудачей не считаются.
+func (a *loginAttempt) fail(ctx context.Context, err error) error {
+	if a.service != nil && err != domain.ErrInvalidCredentials && err != domain.ErrInvalidTOTPCode {
+		a.release(ctx, "")
+	}
+	return err
+}
+
+// succeed завершает успешную попытку: сбрасывает счетчик логина, но не адреса, иначе перебор
+// чужих логинов можно было бы прерывать входом в собственную учетную запись
+func (a *loginAttempt) succeed(ctx context.Context) {
+	if a.service == nil {
+		return
+	}
+	// Ошибка учета не должна мешать входу
+	loginKey := loginAttemptKeyPrefix + a.login
+	_ = a.service.loginAttempts.Delete(ctx, loginKey)
+	a.release(ctx, loginKey)
+}
+
+// release отменяет резервирование по всем ключам, кроме skip
+func (a *loginAttempt) release(ctx context.Context, skip string) {
+	for _, key := range a.keys {
+		if key.key != skip {
+			_ = a.service.loginAttempts.Release(ctx, key.key)
+		}
+	}
+}
+
+// ClearLoginLockout снимает блокировку входа под логином login и (или) с адреса address.
+// Пустые значения пропускаются.
+func ClearLoginLockout(ctx context.Context, attempts interfaces.LoginAttemptRepository, login, address string) error {
+	if login != "" {
+		if err := attempts.Delete(ctx, loginAttemptKeyPrefix+login); err != nil {
+			return err
+		}
+	}
+	if address != "" {
+		if err := attempts.Delete(ctx, addressAttemptKeyPrefix+address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
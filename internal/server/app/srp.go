@@ -0,0 +1,364 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+)
+
+// srpHandshakeTTL время, за которое клиент должен завершить обмен SRP
+const srpHandshakeTTL = time.Minute
+
+// maxSRPHandshakes ограничивает число незавершенных обменов в памяти
+const maxSRPHandshakes = 10000
+
+var errTooManySRPHandshakes = errors.New("too many pending srp handshakes")
+
+// SRPChallenge ответ сервера на первый шаг входа по SRP
+type SRPChallenge struct {
+	HandshakeID     string
+	Salt            []byte
+	ServerEphemeral []byte
+	KDF             crypto.KDFParams
+}
+
+// srpHandshake незавершенный обмен SRP
+type srpHandshake struct {
+	login     string
+	userID    string // пусто, если логин не существует: такой обмен никогда не завершится успешно
+	server    *crypto.SRPServer
+	expiresAt time.Time
+}
+
+// srpHandshakes незавершенные обмены SRP. Каждый обмен одноразовый и живет не дольше srpHandshakeTTL.
+type srpHandshakes struct {
+	mu    sync.Mutex
+	items map[string]*srpHandshake
+}
+
+func newSRPHandshakes() *srpHandshakes {
+	return &srpHandshakes{items: make(map[string]*srpHandshake)}
+}
+
+// put сохраняет обмен и возвращает его ID
+func (h *srpHandshakes) put(handshake *srpHandshake) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.items) >= maxSRPHandshakes {
+		now := time.Now()
+		for id, item := range h.items {
+			if now.After(item.expiresAt) {
+				delete(h.items, id)
+			}
+		}
+		if len(h.items) >= maxSRPHandshakes {
+			return "", errTooManySRPHandshakes
+		}
+	}
+
+	id := domain.GenerateID()
+	h.items[id] = handshake
+	return id, nil
+}
+
+// take извлекает обмен; повторно тот же обмен использовать нельзя
+func (h *srpHandshakes) take(id string) *srpHandshake {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	handshake, ok := h.items[id]
+	if !ok {
+		return nil
+	}
+	delete(h.items, id)
+
+	if time.Now().After(handshake.expiresAt) {
+		return nil
+	}
+	return handshake
+}
+
+// SRPRegister регистрирует пользователя по верификатору SRP: пароль на сервер не передается.
+// Параметры KDF и соль ключа хранилища выбирает клиент, так как верификатор вычисляется из мастер-ключа.
+func (s *AuthService) SRPRegister(ctx context.Context, login string, kdf crypto.KDFParams, salt, verifier []byte) (string, error) {
+	if err := validateLogin(login); err != nil {
+		return "", fmt.Errorf("invalid credentials: %w", err)
+	}
+	if err := kdf.Validate(); err != nil {
+		return "", domain.ValidationError{Field: "kdf", Message: err.Error()}
+	}
+	if err := validateSRPVerifier(salt, verifier); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		ID:             generateUserID(),
+		Login:          login,
+		KDFSalt:        kdf.Salt,
+		KDFIterations:  kdf.Iterations,
+		KDFMemory:      kdf.Memory,
+		KDFParallelism: kdf.Parallelism,
+		SRPSalt:        salt,
+		SRPVerifier:    verifier,
+		Role:           domain.RoleUser,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	if err := s.users.Create(ctx, user); err != nil {
+		if err == domain.ErrUserAlreadyExists {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user.ID, nil
+}
+
+// SRPStart начинает вход по SRP: по открытому значению клиента A возвращает соль,
+// параметры KDF и открытое значение сервера B. Для несуществующего логина и для пользователя,
+// еще не перешедшего на SRP, возвращаются правдоподобные, но ложные значения, чтобы по ответу
+// нельзя было перебирать логины; обмен завершается ErrInvalidCredentials, и клиент повторяет
+// вход по паролю.
+func (s *AuthService) SRPStart(ctx context.Context, login string, clientEphemeral []byte) (*SRPChallenge, error) {
+	if err := s.checkLoginLockout(ctx, login); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByLogin(ctx, login)
+	if err != nil && err != domain.ErrUserNotFound {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var (
+		userID   string
+		salt     []byte
+		verifier []byte
+		kdf      crypto.KDFParams
+	)
+	if user == nil || len(user.SRPVerifier) == 0 {
+		salt, verifier, kdf = s.fakeSRPCredentials(login)
+	} else {
+		userID, salt, verifier, kdf = user.ID, user.SRPSalt, user.SRPVerifier, userKDFParams(user)
+	}
+
+	server, err := crypto.NewSRPServer(login, salt, verifier, clientEphemeral)
+	if err != nil {
+		if err == crypto.ErrSRPInvalidEphemeral {
+			return nil, domain.ValidationError{Field: "client_ephemeral", Message: "is invalid"}
+		}
+		return nil, fmt.Errorf("failed to start srp handshake: %w", err)
+	}
+
+	handshakeID, err := s.srpHandshakes.put(&srpHandshake{
+		login:     login,
+		userID:    userID,
+		server:    server,
+		expiresAt: time.Now().Add(srpHandshakeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SRPChallenge{
+		HandshakeID:     handshakeID,
+		Salt:            salt,
+		ServerEphemeral: server.PublicEphemeral(),
+		KDF:             kdf,
+	}, nil
+}
+
+// SRPLogin завершает вход по SRP: проверяет доказательство клиента и код 2FA, если она включена,
+// и выдает токены вместе с доказательством сервера, по которому клиент убеждается,
+// что говорит с настоящим сервером
+func (s *AuthService) SRPLogin(ctx context.Context, handshakeID string, clientProof []byte, totpCode string) (string, string, string, []byte, error) {
+	handshake := s.srpHandshakes.take(handshakeID)
+	if handshake == nil {
+		return "", "", "", nil, domain.ErrInvalidCredentials
+	}
+	// Обмен мог начаться до блокировки, поэтому она проверяется и здесь
+	attempt, err := s.beginLoginAttempt(ctx, handshake.login)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+
+	serverProof, err := handshake.verify(clientProof)
+	if err != nil {
+		return "", "", "", nil, attempt.fail(ctx, err)
+	}
+
+	user, err := s.users.GetByID(ctx, handshake.userID)
+	if err != nil {
+		return "", "", "", nil, attempt.fail(ctx, domain.ErrInvalidCredentials)
+	}
+
+	if err := s.checkSecondFactor(ctx, user.ID, totpCode); err != nil {
+		return "", "", "", nil, attempt.fail(ctx, err)
+	}
+	attempt.succeed(ctx)
+
+	if user.Disabled() {
+		return "", "", "", nil, domain.ErrAccountDisabled
+	}
+
+	accessToken, refreshToken, err := s.issueTokens(ctx, user, "", "")
+	if err != nil {
+		return "", "", "", nil, err
+	}
+
+	return accessToken, refreshToken, user.ID, serverProof, nil
+}
+
+// ChangePasswordSRP меняет мастер-пароль пользователя, перешедшего на SRP. Знание текущего пароля
+// подтверждается обменом SRP, начатым через SRPStart; новый пароль задается новым верификатором.
+func (s *AuthService) ChangePasswordSRP(ctx context.Context, userID, deviceID, handshakeID string, clientProof, newSalt, newVerifier, protectedVaultKey []byte) (string, string, []byte, error) {
+	if s.txManager == nil {
+		return "", "", nil, errTransactionsNotConfigured
+	}
+	if err := validateSRPVerifier(newSalt, newVerifier); err != nil {
+		return "", "", nil, err
+	}
+	if err := validateProtectedVaultKey(protectedVaultKey); err != nil {
+		return "", "", nil, err
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	attempt, err := s.beginLoginAttempt(ctx, user.Login)
+	if err != nil {
+		return "", "", nil, err
+	}
+	handshakeUserID, serverProof, err := s.finishSRPHandshake(handshakeID, clientProof)
+	if err != nil {
+		return "", "", nil, attempt.fail(ctx, err)
+	}
+	if handshakeUserID != userID {
+		return "", "", nil, attempt.fail(ctx, domain.ErrInvalidCredentials)
+	}
+	attempt.succeed(ctx)
+
+	now := time.Now()
+	updated := *user
+	updated.SRPSalt = newSalt
+	updated.SRPVerifier = newVerifier
+	updated.PasswordChangedAt = now.Truncate(time.Second)
+	updated.UpdatedAt = now
+
+	accessToken, refreshToken, err := s.commitPasswordChange(ctx, &updated, deviceID, protectedVaultKey)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return accessToken, refreshToken, serverProof, nil
+}
+
+// EnrollSRP переводит пользователя, входящего по паролю, на SRP: пароль проверяется последний раз,
+// сохраняется верификатор, а хеш пароля удаляется, после чего вход по паролю для него невозможен.
+// Выданные токены остаются действительными.
+func (s *AuthService) EnrollSRP(ctx context.Context, userID, password string, salt, verifier []byte) error {
+	if !s.legacyPasswordAuth {
+		return domain.ErrLegacyAuthDisabled
+	}
+	if err := validateSRPVerifier(salt, verifier); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" || !s.hasher.Check(password, user.PasswordHash) {
+		return domain.ErrInvalidCredentials
+	}
+
+	updated := *user
+	updated.SRPSalt = salt
+	updated.SRPVerifier = verifier
+	updated.PasswordHash = ""
+	updated.UpdatedAt = time.Now()
+
+	if err := s.users.Update(ctx, &updated); err != nil {
+		return fmt.Errorf("failed to save srp verifier: %w", err)
+	}
+	return nil
+}
+
+// finishSRPHandshake проверяет доказательство клиента и возвращает пользователя обмена
+// и доказательство сервера. Любая ошибка обмена выглядит как неверный пароль.
+func (s *AuthService) finishSRPHandshake(handshakeID string, clientProof []byte) (string, []byte, error) {
+	handshake := s.srpHandshakes.take(handshakeID)
+	if handshake == nil {
+		return "", nil, domain.ErrInvalidCredentials
+	}
+
+	serverProof, err := handshake.verify(clientProof)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return handshake.userID, serverProof, nil
+}
+
+// verify проверяет доказательство клиента и возвращает доказательство сервера
+func (h *srpHandshake) verify(clientProof []byte) ([]byte, error) {
+	if h.userID == "" {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	serverProof, err := h.server.VerifyClientProof(clientProof)
+	if err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+	return serverProof, nil
+}
+
+// fakeSRPCredentials детерминированно получает из логина ложные соль, верификатор и параметры KDF,
+// чтобы повторные запросы для несуществующего логина выглядели одинаково
+func (s *AuthService) fakeSRPCredentials(login string) ([]byte, []byte, crypto.KDFParams) {
+	derive := func(purpose string) []byte {
+		mac := hmac.New(sha256.New, s.srpFakeSeed)
+		mac.Write([]byte(purpose))
+		mac.Write([]byte(login))
+		return mac.Sum(nil)
+	}
+
+	salt := derive("srp-salt")[:crypto.SRPSaltSize]
+	verifier := crypto.ComputeSRPVerifier(login, salt, derive("srp-key"))
+
+	kdf := s.kdfParams
+	kdf.Algorithm = crypto.KDFAlgorithmArgon2id
+	kdf.Salt = derive("kdf-salt")[:crypto.SaltSize]
+
+	return salt, verifier, kdf
+}
+
+// newSRPFakeSeed генерирует секрет для ложных ответов на вход несуществующих пользователей,
+// если WithSRPFakeSeed не задан. Такой секрет меняется при каждом перезапуске сервера.
+func newSRPFakeSeed() []byte {
+	seed := make([]byte, 32)
+	// При ошибке ложные ответы остаются правдоподобными, но становятся предсказуемыми
+	_, _ = rand.Read(seed)
+	return seed
+}
+
+func validateSRPVerifier(salt, verifier []byte) error {
+	if len(salt) < crypto.SRPSaltSize {
+		return domain.ValidationError{Field: "srp_salt", Message: fmt.Sprintf("must be at least %d bytes", crypto.SRPSaltSize)}
+	}
+	if err := crypto.ValidateSRPVerifier(verifier); err != nil {
+		return domain.ValidationError{Field: "srp_verifier", Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+)
+
+// totpIssuer название сервиса в приложении-аутентификаторе
+const totpIssuer = "GophKeeper"
+
+// recoveryCodeCount число кодов восстановления, выдаваемых при подключении 2FA
+const recoveryCodeCount = 10
+
+var errTOTPNotConfigured = errors.New("totp repository is not configured")
+
+// TOTPEnrollment секрет, выданный при подключении двухфакторной аутентификации
+type TOTPEnrollment struct {
+	Secret string // Секрет в base32
+	URI    string // Ссылка otpauth://
+}
+
+// EnableTOTP начинает подключение двухфакторной аутентификации: выдает новый секрет.
+// Вход начнет требовать код только после ConfirmTOTP, поэтому повторный вызов
+// до подтверждения просто заменяет секрет.
+func (s *AuthService) EnableTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	if s.totp == nil {
+		return nil, errTOTPNotConfigured
+	}
+	if s.totpEnrollmentClosed {
+		return nil, domain.ErrTOTPUnavailable
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.totp.GetByUserID(ctx, userID)
+	if err != nil && err != domain.ErrTOTPNotEnabled {
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+	if existing != nil && existing.ConfirmedAt != nil {
+		return nil, domain.ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.totp.Save(ctx, &domain.TOTP{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save totp: %w", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret: crypto.EncodeTOTPSecret(secret),
+		URI:    crypto.TOTPURI(totpIssuer, user.Login, secret),
+	}, nil
+}
+
+// ConfirmTOTP включает двухфакторную аутентификацию, если код из приложения совпал,
+// и возвращает одноразовые коды восстановления. Сервер хранит только их хеши.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if s.totp == nil {
+		return nil, errTOTPNotConfigured
+	}
+	if s.totpEnrollmentClosed {
+		return nil, domain.ErrTOTPUnavailable
+	}
+
+	totp, err := s.totp.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, domain.ErrTOTPAlreadyEnabled
+	}
+
+	now := time.Now()
+	step, ok := crypto.ValidateTOTP(totp.Secret, code, now)
+	if !ok {
+		return nil, domain.ErrInvalidTOTPCode
+	}
+
+	recoveryCodes, err := crypto.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, recoveryCode := range recoveryCodes {
+		hashes = append(hashes, hashRecoveryCode(recoveryCode))
+	}
+
+	confirmed := *totp
+	confirmed.RecoveryCodes = hashes
+	confirmed.LastUsedStep = step
+	confirmed.ConfirmedAt = &now
+	confirmed.UpdatedAt = now
+
+	if err := s.totp.Save(ctx, &confirmed); err != nil {
+		return nil, fmt.Errorf("failed to save totp: %w", err)
+	}
+
+	return recoveryCodes, nil
+}
+
+// DisableTOTP отключает двухфакторную аутентификацию. Отключение подтверждается текущим паролем
+// так же, как удаление учетной записи, а включенная 2FA — еще и кодом из приложения или кодом
+// восстановления. Неудачные попытки учитываются защитой от перебора, как попытки входа.
+func (s *AuthService) DisableTOTP(ctx context.Context, userID, password, handshakeID string, clientProof []byte, code string) error {
+	if s.totp == nil {
+		return errTOTPNotConfigured
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	totp, err := s.totp.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	attempt, err := s.beginLoginAttempt(ctx, user.Login)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCurrentPassword(user, password, handshakeID, clientProof); err != nil {
+		return attempt.fail(ctx, err)
+	}
+	if totp.ConfirmedAt != nil {
+		if err := s.verifySecondFactor(ctx, totp, code); err != nil {
+			return attempt.fail(ctx, err)
+		}
+	}
+	attempt.succeed(ctx)
+
+	if err := s.totp.Delete(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+	return nil
+}
+
+// checkSecondFactor проверяет код двухфакторной аутентификации при входе, если она включена.
+// Вызывается только после проверки пароля, чтобы ответ не раскрывал, включена ли у пользователя 2FA.
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID, code string) error {
+	if s.totp == nil {
+		return nil
+	}
+
+	totp, err := s.totp.GetByUserID(ctx, userID)
+	if err == domain.ErrTOTPNotEnabled {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get totp: %w", err)
+	}
+	if totp.ConfirmedAt == nil {
+		return nil
+	}
+
+	if code == "" {
+		return domain.ErrTOTPRequired
+	}
+	return s.verifySecondFactor(ctx, totp, code)
+}
+
+// verifySecondFactor принимает код из приложения или код восстановления. Оба одноразовые:
+// код приложения нельзя предъявить повторно, а код восстановления погашается.
+func (s *AuthService) verifySecondFactor(ctx context.Context, totp *domain.TOTP, code string) error {
+	var err error
+	if crypto.IsTOTPCode(code) {
+		step, ok := crypto.ValidateTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return domain.ErrInvalidTOTPCode
+		}
+		err = s.totp.MarkUsed(ctx, totp.UserID, step)
+	} else {
+		err = s.totp.UseRecoveryCode(ctx, totp.UserID, hashRecoveryCode(code))
+	}
+
+	if err != nil && err != domain.ErrInvalidTOTPCode {
+		return fmt.Errorf("failed to verify two-factor code: %w", err)
+	}
+	return err
+}
+
+// hashRecoveryCode возвращает SHA-256 кода восстановления в каноническом виде.
+// Коды случайные и длинные, поэтому медленный хеш не нужен.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(crypto.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,22 +2,53 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"time"
 )
 
 var (
-	ErrUserAlreadyExists   = errors.New("user already exists")
-	ErrUserNotFound        = errors.New("user not found")
-	ErrInvalidCredentials  = errors.New("invalid credentials")
-	ErrSecretNotFound      = errors.New("secret not found")
-	ErrVersionConflict     = errors.New("version conflict")
-	ErrInvalidSecretType   = errors.New("invalid secret type")
-	ErrAccessDenied        = errors.New("access denied")
-	ErrInvalidToken        = errors.New("invalid token")
-	ErrTokenExpired        = errors.New("token expired")
-	ErrSecretAlreadyExists = errors.New("secret already exists")
-	ErrInvalidSecret       = errors.New("invalid secret")
+	ErrUserAlreadyExists    = errors.New("user already exists")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrSecretNotFound       = errors.New("secret not found")
+	ErrVersionConflict      = errors.New("version conflict")
+	ErrInvalidSecretType    = errors.New("invalid secret type")
+	ErrAccessDenied         = errors.New("access denied")
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrSecretAlreadyExists  = errors.New("secret already exists")
+	ErrInvalidSecret        = errors.New("invalid secret")
+	ErrVaultKeyNotFound     = errors.New("vault key not found")
+	ErrVaultKeyExists       = errors.New("vault key already exists")
+	ErrDataKeyNotFound      = errors.New("data key not found")
+	ErrDataKeyExists        = errors.New("data key already exists")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrLegacyAuthDisabled   = errors.New("password authentication is disabled")
+	ErrManifestConflict     = errors.New("vault manifest version conflict")
+	ErrTOTPNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrTOTPAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTOTPRequired         = errors.New("two-factor code required")
+	ErrInvalidTOTPCode      = errors.New("invalid two-factor code")
+	ErrTOTPUnavailable      = errors.New("two-factor authentication requires server-side encryption")
+	ErrLoginAttemptNotFound = errors.New("login attempt not found")
+	ErrDeviceNotFound       = errors.New("device not found")
+	ErrDeviceNotApproved    = errors.New("device is not approved")
+	ErrAccessTokenNotFound  = errors.New("access token not found")
+	ErrAccountDisabled      = errors.New("account is disabled")
+	ErrInvalidCertificate   = errors.New("invalid client certificate")
 )
 
+// LoginLockedError вход временно заблокирован после неудачных попыток
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
 type ValidationError struct {
 	Field   string
 	Message string
@@ -16,6 +16,7 @@ func (s *Secret) ToProto() *grpc.Secret {
 		Name:          s.Name,
 		EncryptedData: s.EncryptedData,
 		EncryptedMeta: s.EncryptedMeta,
+		KeyId:         s.KeyID,
 		Version:       s.Version,
 		CreatedAt:     s.CreatedAt.Unix(),
 		UpdatedAt:     s.UpdatedAt.Unix(),
@@ -31,6 +32,7 @@ func SecretFromProto(pb *grpc.Secret) *Secret {
 		Name:          pb.GetName(),
 		EncryptedData: pb.GetEncryptedData(),
 		EncryptedMeta: pb.GetEncryptedMeta(),
+		KeyID:         pb.GetKeyId(),
 		Version:       pb.GetVersion(),
 		CreatedAt:     time.Unix(pb.GetCreatedAt(), 0),
 		UpdatedAt:     time.Unix(pb.GetUpdatedAt(), 0),
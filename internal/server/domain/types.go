@@ -5,13 +5,172 @@ import (
 )
 
 type User struct {
-	ID           string
-	Login        string
-	PasswordHash string
+	ID                string
+	Login             string
+	PasswordHash      string
+	KDFSalt           []byte
+	KDFIterations     uint32
+	KDFMemory         uint32
+	KDFParallelism    uint8
+	SRPSalt           []byte // Соль верификатора SRP
+	SRPVerifier       []byte // Верификатор SRP; у перешедших на SRP пользователей хеша пароля нет
+	PasswordChangedAt time.Time
+	Role              UserRole
+	DisabledAt        *time.Time // Пока задано, вход и запросы пользователя отклоняются
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// UserRole роль пользователя на сервере
+type UserRole string
+
+const (
+	RoleUser  UserRole = "user"
+	RoleAdmin UserRole = "admin"
+)
+
+// IsAdmin сообщает, доступен ли пользователю сервис администрирования
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Disabled сообщает, заблокирована ли учетная запись администратором
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// SecretUsage число и объем секретов пользователя без их содержимого
+type SecretUsage struct {
+	Secrets        int64 // Неудаленные секреты
+	DeletedSecrets int64 // Удаленные секреты, которые хранятся для синхронизации других устройств
+	Bytes          int64 // Объем зашифрованных данных и метаданных всех секретов
+}
+
+// VaultKey ключ хранилища пользователя, зашифрованный на клиенте ключом из мастер-пароля
+type VaultKey struct {
+	UserID       string
+	ProtectedKey []byte
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
 
+// VaultManifest опись хранилища пользователя, подписанная на клиенте ключом хранилища.
+// Сервер хранит ее как есть и только следит, чтобы версии шли подряд.
+type VaultManifest struct {
+	UserID    string
+	Data      []byte
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// TOTP настройки двухфакторной аутентификации пользователя
+type TOTP struct {
+	UserID        string
+	Secret        []byte
+	RecoveryCodes []string   // Хеши неиспользованных кодов восстановления
+	LastUsedStep  int64      // Шаг времени последнего принятого кода: коды этого и прежних шагов не принимаются
+	ConfirmedAt   *time.Time // Пока пусто, настройка не подтверждена кодом и вход кода не требует
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// LoginAttempt неудачные попытки входа по одному ключу: логину или адресу клиента
+type LoginAttempt struct {
+	Key           string
+	Failures      int // Число неудачных попыток подряд
+	LastFailureAt time.Time
+}
+
+// DataKey ключ шифрования данных пользователя на сервере, зашифрованный мастер-ключом сервера
+type DataKey struct {
+	UserID     string
+	WrappedKey []byte
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Device зарегистрированная установка клиента. Сервер выдает ей ID и секрет, которым
+// клиент подтверждает, что это то же устройство, при следующих входах.
+type Device struct {
+	ID            string
+	UserID        string
+	Name          string
+	ClientVersion string
+	SecretHash    string     // SHA-256 секрета устройства; сам секрет хранит только клиент
+	ApprovedAt    *time.Time // Пока пусто, устройство ждет одобрения с уже доверенного устройства
+	CreatedAt     time.Time
+	LastSeenAt    time.Time
+}
+
+// AccessTokenPermission права токена доступа на секреты из его области
+type AccessTokenPermission string
+
+const (
+	AccessTokenRead      AccessTokenPermission = "read"
+	AccessTokenReadWrite AccessTokenPermission = "read_write"
+)
+
+// AccessToken долгоживущий токен доступа для автоматизации (CI). Токен дает доступ только
+// к секретам из своей области; сам токен не хранится, только хеш его секрета.
+type AccessToken struct {
+	ID         string
+	UserID     string
+	Name       string
+	SecretHash string
+	SecretIDs  []string
+	// Tags метки, из которых клиент получил SecretIDs; сервер метки не видит и хранит их для справки
+	Tags       []string
+	Permission AccessTokenPermission
+	// DelegatedKey ключ хранилища, зашифрованный ключом из части токена, которая не передается серверу
+	DelegatedKey []byte
+	CreatedAt    time.Time
+	ExpiresAt    *time.Time // Пусто у бессрочного токена
+	LastUsedAt   *time.Time
+}
+
+// Covers сообщает, входит ли секрет в область токена
+func (t *AccessToken) Covers(secretID string) bool {
+	for _, id := range t.SecretIDs {
+		if id == secretID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanWrite сообщает, может ли токен изменять секреты из своей области
+func (t *AccessToken) CanWrite() bool {
+	return t.Permission == AccessTokenReadWrite
+}
+
+// Session сессия входа пользователя на одном устройстве. Все refresh-токены сессии
+// образуют одно семейство, а access-токены содержат ее ID.
+type Session struct {
+	ID            string
+	UserID        string
+	DeviceID      string // Пусто, пока клиент не зарегистрировал устройство
+	DeviceName    string
+	ClientVersion string
+	IPAddress     string
+	CreatedAt     time.Time
+	LastUsedAt    time.Time
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+}
+
+// RefreshToken выданный refresh-токен; сам токен не хранится, только его хеш.
+// Токены, полученные друг из друга при обновлении, образуют семейство — сессию одного входа.
+type RefreshToken struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
 type Secret struct {
 	ID            string
 	UserID        string
@@ -19,6 +178,7 @@ type Secret struct {
 	Name          string
 	EncryptedData []byte
 	EncryptedMeta []byte
+	KeyID         string
 	Version       int64
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
@@ -3,6 +3,11 @@ package mocks
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/alisaviation/GophKeeper/internal/crypto"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
@@ -62,6 +67,26 @@ func (m *MockUserRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (m *MockUserRepository) List(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	var result []*domain.User
+	for key, user := range m.Users {
+		if key == user.ID && strings.Contains(strings.ToLower(user.Login), strings.ToLower(query)) {
+			result = append(result, user)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Login < result[j].Login
+	})
+	if offset >= len(result) {
+		return nil, nil
+	}
+	result = result[offset:]
+	if limit < len(result) {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
 type MockSecretRepository struct {
 	Secrets    map[string]*domain.Secret
 	Versions   map[string]int64
@@ -152,6 +177,16 @@ func (m *MockSecretRepository) SoftDelete(ctx context.Context, id, userID string
 	return m.Delete(ctx, id, userID)
 }
 
+func (m *MockSecretRepository) DeleteByUser(ctx context.Context, userID string) error {
+	for _, secretID := range m.UserSecret[userID] {
+		delete(m.Secrets, secretID)
+		delete(m.Versions, secretID)
+		delete(m.Deleted, secretID)
+	}
+	delete(m.UserSecret, userID)
+	return nil
+}
+
 func (m *MockSecretRepository) GetUserSecretsVersion(ctx context.Context, userID string) (int64, error) {
 	return int64(len(m.UserSecret[userID])), nil
 }
@@ -166,6 +201,23 @@ func (m *MockSecretRepository) GetChangedSecrets(ctx context.Context, userID str
 	return result, nil
 }
 
+func (m *MockSecretRepository) GetUsage(ctx context.Context, userID string) (*domain.SecretUsage, error) {
+	usage := &domain.SecretUsage{}
+	for _, secretID := range m.UserSecret[userID] {
+		secret, exists := m.Secrets[secretID]
+		if !exists {
+			continue
+		}
+		if m.Deleted[secretID] {
+			usage.DeletedSecrets++
+		} else {
+			usage.Secrets++
+		}
+		usage.Bytes += int64(len(secret.EncryptedData) + len(secret.EncryptedMeta))
+	}
+	return usage, nil
+}
+
 type MockJWTManager struct {
 	Tokens       map[string]*crypto.TokenClaims
 	TokenCounter int
@@ -178,23 +230,34 @@ func NewMockJWTManager() *MockJWTManager {
 	}
 }
 
-func (m *MockJWTManager) GenerateAccessToken(userID, login string) (string, error) {
+func (m *MockJWTManager) GenerateAccessToken(userID, login, sessionID, deviceID string) (string, error) {
 	m.TokenCounter++
 	token := fmt.Sprintf("access_%s_%s_%d", userID, login, m.TokenCounter)
 	m.Tokens[token] = &crypto.TokenClaims{
-		UserID: userID,
-		Login:  login,
-		Type:   "access",
+		UserID:    userID,
+		Login:     login,
+		Type:      "access",
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
 	}
 	return token, nil
 }
 
-func (m *MockJWTManager) GenerateRefreshToken(userID string) (string, error) {
+func (m *MockJWTManager) GenerateRefreshToken(userID, sessionID, deviceID string) (string, error) {
 	m.TokenCounter++
 	token := fmt.Sprintf("refresh_%s_%d", userID, m.TokenCounter)
 	m.Tokens[token] = &crypto.TokenClaims{
-		UserID: userID,
-		Type:   "refresh",
+		UserID:    userID,
+		Type:      "refresh",
+		SessionID: sessionID,
+		DeviceID:  deviceID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+		},
 	}
 	return token, nil
 }
@@ -215,13 +278,17 @@ func (m *MockJWTManager) IsRefreshToken(claims *crypto.TokenClaims) bool {
 	return claims.Type == "refresh"
 }
 
+func (m *MockJWTManager) PublicKeys() []crypto.JWK {
+	return nil
+}
+
 // MockEncryptor мок шифровальщика
 type MockEncryptor struct{}
 
-func (m *MockEncryptor) Encrypt(data []byte) ([]byte, error) {
+func (m *MockEncryptor) Encrypt(data, additionalData []byte) ([]byte, error) {
 	return data, nil
 }
 
-func (m *MockEncryptor) Decrypt(encryptedData []byte) ([]byte, error) {
+func (m *MockEncryptor) Decrypt(encryptedData, additionalData []byte) ([]byte, error) {
 	return encryptedData, nil
 }
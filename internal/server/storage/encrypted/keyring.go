@@ -0,0 +1,129 @@
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// dataKeyAADPrefix контекст, к которому привязан зашифрованный ключ пользователя
+const dataKeyAADPrefix = "gophkeeper/data-key/v1\x00"
+
+// keyring получает ключи шифрования данных пользователей, расшифровывая их мастер-ключом сервера
+type keyring struct {
+	master crypto.Encryptor
+	// rejectUnencrypted записи, не зашифрованные ключом данных пользователя, считаются подмененными
+	rejectUnencrypted bool
+}
+
+// dataKey возвращает ключ шифрования данных пользователя.
+// Если ключа нет и create = false, возвращает nil без ошибки: данные пользователя
+// еще не шифровались на сервере.
+func (k *keyring) dataKey(ctx context.Context, repo interfaces.DataKeyRepository, userID string, create bool) ([]byte, error) {
+	stored, err := repo.GetByUserID(ctx, userID)
+	if errors.Is(err, domain.ErrDataKeyNotFound) {
+		if !create {
+			return nil, nil
+		}
+		return k.createDataKey(ctx, repo, userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+
+	return k.unwrap(stored)
+}
+
+func (k *keyring) createDataKey(ctx context.Context, repo interfaces.DataKeyRepository, userID string) ([]byte, error) {
+	key, err := crypto.GenerateKey(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := wrapDataKey(k.master, userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := domain.Now()
+	err = repo.Create(ctx, &domain.DataKey{
+		UserID:     userID,
+		WrappedKey: wrapped,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if errors.Is(err, domain.ErrDataKeyExists) {
+		// Ключ параллельно создал другой запрос
+		return k.dataKey(ctx, repo, userID, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save data key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (k *keyring) unwrap(stored *domain.DataKey) ([]byte, error) {
+	key, err := k.master.Decrypt(stored.WrappedKey, dataKeyAAD(stored.UserID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of user %s: %w", stored.UserID, err)
+	}
+	return key, nil
+}
+
+func wrapDataKey(master crypto.Encryptor, userID string, key []byte) ([]byte, error) {
+	wrapped, err := master.Encrypt(key, dataKeyAAD(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return wrapped, nil
+}
+
+func dataKeyAAD(userID string) []byte {
+	return []byte(dataKeyAADPrefix + userID)
+}
+
+// RewrapDataKeys перешифровывает ключи данных всех пользователей новым мастер-ключом.
+// Ключи, которые уже расшифровываются новым мастер-ключом, пропускаются, поэтому
+// повторный запуск после сбоя безопасен. Сами секреты не перешифровываются.
+// Возвращает количество перешифрованных ключей.
+func RewrapDataKeys(ctx context.Context, repo interfaces.DataKeyRepository, oldMaster, newMaster crypto.Encryptor) (int, error) {
+	keys, err := repo.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list data keys: %w", err)
+	}
+
+	rewrapped := 0
+	for _, stored := range keys {
+		if _, err := newMaster.Decrypt(stored.WrappedKey, dataKeyAAD(stored.UserID)); err == nil {
+			continue
+		}
+
+		key, err := (&keyring{master: oldMaster}).unwrap(stored)
+		if err != nil {
+			return rewrapped, err
+		}
+
+		wrapped, err := wrapDataKey(newMaster, stored.UserID, key)
+		if err != nil {
+			return rewrapped, err
+		}
+
+		err = repo.Update(ctx, &domain.DataKey{
+			UserID:     stored.UserID,
+			WrappedKey: wrapped,
+			CreatedAt:  stored.CreatedAt,
+			UpdatedAt:  domain.Now(),
+		})
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to update data key of user %s: %w", stored.UserID, err)
+		}
+		rewrapped++
+	}
+
+	return rewrapped, nil
+}
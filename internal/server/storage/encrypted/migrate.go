@@ -0,0 +1,108 @@
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// migrateUsersPageSize число пользователей, читаемых за один запрос
+const migrateUsersPageSize = 500
+
+// EncryptLegacyRecords шифрует ключами данных пользователей поля секретов и секреты TOTP,
+// сохраненные до включения шифрования на сервере. storage — хранилище без шифрования.
+// Записи каждого пользователя шифруются в отдельной транзакции под блокировкой его секретов,
+// поэтому сервер может продолжать работу, а повторный запуск после сбоя безопасен. Версия
+// зашифрованного секрета увеличивается, и клиенты получат его при следующей синхронизации.
+// Возвращает количество зашифрованных записей.
+func EncryptLegacyRecords(ctx context.Context, storage interfaces.Storage, master crypto.Encryptor) (int, error) {
+	keys := &keyring{master: master}
+
+	encrypted := 0
+	for offset := 0; ; offset += migrateUsersPageSize {
+		users, err := storage.UserRepository().List(ctx, "", migrateUsersPageSize, offset)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list users: %w", err)
+		}
+
+		for _, user := range users {
+			count, err := encryptUserRecords(ctx, storage.TransactionManager(), keys, user.ID)
+			if err != nil {
+				return encrypted, err
+			}
+			encrypted += count
+		}
+
+		if len(users) < migrateUsersPageSize {
+			return encrypted, nil
+		}
+	}
+}
+
+// encryptUserRecords шифрует открытые записи пользователя userID и возвращает их количество
+func encryptUserRecords(ctx context.Context, manager interfaces.TransactionManager, keys *keyring, userID string) (int, error) {
+	tx, err := manager.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := tx.LockUserSecrets(ctx, userID); err != nil {
+		return 0, fmt.Errorf("failed to lock secrets of user %s: %w", userID, err)
+	}
+
+	key, err := keys.dataKey(ctx, tx.DataKeyRepository(), userID, false)
+	if err != nil {
+		return 0, err
+	}
+
+	stored, err := tx.SecretRepository().GetChangedSecrets(ctx, userID, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list secrets of user %s: %w", userID, err)
+	}
+
+	secrets := &secretRepository{secrets: tx.SecretRepository(), dataKeys: tx.DataKeyRepository(), keys: keys}
+	count := 0
+	for _, secret := range stored {
+		if !needsSealing(key, secret.EncryptedData) && !needsSealing(key, secret.EncryptedMeta) {
+			continue
+		}
+
+		// Поле могло быть уже зашифровано, а второе еще нет
+		opened, err := open(key, secret, false)
+		if err != nil {
+			return 0, err
+		}
+		if err := secrets.Update(ctx, opened); err != nil {
+			return 0, fmt.Errorf("failed to encrypt secret %s: %w", secret.ID, err)
+		}
+		count++
+	}
+
+	totp, err := tx.TOTPRepository().GetByUserID(ctx, userID)
+	switch {
+	case errors.Is(err, domain.ErrTOTPNotEnabled):
+	case err != nil:
+		return 0, fmt.Errorf("failed to get totp of user %s: %w", userID, err)
+	case needsSealing(key, totp.Secret):
+		sealed := &totpRepository{totp: tx.TOTPRepository(), dataKeys: tx.DataKeyRepository(), keys: keys}
+		if err := sealed.Save(ctx, totp); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit encrypted records of user %s: %w", userID, err)
+	}
+	return count, nil
+}
+
+// needsSealing сообщает, что непустое значение не зашифровано ключом key
+func needsSealing(key, value []byte) bool {
+	return len(value) > 0 && (key == nil || !sealedWith(key, value))
+}
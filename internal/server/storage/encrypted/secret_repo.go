@@ -0,0 +1,226 @@
+package encrypted
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// fieldAADPrefix контекст, к которому привязано зашифрованное на сервере поле секрета
+const fieldAADPrefix = "gophkeeper/at-rest/v1\x00"
+
+const (
+	fieldData = "encrypted_data"
+	fieldMeta = "encrypted_meta"
+)
+
+// secretRepository шифрует encrypted_data и encrypted_meta ключом данных пользователя
+// перед записью во вложенный репозиторий и расшифровывает их при чтении.
+// Записи, сохраненные до включения шифрования на сервере, возвращаются как есть,
+// если не задан WithRejectUnencrypted.
+type secretRepository struct {
+	secrets  interfaces.SecretRepository
+	dataKeys interfaces.DataKeyRepository
+	keys     *keyring
+}
+
+// Create шифрует и сохраняет новый секрет
+func (r *secretRepository) Create(ctx context.Context, secret *domain.Secret) error {
+	sealed, err := r.seal(ctx, secret)
+	if err != nil {
+		return err
+	}
+	return r.secrets.Create(ctx, sealed)
+}
+
+// GetByID получает и расшифровывает секрет
+func (r *secretRepository) GetByID(ctx context.Context, id, userID string) (*domain.Secret, error) {
+	secret, err := r.secrets.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return r.openOne(ctx, userID, secret)
+}
+
+// ListByUser получает и расшифровывает секреты пользователя
+func (r *secretRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Secret, error) {
+	secrets, err := r.secrets.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return r.openAll(ctx, userID, secrets)
+}
+
+// ListByUserAndType получает и расшифровывает секреты пользователя определенного типа
+func (r *secretRepository) ListByUserAndType(ctx context.Context, userID string, secretType domain.SecretType) ([]*domain.Secret, error) {
+	secrets, err := r.secrets.ListByUserAndType(ctx, userID, secretType)
+	if err != nil {
+		return nil, err
+	}
+	return r.openAll(ctx, userID, secrets)
+}
+
+// Update шифрует и обновляет секрет
+func (r *secretRepository) Update(ctx context.Context, secret *domain.Secret) error {
+	sealed, err := r.seal(ctx, secret)
+	if err != nil {
+		return err
+	}
+	return r.secrets.Update(ctx, sealed)
+}
+
+// Delete удаляет секрет
+func (r *secretRepository) Delete(ctx context.Context, id, userID string) error {
+	return r.secrets.Delete(ctx, id, userID)
+}
+
+// DeleteByUser удаляет все секреты пользователя
+func (r *secretRepository) DeleteByUser(ctx context.Context, userID string) error {
+	return r.secrets.DeleteByUser(ctx, userID)
+}
+
+// SoftDelete выполняет мягкое удаление секрета
+func (r *secretRepository) SoftDelete(ctx context.Context, id, userID string) error {
+	return r.secrets.SoftDelete(ctx, id, userID)
+}
+
+// GetUserSecretsVersion получает максимальную версию секретов пользователя
+func (r *secretRepository) GetUserSecretsVersion(ctx context.Context, userID string) (int64, error) {
+	return r.secrets.GetUserSecretsVersion(ctx, userID)
+}
+
+// GetUsage возвращает число и объем секретов пользователя; объем учитывает серверное шифрование
+func (r *secretRepository) GetUsage(ctx context.Context, userID string) (*domain.SecretUsage, error) {
+	return r.secrets.GetUsage(ctx, userID)
+}
+
+// GetChangedSecrets получает и расшифровывает секреты, измененные после указанной версии
+func (r *secretRepository) GetChangedSecrets(ctx context.Context, userID string, lastSyncVersion int64) ([]*domain.Secret, error) {
+	secrets, err := r.secrets.GetChangedSecrets(ctx, userID, lastSyncVersion)
+	if err != nil {
+		return nil, err
+	}
+	return r.openAll(ctx, userID, secrets)
+}
+
+// seal возвращает копию секрета с зашифрованными полями; исходный секрет не изменяется
+func (r *secretRepository) seal(ctx context.Context, secret *domain.Secret) (*domain.Secret, error) {
+	key, err := r.keys.dataKey(ctx, r.dataKeys, secret.UserID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := *secret
+	if sealed.EncryptedData, err = sealField(encryptor, secret, fieldData, secret.EncryptedData); err != nil {
+		return nil, err
+	}
+	if sealed.EncryptedMeta, err = sealField(encryptor, secret, fieldMeta, secret.EncryptedMeta); err != nil {
+		return nil, err
+	}
+
+	return &sealed, nil
+}
+
+func (r *secretRepository) openAll(ctx context.Context, userID string, secrets []*domain.Secret) ([]*domain.Secret, error) {
+	if len(secrets) == 0 {
+		return secrets, nil
+	}
+
+	key, err := r.keys.dataKey(ctx, r.dataKeys, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := make([]*domain.Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		plain, err := open(key, secret, r.keys.rejectUnencrypted)
+		if err != nil {
+			return nil, err
+		}
+		opened = append(opened, plain)
+	}
+
+	return opened, nil
+}
+
+func (r *secretRepository) openOne(ctx context.Context, userID string, secret *domain.Secret) (*domain.Secret, error) {
+	opened, err := r.openAll(ctx, userID, []*domain.Secret{secret})
+	if err != nil {
+		return nil, err
+	}
+	return opened[0], nil
+}
+
+// open возвращает копию секрета с расшифрованными полями. Если strict, поля без заголовка
+// ключа данных пользователя отклоняются.
+func open(key []byte, secret *domain.Secret, strict bool) (*domain.Secret, error) {
+	if key == nil {
+		if strict && (len(secret.EncryptedData) > 0 || len(secret.EncryptedMeta) > 0) {
+			return nil, fmt.Errorf("%w: secret %s", ErrNotEncrypted, secret.ID)
+		}
+		return secret, nil
+	}
+
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := *secret
+	if opened.EncryptedData, err = openField(encryptor, key, secret, fieldData, secret.EncryptedData, strict); err != nil {
+		return nil, err
+	}
+	if opened.EncryptedMeta, err = openField(encryptor, key, secret, fieldMeta, secret.EncryptedMeta, strict); err != nil {
+		return nil, err
+	}
+
+	return &opened, nil
+}
+
+func sealField(encryptor crypto.Encryptor, secret *domain.Secret, field string, value []byte) ([]byte, error) {
+	if len(value) == 0 {
+		return value, nil
+	}
+
+	sealed, err := encryptor.Encrypt(value, fieldAAD(secret, field))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s of secret %s: %w", field, secret.ID, err)
+	}
+	return sealed, nil
+}
+
+// openField расшифровывает поле, если оно зашифровано ключом данных пользователя.
+// Поля без заголовка этого ключа записаны до включения шифрования и возвращаются как есть,
+// а если strict, отклоняются.
+func openField(encryptor crypto.Encryptor, key []byte, secret *domain.Secret, field string, value []byte, strict bool) ([]byte, error) {
+	if !sealedWith(key, value) {
+		if strict && len(value) > 0 {
+			return nil, fmt.Errorf("%w: %s of secret %s", ErrNotEncrypted, field, secret.ID)
+		}
+		return value, nil
+	}
+
+	plain, err := encryptor.Decrypt(value, fieldAAD(secret, field))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s of secret %s: %w", field, secret.ID, err)
+	}
+	return plain, nil
+}
+
+// sealedWith сообщает, что значение зашифровано ключом key
+func sealedWith(key, value []byte) bool {
+	header, err := crypto.ParseEnvelope(value)
+	return err == nil && header.KeyID == crypto.KeyID(key)
+}
+
+func fieldAAD(secret *domain.Secret, field string) []byte {
+	return []byte(fieldAADPrefix + secret.UserID + "\x00" + secret.ID + "\x00" + field)
+}
@@ -0,0 +1,117 @@
+// Package encrypted добавляет к хранилищу серверное шифрование секретов.
+//
+// Каждому пользователю выдается собственный случайный ключ данных, который хранится
+// зашифрованным мастер-ключом сервера. Этим ключом шифруются поля encrypted_data и
+// encrypted_meta поверх шифрования на клиенте, а также секрет TOTP пользователя,
+// поэтому копия базы без мастер-ключа не раскрывает даже клиентские шифротексты. Смена мастер-ключа требует только
+// перешифрования ключей данных (RewrapDataKeys), но не самих секретов.
+package encrypted
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// ErrNotEncrypted запись не зашифрована ключом данных пользователя, хотя шифрование обязательно
+var ErrNotEncrypted = errors.New("record is not encrypted on the server")
+
+// Option настраивает необязательные параметры хранилища с шифрованием
+type Option func(*keyring)
+
+// WithRejectUnencrypted отклоняет при чтении записи, не зашифрованные ключом данных пользователя,
+// вместо того чтобы возвращать их как есть. Включается после EncryptLegacyRecords: тогда
+// открытая запись в базе может появиться только подменой.
+func WithRejectUnencrypted() Option {
+	return func(k *keyring) {
+		k.rejectUnencrypted = true
+	}
+}
+
+// encryptedStorage оборачивает Storage, шифруя секреты в репозиториях
+type encryptedStorage struct {
+	interfaces.Storage
+	keys *keyring
+}
+
+// NewStorage возвращает хранилище, которое шифрует секреты ключами данных пользователей,
+// защищенными мастер-ключом master
+func NewStorage(storage interfaces.Storage, master crypto.Encryptor, opts ...Option) interfaces.Storage {
+	keys := &keyring{master: master}
+	for _, opt := range opts {
+		opt(keys)
+	}
+	return &encryptedStorage{
+		Storage: storage,
+		keys:    keys,
+	}
+}
+
+// SecretRepository возвращает репозиторий секретов с шифрованием
+func (s *encryptedStorage) SecretRepository() interfaces.SecretRepository {
+	return &secretRepository{
+		secrets:  s.Storage.SecretRepository(),
+		dataKeys: s.Storage.DataKeyRepository(),
+		keys:     s.keys,
+	}
+}
+
+// TOTPRepository возвращает репозиторий настроек двухфакторной аутентификации с шифрованием секрета
+func (s *encryptedStorage) TOTPRepository() interfaces.TOTPRepository {
+	return &totpRepository{
+		totp:     s.Storage.TOTPRepository(),
+		dataKeys: s.Storage.DataKeyRepository(),
+		keys:     s.keys,
+	}
+}
+
+// TransactionManager возвращает менеджер транзакций с шифрованием
+func (s *encryptedStorage) TransactionManager() interfaces.TransactionManager {
+	return &transactionManager{
+		manager: s.Storage.TransactionManager(),
+		keys:    s.keys,
+	}
+}
+
+// transactionManager начинает транзакции, в которых секреты шифруются
+type transactionManager struct {
+	manager interfaces.TransactionManager
+	keys    *keyring
+}
+
+// BeginTx начинает новую транзакцию
+func (m *transactionManager) BeginTx(ctx context.Context) (interfaces.Transaction, error) {
+	tx, err := m.manager.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{Transaction: tx, keys: m.keys}, nil
+}
+
+// transaction оборачивает Transaction, шифруя секреты
+type transaction struct {
+	interfaces.Transaction
+	keys *keyring
+}
+
+// SecretRepository возвращает репозиторий секретов с шифрованием в контексте транзакции.
+// Ключ данных нового пользователя создается в той же транзакции.
+func (t *transaction) SecretRepository() interfaces.SecretRepository {
+	return &secretRepository{
+		secrets:  t.Transaction.SecretRepository(),
+		dataKeys: t.Transaction.DataKeyRepository(),
+		keys:     t.keys,
+	}
+}
+
+// TOTPRepository возвращает репозиторий настроек двухфакторной аутентификации с шифрованием
+// в контексте транзакции
+func (t *transaction) TOTPRepository() interfaces.TOTPRepository {
+	return &totpRepository{
+		totp:     t.Transaction.TOTPRepository(),
+		dataKeys: t.Transaction.DataKeyRepository(),
+		keys:     t.keys,
+	}
+}
@@ -0,0 +1,233 @@
+package encrypted_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/encrypted"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
+)
+
+func newMaster(t *testing.T) crypto.Encryptor {
+	t.Helper()
+
+	key, err := crypto.GenerateKey(32)
+	require.NoError(t, err)
+	master, err := crypto.NewAESGCMEncryptor(key)
+	require.NoError(t, err)
+	return master
+}
+
+func newSecret(userID, data string) *domain.Secret {
+	return &domain.Secret{
+		ID:            domain.GenerateID(),
+		UserID:        userID,
+		Type:          domain.TextData,
+		Name:          "note",
+		EncryptedData: []byte(data),
+		EncryptedMeta: []byte(data + " meta"),
+		Version:       1,
+		CreatedAt:     domain.Now(),
+		UpdatedAt:     domain.Now(),
+	}
+}
+
+func TestEncryptedStorage_Secrets(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.NewStorage()
+	storage := encrypted.NewStorage(inner, newMaster(t))
+	userID := domain.GenerateID()
+
+	secret := newSecret(userID, "client ciphertext")
+	require.NoError(t, storage.SecretRepository().Create(ctx, secret))
+	assert.Equal(t, []byte("client ciphertext"), secret.EncryptedData, "caller's secret must not be modified")
+
+	stored, err := inner.SecretRepository().GetByID(ctx, secret.ID, userID)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("client ciphertext"), stored.EncryptedData)
+	assert.NotEqual(t, []byte("client ciphertext meta"), stored.EncryptedMeta)
+
+	dataKey, err := inner.DataKeyRepository().GetByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, dataKey.WrappedKey)
+
+	read, err := storage.SecretRepository().GetByID(ctx, secret.ID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("client ciphertext"), read.EncryptedData)
+	assert.Equal(t, []byte("client ciphertext meta"), read.EncryptedMeta)
+
+	changed, err := storage.SecretRepository().GetChangedSecrets(ctx, userID, 0)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, []byte("client ciphertext"), changed[0].EncryptedData)
+
+	t.Run("legacy plaintext rows", func(t *testing.T) {
+		legacy := newSecret(userID, "legacy ciphertext")
+		require.NoError(t, inner.SecretRepository().Create(ctx, legacy))
+
+		read, err := storage.SecretRepository().GetByID(ctx, legacy.ID, userID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("legacy ciphertext"), read.EncryptedData)
+	})
+
+	t.Run("swapped rows rejected", func(t *testing.T) {
+		other := newSecret(userID, "other ciphertext")
+		require.NoError(t, storage.SecretRepository().Create(ctx, other))
+
+		storedOther, err := inner.SecretRepository().GetByID(ctx, other.ID, userID)
+		require.NoError(t, err)
+		storedOther.EncryptedData = stored.EncryptedData
+
+		_, err = storage.SecretRepository().GetByID(ctx, other.ID, userID)
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptedStorage_Transaction(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.NewStorage()
+	storage := encrypted.NewStorage(inner, newMaster(t))
+	userID := domain.GenerateID()
+
+	tx, err := storage.TransactionManager().BeginTx(ctx)
+	require.NoError(t, err)
+
+	secret := newSecret(userID, "in transaction")
+	require.NoError(t, tx.SecretRepository().Create(ctx, secret))
+	require.NoError(t, tx.Commit(ctx))
+
+	stored, err := inner.SecretRepository().GetByID(ctx, secret.ID, userID)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("in transaction"), stored.EncryptedData)
+
+	read, err := storage.SecretRepository().GetByID(ctx, secret.ID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("in transaction"), read.EncryptedData)
+}
+
+func TestEncryptedStorage_TOTP(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.NewStorage()
+	storage := encrypted.NewStorage(inner, newMaster(t))
+	userID := domain.GenerateID()
+
+	totp := &domain.TOTP{
+		UserID:    userID,
+		Secret:    []byte("12345678901234567890"),
+		CreatedAt: domain.Now(),
+		UpdatedAt: domain.Now(),
+	}
+	require.NoError(t, storage.TOTPRepository().Save(ctx, totp))
+
+	stored, err := inner.TOTPRepository().GetByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.NotEqual(t, totp.Secret, stored.Secret)
+
+	read, err := storage.TOTPRepository().GetByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, totp.Secret, read.Secret)
+
+	t.Run("legacy plaintext row", func(t *testing.T) {
+		legacy := *totp
+		legacy.UserID = domain.GenerateID()
+		require.NoError(t, inner.TOTPRepository().Save(ctx, &legacy))
+
+		read, err := storage.TOTPRepository().GetByUserID(ctx, legacy.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, totp.Secret, read.Secret)
+	})
+}
+
+func TestRewrapDataKeys(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.NewStorage()
+	oldMaster, newMasterKey := newMaster(t), newMaster(t)
+	userIDs := []string{domain.GenerateID(), domain.GenerateID()}
+
+	var secrets []*domain.Secret
+	for _, userID := range userIDs {
+		secret := newSecret(userID, "data of "+userID)
+		require.NoError(t, encrypted.NewStorage(inner, oldMaster).SecretRepository().Create(ctx, secret))
+		secrets = append(secrets, secret)
+	}
+
+	count, err := encrypted.RewrapDataKeys(ctx, inner.DataKeyRepository(), oldMaster, newMasterKey)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = encrypted.RewrapDataKeys(ctx, inner.DataKeyRepository(), oldMaster, newMasterKey)
+	require.NoError(t, err)
+	assert.Zero(t, count, "already rewrapped keys are skipped")
+
+	assertReadable := func(storage interfaces.Storage, ok bool) {
+		for _, secret := range secrets {
+			read, err := storage.SecretRepository().GetByID(ctx, secret.ID, secret.UserID)
+			if !ok {
+				assert.Error(t, err)
+				continue
+			}
+			require.NoError(t, err)
+			assert.Equal(t, secret.EncryptedData, read.EncryptedData)
+		}
+	}
+	assertReadable(encrypted.NewStorage(inner, newMasterKey), true)
+	assertReadable(encrypted.NewStorage(inner, oldMaster), false)
+}
+
+func TestEncryptLegacyRecords(t *testing.T) {
+	ctx := context.Background()
+	inner := memory.NewStorage()
+	master := newMaster(t)
+
+	user := &domain.User{ID: domain.GenerateID(), Login: "legacy", CreatedAt: domain.Now(), UpdatedAt: domain.Now()}
+	require.NoError(t, inner.UserRepository().Create(ctx, user))
+	legacy := newSecret(user.ID, "legacy ciphertext")
+	require.NoError(t, inner.SecretRepository().Create(ctx, legacy))
+	require.NoError(t, inner.TOTPRepository().Save(ctx, &domain.TOTP{
+		UserID:    user.ID,
+		Secret:    []byte("12345678901234567890"),
+		CreatedAt: domain.Now(),
+		UpdatedAt: domain.Now(),
+	}))
+
+	strict := encrypted.NewStorage(inner, master, encrypted.WithRejectUnencrypted())
+	_, err := strict.SecretRepository().GetByID(ctx, legacy.ID, user.ID)
+	assert.ErrorIs(t, err, encrypted.ErrNotEncrypted)
+	_, err = strict.TOTPRepository().GetByUserID(ctx, user.ID)
+	assert.ErrorIs(t, err, encrypted.ErrNotEncrypted)
+
+	count, err := encrypted.EncryptLegacyRecords(ctx, inner, master)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	stored, err := inner.SecretRepository().GetByID(ctx, legacy.ID, user.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("legacy ciphertext"), stored.EncryptedData)
+	assert.Greater(t, stored.Version, legacy.Version)
+
+	read, err := strict.SecretRepository().GetByID(ctx, legacy.ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy ciphertext"), read.EncryptedData)
+	assert.Equal(t, []byte("legacy ciphertext meta"), read.EncryptedMeta)
+	totp, err := strict.TOTPRepository().GetByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("12345678901234567890"), totp.Secret)
+
+	count, err = encrypted.EncryptLegacyRecords(ctx, inner, master)
+	require.NoError(t, err)
+	assert.Zero(t, count, "second run must not encrypt anything")
+
+	t.Run("swapped plaintext rejected", func(t *testing.T) {
+		stored.EncryptedMeta = []byte("injected")
+		require.NoError(t, inner.SecretRepository().Update(ctx, stored))
+
+		_, err := strict.SecretRepository().GetByID(ctx, legacy.ID, user.ID)
+		assert.ErrorIs(t, err, encrypted.ErrNotEncrypted)
+	})
+}
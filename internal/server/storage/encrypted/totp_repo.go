@@ -0,0 +1,94 @@
+package encrypted
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// totpAADPrefix контекст, к которому привязан зашифрованный секрет TOTP
+const totpAADPrefix = "gophkeeper/totp/v1\x00"
+
+// totpRepository шифрует секрет TOTP ключом данных пользователя перед записью
+// во вложенный репозиторий и расшифровывает его при чтении
+type totpRepository struct {
+	totp     interfaces.TOTPRepository
+	dataKeys interfaces.DataKeyRepository
+	keys     *keyring
+}
+
+// Save шифрует секрет и сохраняет настройки двухфакторной аутентификации
+func (r *totpRepository) Save(ctx context.Context, totp *domain.TOTP) error {
+	key, err := r.keys.dataKey(ctx, r.dataKeys, totp.UserID, true)
+	if err != nil {
+		return err
+	}
+
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	if err != nil {
+		return err
+	}
+
+	sealed := *totp
+	sealed.Secret, err = encryptor.Encrypt(totp.Secret, totpAAD(totp.UserID))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	return r.totp.Save(ctx, &sealed)
+}
+
+// GetByUserID получает настройки двухфакторной аутентификации и расшифровывает секрет.
+// Секреты, сохраненные до включения шифрования на сервере, возвращаются как есть,
+// если не задан WithRejectUnencrypted.
+func (r *totpRepository) GetByUserID(ctx context.Context, userID string) (*domain.TOTP, error) {
+	totp, err := r.totp.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := r.keys.dataKey(ctx, r.dataKeys, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || !sealedWith(key, totp.Secret) {
+		if r.keys.rejectUnencrypted {
+			return nil, fmt.Errorf("%w: totp secret of user %s", ErrNotEncrypted, userID)
+		}
+		return totp, nil
+	}
+
+	encryptor, err := crypto.NewXChaCha20Poly1305Encryptor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := *totp
+	opened.Secret, err = encryptor.Decrypt(totp.Secret, totpAAD(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt totp secret of user %s: %w", userID, err)
+	}
+	return &opened, nil
+}
+
+// Delete удаляет настройки двухфакторной аутентификации
+func (r *totpRepository) Delete(ctx context.Context, userID string) error {
+	return r.totp.Delete(ctx, userID)
+}
+
+// MarkUsed запоминает шаг времени принятого кода
+func (r *totpRepository) MarkUsed(ctx context.Context, userID string, step int64) error {
+	return r.totp.MarkUsed(ctx, userID, step)
+}
+
+// UseRecoveryCode погашает код восстановления
+func (r *totpRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	return r.totp.UseRecoveryCode(ctx, userID, codeHash)
+}
+
+func totpAAD(userID string) []byte {
+	return []byte(totpAADPrefix + userID)
+}
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 )
@@ -13,6 +14,9 @@ type UserRepository interface {
 	GetByID(ctx context.Context, id string) (*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id string) error
+	// List возвращает пользователей, логин которых содержит query (без учета регистра),
+	// упорядоченных по логину; пустой query — все пользователи
+	List(ctx context.Context, query string, limit, offset int) ([]*domain.User, error)
 }
 
 // SecretRepository определяет контракт для работы с секретами
@@ -26,6 +30,123 @@ type SecretRepository interface {
 	SoftDelete(ctx context.Context, id, userID string) error
 	GetUserSecretsVersion(ctx context.Context, userID string) (int64, error)
 	GetChangedSecrets(ctx context.Context, userID string, lastSyncVersion int64) ([]*domain.Secret, error)
+	// DeleteByUser удаляет все секреты пользователя вместе с версией его секретов
+	DeleteByUser(ctx context.Context, userID string) error
+	// GetUsage возвращает число и объем секретов пользователя, не читая их содержимое
+	GetUsage(ctx context.Context, userID string) (*domain.SecretUsage, error)
+}
+
+// VaultKeyRepository определяет контракт для работы с защищенными ключами хранилища
+type VaultKeyRepository interface {
+	// Create сохраняет ключ, если у пользователя его еще нет, иначе возвращает domain.ErrVaultKeyExists
+	Create(ctx context.Context, key *domain.VaultKey) error
+	// Save сохраняет или заменяет ключ; заменять ключ можно только вместе с перешифровкой
+	// (смена пароля, ротация ключа)
+	Save(ctx context.Context, key *domain.VaultKey) error
+	GetByUserID(ctx context.Context, userID string) (*domain.VaultKey, error)
+}
+
+// ManifestRepository определяет контракт для работы с описями хранилищ
+type ManifestRepository interface {
+	// Save сохраняет опись, если ее версия на единицу больше сохраненной (или равна 1 для первой описи),
+	// иначе возвращает domain.ErrManifestConflict
+	Save(ctx context.Context, manifest *domain.VaultManifest) error
+	// GetByUserID возвращает опись пользователя или nil, если она еще не сохранена
+	GetByUserID(ctx context.Context, userID string) (*domain.VaultManifest, error)
+}
+
+// TOTPRepository определяет контракт для работы с настройками двухфакторной аутентификации
+type TOTPRepository interface {
+	// Save создает или заменяет настройки пользователя
+	Save(ctx context.Context, totp *domain.TOTP) error
+	// GetByUserID возвращает domain.ErrTOTPNotEnabled, если пользователь не настраивал 2FA
+	GetByUserID(ctx context.Context, userID string) (*domain.TOTP, error)
+	Delete(ctx context.Context, userID string) error
+	// MarkUsed запоминает шаг времени принятого кода. Если уже принят код этого
+	// или более позднего шага, возвращает domain.ErrInvalidTOTPCode.
+	MarkUsed(ctx context.Context, userID string, step int64) error
+	// UseRecoveryCode удаляет неиспользованный код восстановления с хешем codeHash;
+	// если такого кода нет, возвращает domain.ErrInvalidTOTPCode
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+}
+
+// LoginAttemptRepository определяет контракт для учета неудачных попыток входа
+type LoginAttemptRepository interface {
+	// Get возвращает domain.ErrLoginAttemptNotFound, если неудачных попыток не было
+	Get(ctx context.Context, key string) (*domain.LoginAttempt, error)
+	// RecordFailure атомарно увеличивает счетчик неудачных попыток и возвращает его новое значение.
+	// Если последняя неудача была раньше now-window, счетчик начинается заново.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*domain.LoginAttempt, error)
+	// Release атомарно уменьшает счетчик на единицу, отменяя попытку, заранее учтенную RecordFailure
+	Release(ctx context.Context, key string) error
+	// Delete сбрасывает счетчик
+	Delete(ctx context.Context, key string) error
+	// DeleteStale удаляет счетчики, последняя неудача которых была раньше before, и возвращает их число
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DataKeyRepository определяет контракт для работы с ключами шифрования данных на сервере
+type DataKeyRepository interface {
+	Create(ctx context.Context, key *domain.DataKey) error
+	GetByUserID(ctx context.Context, userID string) (*domain.DataKey, error)
+	List(ctx context.Context) ([]*domain.DataKey, error)
+	Update(ctx context.Context, key *domain.DataKey) error
+}
+
+// SessionRepository определяет контракт для работы с сессиями пользователей
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	GetByID(ctx context.Context, id string) (*domain.Session, error)
+	// ListActiveByUser возвращает неотозванные сессии пользователя, не истекшие к моменту now
+	ListActiveByUser(ctx context.Context, userID string, now time.Time) ([]*domain.Session, error)
+	Update(ctx context.Context, session *domain.Session) error
+	Revoke(ctx context.Context, id string, revokedAt time.Time) error
+	RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error
+	// DeleteByUser удаляет все сессии пользователя, в том числе отозванные
+	DeleteByUser(ctx context.Context, userID string) error
+	// DeleteExpired удаляет сессии, истекшие или отозванные раньше before, и возвращает их число
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DeviceRepository определяет контракт для работы с устройствами пользователей
+type DeviceRepository interface {
+	Create(ctx context.Context, device *domain.Device) error
+	// GetByID возвращает domain.ErrDeviceNotFound, если устройства нет
+	GetByID(ctx context.Context, id string) (*domain.Device, error)
+	// ListByUser возвращает устройства пользователя, начиная с последнего активного
+	ListByUser(ctx context.Context, userID string) ([]*domain.Device, error)
+	Update(ctx context.Context, device *domain.Device) error
+	Delete(ctx context.Context, id string) error
+	// ClaimFirstApproval отмечает, что у пользователя появилось одобренное устройство, и сообщает,
+	// что отметка поставлена впервые. Отметка не снимается при удалении устройств; из одновременных
+	// вызовов true получает только один.
+	ClaimFirstApproval(ctx context.Context, userID string, at time.Time) (bool, error)
+}
+
+// AccessTokenRepository определяет контракт для работы с токенами доступа
+type AccessTokenRepository interface {
+	Create(ctx context.Context, token *domain.AccessToken) error
+	// GetByID возвращает domain.ErrAccessTokenNotFound, если токена нет
+	GetByID(ctx context.Context, id string) (*domain.AccessToken, error)
+	// ListByUser возвращает токены пользователя, начиная с последнего созданного
+	ListByUser(ctx context.Context, userID string) ([]*domain.AccessToken, error)
+	UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	// DeleteExpired удаляет токены, истекшие раньше before, и возвращает их число
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RefreshTokenRepository определяет контракт для работы с выданными refresh-токенами
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *domain.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+	// MarkRotated помечает токен использованным; для уже использованного или отозванного
+	// токена возвращает domain.ErrRefreshTokenReused
+	MarkRotated(ctx context.Context, id string, rotatedAt time.Time) error
+	RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error
+	RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error
+	// DeleteExpired удаляет токены, истекшие раньше before, и возвращает их число
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
 }
 
 // TransactionManager определяет контракт для управления транзакциями
@@ -37,14 +158,35 @@ type TransactionManager interface {
 type Transaction interface {
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context) error
+	// LockUserSecrets блокирует версию секретов пользователя до конца транзакции: другие
+	// транзакции, вызвавшие LockUserSecrets для того же пользователя, ждут ее завершения
+	LockUserSecrets(ctx context.Context, userID string) error
 	UserRepository() UserRepository
 	SecretRepository() SecretRepository
+	VaultKeyRepository() VaultKeyRepository
+	ManifestRepository() ManifestRepository
+	TOTPRepository() TOTPRepository
+	LoginAttemptRepository() LoginAttemptRepository
+	DataKeyRepository() DataKeyRepository
+	RefreshTokenRepository() RefreshTokenRepository
+	SessionRepository() SessionRepository
+	DeviceRepository() DeviceRepository
+	AccessTokenRepository() AccessTokenRepository
 }
 
 // Storage объединяет все репозитории
 type Storage interface {
 	UserRepository() UserRepository
 	SecretRepository() SecretRepository
+	VaultKeyRepository() VaultKeyRepository
+	ManifestRepository() ManifestRepository
+	TOTPRepository() TOTPRepository
+	LoginAttemptRepository() LoginAttemptRepository
+	DataKeyRepository() DataKeyRepository
+	RefreshTokenRepository() RefreshTokenRepository
+	SessionRepository() SessionRepository
+	DeviceRepository() DeviceRepository
+	AccessTokenRepository() AccessTokenRepository
 	TransactionManager() TransactionManager
 	Close() error
 	Ping(ctx context.Context) error
@@ -2,6 +2,9 @@ package memory
 
 import (
 	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,11 +14,30 @@ import (
 
 // memoryStorage реализует Storage в памяти
 type memoryStorage struct {
-	mu         sync.RWMutex
-	users      map[string]*domain.User
-	secrets    map[string]*domain.Secret
-	userRepo   *memoryUserRepository
-	secretRepo *memorySecretRepository
+	mu               sync.RWMutex
+	users            map[string]*domain.User
+	secrets          map[string]*domain.Secret
+	vaultKeys        map[string]*domain.VaultKey
+	manifests        map[string]*domain.VaultManifest
+	totp             map[string]*domain.TOTP
+	loginAttempts    map[string]*domain.LoginAttempt
+	dataKeys         map[string]*domain.DataKey
+	refreshTokens    map[string]*domain.RefreshToken
+	sessions         map[string]*domain.Session
+	devices          map[string]*domain.Device
+	deviceApprovals  map[string]time.Time
+	accessTokens     map[string]*domain.AccessToken
+	userRepo         *memoryUserRepository
+	secretRepo       *memorySecretRepository
+	vaultKeyRepo     *memoryVaultKeyRepository
+	manifestRepo     *memoryManifestRepository
+	totpRepo         *memoryTOTPRepository
+	loginAttemptRepo *memoryLoginAttemptRepository
+	dataKeyRepo      *memoryDataKeyRepository
+	refreshTokenRepo *memoryRefreshTokenRepository
+	sessionRepo      *memorySessionRepository
+	deviceRepo       *memoryDeviceRepository
+	accessTokenRepo  *memoryAccessTokenRepository
 }
 
 // memoryUserRepository реализует UserRepository
@@ -28,15 +50,79 @@ type memorySecretRepository struct {
 	storage *memoryStorage
 }
 
+// memoryVaultKeyRepository реализует VaultKeyRepository
+type memoryVaultKeyRepository struct {
+	storage *memoryStorage
+}
+
+// memoryManifestRepository реализует ManifestRepository
+type memoryManifestRepository struct {
+	storage *memoryStorage
+}
+
+// memoryTOTPRepository реализует TOTPRepository
+type memoryTOTPRepository struct {
+	storage *memoryStorage
+}
+
+// memoryLoginAttemptRepository реализует LoginAttemptRepository
+type memoryLoginAttemptRepository struct {
+	storage *memoryStorage
+}
+
+// memoryDataKeyRepository реализует DataKeyRepository
+type memoryDataKeyRepository struct {
+	storage *memoryStorage
+}
+
+// memoryRefreshTokenRepository реализует RefreshTokenRepository
+type memoryRefreshTokenRepository struct {
+	storage *memoryStorage
+}
+
+// memorySessionRepository реализует SessionRepository
+type memorySessionRepository struct {
+	storage *memoryStorage
+}
+
+// memoryDeviceRepository реализует DeviceRepository
+type memoryDeviceRepository struct {
+	storage *memoryStorage
+}
+
+// memoryAccessTokenRepository реализует AccessTokenRepository
+type memoryAccessTokenRepository struct {
+	storage *memoryStorage
+}
+
 // NewStorage создает новый in-memory Storage
 func NewStorage() interfaces.Storage {
 	s := &memoryStorage{
-		users:   make(map[string]*domain.User),
-		secrets: make(map[string]*domain.Secret),
+		users:           make(map[string]*domain.User),
+		secrets:         make(map[string]*domain.Secret),
+		vaultKeys:       make(map[string]*domain.VaultKey),
+		manifests:       make(map[string]*domain.VaultManifest),
+		totp:            make(map[string]*domain.TOTP),
+		loginAttempts:   make(map[string]*domain.LoginAttempt),
+		dataKeys:        make(map[string]*domain.DataKey),
+		refreshTokens:   make(map[string]*domain.RefreshToken),
+		sessions:        make(map[string]*domain.Session),
+		devices:         make(map[string]*domain.Device),
+		deviceApprovals: make(map[string]time.Time),
+		accessTokens:    make(map[string]*domain.AccessToken),
 	}
 
 	s.userRepo = &memoryUserRepository{storage: s}
 	s.secretRepo = &memorySecretRepository{storage: s}
+	s.vaultKeyRepo = &memoryVaultKeyRepository{storage: s}
+	s.manifestRepo = &memoryManifestRepository{storage: s}
+	s.totpRepo = &memoryTOTPRepository{storage: s}
+	s.loginAttemptRepo = &memoryLoginAttemptRepository{storage: s}
+	s.dataKeyRepo = &memoryDataKeyRepository{storage: s}
+	s.refreshTokenRepo = &memoryRefreshTokenRepository{storage: s}
+	s.sessionRepo = &memorySessionRepository{storage: s}
+	s.deviceRepo = &memoryDeviceRepository{storage: s}
+	s.accessTokenRepo = &memoryAccessTokenRepository{storage: s}
 
 	return s
 }
@@ -51,6 +137,51 @@ func (s *memoryStorage) SecretRepository() interfaces.SecretRepository {
 	return s.secretRepo
 }
 
+// VaultKeyRepository возвращает in-memory VaultKeyRepository
+func (s *memoryStorage) VaultKeyRepository() interfaces.VaultKeyRepository {
+	return s.vaultKeyRepo
+}
+
+// ManifestRepository возвращает in-memory ManifestRepository
+func (s *memoryStorage) ManifestRepository() interfaces.ManifestRepository {
+	return s.manifestRepo
+}
+
+// TOTPRepository возвращает in-memory TOTPRepository
+func (s *memoryStorage) TOTPRepository() interfaces.TOTPRepository {
+	return s.totpRepo
+}
+
+// LoginAttemptRepository возвращает in-memory LoginAttemptRepository
+func (s *memoryStorage) LoginAttemptRepository() interfaces.LoginAttemptRepository {
+	return s.loginAttemptRepo
+}
+
+// DataKeyRepository возвращает in-memory DataKeyRepository
+func (s *memoryStorage) DataKeyRepository() interfaces.DataKeyRepository {
+	return s.dataKeyRepo
+}
+
+// RefreshTokenRepository возвращает in-memory RefreshTokenRepository
+func (s *memoryStorage) RefreshTokenRepository() interfaces.RefreshTokenRepository {
+	return s.refreshTokenRepo
+}
+
+// SessionRepository возвращает in-memory SessionRepository
+func (s *memoryStorage) SessionRepository() interfaces.SessionRepository {
+	return s.sessionRepo
+}
+
+// DeviceRepository возвращает in-memory DeviceRepository
+func (s *memoryStorage) DeviceRepository() interfaces.DeviceRepository {
+	return s.deviceRepo
+}
+
+// AccessTokenRepository возвращает in-memory AccessTokenRepository
+func (s *memoryStorage) AccessTokenRepository() interfaces.AccessTokenRepository {
+	return s.accessTokenRepo
+}
+
 // TransactionManager возвращает менеджер транзакций
 func (s *memoryStorage) TransactionManager() interfaces.TransactionManager {
 	return s
@@ -66,6 +197,11 @@ func (s *memoryStorage) Commit(ctx context.Context) error {
 	return nil
 }
 
+// LockUserSecrets для in-memory не делает ничего: каждая операция выполняется под общей блокировкой
+func (s *memoryStorage) LockUserSecrets(ctx context.Context, userID string) error {
+	return nil
+}
+
 // Rollback для in-memory не делает ничего
 func (s *memoryStorage) Rollback(ctx context.Context) error {
 	return nil
@@ -77,6 +213,15 @@ func (s *memoryStorage) Close() error {
 	defer s.mu.Unlock()
 	s.users = make(map[string]*domain.User)
 	s.secrets = make(map[string]*domain.Secret)
+	s.vaultKeys = make(map[string]*domain.VaultKey)
+	s.manifests = make(map[string]*domain.VaultManifest)
+	s.totp = make(map[string]*domain.TOTP)
+	s.loginAttempts = make(map[string]*domain.LoginAttempt)
+	s.dataKeys = make(map[string]*domain.DataKey)
+	s.refreshTokens = make(map[string]*domain.RefreshToken)
+	s.sessions = make(map[string]*domain.Session)
+	s.devices = make(map[string]*domain.Device)
+	s.accessTokens = make(map[string]*domain.AccessToken)
 	return nil
 }
 
@@ -156,9 +301,68 @@ func (r *memoryUserRepository) Delete(ctx context.Context, id string) error {
 	}
 
 	delete(r.storage.users, id)
+
+	// Как и в PostgreSQL, вместе с пользователем удаляется все, что ему принадлежит
+	for key := range r.storage.secrets {
+		if r.storage.extractUserID(key) == id {
+			delete(r.storage.secrets, key)
+		}
+	}
+	delete(r.storage.vaultKeys, id)
+	delete(r.storage.manifests, id)
+	delete(r.storage.totp, id)
+	delete(r.storage.dataKeys, id)
+	for key, token := range r.storage.refreshTokens {
+		if token.UserID == id {
+			delete(r.storage.refreshTokens, key)
+		}
+	}
+	for key, session := range r.storage.sessions {
+		if session.UserID == id {
+			delete(r.storage.sessions, key)
+		}
+	}
+	for key, device := range r.storage.devices {
+		if device.UserID == id {
+			delete(r.storage.devices, key)
+		}
+	}
+	delete(r.storage.deviceApprovals, id)
+	for key, token := range r.storage.accessTokens {
+		if token.UserID == id {
+			delete(r.storage.accessTokens, key)
+		}
+	}
 	return nil
 }
 
+// List возвращает пользователей, логин которых содержит query, упорядоченных по логину
+func (r *memoryUserRepository) List(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	var users []*domain.User
+	for _, user := range r.storage.users {
+		if strings.Contains(strings.ToLower(user.Login), query) {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Login < users[j].Login
+	})
+
+	if offset >= len(users) {
+		return nil, nil
+	}
+	users = users[offset:]
+	if limit < len(users) {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
 // Create создает новый секрет
 func (r *memorySecretRepository) Create(ctx context.Context, secret *domain.Secret) error {
 	r.storage.mu.Lock()
@@ -246,6 +450,19 @@ func (r *memorySecretRepository) Delete(ctx context.Context, id, userID string)
 	return nil
 }
 
+// DeleteByUser удаляет все секреты пользователя
+func (r *memorySecretRepository) DeleteByUser(ctx context.Context, userID string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	for key := range r.storage.secrets {
+		if r.storage.extractUserID(key) == userID {
+			delete(r.storage.secrets, key)
+		}
+	}
+	return nil
+}
+
 // SoftDelete выполняет мягкое удаление секрета
 func (r *memorySecretRepository) SoftDelete(ctx context.Context, id, userID string) error {
 	r.storage.mu.Lock()
@@ -294,6 +511,634 @@ func (r *memorySecretRepository) GetChangedSecrets(ctx context.Context, userID s
 	return secrets, nil
 }
 
+// GetUsage возвращает число и объем секретов пользователя
+func (r *memorySecretRepository) GetUsage(ctx context.Context, userID string) (*domain.SecretUsage, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	usage := &domain.SecretUsage{}
+	for key, secret := range r.storage.secrets {
+		if r.storage.extractUserID(key) != userID {
+			continue
+		}
+		if secret.IsDeleted {
+			usage.DeletedSecrets++
+		} else {
+			usage.Secrets++
+		}
+		usage.Bytes += int64(len(secret.EncryptedData) + len(secret.EncryptedMeta))
+	}
+	return usage, nil
+}
+
+// Create сохраняет защищенный ключ хранилища, если у пользователя его еще нет
+func (r *memoryVaultKeyRepository) Create(ctx context.Context, key *domain.VaultKey) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.vaultKeys[key.UserID]; exists {
+		return domain.ErrVaultKeyExists
+	}
+
+	r.storage.vaultKeys[key.UserID] = key
+	return nil
+}
+
+// Save сохраняет или заменяет защищенный ключ хранилища пользователя
+func (r *memoryVaultKeyRepository) Save(ctx context.Context, key *domain.VaultKey) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if existing, exists := r.storage.vaultKeys[key.UserID]; exists {
+		key.CreatedAt = existing.CreatedAt
+	}
+
+	r.storage.vaultKeys[key.UserID] = key
+	return nil
+}
+
+// GetByUserID получает защищенный ключ хранилища пользователя
+func (r *memoryVaultKeyRepository) GetByUserID(ctx context.Context, userID string) (*domain.VaultKey, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	key, exists := r.storage.vaultKeys[userID]
+	if !exists {
+		return nil, domain.ErrVaultKeyNotFound
+	}
+	return key, nil
+}
+
+// Save сохраняет опись хранилища, если ее версия следует за сохраненной
+func (r *memoryManifestRepository) Save(ctx context.Context, manifest *domain.VaultManifest) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	var current int64
+	if existing, exists := r.storage.manifests[manifest.UserID]; exists {
+		current = existing.Version
+	}
+	if manifest.Version != current+1 {
+		return domain.ErrManifestConflict
+	}
+
+	stored := *manifest
+	r.storage.manifests[manifest.UserID] = &stored
+	return nil
+}
+
+// GetByUserID получает опись хранилища пользователя
+func (r *memoryManifestRepository) GetByUserID(ctx context.Context, userID string) (*domain.VaultManifest, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	manifest, exists := r.storage.manifests[userID]
+	if !exists {
+		return nil, nil
+	}
+	stored := *manifest
+	return &stored, nil
+}
+
+// Save создает или заменяет настройки двухфакторной аутентификации
+func (r *memoryTOTPRepository) Save(ctx context.Context, totp *domain.TOTP) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	r.storage.totp[totp.UserID] = copyTOTP(totp)
+	return nil
+}
+
+// GetByUserID получает настройки двухфакторной аутентификации пользователя
+func (r *memoryTOTPRepository) GetByUserID(ctx context.Context, userID string) (*domain.TOTP, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	totp, exists := r.storage.totp[userID]
+	if !exists {
+		return nil, domain.ErrTOTPNotEnabled
+	}
+	return copyTOTP(totp), nil
+}
+
+// Delete удаляет настройки двухфакторной аутентификации пользователя
+func (r *memoryTOTPRepository) Delete(ctx context.Context, userID string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	delete(r.storage.totp, userID)
+	return nil
+}
+
+// MarkUsed запоминает шаг времени принятого кода
+func (r *memoryTOTPRepository) MarkUsed(ctx context.Context, userID string, step int64) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	totp, exists := r.storage.totp[userID]
+	if !exists || totp.LastUsedStep >= step {
+		return domain.ErrInvalidTOTPCode
+	}
+	totp.LastUsedStep = step
+	return nil
+}
+
+// UseRecoveryCode погашает код восстановления
+func (r *memoryTOTPRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	totp, exists := r.storage.totp[userID]
+	if !exists {
+		return domain.ErrInvalidTOTPCode
+	}
+	index := slices.Index(totp.RecoveryCodes, codeHash)
+	if index < 0 {
+		return domain.ErrInvalidTOTPCode
+	}
+	totp.RecoveryCodes = slices.Delete(totp.RecoveryCodes, index, index+1)
+	return nil
+}
+
+// Get получает неудачные попытки входа по ключу
+func (r *memoryLoginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	attempt, exists := r.storage.loginAttempts[key]
+	if !exists {
+		return nil, domain.ErrLoginAttemptNotFound
+	}
+	stored := *attempt
+	return &stored, nil
+}
+
+// RecordFailure учитывает неудачную попытку входа
+func (r *memoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*domain.LoginAttempt, error) {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	attempt, exists := r.storage.loginAttempts[key]
+	if !exists || attempt.LastFailureAt.Before(now.Add(-window)) {
+		attempt = &domain.LoginAttempt{Key: key}
+		r.storage.loginAttempts[key] = attempt
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+
+	stored := *attempt
+	return &stored, nil
+}
+
+// Release отменяет заранее учтенную попытку входа
+func (r *memoryLoginAttemptRepository) Release(ctx context.Context, key string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if attempt, exists := r.storage.loginAttempts[key]; exists && attempt.Failures > 0 {
+		attempt.Failures--
+	}
+	return nil
+}
+
+// Delete сбрасывает счетчик неудачных попыток
+func (r *memoryLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	delete(r.storage.loginAttempts, key)
+	return nil
+}
+
+// DeleteStale удаляет счетчики с последней неудачей раньше before
+func (r *memoryLoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	var deleted int64
+	for key, attempt := range r.storage.loginAttempts {
+		if attempt.LastFailureAt.Before(before) {
+			delete(r.storage.loginAttempts, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Create сохраняет ключ шифрования данных, если у пользователя его еще нет
+func (r *memoryDataKeyRepository) Create(ctx context.Context, key *domain.DataKey) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.dataKeys[key.UserID]; exists {
+		return domain.ErrDataKeyExists
+	}
+
+	r.storage.dataKeys[key.UserID] = key
+	return nil
+}
+
+// GetByUserID получает ключ шифрования данных пользователя
+func (r *memoryDataKeyRepository) GetByUserID(ctx context.Context, userID string) (*domain.DataKey, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	key, exists := r.storage.dataKeys[userID]
+	if !exists {
+		return nil, domain.ErrDataKeyNotFound
+	}
+	return key, nil
+}
+
+// List возвращает ключи шифрования данных всех пользователей
+func (r *memoryDataKeyRepository) List(ctx context.Context) ([]*domain.DataKey, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	keys := make([]*domain.DataKey, 0, len(r.storage.dataKeys))
+	for _, key := range r.storage.dataKeys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Update заменяет зашифрованный ключ шифрования данных пользователя
+func (r *memoryDataKeyRepository) Update(ctx context.Context, key *domain.DataKey) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.dataKeys[key.UserID]; !exists {
+		return domain.ErrDataKeyNotFound
+	}
+
+	r.storage.dataKeys[key.UserID] = key
+	return nil
+}
+
+// Create сохраняет выданный refresh-токен
+func (r *memoryRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	stored := *token
+	r.storage.refreshTokens[token.ID] = &stored
+	return nil
+}
+
+// GetByHash получает refresh-токен по хешу
+func (r *memoryRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	for _, token := range r.storage.refreshTokens {
+		if token.TokenHash == tokenHash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrRefreshTokenNotFound
+}
+
+// MarkRotated помечает refresh-токен использованным
+func (r *memoryRefreshTokenRepository) MarkRotated(ctx context.Context, id string, rotatedAt time.Time) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	token, exists := r.storage.refreshTokens[id]
+	if !exists {
+		return domain.ErrRefreshTokenNotFound
+	}
+	if token.RotatedAt != nil || token.RevokedAt != nil {
+		return domain.ErrRefreshTokenReused
+	}
+
+	token.RotatedAt = &rotatedAt
+	return nil
+}
+
+// RevokeFamily отзывает все токены семейства
+func (r *memoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	for _, token := range r.storage.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// RevokeByUser отзывает все токены пользователя
+func (r *memoryRefreshTokenRepository) RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	for _, token := range r.storage.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// DeleteExpired удаляет refresh-токены, истекшие раньше before
+func (r *memoryRefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	var deleted int64
+	for id, token := range r.storage.refreshTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(r.storage.refreshTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Create сохраняет новую сессию
+func (r *memorySessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	stored := *session
+	r.storage.sessions[session.ID] = &stored
+	return nil
+}
+
+// GetByID получает сессию по ID
+func (r *memorySessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	session, exists := r.storage.sessions[id]
+	if !exists {
+		return nil, domain.ErrSessionNotFound
+	}
+	found := *session
+	return &found, nil
+}
+
+// ListActiveByUser возвращает активные сессии пользователя, начиная с последней использованной
+func (r *memorySessionRepository) ListActiveByUser(ctx context.Context, userID string, now time.Time) ([]*domain.Session, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	var sessions []*domain.Session
+	for _, session := range r.storage.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// Update обновляет сессию
+func (r *memorySessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.sessions[session.ID]; !exists {
+		return domain.ErrSessionNotFound
+	}
+
+	stored := *session
+	r.storage.sessions[session.ID] = &stored
+	return nil
+}
+
+// Revoke отзывает сессию
+func (r *memorySessionRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	session, exists := r.storage.sessions[id]
+	if !exists {
+		return domain.ErrSessionNotFound
+	}
+	if session.RevokedAt == nil {
+		session.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+// RevokeByUser отзывает все сессии пользователя
+func (r *memorySessionRepository) RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	for _, session := range r.storage.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+// DeleteByUser удаляет все сессии пользователя
+func (r *memorySessionRepository) DeleteByUser(ctx context.Context, userID string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	for id, session := range r.storage.sessions {
+		if session.UserID == userID {
+			delete(r.storage.sessions, id)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired удаляет сессии, истекшие или отозванные раньше before
+func (r *memorySessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	var deleted int64
+	for id, session := range r.storage.sessions {
+		if session.ExpiresAt.Before(before) || (session.RevokedAt != nil && session.RevokedAt.Before(before)) {
+			delete(r.storage.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Create сохраняет новое устройство
+func (r *memoryDeviceRepository) Create(ctx context.Context, device *domain.Device) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	stored := *device
+	r.storage.devices[device.ID] = &stored
+	return nil
+}
+
+// GetByID получает устройство по ID
+func (r *memoryDeviceRepository) GetByID(ctx context.Context, id string) (*domain.Device, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	device, exists := r.storage.devices[id]
+	if !exists {
+		return nil, domain.ErrDeviceNotFound
+	}
+	found := *device
+	return &found, nil
+}
+
+// ListByUser возвращает устройства пользователя, начиная с последнего активного
+func (r *memoryDeviceRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Device, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	var devices []*domain.Device
+	for _, device := range r.storage.devices {
+		if device.UserID == userID {
+			found := *device
+			devices = append(devices, &found)
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].LastSeenAt.After(devices[j].LastSeenAt)
+	})
+	return devices, nil
+}
+
+// Update обновляет устройство
+func (r *memoryDeviceRepository) Update(ctx context.Context, device *domain.Device) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.devices[device.ID]; !exists {
+		return domain.ErrDeviceNotFound
+	}
+
+	stored := *device
+	r.storage.devices[device.ID] = &stored
+	return nil
+}
+
+// Delete удаляет устройство
+func (r *memoryDeviceRepository) Delete(ctx context.Context, id string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.devices[id]; !exists {
+		return domain.ErrDeviceNotFound
+	}
+
+	delete(r.storage.devices, id)
+	return nil
+}
+
+// ClaimFirstApproval отмечает, что у пользователя появилось одобренное устройство
+func (r *memoryDeviceRepository) ClaimFirstApproval(ctx context.Context, userID string, at time.Time) (bool, error) {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, claimed := r.storage.deviceApprovals[userID]; claimed {
+		return false, nil
+	}
+	r.storage.deviceApprovals[userID] = at
+	return true, nil
+}
+
+// Create сохраняет новый токен доступа
+func (r *memoryAccessTokenRepository) Create(ctx context.Context, token *domain.AccessToken) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	r.storage.accessTokens[token.ID] = copyAccessToken(token)
+	return nil
+}
+
+// GetByID получает токен доступа по ID
+func (r *memoryAccessTokenRepository) GetByID(ctx context.Context, id string) (*domain.AccessToken, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	token, exists := r.storage.accessTokens[id]
+	if !exists {
+		return nil, domain.ErrAccessTokenNotFound
+	}
+	return copyAccessToken(token), nil
+}
+
+// ListByUser возвращает токены доступа пользователя, начиная с последнего созданного
+func (r *memoryAccessTokenRepository) ListByUser(ctx context.Context, userID string) ([]*domain.AccessToken, error) {
+	r.storage.mu.RLock()
+	defer r.storage.mu.RUnlock()
+
+	var tokens []*domain.AccessToken
+	for _, token := range r.storage.accessTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, copyAccessToken(token))
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// UpdateLastUsed запоминает время последнего использования токена
+func (r *memoryAccessTokenRepository) UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	token, exists := r.storage.accessTokens[id]
+	if !exists {
+		return domain.ErrAccessTokenNotFound
+	}
+	token.LastUsedAt = &usedAt
+	return nil
+}
+
+// Delete удаляет токен доступа
+func (r *memoryAccessTokenRepository) Delete(ctx context.Context, id string) error {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	if _, exists := r.storage.accessTokens[id]; !exists {
+		return domain.ErrAccessTokenNotFound
+	}
+
+	delete(r.storage.accessTokens, id)
+	return nil
+}
+
+// DeleteExpired удаляет токены доступа, истекшие раньше before
+func (r *memoryAccessTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+
+	var deleted int64
+	for id, token := range r.storage.accessTokens {
+		if token.ExpiresAt != nil && token.ExpiresAt.Before(before) {
+			delete(r.storage.accessTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// copyAccessToken копирует токен вместе со срезами, чтобы вызывающий не менял сохраненное значение
+func copyAccessToken(token *domain.AccessToken) *domain.AccessToken {
+	copied := *token
+	copied.SecretIDs = append([]string(nil), token.SecretIDs...)
+	copied.Tags = append([]string(nil), token.Tags...)
+	copied.DelegatedKey = append([]byte(nil), token.DelegatedKey...)
+	return &copied
+}
+
 func (s *memoryStorage) secretKey(userID, secretID string) string {
 	return userID + "_" + secretID
 }
@@ -306,3 +1151,9 @@ func (s *memoryStorage) extractUserID(key string) string {
 	}
 	return key
 }
+
+func copyTOTP(totp *domain.TOTP) *domain.TOTP {
+	stored := *totp
+	stored.RecoveryCodes = slices.Clone(totp.RecoveryCodes)
+	return &stored
+}
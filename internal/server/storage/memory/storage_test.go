@@ -361,3 +361,326 @@ func TestMemoryStorage_GetUserSecretsVersion(t *testing.T) {
 
 	assert.True(t, versionAfterDelete >= 0)
 }
+
+func TestMemoryStorage_VaultKey(t *testing.T) {
+	storage := memory.NewStorage()
+	ctx := context.Background()
+	userID := uuid.New().String()
+
+	_, err := storage.VaultKeyRepository().GetByUserID(ctx, userID)
+	assert.ErrorIs(t, err, domain.ErrVaultKeyNotFound)
+
+	created := time.Now().Add(-time.Hour)
+	err = storage.VaultKeyRepository().Save(ctx, &domain.VaultKey{
+		UserID:       userID,
+		ProtectedKey: []byte("wrapped-1"),
+		CreatedAt:    created,
+		UpdatedAt:    created,
+	})
+	require.NoError(t, err)
+
+	err = storage.VaultKeyRepository().Save(ctx, &domain.VaultKey{
+		UserID:       userID,
+		ProtectedKey: []byte("wrapped-2"),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+	require.NoError(t, err)
+
+	key, err := storage.VaultKeyRepository().GetByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wrapped-2"), key.ProtectedKey)
+	assert.Equal(t, created, key.CreatedAt)
+}
+
+func TestMemoryStorage_DataKey(t *testing.T) {
+	storage := memory.NewStorage()
+	ctx := context.Background()
+	userID := uuid.New().String()
+
+	_, err := storage.DataKeyRepository().GetByUserID(ctx, userID)
+	assert.ErrorIs(t, err, domain.ErrDataKeyNotFound)
+
+	err = storage.DataKeyRepository().Update(ctx, &domain.DataKey{UserID: userID, WrappedKey: []byte("wrapped-0")})
+	assert.ErrorIs(t, err, domain.ErrDataKeyNotFound)
+
+	err = storage.DataKeyRepository().Create(ctx, &domain.DataKey{UserID: userID, WrappedKey: []byte("wrapped-1")})
+	require.NoError(t, err)
+
+	err = storage.DataKeyRepository().Create(ctx, &domain.DataKey{UserID: userID, WrappedKey: []byte("wrapped-2")})
+	assert.ErrorIs(t, err, domain.ErrDataKeyExists)
+
+	err = storage.DataKeyRepository().Update(ctx, &domain.DataKey{UserID: userID, WrappedKey: []byte("wrapped-3")})
+	require.NoError(t, err)
+
+	key, err := storage.DataKeyRepository().GetByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wrapped-3"), key.WrappedKey)
+
+	keys, err := storage.DataKeyRepository().List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestMemoryStorage_RefreshToken(t *testing.T) {
+	storage := memory.NewStorage()
+	repo := storage.RefreshTokenRepository()
+	ctx := context.Background()
+	userID := uuid.New().String()
+	now := time.Now()
+
+	first := &domain.RefreshToken{ID: "t1", UserID: userID, FamilyID: "f1", TokenHash: "h1", CreatedAt: now}
+	second := &domain.RefreshToken{ID: "t2", UserID: userID, FamilyID: "f1", TokenHash: "h2", CreatedAt: now}
+	other := &domain.RefreshToken{ID: "t3", UserID: userID, FamilyID: "f2", TokenHash: "h3", CreatedAt: now}
+	for _, token := range []*domain.RefreshToken{first, second, other} {
+		require.NoError(t, repo.Create(ctx, token))
+	}
+
+	_, err := repo.GetByHash(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+
+	require.NoError(t, repo.MarkRotated(ctx, "t1", now))
+	assert.ErrorIs(t, repo.MarkRotated(ctx, "t1", now), domain.ErrRefreshTokenReused)
+
+	require.NoError(t, repo.RevokeFamily(ctx, "f1", now))
+	token, err := repo.GetByHash(ctx, "h2")
+	require.NoError(t, err)
+	assert.NotNil(t, token.RevokedAt)
+	assert.ErrorIs(t, repo.MarkRotated(ctx, "t2", now), domain.ErrRefreshTokenReused)
+
+	token, err = repo.GetByHash(ctx, "h3")
+	require.NoError(t, err)
+	assert.Nil(t, token.RevokedAt)
+
+	require.NoError(t, repo.RevokeByUser(ctx, userID, now))
+	token, err = repo.GetByHash(ctx, "h3")
+	require.NoError(t, err)
+	assert.NotNil(t, token.RevokedAt)
+}
+
+func TestMemoryStorage_Session(t *testing.T) {
+	storage := memory.NewStorage()
+	repo := storage.SessionRepository()
+	ctx := context.Background()
+	userID := uuid.New().String()
+	now := time.Now()
+
+	older := &domain.Session{ID: "s1", UserID: userID, DeviceName: "laptop", LastUsedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+	newer := &domain.Session{ID: "s2", UserID: userID, DeviceName: "phone", LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+	expired := &domain.Session{ID: "s3", UserID: userID, LastUsedAt: now, ExpiresAt: now.Add(-time.Minute)}
+	for _, session := range []*domain.Session{older, newer, expired} {
+		require.NoError(t, repo.Create(ctx, session))
+	}
+
+	_, err := repo.GetByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrSessionNotFound)
+
+	sessions, err := repo.ListActiveByUser(ctx, userID, now)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "s2", sessions[0].ID)
+	assert.Equal(t, "s1", sessions[1].ID)
+
+	older.IPAddress = "192.0.2.1"
+	require.NoError(t, repo.Update(ctx, older))
+	session, err := repo.GetByID(ctx, "s1")
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", session.IPAddress)
+
+	require.NoError(t, repo.Revoke(ctx, "s2", now))
+	sessions, err = repo.ListActiveByUser(ctx, userID, now)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "s1", sessions[0].ID)
+
+	require.NoError(t, repo.RevokeByUser(ctx, userID, now))
+	sessions, err = repo.ListActiveByUser(ctx, userID, now)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestMemoryStorage_Device(t *testing.T) {
+	storage := memory.NewStorage()
+	repo := storage.DeviceRepository()
+	ctx := context.Background()
+	userID := uuid.New().String()
+	now := time.Now()
+
+	older := &domain.Device{ID: "d1", UserID: userID, Name: "laptop", SecretHash: "hash-1", LastSeenAt: now.Add(-time.Hour)}
+	newer := &domain.Device{ID: "d2", UserID: userID, Name: "phone", SecretHash: "hash-2", LastSeenAt: now}
+	other := &domain.Device{ID: "d3", UserID: uuid.New().String(), LastSeenAt: now}
+	for _, device := range []*domain.Device{older, newer, other} {
+		require.NoError(t, repo.Create(ctx, device))
+	}
+
+	_, err := repo.GetByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrDeviceNotFound)
+
+	devices, err := repo.ListByUser(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "d2", devices[0].ID)
+	assert.Equal(t, "d1", devices[1].ID)
+
+	older.ApprovedAt = &now
+	require.NoError(t, repo.Update(ctx, older))
+	device, err := repo.GetByID(ctx, "d1")
+	require.NoError(t, err)
+	assert.NotNil(t, device.ApprovedAt)
+	assert.ErrorIs(t, repo.Update(ctx, &domain.Device{ID: "missing"}), domain.ErrDeviceNotFound)
+
+	require.NoError(t, repo.Delete(ctx, "d2"))
+	assert.ErrorIs(t, repo.Delete(ctx, "d2"), domain.ErrDeviceNotFound)
+	devices, err = repo.ListByUser(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+}
+
+func TestMemoryStorage_AccessToken(t *testing.T) {
+	storage := memory.NewStorage()
+	repo := storage.AccessTokenRepository()
+	ctx := context.Background()
+	userID := uuid.New().String()
+	now := time.Now()
+
+	older := &domain.AccessToken{ID: "t1", UserID: userID, SecretIDs: []string{"s1"}, CreatedAt: now.Add(-time.Hour)}
+	newer := &domain.AccessToken{ID: "t2", UserID: userID, SecretIDs: []string{"s2"}, CreatedAt: now}
+	for _, token := range []*domain.AccessToken{older, newer} {
+		require.NoError(t, repo.Create(ctx, token))
+	}
+	// Изменение переданного значения не меняет сохраненный токен
+	older.SecretIDs[0] = "changed"
+
+	token, err := repo.GetByID(ctx, "t1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s1"}, token.SecretIDs)
+	_, err = repo.GetByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrAccessTokenNotFound)
+
+	tokens, err := repo.ListByUser(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "t2", tokens[0].ID)
+
+	require.NoError(t, repo.UpdateLastUsed(ctx, "t1", now))
+	token, err = repo.GetByID(ctx, "t1")
+	require.NoError(t, err)
+	require.NotNil(t, token.LastUsedAt)
+	assert.ErrorIs(t, repo.UpdateLastUsed(ctx, "missing", now), domain.ErrAccessTokenNotFound)
+
+	require.NoError(t, repo.Delete(ctx, "t1"))
+	assert.ErrorIs(t, repo.Delete(ctx, "t1"), domain.ErrAccessTokenNotFound)
+}
+
+func TestMemoryStorage_LoginAttempt(t *testing.T) {
+	storage := memory.NewStorage()
+	ctx := context.Background()
+	repo := storage.LoginAttemptRepository()
+	now := time.Now()
+
+	_, err := repo.Get(ctx, "login:alice")
+	assert.ErrorIs(t, err, domain.ErrLoginAttemptNotFound)
+
+	for i := 1; i <= 3; i++ {
+		attempt, err := repo.RecordFailure(ctx, "login:alice", now, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, i, attempt.Failures)
+	}
+
+	// Неудачи старше окна забываются
+	attempt, err := repo.RecordFailure(ctx, "login:alice", now.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+
+	attempt, err = repo.Get(ctx, "login:alice")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Hour), attempt.LastFailureAt)
+
+	require.NoError(t, repo.Delete(ctx, "login:alice"))
+	_, err = repo.Get(ctx, "login:alice")
+	assert.ErrorIs(t, err, domain.ErrLoginAttemptNotFound)
+
+	_, err = repo.RecordFailure(ctx, "login:alice", now, time.Hour)
+	require.NoError(t, err)
+	_, err = repo.RecordFailure(ctx, "login:bob", now.Add(-2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	removed, err := repo.DeleteStale(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	_, err = repo.Get(ctx, "login:bob")
+	assert.ErrorIs(t, err, domain.ErrLoginAttemptNotFound)
+	_, err = repo.Get(ctx, "login:alice")
+	assert.NoError(t, err)
+}
+
+func TestMemoryStorage_UserList(t *testing.T) {
+	storage := memory.NewStorage()
+	ctx := context.Background()
+	repo := storage.UserRepository()
+
+	for _, login := range []string{"carol", "Alice", "bob", "alina"} {
+		require.NoError(t, repo.Create(ctx, &domain.User{ID: uuid.New().String(), Login: login}))
+	}
+
+	users, err := repo.List(ctx, "", 10, 0)
+	require.NoError(t, err)
+	logins := make([]string, 0, len(users))
+	for _, user := range users {
+		logins = append(logins, user.Login)
+	}
+	assert.Equal(t, []string{"Alice", "alina", "bob", "carol"}, logins)
+
+	users, err = repo.List(ctx, "ALI", 1, 1)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "alina", users[0].Login)
+
+	users, err = repo.List(ctx, "ali", 10, 5)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestMemoryStorage_DeleteUserCascades(t *testing.T) {
+	storage := memory.NewStorage()
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, id := range []string{"user-1", "user-2"} {
+		require.NoError(t, storage.UserRepository().Create(ctx, &domain.User{ID: id, Login: id}))
+		require.NoError(t, storage.SecretRepository().Create(ctx, &domain.Secret{ID: "secret-" + id, UserID: id, Version: 1}))
+		require.NoError(t, storage.VaultKeyRepository().Save(ctx, &domain.VaultKey{UserID: id, ProtectedKey: []byte("key")}))
+		require.NoError(t, storage.SessionRepository().Create(ctx, &domain.Session{ID: "session-" + id, UserID: id, ExpiresAt: now.Add(time.Hour)}))
+		require.NoError(t, storage.DeviceRepository().Create(ctx, &domain.Device{ID: "device-" + id, UserID: id}))
+		require.NoError(t, storage.AccessTokenRepository().Create(ctx, &domain.AccessToken{ID: "token-" + id, UserID: id}))
+	}
+
+	require.NoError(t, storage.SecretRepository().DeleteByUser(ctx, "user-1"))
+	secrets, err := storage.SecretRepository().ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+
+	require.NoError(t, storage.SessionRepository().DeleteByUser(ctx, "user-1"))
+	_, err = storage.SessionRepository().GetByID(ctx, "session-user-1")
+	assert.ErrorIs(t, err, domain.ErrSessionNotFound)
+
+	require.NoError(t, storage.UserRepository().Delete(ctx, "user-1"))
+	_, err = storage.VaultKeyRepository().GetByUserID(ctx, "user-1")
+	assert.ErrorIs(t, err, domain.ErrVaultKeyNotFound)
+	_, err = storage.DeviceRepository().GetByID(ctx, "device-user-1")
+	assert.ErrorIs(t, err, domain.ErrDeviceNotFound)
+	_, err = storage.AccessTokenRepository().GetByID(ctx, "token-user-1")
+	assert.ErrorIs(t, err, domain.ErrAccessTokenNotFound)
+
+	// Данные другого пользователя не затрагиваются
+	secrets, err = storage.SecretRepository().ListByUser(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Len(t, secrets, 1)
+	_, err = storage.SessionRepository().GetByID(ctx, "session-user-2")
+	require.NoError(t, err)
+	_, err = storage.VaultKeyRepository().GetByUserID(ctx, "user-2")
+	require.NoError(t, err)
+	_, err = storage.DeviceRepository().GetByID(ctx, "device-user-2")
+	require.NoError(t, err)
+	_, err = storage.AccessTokenRepository().GetByID(ctx, "token-user-2")
+	require.NoError(t, err)
+}
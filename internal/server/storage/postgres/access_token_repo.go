@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+const (
+	accessTokenCreateQuery = `
+		INSERT INTO access_tokens (id, user_id, name, secret_hash, secret_ids, tags, permission, delegated_key, created_at, expires_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	accessTokenGetQuery = `
+		SELECT id, user_id, name, secret_hash, secret_ids, tags, permission, delegated_key, created_at, expires_at, last_used_at
+		FROM access_tokens
+		WHERE id = $1
+	`
+	accessTokenListByUserQuery = `
+		SELECT id, user_id, name, secret_hash, secret_ids, tags, permission, delegated_key, created_at, expires_at, last_used_at
+		FROM access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	accessTokenUpdateLastUsedQuery = `UPDATE access_tokens SET last_used_at = $1 WHERE id = $2`
+	accessTokenDeleteQuery         = `DELETE FROM access_tokens WHERE id = $1`
+	accessTokenDeleteExpiredQuery  = `DELETE FROM access_tokens WHERE expires_at < $1`
+)
+
+// accessTokenRepository реализует AccessTokenRepository для PostgreSQL
+type accessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewAccessTokenRepository создает новый экземпляр AccessTokenRepository для PostgreSQL
+func NewAccessTokenRepository(db *pgxpool.Pool) interfaces.AccessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+// Create сохраняет новый токен доступа
+func (r *accessTokenRepository) Create(ctx context.Context, token *domain.AccessToken) error {
+	_, err := r.db.Exec(ctx, accessTokenCreateQuery, accessTokenArgs(token)...)
+	if err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID получает токен доступа по ID
+func (r *accessTokenRepository) GetByID(ctx context.Context, id string) (*domain.AccessToken, error) {
+	token, err := scanAccessToken(r.db.QueryRow(ctx, accessTokenGetQuery, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrAccessTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ListByUser возвращает токены доступа пользователя, начиная с последнего созданного
+func (r *accessTokenRepository) ListByUser(ctx context.Context, userID string) ([]*domain.AccessToken, error) {
+	rows, err := r.db.Query(ctx, accessTokenListByUserQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*domain.AccessToken
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// UpdateLastUsed запоминает время последнего использования токена
+func (r *accessTokenRepository) UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	result, err := r.db.Exec(ctx, accessTokenUpdateLastUsedQuery, usedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update access token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// Delete удаляет токен доступа
+func (r *accessTokenRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.Exec(ctx, accessTokenDeleteQuery, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete access token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired удаляет токены доступа, истекшие раньше before
+func (r *accessTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, accessTokenDeleteExpiredQuery, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired access tokens: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// accessTokenArgs параметры запроса accessTokenCreateQuery
+func accessTokenArgs(token *domain.AccessToken) []any {
+	tags := token.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return []any{
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.SecretHash,
+		token.SecretIDs,
+		tags,
+		string(token.Permission),
+		token.DelegatedKey,
+		token.CreatedAt,
+		token.ExpiresAt,
+		token.LastUsedAt,
+	}
+}
+
+// scanAccessToken читает токен доступа из строки результата
+func scanAccessToken(row pgx.Row) (*domain.AccessToken, error) {
+	var token domain.AccessToken
+	var permission string
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.SecretHash,
+		&token.SecretIDs,
+		&token.Tags,
+		&permission,
+		&token.DelegatedKey,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.Permission = domain.AccessTokenPermission(permission)
+	return &token, nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// dataKeyRepository реализует DataKeyRepository для PostgreSQL
+type dataKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewDataKeyRepository создает новый экземпляр DataKeyRepository для PostgreSQL
+func NewDataKeyRepository(db *pgxpool.Pool) interfaces.DataKeyRepository {
+	return &dataKeyRepository{db: db}
+}
+
+// Create сохраняет ключ шифрования данных, если у пользователя его еще нет
+func (r *dataKeyRepository) Create(ctx context.Context, key *domain.DataKey) error {
+	query := `
+		INSERT INTO data_keys (user_id, wrapped_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		key.UserID,
+		key.WrappedKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create data key: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDataKeyExists
+	}
+
+	return nil
+}
+
+// GetByUserID возвращает ключ шифрования данных пользователя
+func (r *dataKeyRepository) GetByUserID(ctx context.Context, userID string) (*domain.DataKey, error) {
+	query := `
+		SELECT user_id, wrapped_key, created_at, updated_at
+		FROM data_keys
+		WHERE user_id = $1
+	`
+
+	var key domain.DataKey
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&key.UserID,
+		&key.WrappedKey,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrDataKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// List возвращает ключи шифрования данных всех пользователей
+func (r *dataKeyRepository) List(ctx context.Context) ([]*domain.DataKey, error) {
+	query := `
+		SELECT user_id, wrapped_key, created_at, updated_at
+		FROM data_keys
+		ORDER BY user_id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*domain.DataKey
+	for rows.Next() {
+		var key domain.DataKey
+		if err := rows.Scan(&key.UserID, &key.WrappedKey, &key.CreatedAt, &key.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan data key: %w", err)
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Update заменяет зашифрованный ключ шифрования данных пользователя
+func (r *dataKeyRepository) Update(ctx context.Context, key *domain.DataKey) error {
+	query := `
+		UPDATE data_keys
+		SET wrapped_key = $1, updated_at = $2
+		WHERE user_id = $3
+	`
+
+	result, err := r.db.Exec(ctx, query, key.WrappedKey, key.UpdatedAt, key.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update data key: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDataKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+const (
+	deviceCreateQuery = `
+		INSERT INTO devices (id, user_id, name, client_version, secret_hash, approved_at, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	deviceGetQuery = `
+		SELECT id, user_id, name, client_version, secret_hash, approved_at, created_at, last_seen_at
+		FROM devices
+		WHERE id = $1
+	`
+	deviceListByUserQuery = `
+		SELECT id, user_id, name, client_version, secret_hash, approved_at, created_at, last_seen_at
+		FROM devices
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`
+	deviceUpdateQuery = `
+		UPDATE devices
+		SET name = $1, client_version = $2, approved_at = $3, last_seen_at = $4
+		WHERE id = $5
+	`
+	deviceDeleteQuery = `DELETE FROM devices WHERE id = $1`
+	// deviceClaimFirstApprovalQuery вставляет отметку только один раз: конфликт по user_id
+	// означает, что одобренное устройство у пользователя уже было
+	deviceClaimFirstApprovalQuery = `
+		INSERT INTO device_first_approvals (user_id, approved_at)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+)
+
+// deviceRepository реализует DeviceRepository для PostgreSQL
+type deviceRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewDeviceRepository создает новый экземпляр DeviceRepository для PostgreSQL
+func NewDeviceRepository(db *pgxpool.Pool) interfaces.DeviceRepository {
+	return &deviceRepository{db: db}
+}
+
+// Create сохраняет новое устройство
+func (r *deviceRepository) Create(ctx context.Context, device *domain.Device) error {
+	_, err := r.db.Exec(ctx, deviceCreateQuery,
+		device.ID,
+		device.UserID,
+		device.Name,
+		device.ClientVersion,
+		device.SecretHash,
+		device.ApprovedAt,
+		device.CreatedAt,
+		device.LastSeenAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create device: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID получает устройство по ID
+func (r *deviceRepository) GetByID(ctx context.Context, id string) (*domain.Device, error) {
+	device, err := scanDevice(r.db.QueryRow(ctx, deviceGetQuery, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrDeviceNotFound
+		}
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	return device, nil
+}
+
+// ListByUser возвращает устройства пользователя, начиная с последнего активного
+func (r *deviceRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Device, error) {
+	rows, err := r.db.Query(ctx, deviceListByUserQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*domain.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, rows.Err()
+}
+
+// Update обновляет устройство
+func (r *deviceRepository) Update(ctx context.Context, device *domain.Device) error {
+	result, err := r.db.Exec(ctx, deviceUpdateQuery,
+		device.Name,
+		device.ClientVersion,
+		device.ApprovedAt,
+		device.LastSeenAt,
+		device.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update device: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// Delete удаляет устройство
+func (r *deviceRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.Exec(ctx, deviceDeleteQuery, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// scanDevice читает устройство из строки результата
+func scanDevice(row pgx.Row) (*domain.Device, error) {
+	var device domain.Device
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.Name,
+		&device.ClientVersion,
+		&device.SecretHash,
+		&device.ApprovedAt,
+		&device.CreatedAt,
+		&device.LastSeenAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// ClaimFirstApproval отмечает, что у пользователя появилось одобренное устройство
+func (r *deviceRepository) ClaimFirstApproval(ctx context.Context, userID string, at time.Time) (bool, error) {
+	result, err := r.db.Exec(ctx, deviceClaimFirstApprovalQuery, userID, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim first device approval: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+const (
+	loginAttemptGetQuery = `
+		SELECT attempt_key, failures, last_failure_at
+		FROM login_attempts
+		WHERE attempt_key = $1
+	`
+	// Счетчик начинается заново, если последняя неудача была раньше $3
+	loginAttemptRecordFailureQuery = `
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failure_at < $3 THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING attempt_key, failures, last_failure_at
+	`
+	loginAttemptReleaseQuery = `
+		UPDATE login_attempts
+		SET failures = failures - 1
+		WHERE attempt_key = $1 AND failures > 0
+	`
+	loginAttemptDeleteQuery      = `DELETE FROM login_attempts WHERE attempt_key = $1`
+	loginAttemptDeleteStaleQuery = `DELETE FROM login_attempts WHERE last_failure_at < $1`
+)
+
+// loginAttemptRepository реализует LoginAttemptRepository для PostgreSQL
+type loginAttemptRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewLoginAttemptRepository создает новый экземпляр LoginAttemptRepository для PostgreSQL
+func NewLoginAttemptRepository(db *pgxpool.Pool) interfaces.LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+// Get получает неудачные попытки входа по ключу
+func (r *loginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := r.db.QueryRow(ctx, loginAttemptGetQuery, key).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrLoginAttemptNotFound
+		}
+		return nil, fmt.Errorf("failed to get login attempt: %w", err)
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure учитывает неудачную попытку входа
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := r.db.QueryRow(ctx, loginAttemptRecordFailureQuery, key, now, now.Add(-window)).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return &attempt, nil
+}
+
+// Release отменяет заранее учтенную попытку входа
+func (r *loginAttemptRepository) Release(ctx context.Context, key string) error {
+	if _, err := r.db.Exec(ctx, loginAttemptReleaseQuery, key); err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// Delete сбрасывает счетчик неудачных попыток
+func (r *loginAttemptRepository) Delete(ctx context.Context, key string) error {
+	if _, err := r.db.Exec(ctx, loginAttemptDeleteQuery, key); err != nil {
+		return fmt.Errorf("failed to delete login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStale удаляет счетчики с последней неудачей раньше before
+func (r *loginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, loginAttemptDeleteStaleQuery, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login attempts: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// manifestSaveQuery возвращает запрос сохранения описи: первая опись вставляется,
+// следующие заменяют опись предыдущей версии. При несовпадении версий ни одна строка не изменяется.
+func manifestSaveQuery(version int64) string {
+	if version == 1 {
+		return `
+			INSERT INTO vault_manifests (user_id, manifest, version, updated_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO NOTHING
+		`
+	}
+	return `
+		UPDATE vault_manifests
+		SET manifest = $2, version = $3, updated_at = $4
+		WHERE user_id = $1 AND version = $3 - 1
+	`
+}
+
+// manifestRepository реализует ManifestRepository для PostgreSQL
+type manifestRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewManifestRepository создает новый экземпляр ManifestRepository для PostgreSQL
+func NewManifestRepository(db *pgxpool.Pool) interfaces.ManifestRepository {
+	return &manifestRepository{db: db}
+}
+
+// Save сохраняет опись хранилища, если ее версия следует за сохраненной
+func (r *manifestRepository) Save(ctx context.Context, manifest *domain.VaultManifest) error {
+	result, err := r.db.Exec(ctx, manifestSaveQuery(manifest.Version),
+		manifest.UserID,
+		manifest.Data,
+		manifest.Version,
+		manifest.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save vault manifest: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrManifestConflict
+	}
+
+	return nil
+}
+
+// GetByUserID возвращает опись хранилища пользователя
+func (r *manifestRepository) GetByUserID(ctx context.Context, userID string) (*domain.VaultManifest, error) {
+	query := `
+		SELECT user_id, manifest, version, updated_at
+		FROM vault_manifests
+		WHERE user_id = $1
+	`
+
+	var manifest domain.VaultManifest
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&manifest.UserID,
+		&manifest.Data,
+		&manifest.Version,
+		&manifest.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get vault manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
@@ -0,0 +1,6 @@
+-- Параметры формирования ключа хранилища из мастер-пароля
+ALTER TABLE users
+    ADD COLUMN kdf_salt BYTEA,
+    ADD COLUMN kdf_iterations INTEGER NOT NULL DEFAULT 3,
+    ADD COLUMN kdf_memory INTEGER NOT NULL DEFAULT 65536,
+    ADD COLUMN kdf_parallelism SMALLINT NOT NULL DEFAULT 4;
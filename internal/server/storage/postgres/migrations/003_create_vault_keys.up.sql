@@ -0,0 +1,7 @@
+-- Ключи хранилища пользователей, зашифрованные на клиенте ключом из мастер-пароля
+CREATE TABLE vault_keys (
+                            user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                            protected_key BYTEA NOT NULL,
+                            created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                            updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Момент последней смены пароля: токены, выданные раньше, считаются отозванными
+ALTER TABLE users
+    ADD COLUMN password_changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT 'epoch';
@@ -0,0 +1,2 @@
+ALTER TABLE secrets
+    DROP COLUMN IF EXISTS key_id;
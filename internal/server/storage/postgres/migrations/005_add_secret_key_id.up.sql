@@ -0,0 +1,3 @@
+-- Идентификатор ключа хранилища, которым зашифрован секрет
+ALTER TABLE secrets
+    ADD COLUMN key_id VARCHAR(64) NOT NULL DEFAULT '';
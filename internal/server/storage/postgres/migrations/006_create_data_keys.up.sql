@@ -0,0 +1,7 @@
+-- Ключи шифрования данных пользователей на сервере, зашифрованные мастер-ключом сервера
+CREATE TABLE data_keys (
+                           user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                           wrapped_key BYTEA NOT NULL,
+                           created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                           updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
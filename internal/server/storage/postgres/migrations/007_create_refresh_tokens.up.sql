@@ -0,0 +1,14 @@
+-- Выданные refresh-токены (хранится только SHA-256 токена)
+CREATE TABLE refresh_tokens (
+                                id VARCHAR(36) PRIMARY KEY,
+                                user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                family_id VARCHAR(36) NOT NULL,
+                                token_hash VARCHAR(64) UNIQUE NOT NULL,
+                                expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                                rotated_at TIMESTAMP WITH TIME ZONE,
+                                revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
@@ -0,0 +1,14 @@
+-- Сессии входа пользователей; ID сессии совпадает с семейством ее refresh-токенов
+CREATE TABLE sessions (
+                          id VARCHAR(36) PRIMARY KEY,
+                          user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                          device_name VARCHAR(255) NOT NULL DEFAULT '',
+                          client_version VARCHAR(64) NOT NULL DEFAULT '',
+                          ip_address VARCHAR(64) NOT NULL DEFAULT '',
+                          created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                          last_used_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                          expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                          revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS srp_verifier,
+    DROP COLUMN IF EXISTS srp_salt;
@@ -0,0 +1,5 @@
+-- Верификатор SRP: сервер проверяет пароль, не получая его.
+-- У пользователей, перешедших на SRP, password_hash пустой.
+ALTER TABLE users
+    ADD COLUMN srp_salt BYTEA,
+    ADD COLUMN srp_verifier BYTEA;
@@ -0,0 +1,7 @@
+-- Описи хранилищ пользователей, подписанные на клиенте ключом хранилища
+CREATE TABLE vault_manifests (
+                                 user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                                 manifest BYTEA NOT NULL,
+                                 version BIGINT NOT NULL,
+                                 updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
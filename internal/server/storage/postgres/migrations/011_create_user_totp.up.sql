@@ -0,0 +1,11 @@
+-- Двухфакторная аутентификация: секрет TOTP (зашифрован мастер-ключом сервера, если он задан)
+-- и хеши неиспользованных кодов восстановления. Пока confirmed_at пуст, вход код не требует.
+CREATE TABLE user_totp (
+                           user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                           secret BYTEA NOT NULL,
+                           recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+                           last_used_step BIGINT NOT NULL DEFAULT 0,
+                           confirmed_at TIMESTAMP WITH TIME ZONE,
+                           created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                           updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
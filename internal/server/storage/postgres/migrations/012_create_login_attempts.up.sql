@@ -0,0 +1,6 @@
+-- Неудачные попытки входа по логину ("login:<логин>") и по адресу клиента ("ip:<адрес>")
+CREATE TABLE login_attempts (
+                                attempt_key TEXT PRIMARY KEY,
+                                failures INTEGER NOT NULL,
+                                last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
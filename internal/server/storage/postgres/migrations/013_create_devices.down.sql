@@ -0,0 +1,4 @@
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS device_id;
+
+DROP TABLE IF EXISTS devices;
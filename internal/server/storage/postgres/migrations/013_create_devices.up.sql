@@ -0,0 +1,18 @@
+-- Устройства пользователей: сервер выдает установке клиента ID и секрет (хранится только его хеш).
+-- Пока approved_at пуст, устройство ждет одобрения с уже доверенного устройства.
+CREATE TABLE devices (
+                         id VARCHAR(36) PRIMARY KEY,
+                         user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                         name VARCHAR(255) NOT NULL DEFAULT '',
+                         client_version VARCHAR(64) NOT NULL DEFAULT '',
+                         secret_hash VARCHAR(64) NOT NULL,
+                         approved_at TIMESTAMP WITH TIME ZONE,
+                         created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                         last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_devices_user_id ON devices(user_id);
+
+-- Устройство, с которого выполнен вход; пусто для сессий без зарегистрированного устройства
+ALTER TABLE sessions
+    ADD COLUMN device_id VARCHAR(36) NOT NULL DEFAULT '';
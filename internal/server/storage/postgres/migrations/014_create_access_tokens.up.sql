@@ -0,0 +1,17 @@
+-- Токены доступа для автоматизации: хранится только хеш секрета токена.
+-- delegated_key — ключ хранилища, зашифрованный частью токена, которую сервер не получает.
+CREATE TABLE access_tokens (
+                               id VARCHAR(36) PRIMARY KEY,
+                               user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                               name VARCHAR(255) NOT NULL,
+                               secret_hash VARCHAR(64) NOT NULL,
+                               secret_ids TEXT[] NOT NULL,
+                               tags TEXT[] NOT NULL DEFAULT '{}',
+                               permission VARCHAR(20) NOT NULL,
+                               delegated_key BYTEA NOT NULL,
+                               created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+                               expires_at TIMESTAMP WITH TIME ZONE,
+                               last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_access_tokens_user_id ON access_tokens(user_id);
@@ -0,0 +1,4 @@
+-- Роль пользователя и блокировка учетной записи администратором
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
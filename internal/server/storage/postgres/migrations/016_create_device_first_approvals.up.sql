@@ -0,0 +1,12 @@
+-- Отметка о том, что у пользователя уже было одобренное устройство. Она не снимается при удалении
+-- устройств, поэтому автоматически одобряется только самое первое устройство пользователя.
+CREATE TABLE device_first_approvals (
+                                        user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                                        approved_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+INSERT INTO device_first_approvals (user_id, approved_at)
+SELECT user_id, MIN(approved_at)
+FROM devices
+WHERE approved_at IS NOT NULL
+GROUP BY user_id;
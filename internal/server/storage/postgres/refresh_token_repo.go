@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// refreshTokenRepository реализует RefreshTokenRepository для PostgreSQL
+type refreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRefreshTokenRepository создает новый экземпляр RefreshTokenRepository для PostgreSQL
+func NewRefreshTokenRepository(db *pgxpool.Pool) interfaces.RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+// Create сохраняет выданный refresh-токен
+func (r *refreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+		token.RotatedAt,
+		token.RevokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetByHash получает refresh-токен по хешу
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token domain.RefreshToken
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.RotatedAt,
+		&token.RevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// MarkRotated помечает refresh-токен использованным. Условие в запросе гарантирует,
+// что из двух одновременных обновлений одним токеном успешным будет только одно.
+func (r *refreshTokenRepository) MarkRotated(ctx context.Context, id string, rotatedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET rotated_at = $1
+		WHERE id = $2 AND rotated_at IS NULL AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, rotatedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token rotated: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+// RevokeFamily отзывает все токены семейства
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE family_id = $2 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.Exec(ctx, query, revokedAt, familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeByUser отзывает все токены пользователя
+func (r *refreshTokenRepository) RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.Exec(ctx, query, revokedAt, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// refreshTokenDeleteExpiredQuery удаляет refresh-токены, истекшие раньше $1: предъявить их уже нельзя,
+// поэтому для обнаружения повторного использования они не нужны
+const refreshTokenDeleteExpiredQuery = `DELETE FROM refresh_tokens WHERE expires_at < $1`
+
+// DeleteExpired удаляет refresh-токены, истекшие раньше before
+func (r *refreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, refreshTokenDeleteExpiredQuery, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -25,8 +25,8 @@ func NewSecretRepository(db *pgxpool.Pool) interfaces.SecretRepository {
 // Create создает новый секрет
 func (r *secretRepository) Create(ctx context.Context, secret *domain.Secret) error {
 	query := `
-		INSERT INTO secrets (id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO secrets (id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
 	_, err := r.db.Exec(ctx, query,
@@ -36,6 +36,7 @@ func (r *secretRepository) Create(ctx context.Context, secret *domain.Secret) er
 		secret.Name,
 		secret.EncryptedData,
 		secret.EncryptedMeta,
+		secret.KeyID,
 		secret.Version,
 		secret.CreatedAt,
 		secret.UpdatedAt,
@@ -52,7 +53,7 @@ func (r *secretRepository) Create(ctx context.Context, secret *domain.Secret) er
 // GetByID возвращает секрет по ID и UserID
 func (r *secretRepository) GetByID(ctx context.Context, id, userID string) (*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE id = $1 AND user_id = $2 AND NOT is_deleted
 	`
@@ -67,6 +68,7 @@ func (r *secretRepository) GetByID(ctx context.Context, id, userID string) (*dom
 		&secret.Name,
 		&secret.EncryptedData,
 		&secret.EncryptedMeta,
+		&secret.KeyID,
 		&secret.Version,
 		&secret.CreatedAt,
 		&secret.UpdatedAt,
@@ -87,7 +89,7 @@ func (r *secretRepository) GetByID(ctx context.Context, id, userID string) (*dom
 // ListByUser возвращает все секреты пользователя
 func (r *secretRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE user_id = $1 AND NOT is_deleted
 		ORDER BY created_at DESC
@@ -111,6 +113,7 @@ func (r *secretRepository) ListByUser(ctx context.Context, userID string) ([]*do
 			&secret.Name,
 			&secret.EncryptedData,
 			&secret.EncryptedMeta,
+			&secret.KeyID,
 			&secret.Version,
 			&secret.CreatedAt,
 			&secret.UpdatedAt,
@@ -134,7 +137,7 @@ func (r *secretRepository) ListByUser(ctx context.Context, userID string) ([]*do
 // ListByUserAndType возвращает секреты пользователя определенного типа
 func (r *secretRepository) ListByUserAndType(ctx context.Context, userID string, secretType domain.SecretType) ([]*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE user_id = $1 AND type = $2 AND NOT is_deleted
 		ORDER BY created_at DESC
@@ -158,6 +161,7 @@ func (r *secretRepository) ListByUserAndType(ctx context.Context, userID string,
 			&secret.Name,
 			&secret.EncryptedData,
 			&secret.EncryptedMeta,
+			&secret.KeyID,
 			&secret.Version,
 			&secret.CreatedAt,
 			&secret.UpdatedAt,
@@ -182,9 +186,9 @@ func (r *secretRepository) ListByUserAndType(ctx context.Context, userID string,
 func (r *secretRepository) Update(ctx context.Context, secret *domain.Secret) error {
 	query := `
 		UPDATE secrets 
-		SET type = $1, name = $2, encrypted_data = $3, encrypted_meta = $4, 
-		    version = version + 1, updated_at = $5, is_deleted = $6
-		WHERE id = $7 AND user_id = $8 AND version = $9
+		SET type = $1, name = $2, encrypted_data = $3, encrypted_meta = $4, key_id = $5,
+		    version = version + 1, updated_at = $6, is_deleted = $7
+		WHERE id = $8 AND user_id = $9 AND version = $10
 	`
 
 	result, err := r.db.Exec(ctx, query,
@@ -192,6 +196,7 @@ func (r *secretRepository) Update(ctx context.Context, secret *domain.Secret) er
 		secret.Name,
 		secret.EncryptedData,
 		secret.EncryptedMeta,
+		secret.KeyID,
 		time.Now(),
 		secret.IsDeleted,
 		secret.ID,
@@ -233,6 +238,17 @@ func (r *secretRepository) Delete(ctx context.Context, id, userID string) error
 	return nil
 }
 
+// DeleteByUser удаляет все секреты пользователя и версию его секретов
+func (r *secretRepository) DeleteByUser(ctx context.Context, userID string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM secrets WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user secrets: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, `DELETE FROM user_secrets_version WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user secrets version: %w", err)
+	}
+	return nil
+}
+
 // SoftDelete помечает секрет как удаленный
 func (r *secretRepository) SoftDelete(ctx context.Context, id, userID string) error {
 	query := `
@@ -276,7 +292,7 @@ func (r *secretRepository) GetUserSecretsVersion(ctx context.Context, userID str
 // GetChangedSecrets возвращает секреты, измененные после указанной версии
 func (r *secretRepository) GetChangedSecrets(ctx context.Context, userID string, lastSyncVersion int64) ([]*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE user_id = $1 AND version > $2
 		ORDER BY version ASC
@@ -300,6 +316,7 @@ func (r *secretRepository) GetChangedSecrets(ctx context.Context, userID string,
 			&secret.Name,
 			&secret.EncryptedData,
 			&secret.EncryptedMeta,
+			&secret.KeyID,
 			&secret.Version,
 			&secret.CreatedAt,
 			&secret.UpdatedAt,
@@ -319,3 +336,23 @@ func (r *secretRepository) GetChangedSecrets(ctx context.Context, userID string,
 
 	return secrets, nil
 }
+
+// secretUsageQuery считает секреты пользователя и их объем, не читая содержимое
+const secretUsageQuery = `
+	SELECT COUNT(*) FILTER (WHERE NOT is_deleted),
+	       COUNT(*) FILTER (WHERE is_deleted),
+	       COALESCE(SUM(octet_length(encrypted_data) + COALESCE(octet_length(encrypted_meta), 0)), 0)
+	FROM secrets
+	WHERE user_id = $1
+`
+
+// GetUsage возвращает число и объем секретов пользователя
+func (r *secretRepository) GetUsage(ctx context.Context, userID string) (*domain.SecretUsage, error) {
+	var usage domain.SecretUsage
+	err := r.db.QueryRow(ctx, secretUsageQuery, userID).Scan(&usage.Secrets, &usage.DeletedSecrets, &usage.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret usage: %w", err)
+	}
+
+	return &usage, nil
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// sessionRepository реализует SessionRepository для PostgreSQL
+type sessionRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewSessionRepository создает новый экземпляр SessionRepository для PostgreSQL
+func NewSessionRepository(db *pgxpool.Pool) interfaces.SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create сохраняет новую сессию
+func (r *sessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, device_id, device_name, client_version, ip_address, created_at, last_used_at, expires_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		session.ID,
+		session.UserID,
+		session.DeviceID,
+		session.DeviceName,
+		session.ClientVersion,
+		session.IPAddress,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.ExpiresAt,
+		session.RevokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID получает сессию по ID
+func (r *sessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_id, device_name, client_version, ip_address, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	var session domain.Session
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceID,
+		&session.DeviceName,
+		&session.ClientVersion,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// ListActiveByUser возвращает активные сессии пользователя, начиная с последней использованной
+func (r *sessionRepository) ListActiveByUser(ctx context.Context, userID string, now time.Time) ([]*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_id, device_name, client_version, ip_address, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		var session domain.Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.DeviceID,
+			&session.DeviceName,
+			&session.ClientVersion,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+			&session.RevokedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Update обновляет сессию
+func (r *sessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	query := `
+		UPDATE sessions
+		SET device_id = $1, device_name = $2, client_version = $3, ip_address = $4, last_used_at = $5, expires_at = $6
+		WHERE id = $7
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		session.DeviceID,
+		session.DeviceName,
+		session.ClientVersion,
+		session.IPAddress,
+		session.LastUsedAt,
+		session.ExpiresAt,
+		session.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// Revoke отзывает сессию
+func (r *sessionRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, revokedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeByUser отзывает все сессии пользователя
+func (r *sessionRepository) RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	if _, err := r.db.Exec(ctx, query, revokedAt, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteByUser удаляет все сессии пользователя
+func (r *sessionRepository) DeleteByUser(ctx context.Context, userID string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	return nil
+}
+
+// sessionDeleteExpiredQuery удаляет сессии, истекшие или отозванные раньше $1
+const sessionDeleteExpiredQuery = `DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1`
+
+// DeleteExpired удаляет сессии, истекшие или отозванные раньше before
+func (r *sessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, sessionDeleteExpiredQuery, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
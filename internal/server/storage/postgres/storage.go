@@ -11,17 +11,35 @@ import (
 
 // postgresStorage реализует Storage для PostgreSQL
 type postgresStorage struct {
-	db      *pgxpool.Pool
-	users   interfaces.UserRepository
-	secrets interfaces.SecretRepository
+	db            *pgxpool.Pool
+	users         interfaces.UserRepository
+	secrets       interfaces.SecretRepository
+	vaultKeys     interfaces.VaultKeyRepository
+	manifests     interfaces.ManifestRepository
+	totp          interfaces.TOTPRepository
+	loginAttempts interfaces.LoginAttemptRepository
+	dataKeys      interfaces.DataKeyRepository
+	refreshTokens interfaces.RefreshTokenRepository
+	sessions      interfaces.SessionRepository
+	devices       interfaces.DeviceRepository
+	accessTokens  interfaces.AccessTokenRepository
 }
 
 // NewStorage создает новый экземпляр Storage для PostgreSQL
 func NewStorage(db *pgxpool.Pool) interfaces.Storage {
 	return &postgresStorage{
-		db:      db,
-		users:   NewUserRepository(db),
-		secrets: NewSecretRepository(db),
+		db:            db,
+		users:         NewUserRepository(db),
+		secrets:       NewSecretRepository(db),
+		vaultKeys:     NewVaultKeyRepository(db),
+		manifests:     NewManifestRepository(db),
+		totp:          NewTOTPRepository(db),
+		loginAttempts: NewLoginAttemptRepository(db),
+		dataKeys:      NewDataKeyRepository(db),
+		refreshTokens: NewRefreshTokenRepository(db),
+		sessions:      NewSessionRepository(db),
+		devices:       NewDeviceRepository(db),
+		accessTokens:  NewAccessTokenRepository(db),
 	}
 }
 
@@ -35,6 +53,51 @@ func (s *postgresStorage) SecretRepository() interfaces.SecretRepository {
 	return s.secrets
 }
 
+// VaultKeyRepository возвращает репозиторий защищенных ключей хранилища
+func (s *postgresStorage) VaultKeyRepository() interfaces.VaultKeyRepository {
+	return s.vaultKeys
+}
+
+// ManifestRepository возвращает репозиторий описей хранилищ
+func (s *postgresStorage) ManifestRepository() interfaces.ManifestRepository {
+	return s.manifests
+}
+
+// TOTPRepository возвращает репозиторий настроек двухфакторной аутентификации
+func (s *postgresStorage) TOTPRepository() interfaces.TOTPRepository {
+	return s.totp
+}
+
+// LoginAttemptRepository возвращает репозиторий неудачных попыток входа
+func (s *postgresStorage) LoginAttemptRepository() interfaces.LoginAttemptRepository {
+	return s.loginAttempts
+}
+
+// DataKeyRepository возвращает репозиторий ключей шифрования данных
+func (s *postgresStorage) DataKeyRepository() interfaces.DataKeyRepository {
+	return s.dataKeys
+}
+
+// RefreshTokenRepository возвращает репозиторий refresh-токенов
+func (s *postgresStorage) RefreshTokenRepository() interfaces.RefreshTokenRepository {
+	return s.refreshTokens
+}
+
+// SessionRepository возвращает репозиторий сессий
+func (s *postgresStorage) SessionRepository() interfaces.SessionRepository {
+	return s.sessions
+}
+
+// DeviceRepository возвращает репозиторий устройств
+func (s *postgresStorage) DeviceRepository() interfaces.DeviceRepository {
+	return s.devices
+}
+
+// AccessTokenRepository возвращает репозиторий токенов доступа
+func (s *postgresStorage) AccessTokenRepository() interfaces.AccessTokenRepository {
+	return s.accessTokens
+}
+
 // TransactionManager возвращает менеджер транзакций
 func (s *postgresStorage) TransactionManager() interfaces.TransactionManager {
 	return s
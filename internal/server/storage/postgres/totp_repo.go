@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+const (
+	totpSaveQuery = `
+		INSERT INTO user_totp (user_id, secret, recovery_codes, last_used_step, confirmed_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret,
+			recovery_codes = EXCLUDED.recovery_codes,
+			last_used_step = EXCLUDED.last_used_step,
+			confirmed_at = EXCLUDED.confirmed_at,
+			updated_at = EXCLUDED.updated_at
+	`
+	totpGetQuery = `
+		SELECT user_id, secret, recovery_codes, last_used_step, confirmed_at, created_at, updated_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+	totpDeleteQuery = `DELETE FROM user_totp WHERE user_id = $1`
+	// Шаг записывается, только если он новее последнего принятого: один код нельзя предъявить дважды
+	totpMarkUsedQuery = `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+	totpUseRecoveryCodeQuery = `
+		UPDATE user_totp
+		SET recovery_codes = array_remove(recovery_codes, $2)
+		WHERE user_id = $1 AND $2 = ANY(recovery_codes)
+	`
+)
+
+// totpRepository реализует TOTPRepository для PostgreSQL
+type totpRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewTOTPRepository создает новый экземпляр TOTPRepository для PostgreSQL
+func NewTOTPRepository(db *pgxpool.Pool) interfaces.TOTPRepository {
+	return &totpRepository{db: db}
+}
+
+// Save создает или заменяет настройки двухфакторной аутентификации
+func (r *totpRepository) Save(ctx context.Context, totp *domain.TOTP) error {
+	_, err := r.db.Exec(ctx, totpSaveQuery,
+		totp.UserID,
+		totp.Secret,
+		totp.RecoveryCodes,
+		totp.LastUsedStep,
+		totp.ConfirmedAt,
+		totp.CreatedAt,
+		totp.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save totp: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserID получает настройки двухфакторной аутентификации пользователя
+func (r *totpRepository) GetByUserID(ctx context.Context, userID string) (*domain.TOTP, error) {
+	var totp domain.TOTP
+	err := r.db.QueryRow(ctx, totpGetQuery, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.RecoveryCodes,
+		&totp.LastUsedStep,
+		&totp.ConfirmedAt,
+		&totp.CreatedAt,
+		&totp.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTOTPNotEnabled
+		}
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+
+	return &totp, nil
+}
+
+// Delete удаляет настройки двухфакторной аутентификации пользователя
+func (r *totpRepository) Delete(ctx context.Context, userID string) error {
+	if _, err := r.db.Exec(ctx, totpDeleteQuery, userID); err != nil {
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+
+	return nil
+}
+
+// MarkUsed запоминает шаг времени принятого кода
+func (r *totpRepository) MarkUsed(ctx context.Context, userID string, step int64) error {
+	result, err := r.db.Exec(ctx, totpMarkUsedQuery, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to mark totp code used: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrInvalidTOTPCode
+	}
+
+	return nil
+}
+
+// UseRecoveryCode погашает код восстановления
+func (r *totpRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	result, err := r.db.Exec(ctx, totpUseRecoveryCodeQuery, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrInvalidTOTPCode
+	}
+
+	return nil
+}
@@ -28,6 +28,28 @@ func (t *postgresTransaction) Rollback(ctx context.Context) error {
 	return t.tx.Rollback(ctx)
 }
 
+// LockUserSecrets блокирует строку версии секретов пользователя (SELECT ... FOR UPDATE);
+// строка создается, если ее еще нет
+func (t *postgresTransaction) LockUserSecrets(ctx context.Context, userID string) error {
+	query := `
+		INSERT INTO user_secrets_version (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	if _, err := t.tx.Exec(ctx, query, userID); err != nil {
+		return err
+	}
+
+	query = `
+		SELECT current_version
+		FROM user_secrets_version
+		WHERE user_id = $1
+		FOR UPDATE
+	`
+	_, err := t.tx.Exec(ctx, query, userID)
+	return err
+}
+
 // UserRepository возвращает UserRepository в контексте транзакции
 func (t *postgresTransaction) UserRepository() interfaces.UserRepository {
 	return NewTxUserRepository(t.tx)
@@ -37,3 +59,48 @@ func (t *postgresTransaction) UserRepository() interfaces.UserRepository {
 func (t *postgresTransaction) SecretRepository() interfaces.SecretRepository {
 	return NewTxSecretRepository(t.tx)
 }
+
+// VaultKeyRepository возвращает VaultKeyRepository в контексте транзакции
+func (t *postgresTransaction) VaultKeyRepository() interfaces.VaultKeyRepository {
+	return NewTxVaultKeyRepository(t.tx)
+}
+
+// ManifestRepository возвращает ManifestRepository в контексте транзакции
+func (t *postgresTransaction) ManifestRepository() interfaces.ManifestRepository {
+	return NewTxManifestRepository(t.tx)
+}
+
+// TOTPRepository возвращает TOTPRepository в контексте транзакции
+func (t *postgresTransaction) TOTPRepository() interfaces.TOTPRepository {
+	return NewTxTOTPRepository(t.tx)
+}
+
+// LoginAttemptRepository возвращает LoginAttemptRepository в контексте транзакции
+func (t *postgresTransaction) LoginAttemptRepository() interfaces.LoginAttemptRepository {
+	return NewTxLoginAttemptRepository(t.tx)
+}
+
+// DataKeyRepository возвращает DataKeyRepository в контексте транзакции
+func (t *postgresTransaction) DataKeyRepository() interfaces.DataKeyRepository {
+	return NewTxDataKeyRepository(t.tx)
+}
+
+// RefreshTokenRepository возвращает RefreshTokenRepository в контексте транзакции
+func (t *postgresTransaction) RefreshTokenRepository() interfaces.RefreshTokenRepository {
+	return NewTxRefreshTokenRepository(t.tx)
+}
+
+// SessionRepository возвращает SessionRepository в контексте транзакции
+func (t *postgresTransaction) SessionRepository() interfaces.SessionRepository {
+	return NewTxSessionRepository(t.tx)
+}
+
+// DeviceRepository возвращает DeviceRepository в контексте транзакции
+func (t *postgresTransaction) DeviceRepository() interfaces.DeviceRepository {
+	return NewTxDeviceRepository(t.tx)
+}
+
+// AccessTokenRepository возвращает AccessTokenRepository в контексте транзакции
+func (t *postgresTransaction) AccessTokenRepository() interfaces.AccessTokenRepository {
+	return NewTxAccessTokenRepository(t.tx)
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txAccessTokenRepository реализует AccessTokenRepository для транзакций
+type txAccessTokenRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxAccessTokenRepository создает новый AccessTokenRepository для транзакций
+func NewTxAccessTokenRepository(tx pgx.Tx) interfaces.AccessTokenRepository {
+	return &txAccessTokenRepository{tx: tx}
+}
+
+// Create сохраняет новый токен доступа
+func (r *txAccessTokenRepository) Create(ctx context.Context, token *domain.AccessToken) error {
+	_, err := r.tx.Exec(ctx, accessTokenCreateQuery, accessTokenArgs(token)...)
+	return err
+}
+
+// GetByID получает токен доступа по ID
+func (r *txAccessTokenRepository) GetByID(ctx context.Context, id string) (*domain.AccessToken, error) {
+	token, err := scanAccessToken(r.tx.QueryRow(ctx, accessTokenGetQuery, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrAccessTokenNotFound
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ListByUser возвращает токены доступа пользователя, начиная с последнего созданного
+func (r *txAccessTokenRepository) ListByUser(ctx context.Context, userID string) ([]*domain.AccessToken, error) {
+	rows, err := r.tx.Query(ctx, accessTokenListByUserQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*domain.AccessToken
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// UpdateLastUsed запоминает время последнего использования токена
+func (r *txAccessTokenRepository) UpdateLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	result, err := r.tx.Exec(ctx, accessTokenUpdateLastUsedQuery, usedAt, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// Delete удаляет токен доступа
+func (r *txAccessTokenRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.tx.Exec(ctx, accessTokenDeleteQuery, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired удаляет токены доступа, истекшие раньше before
+func (r *txAccessTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.tx.Exec(ctx, accessTokenDeleteExpiredQuery, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
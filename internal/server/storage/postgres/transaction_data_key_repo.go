@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txDataKeyRepository реализует DataKeyRepository для транзакций
+type txDataKeyRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxDataKeyRepository создает новый DataKeyRepository для транзакций
+func NewTxDataKeyRepository(tx pgx.Tx) interfaces.DataKeyRepository {
+	return &txDataKeyRepository{tx: tx}
+}
+
+// Create сохраняет ключ шифрования данных, если у пользователя его еще нет
+func (r *txDataKeyRepository) Create(ctx context.Context, key *domain.DataKey) error {
+	query := `
+		INSERT INTO data_keys (user_id, wrapped_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	result, err := r.tx.Exec(ctx, query,
+		key.UserID,
+		key.WrappedKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDataKeyExists
+	}
+
+	return nil
+}
+
+// GetByUserID возвращает ключ шифрования данных пользователя
+func (r *txDataKeyRepository) GetByUserID(ctx context.Context, userID string) (*domain.DataKey, error) {
+	query := `
+		SELECT user_id, wrapped_key, created_at, updated_at
+		FROM data_keys
+		WHERE user_id = $1
+	`
+
+	var key domain.DataKey
+	err := r.tx.QueryRow(ctx, query, userID).Scan(
+		&key.UserID,
+		&key.WrappedKey,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrDataKeyNotFound
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// List возвращает ключи шифрования данных всех пользователей
+func (r *txDataKeyRepository) List(ctx context.Context) ([]*domain.DataKey, error) {
+	query := `
+		SELECT user_id, wrapped_key, created_at, updated_at
+		FROM data_keys
+		ORDER BY user_id
+	`
+
+	rows, err := r.tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.DataKey
+	for rows.Next() {
+		var key domain.DataKey
+		if err := rows.Scan(&key.UserID, &key.WrappedKey, &key.CreatedAt, &key.UpdatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Update заменяет зашифрованный ключ шифрования данных пользователя
+func (r *txDataKeyRepository) Update(ctx context.Context, key *domain.DataKey) error {
+	query := `
+		UPDATE data_keys
+		SET wrapped_key = $1, updated_at = $2
+		WHERE user_id = $3
+	`
+
+	result, err := r.tx.Exec(ctx, query, key.WrappedKey, key.UpdatedAt, key.UserID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDataKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txDeviceRepository реализует DeviceRepository для транзакций
+type txDeviceRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxDeviceRepository создает новый DeviceRepository для транзакций
+func NewTxDeviceRepository(tx pgx.Tx) interfaces.DeviceRepository {
+	return &txDeviceRepository{tx: tx}
+}
+
+// Create сохраняет новое устройство
+func (r *txDeviceRepository) Create(ctx context.Context, device *domain.Device) error {
+	_, err := r.tx.Exec(ctx, deviceCreateQuery,
+		device.ID,
+		device.UserID,
+		device.Name,
+		device.ClientVersion,
+		device.SecretHash,
+		device.ApprovedAt,
+		device.CreatedAt,
+		device.LastSeenAt,
+	)
+	return err
+}
+
+// GetByID получает устройство по ID
+func (r *txDeviceRepository) GetByID(ctx context.Context, id string) (*domain.Device, error) {
+	device, err := scanDevice(r.tx.QueryRow(ctx, deviceGetQuery, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// ListByUser возвращает устройства пользователя, начиная с последнего активного
+func (r *txDeviceRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Device, error) {
+	rows, err := r.tx.Query(ctx, deviceListByUserQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []*domain.Device
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, rows.Err()
+}
+
+// Update обновляет устройство
+func (r *txDeviceRepository) Update(ctx context.Context, device *domain.Device) error {
+	result, err := r.tx.Exec(ctx, deviceUpdateQuery,
+		device.Name,
+		device.ClientVersion,
+		device.ApprovedAt,
+		device.LastSeenAt,
+		device.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// Delete удаляет устройство
+func (r *txDeviceRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.tx.Exec(ctx, deviceDeleteQuery, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+// ClaimFirstApproval отмечает, что у пользователя появилось одобренное устройство
+func (r *txDeviceRepository) ClaimFirstApproval(ctx context.Context, userID string, at time.Time) (bool, error) {
+	result, err := r.tx.Exec(ctx, deviceClaimFirstApprovalQuery, userID, at)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
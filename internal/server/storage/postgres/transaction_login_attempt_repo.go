@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txLoginAttemptRepository реализует LoginAttemptRepository для транзакций
+type txLoginAttemptRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxLoginAttemptRepository создает новый LoginAttemptRepository для транзакций
+func NewTxLoginAttemptRepository(tx pgx.Tx) interfaces.LoginAttemptRepository {
+	return &txLoginAttemptRepository{tx: tx}
+}
+
+// Get получает неудачные попытки входа по ключу
+func (r *txLoginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := r.tx.QueryRow(ctx, loginAttemptGetQuery, key).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrLoginAttemptNotFound
+		}
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure учитывает неудачную попытку входа
+func (r *txLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := r.tx.QueryRow(ctx, loginAttemptRecordFailureQuery, key, now, now.Add(-window)).Scan(
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// Release отменяет заранее учтенную попытку входа
+func (r *txLoginAttemptRepository) Release(ctx context.Context, key string) error {
+	_, err := r.tx.Exec(ctx, loginAttemptReleaseQuery, key)
+	return err
+}
+
+// Delete сбрасывает счетчик неудачных попыток
+func (r *txLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	_, err := r.tx.Exec(ctx, loginAttemptDeleteQuery, key)
+	return err
+}
+
+// DeleteStale удаляет счетчики с последней неудачей раньше before
+func (r *txLoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.tx.Exec(ctx, loginAttemptDeleteStaleQuery, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
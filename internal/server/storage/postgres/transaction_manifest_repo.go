@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txManifestRepository реализует ManifestRepository для транзакций
+type txManifestRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxManifestRepository создает новый ManifestRepository для транзакций
+func NewTxManifestRepository(tx pgx.Tx) interfaces.ManifestRepository {
+	return &txManifestRepository{tx: tx}
+}
+
+// Save сохраняет опись хранилища, если ее версия следует за сохраненной
+func (r *txManifestRepository) Save(ctx context.Context, manifest *domain.VaultManifest) error {
+	result, err := r.tx.Exec(ctx, manifestSaveQuery(manifest.Version),
+		manifest.UserID,
+		manifest.Data,
+		manifest.Version,
+		manifest.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrManifestConflict
+	}
+
+	return nil
+}
+
+// GetByUserID получает опись хранилища пользователя
+func (r *txManifestRepository) GetByUserID(ctx context.Context, userID string) (*domain.VaultManifest, error) {
+	query := `
+		SELECT user_id, manifest, version, updated_at
+		FROM vault_manifests
+		WHERE user_id = $1
+	`
+
+	var manifest domain.VaultManifest
+	err := r.tx.QueryRow(ctx, query, userID).Scan(
+		&manifest.UserID,
+		&manifest.Data,
+		&manifest.Version,
+		&manifest.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &manifest, nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txRefreshTokenRepository реализует RefreshTokenRepository для транзакций
+type txRefreshTokenRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxRefreshTokenRepository создает новый RefreshTokenRepository для транзакций
+func NewTxRefreshTokenRepository(tx pgx.Tx) interfaces.RefreshTokenRepository {
+	return &txRefreshTokenRepository{tx: tx}
+}
+
+// Create сохраняет выданный refresh-токен
+func (r *txRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.tx.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+		token.RotatedAt,
+		token.RevokedAt,
+	)
+	return err
+}
+
+// GetByHash получает refresh-токен по хешу
+func (r *txRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token domain.RefreshToken
+	err := r.tx.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.RotatedAt,
+		&token.RevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkRotated помечает refresh-токен использованным
+func (r *txRefreshTokenRepository) MarkRotated(ctx context.Context, id string, rotatedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET rotated_at = $1
+		WHERE id = $2 AND rotated_at IS NULL AND revoked_at IS NULL
+	`
+
+	result, err := r.tx.Exec(ctx, query, rotatedAt, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+// RevokeFamily отзывает все токены семейства
+func (r *txRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE family_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.tx.Exec(ctx, query, revokedAt, familyID)
+	return err
+}
+
+// RevokeByUser отзывает все токены пользователя
+func (r *txRefreshTokenRepository) RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.tx.Exec(ctx, query, revokedAt, userID)
+	return err
+}
+
+// DeleteExpired удаляет refresh-токены, истекшие раньше before
+func (r *txRefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.tx.Exec(ctx, refreshTokenDeleteExpiredQuery, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jackc/pgx/v5"
 
@@ -22,8 +23,8 @@ func NewTxSecretRepository(tx pgx.Tx) interfaces.SecretRepository {
 // Create создает новый секрет
 func (r *txSecretRepository) Create(ctx context.Context, secret *domain.Secret) error {
 	query := `
-		INSERT INTO secrets (id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO secrets (id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
 	_, err := r.tx.Exec(ctx, query,
@@ -33,6 +34,7 @@ func (r *txSecretRepository) Create(ctx context.Context, secret *domain.Secret)
 		secret.Name,
 		secret.EncryptedData,
 		secret.EncryptedMeta,
+		secret.KeyID,
 		secret.Version,
 		secret.CreatedAt,
 		secret.UpdatedAt,
@@ -45,7 +47,7 @@ func (r *txSecretRepository) Create(ctx context.Context, secret *domain.Secret)
 // GetByID получает секрет по ID и ID пользователя
 func (r *txSecretRepository) GetByID(ctx context.Context, id, userID string) (*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE id = $1 AND user_id = $2 AND NOT is_deleted
 	`
@@ -60,6 +62,7 @@ func (r *txSecretRepository) GetByID(ctx context.Context, id, userID string) (*d
 		&secret.Name,
 		&secret.EncryptedData,
 		&secret.EncryptedMeta,
+		&secret.KeyID,
 		&secret.Version,
 		&secret.CreatedAt,
 		&secret.UpdatedAt,
@@ -67,6 +70,9 @@ func (r *txSecretRepository) GetByID(ctx context.Context, id, userID string) (*d
 	)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrSecretNotFound
+		}
 		return nil, err
 	}
 
@@ -77,7 +83,7 @@ func (r *txSecretRepository) GetByID(ctx context.Context, id, userID string) (*d
 // ListByUser получает список секретов пользователя
 func (r *txSecretRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE user_id = $1 AND NOT is_deleted
 		ORDER BY created_at DESC
@@ -101,6 +107,7 @@ func (r *txSecretRepository) ListByUser(ctx context.Context, userID string) ([]*
 			&secret.Name,
 			&secret.EncryptedData,
 			&secret.EncryptedMeta,
+			&secret.KeyID,
 			&secret.Version,
 			&secret.CreatedAt,
 			&secret.UpdatedAt,
@@ -120,7 +127,7 @@ func (r *txSecretRepository) ListByUser(ctx context.Context, userID string) ([]*
 // ListByUserAndType получает список секретов пользователя определенного типа
 func (r *txSecretRepository) ListByUserAndType(ctx context.Context, userID string, secretType domain.SecretType) ([]*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE user_id = $1 AND type = $2 AND NOT is_deleted
 		ORDER BY created_at DESC
@@ -144,6 +151,7 @@ func (r *txSecretRepository) ListByUserAndType(ctx context.Context, userID strin
 			&secret.Name,
 			&secret.EncryptedData,
 			&secret.EncryptedMeta,
+			&secret.KeyID,
 			&secret.Version,
 			&secret.CreatedAt,
 			&secret.UpdatedAt,
@@ -164,9 +172,9 @@ func (r *txSecretRepository) ListByUserAndType(ctx context.Context, userID strin
 func (r *txSecretRepository) Update(ctx context.Context, secret *domain.Secret) error {
 	query := `
 		UPDATE secrets 
-		SET type = $1, name = $2, encrypted_data = $3, encrypted_meta = $4, 
-		    version = version + 1, updated_at = $5, is_deleted = $6
-		WHERE id = $7 AND user_id = $8 AND version = $9
+		SET type = $1, name = $2, encrypted_data = $3, encrypted_meta = $4, key_id = $5,
+		    version = version + 1, updated_at = $6, is_deleted = $7
+		WHERE id = $8 AND user_id = $9 AND version = $10
 	`
 
 	result, err := r.tx.Exec(ctx, query,
@@ -174,6 +182,7 @@ func (r *txSecretRepository) Update(ctx context.Context, secret *domain.Secret)
 		secret.Name,
 		secret.EncryptedData,
 		secret.EncryptedMeta,
+		secret.KeyID,
 		secret.UpdatedAt,
 		secret.IsDeleted,
 		secret.ID,
@@ -199,6 +208,15 @@ func (r *txSecretRepository) Delete(ctx context.Context, id, userID string) erro
 	return err
 }
 
+// DeleteByUser удаляет все секреты пользователя и версию его секретов
+func (r *txSecretRepository) DeleteByUser(ctx context.Context, userID string) error {
+	if _, err := r.tx.Exec(ctx, `DELETE FROM secrets WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := r.tx.Exec(ctx, `DELETE FROM user_secrets_version WHERE user_id = $1`, userID)
+	return err
+}
+
 // SoftDelete выполняет мягкое удаление секрета
 func (r *txSecretRepository) SoftDelete(ctx context.Context, id, userID string) error {
 	query := `
@@ -235,7 +253,7 @@ func (r *txSecretRepository) GetUserSecretsVersion(ctx context.Context, userID s
 // GetChangedSecrets получает список секретов пользователя, измененных после указанной версии
 func (r *txSecretRepository) GetChangedSecrets(ctx context.Context, userID string, lastSyncVersion int64) ([]*domain.Secret, error) {
 	query := `
-		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, version, created_at, updated_at, is_deleted
+		SELECT id, user_id, type, name, encrypted_data, encrypted_meta, key_id, version, created_at, updated_at, is_deleted
 		FROM secrets
 		WHERE user_id = $1 AND version > $2
 		ORDER BY version ASC
@@ -259,6 +277,7 @@ func (r *txSecretRepository) GetChangedSecrets(ctx context.Context, userID strin
 			&secret.Name,
 			&secret.EncryptedData,
 			&secret.EncryptedMeta,
+			&secret.KeyID,
 			&secret.Version,
 			&secret.CreatedAt,
 			&secret.UpdatedAt,
@@ -274,3 +293,14 @@ func (r *txSecretRepository) GetChangedSecrets(ctx context.Context, userID strin
 
 	return secrets, nil
 }
+
+// GetUsage возвращает число и объем секретов пользователя
+func (r *txSecretRepository) GetUsage(ctx context.Context, userID string) (*domain.SecretUsage, error) {
+	var usage domain.SecretUsage
+	err := r.tx.QueryRow(ctx, secretUsageQuery, userID).Scan(&usage.Secrets, &usage.DeletedSecrets, &usage.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txSessionRepository реализует SessionRepository для транзакций
+type txSessionRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxSessionRepository создает новый SessionRepository для транзакций
+func NewTxSessionRepository(tx pgx.Tx) interfaces.SessionRepository {
+	return &txSessionRepository{tx: tx}
+}
+
+// Create сохраняет новую сессию
+func (r *txSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, device_id, device_name, client_version, ip_address, created_at, last_used_at, expires_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := r.tx.Exec(ctx, query,
+		session.ID,
+		session.UserID,
+		session.DeviceID,
+		session.DeviceName,
+		session.ClientVersion,
+		session.IPAddress,
+		session.CreatedAt,
+		session.LastUsedAt,
+		session.ExpiresAt,
+		session.RevokedAt,
+	)
+	return err
+}
+
+// GetByID получает сессию по ID
+func (r *txSessionRepository) GetByID(ctx context.Context, id string) (*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_id, device_name, client_version, ip_address, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	var session domain.Session
+	err := r.tx.QueryRow(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceID,
+		&session.DeviceName,
+		&session.ClientVersion,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ListActiveByUser возвращает активные сессии пользователя, начиная с последней использованной
+func (r *txSessionRepository) ListActiveByUser(ctx context.Context, userID string, now time.Time) ([]*domain.Session, error) {
+	query := `
+		SELECT id, user_id, device_id, device_name, client_version, ip_address, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.tx.Query(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*domain.Session
+	for rows.Next() {
+		var session domain.Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.DeviceID,
+			&session.DeviceName,
+			&session.ClientVersion,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+			&session.RevokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Update обновляет сессию
+func (r *txSessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	query := `
+		UPDATE sessions
+		SET device_id = $1, device_name = $2, client_version = $3, ip_address = $4, last_used_at = $5, expires_at = $6
+		WHERE id = $7
+	`
+
+	result, err := r.tx.Exec(ctx, query,
+		session.DeviceID,
+		session.DeviceName,
+		session.ClientVersion,
+		session.IPAddress,
+		session.LastUsedAt,
+		session.ExpiresAt,
+		session.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// Revoke отзывает сессию
+func (r *txSessionRepository) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE id = $2
+	`
+
+	result, err := r.tx.Exec(ctx, query, revokedAt, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeByUser отзывает все сессии пользователя
+func (r *txSessionRepository) RevokeByUser(ctx context.Context, userID string, revokedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.tx.Exec(ctx, query, revokedAt, userID)
+	return err
+}
+
+// DeleteByUser удаляет все сессии пользователя
+func (r *txSessionRepository) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.tx.Exec(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+// DeleteExpired удаляет сессии, истекшие или отозванные раньше before
+func (r *txSessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.tx.Exec(ctx, sessionDeleteExpiredQuery, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
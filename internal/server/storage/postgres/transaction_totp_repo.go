@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txTOTPRepository реализует TOTPRepository для транзакций
+type txTOTPRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxTOTPRepository создает новый TOTPRepository для транзакций
+func NewTxTOTPRepository(tx pgx.Tx) interfaces.TOTPRepository {
+	return &txTOTPRepository{tx: tx}
+}
+
+// Save создает или заменяет настройки двухфакторной аутентификации
+func (r *txTOTPRepository) Save(ctx context.Context, totp *domain.TOTP) error {
+	_, err := r.tx.Exec(ctx, totpSaveQuery,
+		totp.UserID,
+		totp.Secret,
+		totp.RecoveryCodes,
+		totp.LastUsedStep,
+		totp.ConfirmedAt,
+		totp.CreatedAt,
+		totp.UpdatedAt,
+	)
+	return err
+}
+
+// GetByUserID получает настройки двухфакторной аутентификации пользователя
+func (r *txTOTPRepository) GetByUserID(ctx context.Context, userID string) (*domain.TOTP, error) {
+	var totp domain.TOTP
+	err := r.tx.QueryRow(ctx, totpGetQuery, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.RecoveryCodes,
+		&totp.LastUsedStep,
+		&totp.ConfirmedAt,
+		&totp.CreatedAt,
+		&totp.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTOTPNotEnabled
+		}
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// Delete удаляет настройки двухфакторной аутентификации пользователя
+func (r *txTOTPRepository) Delete(ctx context.Context, userID string) error {
+	_, err := r.tx.Exec(ctx, totpDeleteQuery, userID)
+	return err
+}
+
+// MarkUsed запоминает шаг времени принятого кода
+func (r *txTOTPRepository) MarkUsed(ctx context.Context, userID string, step int64) error {
+	result, err := r.tx.Exec(ctx, totpMarkUsedQuery, userID, step)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrInvalidTOTPCode
+	}
+
+	return nil
+}
+
+// UseRecoveryCode погашает код восстановления
+func (r *txTOTPRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	result, err := r.tx.Exec(ctx, totpUseRecoveryCodeQuery, userID, codeHash)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrInvalidTOTPCode
+	}
+
+	return nil
+}
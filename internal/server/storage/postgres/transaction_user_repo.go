@@ -22,14 +22,24 @@ func NewTxUserRepository(tx pgx.Tx) interfaces.UserRepository {
 // Create создает нового пользователя
 func (r *txUserRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
-		INSERT INTO users (id, login, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+		                   srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
 	_, err := r.tx.Exec(ctx, query,
 		user.ID,
 		user.Login,
 		user.PasswordHash,
+		user.KDFSalt,
+		user.KDFIterations,
+		user.KDFMemory,
+		user.KDFParallelism,
+		user.SRPSalt,
+		user.SRPVerifier,
+		user.PasswordChangedAt,
+		userRole(user),
+		user.DisabledAt,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -40,7 +50,8 @@ func (r *txUserRepository) Create(ctx context.Context, user *domain.User) error
 // GetByLogin получает пользователя по логину
 func (r *txUserRepository) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at, updated_at
+		SELECT id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+		       srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at
 		FROM users
 		WHERE login = $1
 	`
@@ -50,6 +61,15 @@ func (r *txUserRepository) GetByLogin(ctx context.Context, login string) (*domai
 		&user.ID,
 		&user.Login,
 		&user.PasswordHash,
+		&user.KDFSalt,
+		&user.KDFIterations,
+		&user.KDFMemory,
+		&user.KDFParallelism,
+		&user.SRPSalt,
+		&user.SRPVerifier,
+		&user.PasswordChangedAt,
+		&user.Role,
+		&user.DisabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -64,7 +84,8 @@ func (r *txUserRepository) GetByLogin(ctx context.Context, login string) (*domai
 // GetByID получает пользователя по ID
 func (r *txUserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at, updated_at
+		SELECT id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+		       srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -74,6 +95,15 @@ func (r *txUserRepository) GetByID(ctx context.Context, id string) (*domain.User
 		&user.ID,
 		&user.Login,
 		&user.PasswordHash,
+		&user.KDFSalt,
+		&user.KDFIterations,
+		&user.KDFMemory,
+		&user.KDFParallelism,
+		&user.SRPSalt,
+		&user.SRPVerifier,
+		&user.PasswordChangedAt,
+		&user.Role,
+		&user.DisabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -89,13 +119,24 @@ func (r *txUserRepository) GetByID(ctx context.Context, id string) (*domain.User
 func (r *txUserRepository) Update(ctx context.Context, user *domain.User) error {
 	query := `
 		UPDATE users 
-		SET login = $1, password_hash = $2, updated_at = $3
-		WHERE id = $4
+		SET login = $1, password_hash = $2, kdf_salt = $3, kdf_iterations = $4, kdf_memory = $5,
+		    kdf_parallelism = $6, srp_salt = $7, srp_verifier = $8, password_changed_at = $9,
+		    role = $10, disabled_at = $11, updated_at = $12
+		WHERE id = $13
 	`
 
 	_, err := r.tx.Exec(ctx, query,
 		user.Login,
 		user.PasswordHash,
+		user.KDFSalt,
+		user.KDFIterations,
+		user.KDFMemory,
+		user.KDFParallelism,
+		user.SRPSalt,
+		user.SRPVerifier,
+		user.PasswordChangedAt,
+		userRole(user),
+		user.DisabledAt,
 		user.UpdatedAt,
 		user.ID,
 	)
@@ -109,3 +150,14 @@ func (r *txUserRepository) Delete(ctx context.Context, id string) error {
 	_, err := r.tx.Exec(ctx, query, id)
 	return err
 }
+
+// List возвращает пользователей, логин которых содержит query, упорядоченных по логину
+func (r *txUserRepository) List(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	rows, err := r.tx.Query(ctx, userListQuery, userLoginPattern(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanUsers(rows)
+}
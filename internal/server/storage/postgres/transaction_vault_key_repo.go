@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// txVaultKeyRepository реализует VaultKeyRepository для транзакций
+type txVaultKeyRepository struct {
+	tx pgx.Tx
+}
+
+// NewTxVaultKeyRepository создает новый VaultKeyRepository для транзакций
+func NewTxVaultKeyRepository(tx pgx.Tx) interfaces.VaultKeyRepository {
+	return &txVaultKeyRepository{tx: tx}
+}
+
+// Create сохраняет защищенный ключ хранилища, если у пользователя его еще нет
+func (r *txVaultKeyRepository) Create(ctx context.Context, key *domain.VaultKey) error {
+	query := `
+		INSERT INTO vault_keys (user_id, protected_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	result, err := r.tx.Exec(ctx, query,
+		key.UserID,
+		key.ProtectedKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrVaultKeyExists
+	}
+
+	return nil
+}
+
+// Save сохраняет или заменяет защищенный ключ хранилища пользователя
+func (r *txVaultKeyRepository) Save(ctx context.Context, key *domain.VaultKey) error {
+	query := `
+		INSERT INTO vault_keys (user_id, protected_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET protected_key = EXCLUDED.protected_key, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.tx.Exec(ctx, query,
+		key.UserID,
+		key.ProtectedKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByUserID получает защищенный ключ хранилища пользователя
+func (r *txVaultKeyRepository) GetByUserID(ctx context.Context, userID string) (*domain.VaultKey, error) {
+	query := `
+		SELECT user_id, protected_key, created_at, updated_at
+		FROM vault_keys
+		WHERE user_id = $1
+	`
+
+	var key domain.VaultKey
+	err := r.tx.QueryRow(ctx, query, userID).Scan(
+		&key.UserID,
+		&key.ProtectedKey,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrVaultKeyNotFound
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -14,6 +15,16 @@ import (
 	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
 )
 
+// userListQuery ищет пользователей по подстроке логина; $1 — шаблон из userLoginPattern
+const userListQuery = `
+	SELECT id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+	       srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at
+	FROM users
+	WHERE login ILIKE $1
+	ORDER BY login
+	LIMIT $2 OFFSET $3
+`
+
 // userRepository реализует UserRepository для PostgreSQL
 type userRepository struct {
 	db *pgxpool.Pool
@@ -27,14 +38,24 @@ func NewUserRepository(db *pgxpool.Pool) interfaces.UserRepository {
 // Create создает нового пользователя
 func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
-		INSERT INTO users (id, login, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+		                   srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
 	_, err := r.db.Exec(ctx, query,
 		user.ID,
 		user.Login,
 		user.PasswordHash,
+		user.KDFSalt,
+		user.KDFIterations,
+		user.KDFMemory,
+		user.KDFParallelism,
+		user.SRPSalt,
+		user.SRPVerifier,
+		user.PasswordChangedAt,
+		userRole(user),
+		user.DisabledAt,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -53,7 +74,8 @@ func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
 // GetByLogin возвращает пользователя по логину
 func (r *userRepository) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at, updated_at
+		SELECT id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+		       srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at
 		FROM users
 		WHERE login = $1
 	`
@@ -63,6 +85,15 @@ func (r *userRepository) GetByLogin(ctx context.Context, login string) (*domain.
 		&user.ID,
 		&user.Login,
 		&user.PasswordHash,
+		&user.KDFSalt,
+		&user.KDFIterations,
+		&user.KDFMemory,
+		&user.KDFParallelism,
+		&user.SRPSalt,
+		&user.SRPVerifier,
+		&user.PasswordChangedAt,
+		&user.Role,
+		&user.DisabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -80,7 +111,8 @@ func (r *userRepository) GetByLogin(ctx context.Context, login string) (*domain.
 // GetByID возвращает пользователя по ID
 func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at, updated_at
+		SELECT id, login, password_hash, kdf_salt, kdf_iterations, kdf_memory, kdf_parallelism,
+		       srp_salt, srp_verifier, password_changed_at, role, disabled_at, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -90,6 +122,15 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User,
 		&user.ID,
 		&user.Login,
 		&user.PasswordHash,
+		&user.KDFSalt,
+		&user.KDFIterations,
+		&user.KDFMemory,
+		&user.KDFParallelism,
+		&user.SRPSalt,
+		&user.SRPVerifier,
+		&user.PasswordChangedAt,
+		&user.Role,
+		&user.DisabledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -108,13 +149,24 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*domain.User,
 func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
 	query := `
 		UPDATE users 
-		SET login = $1, password_hash = $2, updated_at = $3
-		WHERE id = $4
+		SET login = $1, password_hash = $2, kdf_salt = $3, kdf_iterations = $4, kdf_memory = $5,
+		    kdf_parallelism = $6, srp_salt = $7, srp_verifier = $8, password_changed_at = $9,
+		    role = $10, disabled_at = $11, updated_at = $12
+		WHERE id = $13
 	`
 
 	result, err := r.db.Exec(ctx, query,
 		user.Login,
 		user.PasswordHash,
+		user.KDFSalt,
+		user.KDFIterations,
+		user.KDFMemory,
+		user.KDFParallelism,
+		user.SRPSalt,
+		user.SRPVerifier,
+		user.PasswordChangedAt,
+		userRole(user),
+		user.DisabledAt,
 		time.Now(),
 		user.ID,
 	)
@@ -149,3 +201,62 @@ func (r *userRepository) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+// List возвращает пользователей, логин которых содержит query, упорядоченных по логину
+func (r *userRepository) List(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	rows, err := r.db.Query(ctx, userListQuery, userLoginPattern(query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := scanUsers(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+	return users, nil
+}
+
+// scanUsers читает строки запроса userListQuery
+func scanUsers(rows pgx.Rows) ([]*domain.User, error) {
+	var users []*domain.User
+	for rows.Next() {
+		var user domain.User
+		err := rows.Scan(
+			&user.ID,
+			&user.Login,
+			&user.PasswordHash,
+			&user.KDFSalt,
+			&user.KDFIterations,
+			&user.KDFMemory,
+			&user.KDFParallelism,
+			&user.SRPSalt,
+			&user.SRPVerifier,
+			&user.PasswordChangedAt,
+			&user.Role,
+			&user.DisabledAt,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// userLoginPattern возвращает шаблон ILIKE для поиска подстроки query в логине
+func userLoginPattern(query string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(query) + "%"
+}
+
+// userRole возвращает роль для записи; пользователи без роли сохраняются обычными
+func userRole(user *domain.User) domain.UserRole {
+	if user.Role == "" {
+		return domain.RoleUser
+	}
+	return user.Role
+}
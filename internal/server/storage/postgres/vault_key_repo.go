@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/interfaces"
+)
+
+// vaultKeyRepository реализует VaultKeyRepository для PostgreSQL
+type vaultKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewVaultKeyRepository создает новый экземпляр VaultKeyRepository для PostgreSQL
+func NewVaultKeyRepository(db *pgxpool.Pool) interfaces.VaultKeyRepository {
+	return &vaultKeyRepository{db: db}
+}
+
+// Create сохраняет защищенный ключ хранилища, если у пользователя его еще нет
+func (r *vaultKeyRepository) Create(ctx context.Context, key *domain.VaultKey) error {
+	query := `
+		INSERT INTO vault_keys (user_id, protected_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		key.UserID,
+		key.ProtectedKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create vault key: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrVaultKeyExists
+	}
+
+	return nil
+}
+
+// Save сохраняет или заменяет защищенный ключ хранилища пользователя
+func (r *vaultKeyRepository) Save(ctx context.Context, key *domain.VaultKey) error {
+	query := `
+		INSERT INTO vault_keys (user_id, protected_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET protected_key = EXCLUDED.protected_key, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		key.UserID,
+		key.ProtectedKey,
+		key.CreatedAt,
+		key.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to save vault key: %w", err)
+	}
+
+	return nil
+}
+
+// GetByUserID возвращает защищенный ключ хранилища пользователя
+func (r *vaultKeyRepository) GetByUserID(ctx context.Context, userID string) (*domain.VaultKey, error) {
+	query := `
+		SELECT user_id, protected_key, created_at, updated_at
+		FROM vault_keys
+		WHERE user_id = $1
+	`
+
+	var key domain.VaultKey
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&key.UserID,
+		&key.ProtectedKey,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrVaultKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get vault key: %w", err)
+	}
+
+	return &key, nil
+}
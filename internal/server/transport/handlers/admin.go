@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alisaviation/GophKeeper/internal/generated/grpc"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
+)
+
+// AdminHandler обработчик gRPC для администрирования. Роль вызывающего проверяет перехватчик аутентификации.
+type AdminHandler struct {
+	grpc.UnimplementedAdminServiceServer
+	adminService *app.AdminService
+}
+
+// NewAdminHandler создает новый обработчик администрирования
+func NewAdminHandler(adminService *app.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// ListUsers возвращает страницу пользователей, найденных по подстроке логина
+func (h *AdminHandler) ListUsers(ctx context.Context, req *grpc.ListUsersRequest) (*grpc.ListUsersResponse, error) {
+	users, err := h.adminService.ListUsers(ctx, req.GetQuery(), int(req.GetLimit()), int(req.GetOffset()))
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	pbUsers := make([]*grpc.AdminUser, 0, len(users))
+	for _, user := range users {
+		pbUsers = append(pbUsers, adminUserToProto(user))
+	}
+
+	return &grpc.ListUsersResponse{
+		Users: pbUsers,
+	}, nil
+}
+
+// GetUserStats возвращает сводку по учетной записи пользователя без содержимого секретов
+func (h *AdminHandler) GetUserStats(ctx context.Context, req *grpc.GetUserStatsRequest) (*grpc.GetUserStatsResponse, error) {
+	if req.GetLogin() == "" {
+		return nil, status.Error(codes.InvalidArgument, "login is required")
+	}
+
+	user, stats, err := h.adminService.GetUserStats(ctx, req.GetLogin())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.GetUserStatsResponse{
+		User: adminUserToProto(user),
+		Stats: &grpc.UserStats{
+			Secrets:        stats.Secrets.Secrets,
+			DeletedSecrets: stats.Secrets.DeletedSecrets,
+			StorageBytes:   stats.Secrets.Bytes,
+			ActiveSessions: int32(stats.ActiveSessions),
+			Devices:        int32(stats.Devices),
+			AccessTokens:   int32(stats.AccessTokens),
+			TotpEnabled:    stats.TOTPEnabled,
+		},
+	}, nil
+}
+
+// SetUserDisabled блокирует или разблокирует учетную запись пользователя
+func (h *AdminHandler) SetUserDisabled(ctx context.Context, req *grpc.SetUserDisabledRequest) (*grpc.SetUserDisabledResponse, error) {
+	admin, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetLogin() == "" {
+		return nil, status.Error(codes.InvalidArgument, "login is required")
+	}
+
+	user, err := h.adminService.SetUserDisabled(ctx, admin.ID, req.GetLogin(), req.GetDisabled())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.SetUserDisabledResponse{
+		User: adminUserToProto(user),
+	}, nil
+}
+
+// ForceLogout завершает все сессии пользователя
+func (h *AdminHandler) ForceLogout(ctx context.Context, req *grpc.ForceLogoutRequest) (*grpc.ForceLogoutResponse, error) {
+	if req.GetLogin() == "" {
+		return nil, status.Error(codes.InvalidArgument, "login is required")
+	}
+
+	if err := h.adminService.ForceLogout(ctx, req.GetLogin(), req.GetRevokeAccessTokens()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.ForceLogoutResponse{}, nil
+}
+
+// ClearLoginLockout снимает блокировку входа по логину и (или) адресу клиента
+func (h *AdminHandler) ClearLoginLockout(ctx context.Context, req *grpc.ClearLoginLockoutRequest) (*grpc.ClearLoginLockoutResponse, error) {
+	if req.GetLogin() == "" && req.GetAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "login or address is required")
+	}
+
+	if err := h.adminService.ClearLoginLockout(ctx, req.GetLogin(), req.GetAddress()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.ClearLoginLockoutResponse{}, nil
+}
+
+// RunMaintenance выполняет задачу обслуживания базы
+func (h *AdminHandler) RunMaintenance(ctx context.Context, req *grpc.RunMaintenanceRequest) (*grpc.RunMaintenanceResponse, error) {
+	job, ok := maintenanceJobFromProto(req.GetJob())
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "unknown maintenance job")
+	}
+
+	removed, err := h.adminService.RunMaintenance(ctx, job)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.RunMaintenanceResponse{
+		Removed: removed,
+	}, nil
+}
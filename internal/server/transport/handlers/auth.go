@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
 )
 
 // AuthHandler обработчик gRPC для аутентификации
@@ -34,8 +36,14 @@ func (h *AuthHandler) Register(ctx context.Context, req *grpc.RegisterRequest) (
 		return nil, MapErrorToStatus(err)
 	}
 
+	kdf, err := h.authService.GetKDFParams(ctx, req.GetLogin())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
 	return &grpc.RegisterResponse{
 		UserId: userID,
+		Kdf:    kdfParamsToProto(kdf),
 	}, nil
 }
 
@@ -45,7 +53,12 @@ func (h *AuthHandler) Login(ctx context.Context, req *grpc.LoginRequest) (*grpc.
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	accessToken, refreshToken, userID, err := h.authService.Login(ctx, req.GetLogin(), req.GetPassword())
+	accessToken, refreshToken, userID, err := h.authService.Login(ctx, req.GetLogin(), req.GetPassword(), req.GetTotpCode())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	kdf, err := h.authService.GetKDFParams(ctx, req.GetLogin())
 	if err != nil {
 		return nil, MapErrorToStatus(err)
 	}
@@ -54,6 +67,7 @@ func (h *AuthHandler) Login(ctx context.Context, req *grpc.LoginRequest) (*grpc.
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		UserId:       userID,
+		Kdf:          kdfParamsToProto(kdf),
 	}, nil
 }
 
@@ -79,7 +93,530 @@ func (h *AuthHandler) Logout(ctx context.Context, req *grpc.LogoutRequest) (*grp
 	if req.GetRefreshToken() == "" {
 		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
 	}
+
+	if err := h.authService.Logout(ctx, req.GetRefreshToken()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
 	return &grpc.LogoutResponse{
 		Success: true,
 	}, nil
 }
+
+// SetProtectedVaultKey сохраняет защищенный ключ хранилища текущего пользователя
+func (h *AuthHandler) SetProtectedVaultKey(ctx context.Context, req *grpc.SetProtectedVaultKeyRequest) (*grpc.SetProtectedVaultKeyResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.authService.SetProtectedVaultKey(ctx, user.ID, req.GetProtectedKey()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.SetProtectedVaultKeyResponse{
+		Success: true,
+	}, nil
+}
+
+// GetProtectedVaultKey возвращает защищенный ключ хранилища текущего пользователя
+func (h *AuthHandler) GetProtectedVaultKey(ctx context.Context, req *grpc.GetProtectedVaultKeyRequest) (*grpc.GetProtectedVaultKeyResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Токену доступа выдается ключ хранилища, зашифрованный частью токена, которая есть только у клиента
+	if token := middleware.GetAccessTokenFromContext(ctx); token != nil {
+		return &grpc.GetProtectedVaultKeyResponse{
+			ProtectedKey: token.DelegatedKey,
+		}, nil
+	}
+
+	protectedKey, err := h.authService.GetProtectedVaultKey(ctx, user.ID)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.GetProtectedVaultKeyResponse{
+		ProtectedKey: protectedKey,
+	}, nil
+}
+
+// ChangePassword меняет мастер-пароль текущего пользователя. Текущий пароль подтверждается
+// обменом SRP, а у еще не перешедших на SRP пользователей — самим паролем.
+func (h *AuthHandler) ChangePassword(ctx context.Context, req *grpc.ChangePasswordRequest) (*grpc.ChangePasswordResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateChangePasswordRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.GetSrpHandshakeId() != "" {
+		accessToken, refreshToken, serverProof, err := h.authService.ChangePasswordSRP(ctx, user.ID,
+			middleware.GetDeviceIDFromContext(ctx), req.GetSrpHandshakeId(), req.GetSrpProof(), req.GetNewSrp().GetSalt(), req.GetNewSrp().GetVerifier(),
+			req.GetProtectedVaultKey())
+		if err != nil {
+			return nil, MapErrorToStatus(err)
+		}
+
+		return &grpc.ChangePasswordResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			ServerProof:  serverProof,
+		}, nil
+	}
+
+	accessToken, refreshToken, err := h.authService.ChangePassword(ctx, user.ID, middleware.GetDeviceIDFromContext(ctx),
+		req.GetOldPassword(), req.GetNewPassword(), req.GetProtectedVaultKey())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	if srp := req.GetNewSrp(); srp != nil {
+		if err := h.authService.EnrollSRP(ctx, user.ID, req.GetNewPassword(), srp.GetSalt(), srp.GetVerifier()); err != nil {
+			return nil, MapErrorToStatus(err)
+		}
+	}
+
+	return &grpc.ChangePasswordResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// GetJWKS возвращает открытые ключи проверки access-токенов
+func (h *AuthHandler) GetJWKS(ctx context.Context, req *grpc.GetJWKSRequest) (*grpc.GetJWKSResponse, error) {
+	keys := h.authService.PublicKeys()
+
+	resp := &grpc.GetJWKSResponse{
+		Keys: make([]*grpc.JWK, 0, len(keys)),
+	}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, jwkToProto(key))
+	}
+
+	return resp, nil
+}
+
+// ListSessions возвращает активные сессии текущего пользователя
+func (h *AuthHandler) ListSessions(ctx context.Context, req *grpc.ListSessionsRequest) (*grpc.ListSessionsResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := h.authService.ListSessions(ctx, user.ID)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	currentID := middleware.GetSessionIDFromContext(ctx)
+	resp := &grpc.ListSessionsResponse{
+		Sessions: make([]*grpc.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionToProto(session, currentID))
+	}
+
+	return resp, nil
+}
+
+// RevokeSession завершает сессию текущего пользователя
+func (h *AuthHandler) RevokeSession(ctx context.Context, req *grpc.RevokeSessionRequest) (*grpc.RevokeSessionResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetSessionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+
+	if err := h.authService.RevokeSession(ctx, user.ID, req.GetSessionId()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.RevokeSessionResponse{
+		Success: true,
+	}, nil
+}
+
+// SRPRegister регистрирует нового пользователя по верификатору SRP
+func (h *AuthHandler) SRPRegister(ctx context.Context, req *grpc.SRPRegisterRequest) (*grpc.SRPRegisterResponse, error) {
+	if err := validateSRPRegisterRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	kdf, err := kdfParamsFromProto(req.GetKdf())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	userID, err := h.authService.SRPRegister(ctx, req.GetLogin(), kdf, req.GetSrp().GetSalt(), req.GetSrp().GetVerifier())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.SRPRegisterResponse{
+		UserId: userID,
+	}, nil
+}
+
+// SRPStart начинает вход по SRP
+func (h *AuthHandler) SRPStart(ctx context.Context, req *grpc.SRPStartRequest) (*grpc.SRPStartResponse, error) {
+	if req.GetLogin() == "" {
+		return nil, status.Error(codes.InvalidArgument, "login is required")
+	}
+	if len(req.GetClientEphemeral()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "client ephemeral is required")
+	}
+
+	challenge, err := h.authService.SRPStart(ctx, req.GetLogin(), req.GetClientEphemeral())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.SRPStartResponse{
+		HandshakeId:     challenge.HandshakeID,
+		Salt:            challenge.Salt,
+		ServerEphemeral: challenge.ServerEphemeral,
+		Kdf:             kdfParamsToProto(challenge.KDF),
+	}, nil
+}
+
+// SRPLogin завершает вход по SRP
+func (h *AuthHandler) SRPLogin(ctx context.Context, req *grpc.SRPLoginRequest) (*grpc.SRPLoginResponse, error) {
+	if req.GetHandshakeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "handshake id is required")
+	}
+	if len(req.GetClientProof()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "client proof is required")
+	}
+
+	accessToken, refreshToken, userID, serverProof, err := h.authService.SRPLogin(ctx, req.GetHandshakeId(), req.GetClientProof(), req.GetTotpCode())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.SRPLoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		UserId:       userID,
+		ServerProof:  serverProof,
+	}, nil
+}
+
+// EnrollSRP переводит текущего пользователя на вход по SRP
+func (h *AuthHandler) EnrollSRP(ctx context.Context, req *grpc.EnrollSRPRequest) (*grpc.EnrollSRPResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+	if req.GetSrp() == nil {
+		return nil, status.Error(codes.InvalidArgument, "srp is required")
+	}
+
+	if err := h.authService.EnrollSRP(ctx, user.ID, req.GetPassword(), req.GetSrp().GetSalt(), req.GetSrp().GetVerifier()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.EnrollSRPResponse{
+		Success: true,
+	}, nil
+}
+
+// EnableTOTP начинает подключение двухфакторной аутентификации текущего пользователя
+func (h *AuthHandler) EnableTOTP(ctx context.Context, req *grpc.EnableTOTPRequest) (*grpc.EnableTOTPResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := h.authService.EnableTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.EnableTOTPResponse{
+		Secret: enrollment.Secret,
+		Uri:    enrollment.URI,
+	}, nil
+}
+
+// ConfirmTOTP включает двухфакторную аутентификацию текущего пользователя
+func (h *AuthHandler) ConfirmTOTP(ctx context.Context, req *grpc.ConfirmTOTPRequest) (*grpc.ConfirmTOTPResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	recoveryCodes, err := h.authService.ConfirmTOTP(ctx, user.ID, req.GetCode())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.ConfirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// DisableTOTP отключает двухфакторную аутентификацию текущего пользователя
+func (h *AuthHandler) DisableTOTP(ctx context.Context, req *grpc.DisableTOTPRequest) (*grpc.DisableTOTPResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateDisableTOTPRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = h.authService.DisableTOTP(ctx, user.ID, req.GetPassword(), req.GetSrpHandshakeId(), req.GetSrpProof(), req.GetCode())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.DisableTOTPResponse{
+		Success: true,
+	}, nil
+}
+
+// DeleteAccount удаляет учетную запись текущего пользователя со всеми данными. Текущий пароль
+// подтверждается так же, как при смене пароля.
+func (h *AuthHandler) DeleteAccount(ctx context.Context, req *grpc.DeleteAccountRequest) (*grpc.DeleteAccountResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateDeleteAccountRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = h.authService.DeleteAccount(ctx, user.ID, req.GetPassword(), req.GetSrpHandshakeId(), req.GetSrpProof(), req.GetTotpCode())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.DeleteAccountResponse{
+		Success: true,
+	}, nil
+}
+
+// ExportAccount передает все записи текущего пользователя: сначала сведения об учетной записи,
+// затем ключ хранилища, опись, секреты и сессии
+func (h *AuthHandler) ExportAccount(req *grpc.ExportAccountRequest, stream grpc.AuthService_ExportAccountServer) error {
+	ctx := stream.Context()
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.authService.ExportAccount(ctx, user.ID)
+	if err != nil {
+		return MapErrorToStatus(err)
+	}
+
+	for _, record := range accountRecords(export, middleware.GetSessionIDFromContext(ctx)) {
+		if err := stream.Send(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterDevice привязывает текущий вход к устройству и выдает токены новой сессии устройства
+func (h *AuthHandler) RegisterDevice(ctx context.Context, req *grpc.RegisterDeviceRequest) (*grpc.RegisterDeviceResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var registration *app.DeviceRegistration
+	if middleware.IsCertificateOnly(ctx) {
+		registration, err = h.authService.RegisterCertificateDevice(ctx, user.ID,
+			req.GetName(), req.GetDeviceId(), req.GetDeviceSecret())
+	} else {
+		registration, err = h.authService.RegisterDevice(ctx, user.ID, middleware.GetSessionIDFromContext(ctx),
+			req.GetName(), req.GetDeviceId(), req.GetDeviceSecret())
+	}
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.RegisterDeviceResponse{
+		DeviceId:     registration.Device.ID,
+		DeviceSecret: registration.Secret,
+		Approved:     registration.Device.ApprovedAt != nil,
+		AccessToken:  registration.AccessToken,
+		RefreshToken: registration.RefreshToken,
+	}, nil
+}
+
+// ListDevices возвращает устройства текущего пользователя
+func (h *AuthHandler) ListDevices(ctx context.Context, req *grpc.ListDevicesRequest) (*grpc.ListDevicesResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := h.authService.ListDevices(ctx, user.ID)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	currentID := middleware.GetDeviceIDFromContext(ctx)
+	resp := &grpc.ListDevicesResponse{
+		Devices: make([]*grpc.Device, 0, len(devices)),
+	}
+	for _, device := range devices {
+		resp.Devices = append(resp.Devices, deviceToProto(device, currentID))
+	}
+
+	return resp, nil
+}
+
+// RenameDevice меняет имя устройства текущего пользователя
+func (h *AuthHandler) RenameDevice(ctx context.Context, req *grpc.RenameDeviceRequest) (*grpc.RenameDeviceResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetDeviceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "device id is required")
+	}
+
+	if err := h.authService.RenameDevice(ctx, user.ID, req.GetDeviceId(), req.GetName()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.RenameDeviceResponse{
+		Success: true,
+	}, nil
+}
+
+// RemoveDevice удаляет устройство текущего пользователя и завершает его сессии
+func (h *AuthHandler) RemoveDevice(ctx context.Context, req *grpc.RemoveDeviceRequest) (*grpc.RemoveDeviceResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetDeviceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "device id is required")
+	}
+
+	if err := h.authService.RemoveDevice(ctx, user.ID, req.GetDeviceId()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.RemoveDeviceResponse{
+		Success: true,
+	}, nil
+}
+
+// ApproveDevice одобряет новое устройство текущего пользователя с уже одобренного устройства
+func (h *AuthHandler) ApproveDevice(ctx context.Context, req *grpc.ApproveDeviceRequest) (*grpc.ApproveDeviceResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetDeviceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "device id is required")
+	}
+
+	err = h.authService.ApproveDevice(ctx, user.ID, middleware.GetDeviceIDFromContext(ctx), req.GetDeviceId())
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.ApproveDeviceResponse{
+		Success: true,
+	}, nil
+}
+
+// CreateAccessToken выпускает токен доступа к секретам текущего пользователя для автоматизации
+func (h *AuthHandler) CreateAccessToken(ctx context.Context, req *grpc.CreateAccessTokenRequest) (*grpc.CreateAccessTokenResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := app.AccessTokenRequest{
+		Name:         req.GetName(),
+		SecretIDs:    req.GetScope().GetSecretIds(),
+		Tags:         req.GetScope().GetTags(),
+		Permission:   accessTokenPermissionFromProto(req.GetScope().GetPermission()),
+		DelegatedKey: req.GetDelegatedKey(),
+	}
+	if req.GetExpiresAt() != 0 {
+		expiresAt := time.Unix(req.GetExpiresAt(), 0)
+		params.ExpiresAt = &expiresAt
+	}
+
+	token, tokenString, err := h.authService.CreateAccessToken(ctx, user.ID, params)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.CreateAccessTokenResponse{
+		Token:       tokenString,
+		AccessToken: accessTokenToProto(token),
+	}, nil
+}
+
+// ListAccessTokens возвращает токены доступа текущего пользователя
+func (h *AuthHandler) ListAccessTokens(ctx context.Context, req *grpc.ListAccessTokensRequest) (*grpc.ListAccessTokensResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := h.authService.ListAccessTokens(ctx, user.ID)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	pbTokens := make([]*grpc.AccessToken, 0, len(tokens))
+	for _, token := range tokens {
+		pbTokens = append(pbTokens, accessTokenToProto(token))
+	}
+
+	return &grpc.ListAccessTokensResponse{
+		AccessTokens: pbTokens,
+	}, nil
+}
+
+// RevokeAccessToken отзывает токен доступа текущего пользователя
+func (h *AuthHandler) RevokeAccessToken(ctx context.Context, req *grpc.RevokeAccessTokenRequest) (*grpc.RevokeAccessTokenResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetTokenId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token id is required")
+	}
+
+	if err := h.authService.RevokeAccessToken(ctx, user.ID, req.GetTokenId()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.RevokeAccessTokenResponse{
+		Success: true,
+	}, nil
+}
@@ -250,7 +250,7 @@ func TestAuthHandler_RefreshToken(t *testing.T) {
 			wantError: false,
 			setupMock: func() *pb.RefreshTokenRequest {
 				mockUserRepo.Users[user.ID] = user
-				token, _ := mockJWTManager.GenerateRefreshToken(user.ID)
+				token, _ := mockJWTManager.GenerateRefreshToken(user.ID, "", "")
 				return &pb.RefreshTokenRequest{RefreshToken: token}
 			},
 			description: "valid refresh token with existing user",
@@ -280,7 +280,7 @@ func TestAuthHandler_RefreshToken(t *testing.T) {
 			wantError: true,
 			errorCode: codes.NotFound,
 			setupMock: func() *pb.RefreshTokenRequest {
-				token, _ := mockJWTManager.GenerateRefreshToken("non-existent-user")
+				token, _ := mockJWTManager.GenerateRefreshToken("non-existent-user", "", "")
 				mockJWTManager.Tokens[token] = &crypto.TokenClaims{
 					UserID: "non-existent-user",
 					Type:   "refresh",
@@ -347,3 +347,26 @@ func TestAuthHandler_RefreshToken(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_GetJWKS(t *testing.T) {
+	signingKey, err := crypto.GenerateJWTSigningKey()
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	jwtManager := crypto.NewJWTManager(&crypto.JWTConfig{SigningKey: signingKey})
+	authService := app.NewAuthService(mocks.NewMockUserRepository(), jwtManager)
+	handler := handlers.NewAuthHandler(authService)
+
+	resp, err := handler.GetJWKS(context.Background(), &pb.GetJWKSRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(resp.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(resp.Keys))
+	}
+	key := resp.Keys[0]
+	if key.Kid != signingKey.ID || key.Kty != "OKP" || key.Crv != "Ed25519" || key.Alg != "EdDSA" || key.X == "" {
+		t.Errorf("Unexpected JWK: %v", key)
+	}
+}
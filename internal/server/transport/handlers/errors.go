@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
 
+	"github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 )
 
+// errorDomain домен причин ошибок в google.rpc.ErrorInfo
+const errorDomain = "gophkeeper"
+
 // MapErrorToStatus преобразует доменные ошибки в gRPC статусы
 func MapErrorToStatus(err error) error {
 	switch err {
@@ -15,7 +22,7 @@ func MapErrorToStatus(err error) error {
 	case domain.ErrUserNotFound:
 		return status.Error(codes.NotFound, "user not found")
 	case domain.ErrInvalidCredentials:
-		return status.Error(codes.Unauthenticated, "invalid credentials")
+		return statusWithReason(codes.Unauthenticated, "invalid credentials", grpc.ErrorReason_INVALID_CREDENTIALS)
 	case domain.ErrSecretNotFound:
 		return status.Error(codes.NotFound, "secret not found")
 	case domain.ErrVersionConflict:
@@ -26,16 +33,66 @@ func MapErrorToStatus(err error) error {
 		return status.Error(codes.PermissionDenied, "access denied")
 	case domain.ErrInvalidToken:
 		return status.Error(codes.Unauthenticated, "invalid token")
+	case domain.ErrInvalidCertificate:
+		return status.Error(codes.Unauthenticated, "invalid client certificate")
 	case domain.ErrTokenExpired:
 		return status.Error(codes.Unauthenticated, "token expired")
+	case domain.ErrRefreshTokenReused:
+		return status.Error(codes.Unauthenticated, "refresh token reused, session revoked")
 	case domain.ErrSecretAlreadyExists:
 		return status.Error(codes.AlreadyExists, "secret already exists")
 	case domain.ErrInvalidSecret:
 		return status.Error(codes.InvalidArgument, "invalid secret")
+	case domain.ErrVaultKeyNotFound:
+		return status.Error(codes.NotFound, "vault key not found")
+	case domain.ErrVaultKeyExists:
+		return status.Error(codes.AlreadyExists, "vault key already exists, change the password or rotate the key to replace it")
+	case domain.ErrManifestConflict:
+		return status.Error(codes.Aborted, "vault manifest version conflict")
+	case domain.ErrSessionNotFound:
+		return status.Error(codes.NotFound, "session not found")
+	case domain.ErrLegacyAuthDisabled:
+		return status.Error(codes.FailedPrecondition, "password authentication is disabled, use srp")
+	case domain.ErrTOTPRequired:
+		return statusWithReason(codes.Unauthenticated, "two-factor code required", grpc.ErrorReason_TOTP_REQUIRED)
+	case domain.ErrInvalidTOTPCode:
+		return status.Error(codes.Unauthenticated, "invalid two-factor code")
+	case domain.ErrTOTPNotEnabled:
+		return status.Error(codes.FailedPrecondition, "two-factor authentication is not enabled")
+	case domain.ErrTOTPAlreadyEnabled:
+		return status.Error(codes.AlreadyExists, "two-factor authentication is already enabled")
+	case domain.ErrTOTPUnavailable:
+		return status.Error(codes.FailedPrecondition, "two-factor authentication requires the server encryption key")
+	case domain.ErrDeviceNotFound:
+		return status.Error(codes.NotFound, "device not found")
+	case domain.ErrDeviceNotApproved:
+		return statusWithReason(codes.FailedPrecondition, "device is not approved", grpc.ErrorReason_DEVICE_NOT_APPROVED)
+	case domain.ErrAccessTokenNotFound:
+		return status.Error(codes.NotFound, "access token not found")
+	case domain.ErrAccountDisabled:
+		return statusWithReason(codes.PermissionDenied, "account is disabled", grpc.ErrorReason_ACCOUNT_DISABLED)
 	}
 	if ve, ok := err.(domain.ValidationError); ok {
 		return status.Error(codes.InvalidArgument, ve.Error())
 	}
+	if le, ok := err.(domain.LoginLockedError); ok {
+		return statusWithReason(codes.ResourceExhausted, "too many failed login attempts", grpc.ErrorReason_LOGIN_LOCKED,
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(le.RetryAfter)})
+	}
 
 	return status.Error(codes.Internal, "internal server error")
 }
+
+// statusWithReason добавляет к статусу причину ошибки, по которой клиент отличает ее
+// от других ошибок с тем же кодом, и дополнительные подробности
+func statusWithReason(code codes.Code, message string, reason grpc.ErrorReason, details ...protoadapt.MessageV1) error {
+	info := &errdetails.ErrorInfo{
+		Reason: reason.String(),
+		Domain: errorDomain,
+	}
+	st, err := status.New(code, message).WithDetails(append([]protoadapt.MessageV1{info}, details...)...)
+	if err != nil {
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
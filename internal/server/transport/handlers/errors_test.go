@@ -3,10 +3,15 @@ package handlers_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 	"github.com/alisaviation/GophKeeper/internal/server/transport/handlers"
 )
@@ -72,6 +77,21 @@ func TestMapErrorToStatus(t *testing.T) {
 			err:          domain.ErrInvalidSecret,
 			expectedCode: codes.InvalidArgument,
 		},
+		{
+			name:         "vault key not found",
+			err:          domain.ErrVaultKeyNotFound,
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "two-factor code required",
+			err:          domain.ErrTOTPRequired,
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "two-factor authentication unavailable",
+			err:          domain.ErrTOTPUnavailable,
+			expectedCode: codes.FailedPrecondition,
+		},
 		{
 			name:         "validation error",
 			err:          domain.ValidationError{Field: "login", Message: "invalid format"},
@@ -104,3 +124,29 @@ func TestMapErrorToStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestMapErrorToStatus_Reason(t *testing.T) {
+	st := status.Convert(handlers.MapErrorToStatus(domain.ErrTOTPRequired))
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, grpc.ErrorReason_TOTP_REQUIRED.String(), info.GetReason())
+
+	st = status.Convert(handlers.MapErrorToStatus(domain.ErrInvalidTOTPCode))
+	assert.Equal(t, codes.Unauthenticated, st.Code())
+	assert.Empty(t, st.Details())
+}
+
+func TestMapErrorToStatus_LoginLocked(t *testing.T) {
+	st := status.Convert(handlers.MapErrorToStatus(domain.LoginLockedError{RetryAfter: 30 * time.Second}))
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 2)
+
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, grpc.ErrorReason_LOGIN_LOCKED.String(), info.GetReason())
+
+	retry, ok := st.Details()[1].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, 30*time.Second, retry.GetRetryDelay().AsDuration())
+}
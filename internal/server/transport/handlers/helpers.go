@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"math"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 	"github.com/alisaviation/GophKeeper/internal/generated/grpc"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 )
 
@@ -29,3 +33,245 @@ func validateLoginRequest(req *grpc.LoginRequest) error {
 	}
 	return nil
 }
+
+// validateChangePasswordRequest валидирует запрос смены пароля
+func validateChangePasswordRequest(req *grpc.ChangePasswordRequest) error {
+	if req.GetSrpHandshakeId() != "" {
+		if len(req.GetSrpProof()) == 0 {
+			return domain.ValidationError{Field: "srp_proof", Message: "is required"}
+		}
+		if req.GetNewSrp() == nil {
+			return domain.ValidationError{Field: "new_srp", Message: "is required"}
+		}
+	} else {
+		if req.GetOldPassword() == "" {
+			return domain.ValidationError{Field: "old_password", Message: "is required"}
+		}
+		if req.GetNewPassword() == "" {
+			return domain.ValidationError{Field: "new_password", Message: "is required"}
+		}
+	}
+	if len(req.GetProtectedVaultKey()) == 0 {
+		return domain.ValidationError{Field: "protected_vault_key", Message: "is required"}
+	}
+	return nil
+}
+
+// validateDeleteAccountRequest валидирует запрос удаления учетной записи
+func validateDeleteAccountRequest(req *grpc.DeleteAccountRequest) error {
+	return validatePasswordConfirmation(req.GetPassword(), req.GetSrpHandshakeId(), req.GetSrpProof())
+}
+
+// validateDisableTOTPRequest валидирует запрос отключения 2FA
+func validateDisableTOTPRequest(req *grpc.DisableTOTPRequest) error {
+	return validatePasswordConfirmation(req.GetPassword(), req.GetSrpHandshakeId(), req.GetSrpProof())
+}
+
+// validatePasswordConfirmation проверяет, что текущий пароль подтвержден обменом SRP или самим паролем
+func validatePasswordConfirmation(password, handshakeID string, proof []byte) error {
+	if handshakeID != "" {
+		if len(proof) == 0 {
+			return domain.ValidationError{Field: "srp_proof", Message: "is required"}
+		}
+	} else if password == "" {
+		return domain.ValidationError{Field: "password", Message: "is required"}
+	}
+	return nil
+}
+
+// validateSRPRegisterRequest валидирует запрос регистрации по SRP
+func validateSRPRegisterRequest(req *grpc.SRPRegisterRequest) error {
+	if req.GetLogin() == "" {
+		return domain.ValidationError{Field: "login", Message: "is required"}
+	}
+	if req.GetKdf() == nil {
+		return domain.ValidationError{Field: "kdf", Message: "is required"}
+	}
+	if req.GetSrp() == nil {
+		return domain.ValidationError{Field: "srp", Message: "is required"}
+	}
+	return nil
+}
+
+// kdfParamsFromProto преобразует параметры KDF из protobuf сообщения
+func kdfParamsFromProto(kdf *grpc.KDFParams) (crypto.KDFParams, error) {
+	if kdf.GetParallelism() > math.MaxUint8 {
+		return crypto.KDFParams{}, domain.ValidationError{Field: "kdf", Message: "parallelism is too large"}
+	}
+
+	return crypto.KDFParams{
+		Algorithm:   kdf.GetAlgorithm(),
+		Salt:        kdf.GetSalt(),
+		Iterations:  kdf.GetIterations(),
+		Memory:      kdf.GetMemory(),
+		Parallelism: uint8(kdf.GetParallelism()),
+	}, nil
+}
+
+// kdfParamsToProto преобразует параметры KDF в protobuf сообщение
+func kdfParamsToProto(params crypto.KDFParams) *grpc.KDFParams {
+	return &grpc.KDFParams{
+		Algorithm:   params.Algorithm,
+		Salt:        params.Salt,
+		Iterations:  params.Iterations,
+		Memory:      params.Memory,
+		Parallelism: uint32(params.Parallelism),
+	}
+}
+
+// jwkToProto преобразует открытый ключ в proto-сообщение
+func jwkToProto(key crypto.JWK) *grpc.JWK {
+	return &grpc.JWK{
+		Kty: key.KeyType,
+		Kid: key.KeyID,
+		Alg: key.Algorithm,
+		Use: key.Use,
+		Crv: key.Curve,
+		X:   key.X,
+		N:   key.N,
+		E:   key.E,
+	}
+}
+
+// sessionToProto преобразует сессию в proto-сообщение
+func sessionToProto(session *domain.Session, currentID string) *grpc.Session {
+	return &grpc.Session{
+		Id:            session.ID,
+		DeviceId:      session.DeviceID,
+		DeviceName:    session.DeviceName,
+		ClientVersion: session.ClientVersion,
+		IpAddress:     session.IPAddress,
+		CreatedAt:     session.CreatedAt.Unix(),
+		LastUsedAt:    session.LastUsedAt.Unix(),
+		Current:       session.ID == currentID,
+	}
+}
+
+// deviceToProto преобразует устройство в proto-сообщение
+func deviceToProto(device *domain.Device, currentID string) *grpc.Device {
+	return &grpc.Device{
+		Id:            device.ID,
+		Name:          device.Name,
+		ClientVersion: device.ClientVersion,
+		Approved:      device.ApprovedAt != nil,
+		CreatedAt:     device.CreatedAt.Unix(),
+		LastSeenAt:    device.LastSeenAt.Unix(),
+		Current:       device.ID == currentID,
+	}
+}
+
+// accessTokenToProto преобразует токен доступа в proto-сообщение
+func accessTokenToProto(token *domain.AccessToken) *grpc.AccessToken {
+	pbToken := &grpc.AccessToken{
+		Id:   token.ID,
+		Name: token.Name,
+		Scope: &grpc.AccessTokenScope{
+			SecretIds:  token.SecretIDs,
+			Tags:       token.Tags,
+			Permission: accessTokenPermissionToProto(token.Permission),
+		},
+		CreatedAt: token.CreatedAt.Unix(),
+	}
+	if token.ExpiresAt != nil {
+		pbToken.ExpiresAt = token.ExpiresAt.Unix()
+	}
+	if token.LastUsedAt != nil {
+		pbToken.LastUsedAt = token.LastUsedAt.Unix()
+	}
+	return pbToken
+}
+
+func accessTokenPermissionToProto(permission domain.AccessTokenPermission) grpc.AccessTokenPermission {
+	switch permission {
+	case domain.AccessTokenRead:
+		return grpc.AccessTokenPermission_ACCESS_TOKEN_READ
+	case domain.AccessTokenReadWrite:
+		return grpc.AccessTokenPermission_ACCESS_TOKEN_READ_WRITE
+	default:
+		return grpc.AccessTokenPermission_ACCESS_TOKEN_PERMISSION_UNSPECIFIED
+	}
+}
+
+// accessTokenPermissionFromProto возвращает права токена; неизвестные права остаются пустыми
+// и отклоняются при проверке запроса
+func accessTokenPermissionFromProto(permission grpc.AccessTokenPermission) domain.AccessTokenPermission {
+	switch permission {
+	case grpc.AccessTokenPermission_ACCESS_TOKEN_READ:
+		return domain.AccessTokenRead
+	case grpc.AccessTokenPermission_ACCESS_TOKEN_READ_WRITE:
+		return domain.AccessTokenReadWrite
+	default:
+		return ""
+	}
+}
+
+// adminUserToProto преобразует пользователя в proto-сообщение для администратора
+func adminUserToProto(user *domain.User) *grpc.AdminUser {
+	pbUser := &grpc.AdminUser{
+		Id:        user.ID,
+		Login:     user.Login,
+		Admin:     user.IsAdmin(),
+		CreatedAt: user.CreatedAt.Unix(),
+	}
+	if user.DisabledAt != nil {
+		pbUser.DisabledAt = user.DisabledAt.Unix()
+	}
+	return pbUser
+}
+
+// maintenanceJobFromProto возвращает задачу обслуживания; false для неизвестной задачи
+func maintenanceJobFromProto(job grpc.MaintenanceJob) (app.MaintenanceJob, bool) {
+	switch job {
+	case grpc.MaintenanceJob_MAINTENANCE_PURGE_SESSIONS:
+		return app.MaintenancePurgeSessions, true
+	case grpc.MaintenanceJob_MAINTENANCE_PURGE_LOGIN_ATTEMPTS:
+		return app.MaintenancePurgeLoginAttempts, true
+	case grpc.MaintenanceJob_MAINTENANCE_PURGE_ACCESS_TOKENS:
+		return app.MaintenancePurgeAccessTokens, true
+	default:
+		return "", false
+	}
+}
+
+// accountRecords преобразует выгрузку учетной записи в последовательность proto-сообщений
+func accountRecords(export *app.AccountExport, currentSessionID string) []*grpc.AccountRecord {
+	user := export.User
+	records := []*grpc.AccountRecord{{
+		Record: &grpc.AccountRecord_Account{Account: &grpc.AccountInfo{
+			UserId:            user.ID,
+			Login:             user.Login,
+			CreatedAt:         user.CreatedAt.Unix(),
+			PasswordChangedAt: user.PasswordChangedAt.Unix(),
+			Kdf:               kdfParamsToProto(export.KDF),
+			SrpEnabled:        len(user.SRPVerifier) > 0,
+			TotpEnabled:       export.TOTPEnabled,
+		}},
+	}}
+
+	if export.ProtectedVaultKey != nil {
+		records = append(records, &grpc.AccountRecord{
+			Record: &grpc.AccountRecord_ProtectedVaultKey{ProtectedVaultKey: export.ProtectedVaultKey},
+		})
+	}
+	if manifest := export.Manifest; manifest != nil {
+		records = append(records, &grpc.AccountRecord{
+			Record: &grpc.AccountRecord_Manifest{Manifest: &grpc.ExportedManifest{
+				Manifest:  manifest.Data,
+				Version:   manifest.Version,
+				UpdatedAt: manifest.UpdatedAt.Unix(),
+			}},
+		})
+	}
+	for _, secret := range export.Secrets {
+		records = append(records, &grpc.AccountRecord{
+			Record: &grpc.AccountRecord_Secret{Secret: secret.ToProto()},
+		})
+	}
+	for _, session := range export.Sessions {
+		records = append(records, &grpc.AccountRecord{
+			Record: &grpc.AccountRecord_Session{Session: sessionToProto(session, currentSessionID)},
+		})
+	}
+
+	return records
+}
@@ -37,7 +37,16 @@ func (h *SecretHandler) Sync(ctx context.Context, req *grpc.SyncRequest) (*grpc.
 		clientSecrets = append(clientSecrets, domain.SecretFromProto(pbSecret))
 	}
 
-	result, err := h.dataService.Sync(ctx, user.ID, clientSecrets, req.GetLastSyncVersion())
+	if len(req.GetProtectedVaultKey()) > 0 && !req.GetAtomic() {
+		return nil, status.Error(codes.InvalidArgument, "protected_vault_key requires atomic sync")
+	}
+
+	var result *app.SyncResult
+	if req.GetAtomic() {
+		result, err = h.dataService.SyncAtomic(ctx, user.ID, clientSecrets, req.GetLastSyncVersion(), req.GetProtectedVaultKey())
+	} else {
+		result, err = h.dataService.Sync(ctx, user.ID, clientSecrets, req.GetLastSyncVersion())
+	}
 	if err != nil {
 		return nil, MapErrorToStatus(err)
 	}
@@ -48,9 +57,10 @@ func (h *SecretHandler) Sync(ctx context.Context, req *grpc.SyncRequest) (*grpc.
 	}
 
 	return &grpc.SyncResponse{
-		CurrentVersion: result.CurrentVersion,
-		Secrets:        serverSecrets,
-		Conflicts:      result.Conflicts,
+		CurrentVersion:      result.CurrentVersion,
+		Secrets:             serverSecrets,
+		Conflicts:           result.Conflicts,
+		RevokedAccessTokens: int32(result.RevokedAccessTokens),
 	}, nil
 }
 
@@ -150,3 +160,37 @@ func (h *SecretHandler) DeleteSecret(ctx context.Context, req *grpc.DeleteSecret
 		Success: true,
 	}, nil
 }
+
+// GetVaultManifest возвращает опись хранилища текущего пользователя
+func (h *SecretHandler) GetVaultManifest(ctx context.Context, req *grpc.GetVaultManifestRequest) (*grpc.GetVaultManifestResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, version, err := h.dataService.GetVaultManifest(ctx, user.ID)
+	if err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.GetVaultManifestResponse{
+		Manifest: manifest,
+		Version:  version,
+	}, nil
+}
+
+// PutVaultManifest сохраняет опись хранилища текущего пользователя
+func (h *SecretHandler) PutVaultManifest(ctx context.Context, req *grpc.PutVaultManifestRequest) (*grpc.PutVaultManifestResponse, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.dataService.PutVaultManifest(ctx, user.ID, req.GetManifest(), req.GetVersion()); err != nil {
+		return nil, MapErrorToStatus(err)
+	}
+
+	return &grpc.PutVaultManifestResponse{
+		Success: true,
+	}, nil
+}
@@ -23,8 +23,7 @@ func testContextWithUser(user *domain.User) context.Context {
 
 func TestSecretHandler_Sync(t *testing.T) {
 	mockSecretRepo := mocks.NewMockSecretRepository()
-	mockEncryptor := &mocks.MockEncryptor{}
-	dataService := app.NewDataService(mockSecretRepo, mockEncryptor)
+	dataService := app.NewDataService(mockSecretRepo)
 	handler := handlers.NewSecretHandler(dataService)
 
 	user := &domain.User{
@@ -194,8 +193,7 @@ func contains(slice []string, item string) bool {
 
 func TestSecretHandler_GetSecret(t *testing.T) {
 	mockSecretRepo := mocks.NewMockSecretRepository()
-	mockEncryptor := &mocks.MockEncryptor{}
-	dataService := app.NewDataService(mockSecretRepo, mockEncryptor)
+	dataService := app.NewDataService(mockSecretRepo)
 	handler := handlers.NewSecretHandler(dataService)
 
 	user := &domain.User{
@@ -298,8 +296,7 @@ func TestSecretHandler_GetSecret(t *testing.T) {
 
 func TestSecretHandler_ListSecrets(t *testing.T) {
 	mockSecretRepo := mocks.NewMockSecretRepository()
-	mockEncryptor := &mocks.MockEncryptor{}
-	dataService := app.NewDataService(mockSecretRepo, mockEncryptor)
+	dataService := app.NewDataService(mockSecretRepo)
 	handler := handlers.NewSecretHandler(dataService)
 
 	user := &domain.User{
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+)
+
+// accessTokenMethods методы, доступные по токену доступа. Опись хранилища описывает все
+// секреты пользователя, поэтому токену, видящему только часть из них, она недоступна.
+var accessTokenMethods = map[string]bool{
+	"/gophkeeper.v1.SecretService/Sync":         true,
+	"/gophkeeper.v1.SecretService/GetSecret":    true,
+	"/gophkeeper.v1.SecretService/ListSecrets":  true,
+	"/gophkeeper.v1.SecretService/UpdateSecret": true,
+	"/gophkeeper.v1.SecretService/DeleteSecret": true,
+	// Токену выдается делегированный ключ вместо ключа, защищенного мастер-паролем
+	"/gophkeeper.v1.AuthService/GetProtectedVaultKey": true,
+}
+
+// AccessTokenContextKey ключ для хранения токена доступа в контексте
+type AccessTokenContextKey struct{}
+
+// GetAccessTokenFromContext извлекает токен доступа, которым аутентифицирован запрос,
+// или nil, если запрос выполнен с access-токеном сессии
+func GetAccessTokenFromContext(ctx context.Context) *domain.AccessToken {
+	token, _ := ctx.Value(AccessTokenContextKey{}).(*domain.AccessToken)
+	return token
+}
+
+// checkAccessTokenScope проверяет, что запрос затрагивает только секреты из области токена
+// и изменяет их, только если токену разрешена запись
+func checkAccessTokenScope(token *domain.AccessToken, req interface{}) error {
+	switch r := req.(type) {
+	case *pb.GetSecretRequest:
+		return checkSecretInScope(token, r.GetSecretId(), false)
+	case *pb.UpdateSecretRequest:
+		return checkSecretInScope(token, r.GetSecret().GetId(), true)
+	case *pb.DeleteSecretRequest:
+		return checkSecretInScope(token, r.GetSecretId(), true)
+	case *pb.SyncRequest:
+		// Ключ хранилища меняет только его владелец
+		if len(r.GetProtectedVaultKey()) > 0 {
+			return status.Error(codes.PermissionDenied, "access token cannot rotate the vault key")
+		}
+		for _, secret := range r.GetSecrets() {
+			if err := checkSecretInScope(token, secret.GetId(), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSecretInScope проверяет доступ токена к одному секрету
+func checkSecretInScope(token *domain.AccessToken, secretID string, write bool) error {
+	if !token.Covers(secretID) {
+		// Так же, как для несуществующего секрета: токен не должен узнавать о чужих секретах
+		return status.Error(codes.NotFound, "secret not found")
+	}
+	if write && !token.CanWrite() {
+		return status.Error(codes.PermissionDenied, "access token is read-only")
+	}
+	return nil
+}
+
+// filterAccessTokenScope убирает из ответа секреты вне области токена
+func filterAccessTokenScope(token *domain.AccessToken, resp interface{}) interface{} {
+	switch r := resp.(type) {
+	case *pb.ListSecretsResponse:
+		r.Secrets = secretsInScope(token, r.GetSecrets())
+	case *pb.SyncResponse:
+		r.Secrets = secretsInScope(token, r.GetSecrets())
+	}
+	return resp
+}
+
+func secretsInScope(token *domain.AccessToken, secrets []*pb.Secret) []*pb.Secret {
+	result := make([]*pb.Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		if token.Covers(secret.GetId()) {
+			result = append(result, secret)
+		}
+	}
+	return result
+}
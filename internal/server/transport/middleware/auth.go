@@ -2,13 +2,18 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"strings"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 )
@@ -16,13 +21,21 @@ import (
 // AuthInterceptor перехватчик для аутентификации
 type AuthInterceptor struct {
 	authService *app.AuthService
+	mode        AuthMode
+	revocations *crypto.RevocationList
 }
 
-// NewAuthInterceptor создает новый перехватчик аутентификации
-func NewAuthInterceptor(authService *app.AuthService) *AuthInterceptor {
-	return &AuthInterceptor{
+// NewAuthInterceptor создает новый перехватчик аутентификации. По умолчанию клиенты
+// аутентифицируются только bearer-токеном.
+func NewAuthInterceptor(authService *app.AuthService, opts ...AuthInterceptorOption) *AuthInterceptor {
+	i := &AuthInterceptor{
 		authService: authService,
+		mode:        AuthModeJWT,
 	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
 }
 
 // Unary возвращает unary interceptor для аутентификации
@@ -33,49 +46,246 @@ func (i *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		if isPublicMethod(info.FullMethod) {
+		ctx, err := i.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		token := GetAccessTokenFromContext(ctx)
+		if token == nil {
 			return handler(ctx, req)
 		}
 
-		user, err := i.authenticate(ctx)
+		if err := checkAccessTokenScope(token, req); err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return filterAccessTokenScope(token, resp), nil
+	}
+}
+
+// Stream возвращает stream interceptor для аутентификации
+func (i *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := i.authorize(ss.Context(), info.FullMethod)
 		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, "authentication required")
+			return err
 		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorize дополняет контекст сведениями о клиенте и, если метод не публичный,
+// аутентифицированным пользователем и его сессией или токеном доступа. Токену доступа
+// открыты только методы работы с секретами; их область проверяет Unary.
+func (i *AuthInterceptor) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	ctx = app.ContextWithClientInfo(ctx, clientInfoFromContext(ctx))
+
+	if isPublicMethod(fullMethod) {
+		return ctx, nil
+	}
+
+	principal, err := i.authenticate(ctx)
+	if err == domain.ErrAccountDisabled {
+		return nil, errorWithReason(codes.PermissionDenied, "account is disabled", pb.ErrorReason_ACCOUNT_DISABLED)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	if principal.AccessToken != nil && !accessTokenMethods[fullMethod] {
+		return nil, status.Error(codes.PermissionDenied, "method is not available to access tokens")
+	}
 
-		ctx = context.WithValue(ctx, UserContextKey{}, user)
-		return handler(ctx, req)
+	if principal.CertificateOnly && !certificateMethods(fullMethod) {
+		return nil, status.Error(codes.PermissionDenied, "method requires a session, authenticate with a bearer token")
 	}
+
+	if requiresAdmin(fullMethod) && !principal.User.IsAdmin() {
+		return nil, status.Error(codes.PermissionDenied, "admin role required")
+	}
+
+	if !principal.DeviceTrusted && requiresTrustedDevice(fullMethod) {
+		return nil, deviceNotApprovedError()
+	}
+
+	ctx = context.WithValue(ctx, UserContextKey{}, principal.User)
+	ctx = context.WithValue(ctx, SessionContextKey{}, principal.SessionID)
+	ctx = context.WithValue(ctx, DeviceContextKey{}, principal.DeviceID)
+	if principal.AccessToken != nil {
+		ctx = context.WithValue(ctx, AccessTokenContextKey{}, principal.AccessToken)
+	}
+	if principal.CertificateOnly {
+		ctx = context.WithValue(ctx, CertificateOnlyContextKey{}, true)
+	}
+	return ctx, nil
+}
+
+// trustedDeviceMethods методы AuthService, доступные только одобренным устройствам. Токен доступа
+// содержит ключ хранилища, поэтому выпускать его тоже может только одобренное устройство. Неодобренное
+// устройство не может и удалить доверенные устройства, завершить их сессии или сменить пароль
+// и ключ хранилища: иначе пароля хватило бы, чтобы обойти одобрение.
+var trustedDeviceMethods = map[string]bool{
+	"/gophkeeper.v1.AuthService/ExportAccount":        true,
+	"/gophkeeper.v1.AuthService/CreateAccessToken":    true,
+	"/gophkeeper.v1.AuthService/RemoveDevice":         true,
+	"/gophkeeper.v1.AuthService/RevokeSession":        true,
+	"/gophkeeper.v1.AuthService/ChangePassword":       true,
+	"/gophkeeper.v1.AuthService/SetProtectedVaultKey": true,
+	"/gophkeeper.v1.AuthService/DeleteAccount":        true,
+}
+
+// requiresTrustedDevice сообщает, что метод доступен только одобренным устройствам: он отдает
+// секреты или меняет учетную запись
+func requiresTrustedDevice(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/gophkeeper.v1.SecretService/") || trustedDeviceMethods[fullMethod]
 }
 
-// authenticate извлекает и проверяет JWT токен
-func (i *AuthInterceptor) authenticate(ctx context.Context) (*domain.User, error) {
+// requiresAdmin сообщает, что метод доступен только администраторам
+func requiresAdmin(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/gophkeeper.v1.AdminService/")
+}
+
+// deviceNotApprovedError статус отказа устройству, которое еще не одобрено
+func deviceNotApprovedError() error {
+	return errorWithReason(codes.FailedPrecondition, "device is not approved", pb.ErrorReason_DEVICE_NOT_APPROVED)
+}
+
+// errorWithReason статус с причиной ошибки, по которой клиент отличает ее от других ошибок с тем же кодом
+func errorWithReason(code codes.Code, msg string, reason pb.ErrorReason) error {
+	st, err := status.New(code, msg).WithDetails(
+		&errdetails.ErrorInfo{Reason: reason.String(), Domain: "gophkeeper"},
+	)
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// contextStream серверный поток с подмененным контекстом
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context возвращает контекст, дополненный перехватчиком
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate аутентифицирует клиента способом, заданным режимом перехватчика
+func (i *AuthInterceptor) authenticate(ctx context.Context) (*app.Principal, error) {
+	switch i.mode {
+	case AuthModeMTLS:
+		return i.authenticateCertificate(ctx)
+	case AuthModeEither:
+		if bearerToken(ctx) == "" {
+			return i.authenticateCertificate(ctx)
+		}
+		return i.authenticateToken(ctx)
+	case AuthModeBoth:
+		certPrincipal, err := i.authenticateCertificate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		principal, err := i.authenticateToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if principal.User.ID != certPrincipal.User.ID {
+			return nil, domain.ErrInvalidCertificate
+		}
+		return principal, nil
+	default:
+		return i.authenticateToken(ctx)
+	}
+}
+
+// authenticateToken извлекает и проверяет JWT или токен доступа
+func (i *AuthInterceptor) authenticateToken(ctx context.Context) (*app.Principal, error) {
+	token := bearerToken(ctx)
+	if token == "" {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return i.authService.Authenticate(ctx, token)
+}
+
+// bearerToken возвращает токен из заголовка authorization или пустую строку
+func bearerToken(ctx context.Context) string {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return nil, domain.ErrInvalidToken
+		return ""
 	}
 
 	authHeaders := md["authorization"]
 	if len(authHeaders) == 0 {
-		return nil, domain.ErrInvalidToken
+		return ""
 	}
 
-	token := strings.TrimPrefix(authHeaders[0], "Bearer ")
-	if token == "" {
-		return nil, domain.ErrInvalidToken
+	return strings.TrimPrefix(authHeaders[0], "Bearer ")
+}
+
+// Заголовки, в которых клиент сообщает о себе
+const (
+	DeviceNameHeader    = "x-device-name"
+	ClientVersionHeader = "x-client-version"
+)
+
+// clientInfoFromContext собирает сведения об устройстве из заголовков и адреса соединения
+func clientInfoFromContext(ctx context.Context) app.ClientInfo {
+	var info app.ClientInfo
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		info.DeviceName = firstHeader(md, DeviceNameHeader)
+		info.ClientVersion = firstHeader(md, ClientVersionHeader)
 	}
 
-	user, err := i.authService.ValidateToken(ctx, token)
-	if err != nil {
-		return nil, err
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.IPAddress = p.Addr.String()
+		if host, _, err := net.SplitHostPort(info.IPAddress); err == nil {
+			info.IPAddress = host
+		}
 	}
 
-	return user, nil
+	return info
+}
+
+// maxClientHeaderLength ограничивает длину сведений об устройстве, присланных клиентом
+const maxClientHeaderLength = 64
+
+func firstHeader(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	value := strings.TrimSpace(values[0])
+	if len(value) > maxClientHeaderLength {
+		value = value[:maxClientHeaderLength]
+	}
+	return value
 }
 
 func isPublicMethod(fullMethod string) bool {
 	publicMethods := map[string]bool{
-		"/gophkeeper.v1.AuthService/Register": true,
-		"/gophkeeper.v1.AuthService/Login":    true,
+		"/gophkeeper.v1.AuthService/Register":    true,
+		"/gophkeeper.v1.AuthService/Login":       true,
+		"/gophkeeper.v1.AuthService/SRPRegister": true,
+		"/gophkeeper.v1.AuthService/SRPStart":    true,
+		"/gophkeeper.v1.AuthService/SRPLogin":    true,
+		"/gophkeeper.v1.AuthService/GetJWKS":     true,
+		// Refresh-токен сам подтверждает сессию, а access-токен к этому моменту может истечь
+		"/gophkeeper.v1.AuthService/RefreshToken": true,
+		"/gophkeeper.v1.AuthService/Logout":       true,
 	}
 
 	return publicMethods[fullMethod]
@@ -84,6 +294,15 @@ func isPublicMethod(fullMethod string) bool {
 // UserContextKey ключ для хранения пользователя в контексте
 type UserContextKey struct{}
 
+// SessionContextKey ключ для хранения ID сессии в контексте
+type SessionContextKey struct{}
+
+// DeviceContextKey ключ для хранения ID устройства в контексте
+type DeviceContextKey struct{}
+
+// CertificateOnlyContextKey ключ признака входа только клиентским сертификатом в контексте
+type CertificateOnlyContextKey struct{}
+
 // GetUserFromContext извлекает пользователя из контекста
 func GetUserFromContext(ctx context.Context) (*domain.User, error) {
 	user, ok := ctx.Value(UserContextKey{}).(*domain.User)
@@ -92,3 +311,21 @@ func GetUserFromContext(ctx context.Context) (*domain.User, error) {
 	}
 	return user, nil
 }
+
+// GetSessionIDFromContext извлекает ID сессии текущего запроса из контекста
+func GetSessionIDFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(SessionContextKey{}).(string)
+	return sessionID
+}
+
+// GetDeviceIDFromContext извлекает ID устройства текущего запроса из контекста
+func GetDeviceIDFromContext(ctx context.Context) string {
+	deviceID, _ := ctx.Value(DeviceContextKey{}).(string)
+	return deviceID
+}
+
+// IsCertificateOnly сообщает, что запрос аутентифицирован только клиентским сертификатом, без сессии
+func IsCertificateOnly(ctx context.Context) bool {
+	certificateOnly, _ := ctx.Value(CertificateOnlyContextKey{}).(bool)
+	return certificateOnly
+}
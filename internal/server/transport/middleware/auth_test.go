@@ -2,16 +2,20 @@ package middleware_test
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/domain"
 	"github.com/alisaviation/GophKeeper/internal/server/mocks"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
 	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
 )
 
@@ -61,7 +65,7 @@ func TestAuthInterceptor_Unary(t *testing.T) {
 			token:      "valid_token",
 			wantError:  false,
 			setupContext: func(ctx context.Context) context.Context {
-				token, _ := mockJWTManager.GenerateAccessToken(user.ID, user.Login)
+				token, _ := mockJWTManager.GenerateAccessToken(user.ID, user.Login, "", "")
 				md := metadata.Pairs("authorization", "Bearer "+token)
 				return metadata.NewIncomingContext(ctx, md)
 			},
@@ -135,6 +139,359 @@ func TestAuthInterceptor_Unary(t *testing.T) {
 	}
 }
 
+// serverStream поток с заданным контекстом для проверки stream interceptor
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthInterceptor_Stream(t *testing.T) {
+	mockUserRepo := mocks.NewMockUserRepository()
+	mockJWTManager := mocks.NewMockJWTManager()
+	authService := app.NewAuthService(mockUserRepo, mockJWTManager)
+	interceptor := middleware.NewAuthInterceptor(authService)
+
+	user := &domain.User{
+		ID:    "test-user-id",
+		Login: "testuser",
+	}
+	mockUserRepo.Users[user.ID] = user
+	mockUserRepo.Users[user.Login] = user
+
+	info := &grpc.StreamServerInfo{FullMethod: "/gophkeeper.v1.AuthService/ExportAccount", IsServerStream: true}
+
+	t.Run("without token", func(t *testing.T) {
+		called := false
+		err := interceptor.Stream()(nil, &serverStream{ctx: context.Background()}, info,
+			func(srv interface{}, stream grpc.ServerStream) error {
+				called = true
+				return nil
+			})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected Unauthenticated, got %v", err)
+		}
+		if called {
+			t.Error("Handler must not be called without a token")
+		}
+	})
+
+	t.Run("with valid token", func(t *testing.T) {
+		token, _ := mockJWTManager.GenerateAccessToken(user.ID, user.Login, "", "")
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+
+		err := interceptor.Stream()(nil, &serverStream{ctx: ctx}, info,
+			func(srv interface{}, stream grpc.ServerStream) error {
+				got, err := middleware.GetUserFromContext(stream.Context())
+				if err != nil {
+					t.Errorf("Expected user in stream context, got error: %v", err)
+				} else if got.ID != user.ID {
+					t.Errorf("Expected user %s, got %s", user.ID, got.ID)
+				}
+				return nil
+			})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestAuthInterceptor_Sessions(t *testing.T) {
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithRefreshTokens(storage.RefreshTokenRepository()),
+		app.WithSessions(storage.SessionRepository()))
+	interceptor := middleware.NewAuthInterceptor(authService).Unary()
+
+	if _, err := authService.Register(context.Background(), "testuser", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	incoming := func(token string) context.Context {
+		md := metadata.Pairs(
+			middleware.DeviceNameHeader, "laptop",
+			middleware.ClientVersionHeader, "v1.2.3",
+		)
+		if token != "" {
+			md.Set("authorization", "Bearer "+token)
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		return peer.NewContext(ctx, &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 51234},
+		})
+	}
+
+	var accessToken string
+	login := func(ctx context.Context, req interface{}) (interface{}, error) {
+		info := app.ClientInfoFromContext(ctx)
+		if info.DeviceName != "laptop" || info.ClientVersion != "v1.2.3" || info.IPAddress != "192.0.2.10" {
+			t.Errorf("Unexpected client info: %+v", info)
+		}
+		access, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		accessToken = access
+		return nil, err
+	}
+	if _, err := interceptor(incoming(""), nil, &grpc.UnaryServerInfo{FullMethod: "/gophkeeper.v1.AuthService/Login"}, login); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	var sessionID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		sessionID = middleware.GetSessionIDFromContext(ctx)
+		return "response", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/gophkeeper.v1.AuthService/ListSessions"}
+
+	if _, err := interceptor(incoming(accessToken), nil, info, handler); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sessionID == "" {
+		t.Fatal("Expected session ID in context")
+	}
+
+	user, err := storage.UserRepository().GetByLogin(context.Background(), "testuser")
+	if err != nil {
+		t.Fatalf("GetByLogin failed: %v", err)
+	}
+	if err := authService.RevokeSession(context.Background(), user.ID, sessionID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+
+	_, err = interceptor(incoming(accessToken), nil, info, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected code %v for revoked session, got %v", codes.Unauthenticated, status.Code(err))
+	}
+}
+
+func TestAuthInterceptor_DeviceApproval(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithRefreshTokens(storage.RefreshTokenRepository()),
+		app.WithSessions(storage.SessionRepository()),
+		app.WithDevices(storage.DeviceRepository(), true))
+	interceptor := middleware.NewAuthInterceptor(authService).Unary()
+
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	register := func(name string) *app.DeviceRegistration {
+		access, _, _, err := authService.Login(ctx, "testuser", "password123", "")
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		principal, err := authService.Authenticate(ctx, access)
+		if err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+		registration, err := authService.RegisterDevice(ctx, userID, principal.SessionID, name, "", "")
+		if err != nil {
+			t.Fatalf("RegisterDevice failed: %v", err)
+		}
+		return registration
+	}
+	call := func(token, method string) (string, error) {
+		incoming := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		var deviceID string
+		_, err := interceptor(incoming, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				deviceID = middleware.GetDeviceIDFromContext(ctx)
+				return "response", nil
+			})
+		return deviceID, err
+	}
+
+	trusted := register("laptop")
+	pending := register("phone")
+
+	deviceID, err := call(trusted.AccessToken, "/gophkeeper.v1.SecretService/Sync")
+	if err != nil {
+		t.Fatalf("Unexpected error for approved device: %v", err)
+	}
+	if deviceID != trusted.Device.ID {
+		t.Errorf("Expected device %s in context, got %q", trusted.Device.ID, deviceID)
+	}
+
+	_, err = call(pending.AccessToken, "/gophkeeper.v1.SecretService/Sync")
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected code %v for device waiting for approval, got %v", codes.FailedPrecondition, status.Code(err))
+	}
+	// Управлять устройствами и сессиями можно и без одобрения
+	if _, err := call(pending.AccessToken, "/gophkeeper.v1.AuthService/ListDevices"); err != nil {
+		t.Errorf("Unexpected error for device management: %v", err)
+	}
+	// Но не удалять доверенные устройства, завершать их сессии или менять учетную запись
+	for _, method := range []string{
+		"/gophkeeper.v1.AuthService/RemoveDevice",
+		"/gophkeeper.v1.AuthService/RevokeSession",
+		"/gophkeeper.v1.AuthService/ChangePassword",
+		"/gophkeeper.v1.AuthService/SetProtectedVaultKey",
+		"/gophkeeper.v1.AuthService/DeleteAccount",
+	} {
+		if _, err := call(pending.AccessToken, method); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("Expected code %v for %s from unapproved device, got %v", codes.FailedPrecondition, method, status.Code(err))
+		}
+	}
+
+	if err := authService.ApproveDevice(ctx, userID, trusted.Device.ID, pending.Device.ID); err != nil {
+		t.Fatalf("ApproveDevice failed: %v", err)
+	}
+	if _, err := call(pending.AccessToken, "/gophkeeper.v1.SecretService/Sync"); err != nil {
+		t.Errorf("Unexpected error after approval: %v", err)
+	}
+}
+
+func TestAuthInterceptor_AccessTokenScope(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithAccessTokens(storage.AccessTokenRepository()))
+	interceptor := middleware.NewAuthInterceptor(authService).Unary()
+
+	userID, err := authService.Register(ctx, "testuser", "password123")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	create := func(permission domain.AccessTokenPermission) string {
+		_, token, err := authService.CreateAccessToken(ctx, userID, app.AccessTokenRequest{
+			Name:         "ci",
+			SecretIDs:    []string{"allowed"},
+			Permission:   permission,
+			DelegatedKey: []byte("delegated"),
+		})
+		if err != nil {
+			t.Fatalf("CreateAccessToken failed: %v", err)
+		}
+		return token
+	}
+	readToken := create(domain.AccessTokenRead)
+	writeToken := create(domain.AccessTokenReadWrite)
+
+	call := func(token, method string, req, resp interface{}) (interface{}, error) {
+		incoming := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		return interceptor(incoming, req, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				if middleware.GetAccessTokenFromContext(ctx) == nil {
+					t.Error("Expected access token in context")
+				}
+				return resp, nil
+			})
+	}
+	secrets := []*pb.Secret{{Id: "allowed"}, {Id: "other"}}
+
+	tests := []struct {
+		name         string
+		token        string
+		method       string
+		req          interface{}
+		expectedCode codes.Code
+	}{
+		{"get secret in scope", readToken, "/gophkeeper.v1.SecretService/GetSecret", &pb.GetSecretRequest{SecretId: "allowed"}, codes.OK},
+		{"get secret out of scope", readToken, "/gophkeeper.v1.SecretService/GetSecret", &pb.GetSecretRequest{SecretId: "other"}, codes.NotFound},
+		{"read-only token cannot update", readToken, "/gophkeeper.v1.SecretService/UpdateSecret", &pb.UpdateSecretRequest{Secret: &pb.Secret{Id: "allowed"}}, codes.PermissionDenied},
+		{"read-only token cannot push", readToken, "/gophkeeper.v1.SecretService/Sync", &pb.SyncRequest{Secrets: []*pb.Secret{{Id: "allowed"}}}, codes.PermissionDenied},
+		{"read-only token can pull", readToken, "/gophkeeper.v1.SecretService/Sync", &pb.SyncRequest{}, codes.OK},
+		{"write token can update", writeToken, "/gophkeeper.v1.SecretService/UpdateSecret", &pb.UpdateSecretRequest{Secret: &pb.Secret{Id: "allowed"}}, codes.OK},
+		{"write token cannot delete other secrets", writeToken, "/gophkeeper.v1.SecretService/DeleteSecret", &pb.DeleteSecretRequest{SecretId: "other"}, codes.NotFound},
+		{"write token cannot push other secrets", writeToken, "/gophkeeper.v1.SecretService/Sync", &pb.SyncRequest{Secrets: []*pb.Secret{{Id: "allowed"}, {Id: "other"}}}, codes.NotFound},
+		{"write token cannot rotate vault key", writeToken, "/gophkeeper.v1.SecretService/Sync", &pb.SyncRequest{Atomic: true, ProtectedVaultKey: []byte("key")}, codes.PermissionDenied},
+		{"manifest is not available", writeToken, "/gophkeeper.v1.SecretService/PutVaultManifest", &pb.PutVaultManifestRequest{}, codes.PermissionDenied},
+		{"account methods are not available", readToken, "/gophkeeper.v1.AuthService/CreateAccessToken", &pb.CreateAccessTokenRequest{}, codes.PermissionDenied},
+		{"delegated key is available", readToken, "/gophkeeper.v1.AuthService/GetProtectedVaultKey", &pb.GetProtectedVaultKeyRequest{}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := call(tt.token, tt.method, tt.req, "response")
+			if status.Code(err) != tt.expectedCode {
+				t.Errorf("Expected code %v, got %v (%v)", tt.expectedCode, status.Code(err), err)
+			}
+		})
+	}
+
+	t.Run("responses are filtered", func(t *testing.T) {
+		resp, err := call(readToken, "/gophkeeper.v1.SecretService/ListSecrets", &pb.ListSecretsRequest{},
+			&pb.ListSecretsResponse{Secrets: secrets})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		listed := resp.(*pb.ListSecretsResponse).GetSecrets()
+		if len(listed) != 1 || listed[0].GetId() != "allowed" {
+			t.Errorf("Expected only the secret in scope, got %v", listed)
+		}
+
+		resp, err = call(readToken, "/gophkeeper.v1.SecretService/Sync", &pb.SyncRequest{},
+			&pb.SyncResponse{Secrets: []*pb.Secret{{Id: "allowed"}, {Id: "other"}}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		synced := resp.(*pb.SyncResponse).GetSecrets()
+		if len(synced) != 1 || synced[0].GetId() != "allowed" {
+			t.Errorf("Expected only the secret in scope, got %v", synced)
+		}
+	})
+}
+
+func TestAuthInterceptor_AdminRole(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithAccessTokens(storage.AccessTokenRepository()))
+	adminService := app.NewAdminService(storage, app.DefaultLockoutPolicy())
+	interceptor := middleware.NewAuthInterceptor(authService).Unary()
+
+	login := func(name string) string {
+		if _, err := authService.Register(ctx, name, "password123"); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		access, _, _, err := authService.Login(ctx, name, "password123", "")
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		return access
+	}
+	adminToken := login("admin")
+	if err := app.SetUserRole(ctx, storage.UserRepository(), "admin", domain.RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole failed: %v", err)
+	}
+	userToken := login("testuser")
+
+	call := func(token, method string) error {
+		incoming := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		_, err := interceptor(incoming, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return "response", nil
+			})
+		return err
+	}
+
+	if err := call(adminToken, "/gophkeeper.v1.AdminService/ListUsers"); err != nil {
+		t.Errorf("Unexpected error for admin: %v", err)
+	}
+	if err := call(userToken, "/gophkeeper.v1.AdminService/ListUsers"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected code %v for regular user, got %v", codes.PermissionDenied, status.Code(err))
+	}
+	if err := call(userToken, "/gophkeeper.v1.SecretService/Sync"); err != nil {
+		t.Errorf("Unexpected error for regular user: %v", err)
+	}
+
+	admin, err := storage.UserRepository().GetByLogin(ctx, "admin")
+	if err != nil {
+		t.Fatalf("GetByLogin failed: %v", err)
+	}
+	if _, err := adminService.SetUserDisabled(ctx, admin.ID, "testuser", true); err != nil {
+		t.Fatalf("SetUserDisabled failed: %v", err)
+	}
+	err = call(userToken, "/gophkeeper.v1.SecretService/Sync")
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected code %v for disabled account, got %v", codes.PermissionDenied, status.Code(err))
+	}
+}
+
 func TestGetUserFromContext(t *testing.T) {
 	t.Run("user exists in context", func(t *testing.T) {
 		user := &domain.User{
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+)
+
+// AuthMode способ аутентификации клиентов перехватчиком
+type AuthMode string
+
+const (
+	// AuthModeJWT только bearer-токен (JWT или токен доступа)
+	AuthModeJWT AuthMode = "jwt"
+	// AuthModeMTLS только клиентский сертификат
+	AuthModeMTLS AuthMode = "mtls"
+	// AuthModeEither bearer-токен, а если его нет, клиентский сертификат
+	AuthModeEither AuthMode = "either"
+	// AuthModeBoth bearer-токен и клиентский сертификат одного и того же пользователя
+	AuthModeBoth AuthMode = "both"
+)
+
+// ParseAuthMode разбирает способ аутентификации; пустая строка означает AuthModeJWT
+func ParseAuthMode(value string) (AuthMode, error) {
+	switch mode := AuthMode(value); mode {
+	case "":
+		return AuthModeJWT, nil
+	case AuthModeJWT, AuthModeMTLS, AuthModeEither, AuthModeBoth:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown auth mode %q, expected jwt, mtls, either or both", value)
+	}
+}
+
+// RequiresClientCert сообщает, что без клиентского сертификата аутентификация невозможна
+func (m AuthMode) RequiresClientCert() bool {
+	return m == AuthModeMTLS || m == AuthModeBoth
+}
+
+// AcceptsClientCert сообщает, что клиентский сертификат участвует в аутентификации
+func (m AuthMode) AcceptsClientCert() bool {
+	return m == AuthModeMTLS || m == AuthModeEither || m == AuthModeBoth
+}
+
+// AuthInterceptorOption настраивает необязательные параметры AuthInterceptor
+type AuthInterceptorOption func(*AuthInterceptor)
+
+// WithClientCertificates задает способ аутентификации mode и список отзыва клиентских сертификатов.
+// Сами сертификаты проверяет TLS сервера; revocations проверяется при каждом вызове, поэтому
+// отзыв действует и на уже открытые соединения.
+func WithClientCertificates(mode AuthMode, revocations *crypto.RevocationList) AuthInterceptorOption {
+	return func(i *AuthInterceptor) {
+		i.mode = mode
+		i.revocations = revocations
+	}
+}
+
+// authenticateCertificate аутентифицирует клиента по сертификату соединения: общее имя
+// субъекта (CN) — логин пользователя
+func (i *AuthInterceptor) authenticateCertificate(ctx context.Context) (*app.Principal, error) {
+	chain := verifiedClientChain(ctx)
+	if len(chain) == 0 {
+		return nil, domain.ErrInvalidCertificate
+	}
+
+	// Корневой сертификат не отзывается списком, которым он сам подписан
+	for _, cert := range chain[:max(len(chain)-1, 1)] {
+		if i.revocations.IsRevoked(cert) {
+			return nil, domain.ErrInvalidCertificate
+		}
+	}
+
+	return i.authService.AuthenticateCertificate(ctx, chain[0].Subject.CommonName)
+}
+
+// certificateMethods сообщает, что метод доступен клиенту, вошедшему только сертификатом: работа
+// с секретами, получение защищенного ключа хранилища и первоначальная настройка учетной записи —
+// сохранение ключа хранилища, которое возможно, только пока его нет, и регистрация устройства,
+// при которой сессия не выдается. Методы, меняющие учетную запись, и методы администратора
+// требуют сессии (в режиме AuthModeBoth — сессии и сертификата): иначе их нельзя было бы прервать
+// принудительным выходом.
+func certificateMethods(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/gophkeeper.v1.SecretService/") ||
+		fullMethod == "/gophkeeper.v1.AuthService/GetProtectedVaultKey" ||
+		fullMethod == "/gophkeeper.v1.AuthService/SetProtectedVaultKey" ||
+		fullMethod == "/gophkeeper.v1.AuthService/RegisterDevice"
+}
+
+// verifiedClientChain возвращает проверенную TLS цепочку клиентского сертификата, начиная с него самого
+func verifiedClientChain(ctx context.Context) []*x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return nil
+	}
+	return tlsInfo.State.VerifiedChains[0]
+}
@@ -0,0 +1,263 @@
+package middleware_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/mocks"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/handlers"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
+)
+
+func TestParseAuthMode(t *testing.T) {
+	for value, expected := range map[string]middleware.AuthMode{
+		"":       middleware.AuthModeJWT,
+		"jwt":    middleware.AuthModeJWT,
+		"mtls":   middleware.AuthModeMTLS,
+		"either": middleware.AuthModeEither,
+		"both":   middleware.AuthModeBoth,
+	} {
+		mode, err := middleware.ParseAuthMode(value)
+		if err != nil || mode != expected {
+			t.Errorf("ParseAuthMode(%q) = %q, %v; expected %q", value, mode, err, expected)
+		}
+	}
+
+	if _, err := middleware.ParseAuthMode("basic"); err == nil {
+		t.Error("Expected error for unknown auth mode")
+	}
+}
+
+func TestAuthInterceptor_ClientCertificate(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager())
+
+	for _, login := range []string{"agent", "otheruser"} {
+		if _, err := authService.Register(ctx, login, "password123"); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+	token, _, _, err := authService.Login(ctx, "agent", "password123", "")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "build agents"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	// TLS уже проверил цепочку, поэтому перехватчику достаточно субъекта, издателя и серийного номера
+	issue := func(login string, serial int64) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:      pkix.Name{CommonName: login},
+			SerialNumber: big.NewInt(serial),
+			RawIssuer:    ca.RawSubject,
+		}
+	}
+	agentCert := issue("agent", 10)
+	otherCert := issue("otheruser", 11)
+	unknownCert := issue("ghost", 12)
+	revokedCert := issue("agent", 13)
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: revokedCert.SerialNumber, RevocationTime: time.Now()},
+		},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatalf("CreateRevocationList failed: %v", err)
+	}
+	crlPath := filepath.Join(t.TempDir(), "agents.crl")
+	if err := os.WriteFile(crlPath, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlDER}), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	revocations, err := crypto.LoadRevocationList(crlPath, []*x509.Certificate{ca})
+	if err != nil {
+		t.Fatalf("LoadRevocationList failed: %v", err)
+	}
+
+	call := func(mode middleware.AuthMode, cert *x509.Certificate, token, method string) error {
+		interceptor := middleware.NewAuthInterceptor(authService,
+			middleware.WithClientCertificates(mode, revocations)).Unary()
+
+		callCtx := ctx
+		if cert != nil {
+			callCtx = peer.NewContext(callCtx, &peer.Peer{
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{{cert, ca}},
+				}},
+			})
+		}
+		if token != "" {
+			callCtx = metadata.NewIncomingContext(callCtx, metadata.Pairs("authorization", "Bearer "+token))
+		}
+
+		if method == "" {
+			method = "/gophkeeper.v1.SecretService/Sync"
+		}
+		_, err := interceptor(callCtx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				user, err := middleware.GetUserFromContext(ctx)
+				if err != nil {
+					return nil, err
+				}
+				if user.Login != "agent" {
+					t.Errorf("Expected user agent in context, got %s", user.Login)
+				}
+				return "response", nil
+			})
+		return err
+	}
+
+	tests := []struct {
+		name         string
+		mode         middleware.AuthMode
+		cert         *x509.Certificate
+		token        string
+		method       string
+		expectedCode codes.Code
+	}{
+		{"jwt mode accepts token", middleware.AuthModeJWT, nil, token, "", codes.OK},
+		{"jwt mode ignores certificate", middleware.AuthModeJWT, agentCert, "", "", codes.Unauthenticated},
+		{"mtls mode accepts certificate", middleware.AuthModeMTLS, agentCert, "", "", codes.OK},
+		{"mtls mode requires certificate", middleware.AuthModeMTLS, nil, token, "", codes.Unauthenticated},
+		{"mtls mode rejects unknown subject", middleware.AuthModeMTLS, unknownCert, "", "", codes.Unauthenticated},
+		{"mtls mode rejects revoked certificate", middleware.AuthModeMTLS, revokedCert, "", "", codes.Unauthenticated},
+		{"either mode accepts token", middleware.AuthModeEither, nil, token, "", codes.OK},
+		{"either mode accepts certificate", middleware.AuthModeEither, agentCert, "", "", codes.OK},
+		{"either mode requires credentials", middleware.AuthModeEither, nil, "", "", codes.Unauthenticated},
+		{"both mode accepts token and certificate", middleware.AuthModeBoth, agentCert, token, "", codes.OK},
+		{"both mode requires token", middleware.AuthModeBoth, agentCert, "", "", codes.Unauthenticated},
+		{"both mode requires certificate", middleware.AuthModeBoth, nil, token, "", codes.Unauthenticated},
+		{"both mode requires the same user", middleware.AuthModeBoth, otherCert, token, "", codes.Unauthenticated},
+		{"both mode rejects revoked certificate", middleware.AuthModeBoth, revokedCert, token, "", codes.Unauthenticated},
+		{"certificate reads vault key", middleware.AuthModeMTLS, agentCert, "", "/gophkeeper.v1.AuthService/GetProtectedVaultKey", codes.OK},
+		{"certificate cannot change password", middleware.AuthModeMTLS, agentCert, "", "/gophkeeper.v1.AuthService/ChangePassword", codes.PermissionDenied},
+		{"certificate cannot create access tokens", middleware.AuthModeEither, agentCert, "", "/gophkeeper.v1.AuthService/CreateAccessToken", codes.PermissionDenied},
+		{"certificate cannot call admin methods", middleware.AuthModeMTLS, agentCert, "", "/gophkeeper.v1.AdminService/ListUsers", codes.PermissionDenied},
+		{"both mode changes password", middleware.AuthModeBoth, agentCert, token, "/gophkeeper.v1.AuthService/ChangePassword", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := call(tt.mode, tt.cert, tt.token, tt.method)
+			if status.Code(err) != tt.expectedCode {
+				t.Errorf("Expected code %v, got %v (%v)", tt.expectedCode, status.Code(err), err)
+			}
+		})
+	}
+}
+
+func TestAuthInterceptor_CertificateOnboarding(t *testing.T) {
+	ctx := context.Background()
+	storage := memory.NewStorage()
+	authService := app.NewAuthService(storage.UserRepository(), mocks.NewMockJWTManager(),
+		app.WithVaultKeys(storage.VaultKeyRepository()),
+		app.WithDevices(storage.DeviceRepository(), true))
+	if _, err := authService.Register(ctx, "agent", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	handler := handlers.NewAuthHandler(authService)
+	interceptor := middleware.NewAuthInterceptor(authService,
+		middleware.WithClientCertificates(middleware.AuthModeMTLS, nil)).Unary()
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent"}, SerialNumber: big.NewInt(1)}
+	certCtx := peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+	call := func(method string, req interface{}, h grpc.UnaryHandler) (interface{}, error) {
+		return interceptor(certCtx, req, &grpc.UnaryServerInfo{FullMethod: "/gophkeeper.v1.AuthService/" + method}, h)
+	}
+
+	// Новый клиент, у которого есть только сертификат, регистрирует устройство и сохраняет ключ хранилища
+	resp, err := call("RegisterDevice", &pb.RegisterDeviceRequest{Name: "build agent"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler.RegisterDevice(ctx, req.(*pb.RegisterDeviceRequest))
+		})
+	if err != nil {
+		t.Fatalf("RegisterDevice failed: %v", err)
+	}
+	registration := resp.(*pb.RegisterDeviceResponse)
+	if registration.GetDeviceSecret() == "" {
+		t.Error("Expected a secret for the new device")
+	}
+	if registration.GetAccessToken() != "" || registration.GetRefreshToken() != "" {
+		t.Error("Certificate must not be exchanged for a session")
+	}
+
+	setVaultKey := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return handler.SetProtectedVaultKey(ctx, req.(*pb.SetProtectedVaultKeyRequest))
+	}
+	if _, err := call("SetProtectedVaultKey", &pb.SetProtectedVaultKeyRequest{ProtectedKey: []byte("wrapped")}, setVaultKey); err != nil {
+		t.Fatalf("SetProtectedVaultKey failed: %v", err)
+	}
+	// Сохраненный ключ сертификатом не заменить
+	_, err = call("SetProtectedVaultKey", &pb.SetProtectedVaultKeyRequest{ProtectedKey: []byte("replaced")}, setVaultKey)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+
+	resp, err = call("GetProtectedVaultKey", &pb.GetProtectedVaultKeyRequest{},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler.GetProtectedVaultKey(ctx, req.(*pb.GetProtectedVaultKeyRequest))
+		})
+	if err != nil {
+		t.Fatalf("GetProtectedVaultKey failed: %v", err)
+	}
+	if key := resp.(*pb.GetProtectedVaultKeyResponse).GetProtectedKey(); string(key) != "wrapped" {
+		t.Errorf("Expected stored vault key, got %q", key)
+	}
+
+	_, err = call("ChangePassword", &pb.ChangePasswordRequest{},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return handler.ChangePassword(ctx, req.(*pb.ChangePasswordRequest))
+		})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", err)
+	}
+}
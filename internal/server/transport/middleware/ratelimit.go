@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+)
+
+// rateLimitSweepInterval как часто удаляются корзины, которые успели заполниться и больше не нужны
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit ограничение частоты вызовов: Rate запросов в секунду в среднем и не больше Burst подряд.
+// Нулевой Rate снимает ограничение.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiterConfig ограничения частоты вызовов. Methods задает отдельные ограничения методов
+// по полному имени ("/gophkeeper.v1.SecretService/Sync") или только по имени метода ("Sync");
+// остальные методы делят между собой общее ограничение Default.
+type RateLimiterConfig struct {
+	Default RateLimit
+	Methods map[string]RateLimit
+}
+
+// RateLimiter перехватчик, ограничивающий частоту вызовов алгоритмом token bucket.
+// Аутентифицированные вызовы учитываются по пользователю, публичные — по IP-адресу клиента.
+type RateLimiter struct {
+	config RateLimiterConfig
+	// byAddress все вызовы учитываются по IP-адресу клиента
+	byAddress bool
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket корзина токенов одного клиента
+type tokenBucket struct {
+	limit   RateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiterOption настраивает необязательные параметры RateLimiter
+type RateLimiterOption func(*RateLimiter)
+
+// WithClientAddressKey учитывает все вызовы, в том числе аутентифицированные, по IP-адресу
+// клиента. Такой перехватчик ставится перед перехватчиком аутентификации, чтобы поток запросов
+// с одного адреса отклонялся до проверки токенов.
+func WithClientAddressKey() RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.byAddress = true
+	}
+}
+
+// NewRateLimiter создает новый перехватчик ограничения частоты вызовов
+func NewRateLimiter(config RateLimiterConfig, opts ...RateLimiterOption) *RateLimiter {
+	l := &RateLimiter{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Unary возвращает unary interceptor ограничения частоты вызовов. Без WithClientAddressKey
+// должен стоять после перехватчика аутентификации, чтобы вызовы учитывались по пользователю.
+func (l *RateLimiter) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := l.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream возвращает stream interceptor ограничения частоты вызовов. Вызов учитывается
+// один раз при открытии потока.
+func (l *RateLimiter) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := l.allow(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allow расходует токен вызывающего или возвращает ResourceExhausted со сроком повтора
+func (l *RateLimiter) allow(ctx context.Context, fullMethod string) error {
+	group, limit := l.limitFor(fullMethod)
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	key := group + "|" + l.callerKey(ctx)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{limit: limit, tokens: float64(max(limit.Burst, 1)), updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.refill(now)
+
+	if bucket.tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - bucket.tokens) / limit.Rate * float64(time.Second)))
+		return rateLimitedError(wait)
+	}
+	bucket.tokens--
+	return nil
+}
+
+// limitFor возвращает группу учета и ограничение метода
+func (l *RateLimiter) limitFor(fullMethod string) (string, RateLimit) {
+	if limit, ok := l.config.Methods[fullMethod]; ok {
+		return fullMethod, limit
+	}
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if limit, ok := l.config.Methods[method]; ok {
+		return fullMethod, limit
+	}
+	return "*", l.config.Default
+}
+
+// sweep удаляет заполнившиеся корзины: они ничем не отличаются от новых
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(max(bucket.limit.Burst, 1)) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// refill пополняет корзину токенами, накопившимися с прошлого обращения
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(max(b.limit.Burst, 1)), b.tokens+elapsed*b.limit.Rate)
+	b.updated = now
+}
+
+// callerKey возвращает ключ учета вызывающего: пользователя, если вызов аутентифицирован,
+// иначе IP-адрес клиента
+func (l *RateLimiter) callerKey(ctx context.Context) string {
+	if l.byAddress {
+		// Перед аутентификацией сведения о клиенте еще не добавлены в контекст
+		return "ip:" + clientInfoFromContext(ctx).IPAddress
+	}
+	if user, err := GetUserFromContext(ctx); err == nil {
+		return "user:" + user.ID
+	}
+	return "ip:" + app.ClientInfoFromContext(ctx).IPAddress
+}
+
+// rateLimitedError статус отказа из-за превышения частоты вызовов со сроком повтора
+func rateLimitedError(retryAfter time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").WithDetails(
+		&errdetails.ErrorInfo{Reason: pb.ErrorReason_RATE_LIMITED.String(), Domain: "gophkeeper"},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+	)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
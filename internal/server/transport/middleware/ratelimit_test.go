@@ -0,0 +1,109 @@
+package middleware_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/alisaviation/GophKeeper/internal/server/app"
+	"github.com/alisaviation/GophKeeper/internal/server/domain"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
+)
+
+func TestRateLimiter_Unary(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		Default: middleware.RateLimit{Rate: 1, Burst: 3},
+		Methods: map[string]middleware.RateLimit{
+			"Register":                           {Rate: 0.01, Burst: 1},
+			"/gophkeeper.v1.AuthService/GetJWKS": {},
+		},
+	})
+	interceptor := limiter.Unary()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(ctx context.Context, method string) error {
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	asUser := func(id string) context.Context {
+		return context.WithValue(context.Background(), middleware.UserContextKey{}, &domain.User{ID: id})
+	}
+	fromAddress := func(address string) context.Context {
+		return app.ContextWithClientInfo(context.Background(), app.ClientInfo{IPAddress: address})
+	}
+
+	t.Run("burst then rejection with retry info", func(t *testing.T) {
+		ctx := asUser("user-1")
+		for i := 0; i < 3; i++ {
+			require.NoError(t, call(ctx, "/gophkeeper.v1.SecretService/Sync"))
+		}
+
+		// Методы без своего ограничения делят общую корзину
+		err := call(ctx, "/gophkeeper.v1.SecretService/ListSecrets")
+		st := status.Convert(err)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+		var retry *errdetails.RetryInfo
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				retry = info
+			}
+		}
+		require.NotNil(t, retry)
+		assert.Greater(t, retry.GetRetryDelay().AsDuration(), time.Duration(0))
+		assert.LessOrEqual(t, retry.GetRetryDelay().AsDuration(), time.Second)
+	})
+
+	t.Run("users are limited separately", func(t *testing.T) {
+		assert.NoError(t, call(asUser("user-2"), "/gophkeeper.v1.SecretService/Sync"))
+	})
+
+	t.Run("method limit keyed by address", func(t *testing.T) {
+		require.NoError(t, call(fromAddress("203.0.113.7"), "/gophkeeper.v1.AuthService/Register"))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(call(fromAddress("203.0.113.7"), "/gophkeeper.v1.AuthService/Register")))
+		assert.NoError(t, call(fromAddress("198.51.100.1"), "/gophkeeper.v1.AuthService/Register"))
+
+		// Ограничение метода не расходует общую корзину
+		assert.NoError(t, call(fromAddress("203.0.113.7"), "/gophkeeper.v1.AuthService/Login"))
+	})
+
+	t.Run("zero rate disables limit", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			require.NoError(t, call(fromAddress("203.0.113.7"), "/gophkeeper.v1.AuthService/GetJWKS"))
+		}
+	})
+}
+
+func TestRateLimiter_ClientAddressKey(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		Default: middleware.RateLimit{Rate: 0.01, Burst: 2},
+	}, middleware.WithClientAddressKey())
+	interceptor := limiter.Unary()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(userID, address string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(address), Port: 40000}})
+		ctx = context.WithValue(ctx, middleware.UserContextKey{}, &domain.User{ID: userID})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/gophkeeper.v1.SecretService/Sync"}, handler)
+		return err
+	}
+
+	// Пользователи за одним адресом делят его корзину
+	require.NoError(t, call("user-1", "203.0.113.7"))
+	require.NoError(t, call("user-2", "203.0.113.7"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("user-3", "203.0.113.7")))
+	assert.NoError(t, call("user-3", "198.51.100.1"))
+}
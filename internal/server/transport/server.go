@@ -1,12 +1,15 @@
 package transport
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/alisaviation/GophKeeper/internal/crypto"
 	pb "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/transport/handlers"
 	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
@@ -23,27 +26,56 @@ type Server struct {
 // Config конфигурация gRPC сервера
 type Config struct {
 	Port int `yaml:"port" env:"GRPC_PORT" default:"50051"`
+	// RateLimit ограничения частоты вызовов; без ограничений, если не задано
+	RateLimit middleware.RateLimiterConfig
+	// AddressRateLimit общее ограничение частоты всех вызовов с одного IP-адреса, проверяемое
+	// до аутентификации; без ограничения, если не задано
+	AddressRateLimit middleware.RateLimit
+	// TLS конфигурация TLS; без шифрования, если не задана
+	TLS *tls.Config
+	// AuthMode способ аутентификации клиентов; по умолчанию только bearer-токен
+	AuthMode middleware.AuthMode
+	// Revocations отозванные сертификаты клиентов, проверяемые при каждом вызове
+	Revocations *crypto.RevocationList
 }
 
 // NewServer создает новый gRPC сервер
 func NewServer(
 	authService *app.AuthService,
 	dataService *app.DataService,
+	adminService *app.AdminService,
 	config Config,
 ) *Server {
-	authInterceptor := middleware.NewAuthInterceptor(authService)
+	authInterceptor := middleware.NewAuthInterceptor(authService,
+		middleware.WithClientCertificates(config.AuthMode, config.Revocations))
+	addressLimiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{Default: config.AddressRateLimit},
+		middleware.WithClientAddressKey())
+	rateLimiter := middleware.NewRateLimiter(config.RateLimit)
 
-	grpcServer := grpc.NewServer(
+	opts := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(
+			addressLimiter.Unary(),
 			authInterceptor.Unary(),
+			rateLimiter.Unary(),
 		),
-	)
+		grpc.ChainStreamInterceptor(
+			addressLimiter.Stream(),
+			authInterceptor.Stream(),
+			rateLimiter.Stream(),
+		),
+	}
+	if config.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config.TLS)))
+	}
+	grpcServer := grpc.NewServer(opts...)
 
 	authHandler := handlers.NewAuthHandler(authService)
 	secretHandler := handlers.NewSecretHandler(dataService)
+	adminHandler := handlers.NewAdminHandler(adminService)
 
 	pb.RegisterAuthServiceServer(grpcServer, authHandler)
 	pb.RegisterSecretServiceServer(grpcServer, secretHandler)
+	pb.RegisterAdminServiceServer(grpcServer, adminHandler)
 
 	reflection.Register(grpcServer)
 
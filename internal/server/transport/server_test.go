@@ -12,6 +12,7 @@ import (
 	grpc2 "github.com/alisaviation/GophKeeper/internal/generated/grpc"
 	"github.com/alisaviation/GophKeeper/internal/server/app"
 	"github.com/alisaviation/GophKeeper/internal/server/mocks"
+	"github.com/alisaviation/GophKeeper/internal/server/storage/memory"
 	"github.com/alisaviation/GophKeeper/internal/server/transport"
 )
 
@@ -19,13 +20,13 @@ func TestServer_Integration(t *testing.T) {
 	mockUserRepo := mocks.NewMockUserRepository()
 	mockSecretRepo := mocks.NewMockSecretRepository()
 	mockJWTManager := mocks.NewMockJWTManager()
-	mockEncryptor := &mocks.MockEncryptor{}
 
 	authService := app.NewAuthService(mockUserRepo, mockJWTManager)
-	dataService := app.NewDataService(mockSecretRepo, mockEncryptor)
+	dataService := app.NewDataService(mockSecretRepo)
+	adminService := app.NewAdminService(memory.NewStorage(), app.DefaultLockoutPolicy())
 
 	config := transport.Config{Port: 50052}
-	server := transport.NewServer(authService, dataService, config)
+	server := transport.NewServer(authService, dataService, adminService, config)
 
 	_, err := net.Listen("tcp", ":50051")
 	if err != nil {
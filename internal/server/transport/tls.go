@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
+)
+
+// TLSConfig параметры TLS сервера
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAs удостоверяющие центры, которыми подписаны сертификаты клиентов
+	ClientCAs []*x509.Certificate
+	// Revocations отозванные сертификаты клиентов; nil — отзыв не проверяется
+	Revocations *crypto.RevocationList
+}
+
+// NewTLSConfig собирает конфигурацию TLS сервера. Клиентский сертификат запрашивается, если он
+// участвует в аутентификации в режиме mode, и обязателен, если без него аутентификация невозможна.
+// Отозванные сертификаты отклоняются уже при установке соединения.
+func NewTLSConfig(cfg TLSConfig, mode middleware.AuthMode) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if !mode.AcceptsClientCert() {
+		return tlsConfig, nil
+	}
+
+	if len(cfg.ClientCAs) == 0 {
+		return nil, fmt.Errorf("auth mode %s requires client CA certificates", mode)
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	for _, ca := range cfg.ClientCAs {
+		tlsConfig.ClientCAs.AddCert(ca)
+	}
+
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if mode.RequiresClientCert() {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	revocations := cfg.Revocations
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		for _, chain := range state.VerifiedChains {
+			for _, c := range chain[:max(len(chain)-1, 1)] {
+				if revocations.IsRevoked(c) {
+					return errors.New("client certificate is revoked")
+				}
+			}
+		}
+		return nil
+	}
+
+	return tlsConfig, nil
+}
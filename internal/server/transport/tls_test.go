@@ -0,0 +1,123 @@
+package transport_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alisaviation/GophKeeper/internal/crypto"
+	"github.com/alisaviation/GophKeeper/internal/server/transport"
+	"github.com/alisaviation/GophKeeper/internal/server/transport/middleware"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	// Самоподписанный сертификат служит и сертификатом сервера, и удостоверяющим центром клиентов
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	crlFile := filepath.Join(dir, "clients.crl")
+	revokedSerial := big.NewInt(42)
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: revokedSerial, RevocationTime: time.Now()},
+		},
+	}, ca, key)
+	if err != nil {
+		t.Fatalf("CreateRevocationList failed: %v", err)
+	}
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "PRIVATE KEY", Bytes: keyDER},
+		crlFile:  {Type: "X509 CRL", Bytes: crlDER},
+	} {
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	revocations, err := crypto.LoadRevocationList(crlFile, []*x509.Certificate{ca})
+	if err != nil {
+		t.Fatalf("LoadRevocationList failed: %v", err)
+	}
+
+	config := transport.TLSConfig{
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		ClientCAs:   []*x509.Certificate{ca},
+		Revocations: revocations,
+	}
+
+	for mode, expected := range map[middleware.AuthMode]tls.ClientAuthType{
+		middleware.AuthModeJWT:    tls.NoClientCert,
+		middleware.AuthModeEither: tls.VerifyClientCertIfGiven,
+		middleware.AuthModeMTLS:   tls.RequireAndVerifyClientCert,
+		middleware.AuthModeBoth:   tls.RequireAndVerifyClientCert,
+	} {
+		tlsConfig, err := transport.NewTLSConfig(config, mode)
+		if err != nil {
+			t.Fatalf("NewTLSConfig(%s) failed: %v", mode, err)
+		}
+		if tlsConfig.ClientAuth != expected {
+			t.Errorf("Expected client auth %v for mode %s, got %v", expected, mode, tlsConfig.ClientAuth)
+		}
+	}
+
+	tlsConfig, err := transport.NewTLSConfig(config, middleware.AuthModeMTLS)
+	if err != nil {
+		t.Fatalf("NewTLSConfig failed: %v", err)
+	}
+	client := func(serial *big.Int) tls.ConnectionState {
+		return tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+			{SerialNumber: serial, RawIssuer: ca.RawSubject},
+			ca,
+		}}}
+	}
+	if err := tlsConfig.VerifyConnection(client(big.NewInt(7))); err != nil {
+		t.Errorf("Unexpected error for valid certificate: %v", err)
+	}
+	if err := tlsConfig.VerifyConnection(client(revokedSerial)); err == nil {
+		t.Error("Expected revoked certificate to be rejected")
+	}
+
+	config.ClientCAs = nil
+	if _, err := transport.NewTLSConfig(config, middleware.AuthModeMTLS); err == nil {
+		t.Error("Expected error without client CA certificates")
+	}
+}